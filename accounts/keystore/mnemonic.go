@@ -0,0 +1,149 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/BerithFoundation/berith-chain/accounts"
+	"github.com/BerithFoundation/berith-chain/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// DefaultMnemonicBits is the entropy of newly generated mnemonics, resulting
+// in 24 words.
+const DefaultMnemonicBits = 256
+
+var (
+	// ErrInvalidMnemonic is returned if a mnemonic has an invalid word count, an
+	// unknown word or a wrong checksum.
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+	// errInvalidChildKey is returned in the (astronomically unlikely) case of a
+	// derivation step producing an invalid key. BIP-32 requires callers to
+	// proceed with the next index instead.
+	errInvalidChildKey = errors.New("derived key is invalid, use the next index")
+)
+
+// NewMnemonic generates a BIP-39 mnemonic with the given bits of entropy. The
+// entropy must be a multiple of 32 in the range [128, 256].
+func NewMnemonic(bits int) (string, error) {
+	entropy, err := bip39.NewEntropy(bits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// ValidateMnemonic checks the word count, the words and the checksum of a
+// BIP-39 mnemonic.
+func ValidateMnemonic(mnemonic string) error {
+	if _, err := bip39.MnemonicToByteArray(normalizeMnemonic(mnemonic)); err != nil {
+		return ErrInvalidMnemonic
+	}
+	return nil
+}
+
+// DeriveKeyFromMnemonic derives the private key at the given BIP-32 path from
+// the seed of a BIP-39 mnemonic protected by the optional passphrase.
+func DeriveKeyFromMnemonic(mnemonic, passphrase string, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	mnemonic = normalizeMnemonic(mnemonic)
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, ErrInvalidMnemonic
+	}
+	return deriveKey(seed, path)
+}
+
+// ImportMnemonic derives the key at the given path from the mnemonic and
+// stores it into the key directory, encrypted with passphrase.
+func (ks *KeyStore) ImportMnemonic(mnemonic, mnemonicPassphrase string, path accounts.DerivationPath, passphrase string) (accounts.Account, error) {
+	priv, err := DeriveKeyFromMnemonic(mnemonic, mnemonicPassphrase, path)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	defer zeroKey(priv)
+	return ks.ImportECDSA(priv, passphrase)
+}
+
+// NewMnemonicAccount generates a new mnemonic and stores the key derived at
+// the given path, encrypted with passphrase. The mnemonic is returned so the
+// caller can show it to the user, it's not stored anywhere.
+func (ks *KeyStore) NewMnemonicAccount(mnemonicPassphrase string, path accounts.DerivationPath, passphrase string) (accounts.Account, string, error) {
+	mnemonic, err := NewMnemonic(DefaultMnemonicBits)
+	if err != nil {
+		return accounts.Account{}, "", err
+	}
+	account, err := ks.ImportMnemonic(mnemonic, mnemonicPassphrase, path, passphrase)
+	if err != nil {
+		return accounts.Account{}, "", err
+	}
+	return account, mnemonic, nil
+}
+
+// normalizeMnemonic collapses the whitespace between the words of a mnemonic.
+func normalizeMnemonic(mnemonic string) string {
+	return strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+}
+
+// deriveKey runs the BIP-32 private key derivation of the given path, starting
+// at the master key of the seed.
+func deriveKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key, chainCode := sum[:32], sum[32:]
+	if k := new(big.Int).SetBytes(key); k.Sign() == 0 || k.Cmp(crypto.S256().Params().N) >= 0 {
+		return nil, errInvalidChildKey
+	}
+	for _, index := range path {
+		var err error
+		if key, chainCode, err = deriveChild(key, chainCode, index); err != nil {
+			return nil, fmt.Errorf("derivation path %v: %v", path, err)
+		}
+	}
+	return crypto.ToECDSA(key)
+}
+
+// deriveChild computes the child private key and chain code of the given index,
+// hardened if the index is at least 2^31.
+func deriveChild(key, chainCode []byte, index uint32) ([]byte, []byte, error) {
+	data := make([]byte, 0, 37)
+	if index >= 0x80000000 {
+		data = append(append(data, 0x00), key...)
+	} else {
+		priv, err := crypto.ToECDSA(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = append(data, crypto.CompressPubkey(&priv.PublicKey)...)
+	}
+	var suffix [4]byte
+	binary.BigEndian.PutUint32(suffix[:], index)
+	data = append(data, suffix[:]...)
+
+	mac := hmac.New(sha512.New, chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(n) >= 0 {
+		return nil, nil, errInvalidChildKey
+	}
+	child := tweak.Add(tweak, new(big.Int).SetBytes(key))
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return nil, nil, errInvalidChildKey
+	}
+	childKey := make([]byte, 32)
+	b := child.Bytes()
+	copy(childKey[32-len(b):], b)
+	return childKey, sum[32:], nil
+}
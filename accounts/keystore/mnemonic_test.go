@@ -0,0 +1,89 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/accounts"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/crypto"
+)
+
+// Tests the BIP-32 derivation against the first test vector of the spec.
+func TestDeriveKeyVector(t *testing.T) {
+	seed := common.FromHex("000102030405060708090a0b0c0d0e0f")
+	tests := []struct {
+		path accounts.DerivationPath
+		key  string
+	}{
+		{accounts.DerivationPath{}, "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+		{accounts.DerivationPath{0x80000000}, "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{accounts.DerivationPath{0x80000000, 1}, "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{accounts.DerivationPath{0x80000000, 1, 0x80000002}, "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+	}
+	for i, tt := range tests {
+		key, err := deriveKey(seed, tt.path)
+		if err != nil {
+			t.Fatalf("test %d: derivation failed: %v", i, err)
+		}
+		if have := hex.EncodeToString(crypto.FromECDSA(key)); have != tt.key {
+			t.Errorf("test %d: key mismatch: have %s, want %s", i, have, tt.key)
+		}
+	}
+}
+
+// Tests that mnemonics derive the same accounts as other BIP-39 wallets.
+func TestDeriveKeyFromMnemonic(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	key, err := DeriveKeyFromMnemonic(mnemonic, "", accounts.DefaultBaseDerivationPath)
+	if err != nil {
+		t.Fatalf("derivation failed: %v", err)
+	}
+	want := common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94")
+	if have := crypto.PubkeyToAddress(key.PublicKey); have != want {
+		t.Errorf("address mismatch: have %x, want %x", have, want)
+	}
+	// Extra whitespace and upper case letters don't matter, a passphrase does
+	if key2, _ := DeriveKeyFromMnemonic("  ABANDON  "+mnemonic[8:]+"\n", "", accounts.DefaultBaseDerivationPath); key2 == nil || key2.D.Cmp(key.D) != 0 {
+		t.Errorf("normalized mnemonic derived a different key")
+	}
+	if key3, _ := DeriveKeyFromMnemonic(mnemonic, "TREZOR", accounts.DefaultBaseDerivationPath); key3 == nil || key3.D.Cmp(key.D) == 0 {
+		t.Errorf("passphrase didn't change the derived key")
+	}
+	// Invalid checksums and unknown words must be rejected
+	if _, err := DeriveKeyFromMnemonic(mnemonic[:len(mnemonic)-5]+"abandon", "", accounts.DefaultBaseDerivationPath); err != ErrInvalidMnemonic {
+		t.Errorf("bad checksum: have %v, want %v", err, ErrInvalidMnemonic)
+	}
+	if _, err := DeriveKeyFromMnemonic(mnemonic[:len(mnemonic)-5]+"berith", "", accounts.DefaultBaseDerivationPath); err != ErrInvalidMnemonic {
+		t.Errorf("unknown word: have %v, want %v", err, ErrInvalidMnemonic)
+	}
+}
+
+// Tests that a generated mnemonic account can be recovered into another keystore.
+func TestMnemonicAccountRecovery(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	account, mnemonic, err := ks.NewMnemonicAccount("salt", accounts.DefaultBaseDerivationPath, "foo")
+	if err != nil {
+		t.Fatalf("failed to create mnemonic account: %v", err)
+	}
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		t.Fatalf("generated mnemonic is invalid: %v", err)
+	}
+	dir2, ks2 := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir2)
+
+	recovered, err := ks2.ImportMnemonic(mnemonic, "salt", accounts.DefaultBaseDerivationPath, "bar")
+	if err != nil {
+		t.Fatalf("failed to recover account: %v", err)
+	}
+	if recovered.Address != account.Address {
+		t.Errorf("recovered address mismatch: have %x, want %x", recovered.Address, account.Address)
+	}
+	if _, err := ks2.ImportMnemonic(mnemonic, "salt", accounts.DefaultBaseDerivationPath, "bar"); err == nil {
+		t.Errorf("recovered the same account twice")
+	}
+}
@@ -0,0 +1,133 @@
+package usbwallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/accounts"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/crypto"
+	"github.com/BerithFoundation/berith-chain/log"
+)
+
+// mockLedger is a USB transport emulating the Ethereum application of a Ledger
+// wallet, signing everything with a single key.
+type mockLedger struct {
+	key     *ecdsa.PrivateKey
+	version [3]byte
+
+	request []byte       // APDU being received from the host
+	length  int          // Total length of the APDU being received
+	message []byte       // Message being streamed for signing
+	reply   bytes.Buffer // Chunks to be read by the host
+}
+
+func (l *mockLedger) Write(chunk []byte) (int, error) {
+	if len(l.request) == 0 {
+		l.length = int(binary.BigEndian.Uint16(chunk[5:7]))
+		l.request = append(l.request, chunk[7:]...)
+	} else {
+		l.request = append(l.request, chunk[5:]...)
+	}
+	if len(l.request) >= l.length {
+		l.respond(l.request[:l.length])
+		l.request = nil
+	}
+	return len(chunk), nil
+}
+
+func (l *mockLedger) Read(chunk []byte) (int, error) {
+	return l.reply.Read(chunk)
+}
+
+// respond executes an APDU and queues the chunks of the reply.
+func (l *mockLedger) respond(apdu []byte) {
+	var reply []byte
+	switch data := apdu[5:]; ledgerOpcode(apdu[1]) {
+	case ledgerOpGetConfiguration:
+		reply = append([]byte{0x01}, l.version[:]...)
+
+	case ledgerOpRetrieveAddress:
+		pubkey := crypto.FromECDSAPub(&l.key.PublicKey)
+		address := []byte(hex.EncodeToString(crypto.PubkeyToAddress(l.key.PublicKey).Bytes()))
+		reply = append(append(append([]byte{byte(len(pubkey))}, pubkey...), byte(len(address))), address...)
+
+	case ledgerOpSignMessage:
+		if ledgerParam1(apdu[2]) == ledgerP1InitTransactionData {
+			data = data[1+4*int(data[0]):]
+			l.message = make([]byte, 0, binary.BigEndian.Uint32(data))
+			data = data[4:]
+		}
+		if l.message = append(l.message, data...); len(l.message) == cap(l.message) {
+			sig, _ := crypto.Sign(types.MessageHash(l.message).Bytes(), l.key)
+			reply = append([]byte{sig[64] + 27}, sig[:64]...)
+		}
+	}
+	reply = append(reply, 0x90, 0x00)
+
+	payload := make([]byte, 2, 2+len(reply))
+	binary.BigEndian.PutUint16(payload, uint16(len(reply)))
+	payload = append(payload, reply...)
+	for i := 0; len(payload) > 0; i++ {
+		chunk := make([]byte, 64)
+		copy(chunk, []byte{0x01, 0x01, 0x05})
+		binary.BigEndian.PutUint16(chunk[3:], uint16(i))
+		payload = payload[copy(chunk[5:], payload):]
+		l.reply.Write(chunk)
+	}
+}
+
+func newMockLedger(t *testing.T, version [3]byte) (*mockLedger, *ledgerDriver) {
+	key, _ := crypto.GenerateKey()
+	device := &mockLedger{key: key, version: version}
+
+	driver := newLedgerDriver(log.New()).(*ledgerDriver)
+	if err := driver.Open(device, ""); err != nil {
+		t.Fatalf("failed to open ledger: %v", err)
+	}
+	return device, driver
+}
+
+// Tests that Berith transactions, including staking ones, are signed by the
+// Ledger as a message the chain accepts from BIP6 on.
+func TestLedgerSignTx(t *testing.T) {
+	device, driver := newMockLedger(t, [3]byte{1, 0, 8})
+	addr := crypto.PubkeyToAddress(device.key.PublicKey)
+	chainID := big.NewInt(206)
+
+	if have, err := driver.Derive(accounts.DefaultBaseDerivationPath); err != nil || have != addr {
+		t.Fatalf("derived address mismatch: have %x (%v), want %x", have, err, addr)
+	}
+	for _, jobs := range [][2]types.JobWallet{{types.Main, types.Main}, {types.Main, types.Stake}, {types.Stake, types.Main}} {
+		tx := types.NewTransaction(3, addr, big.NewInt(1000), 21000, big.NewInt(1), []byte{0xbe, 0x41}, jobs[0], jobs[1])
+
+		sender, signed, err := driver.SignTx(accounts.DefaultBaseDerivationPath, tx, chainID)
+		if err != nil {
+			t.Fatalf("%v->%v: failed to sign: %v", jobs[0], jobs[1], err)
+		}
+		if sender != addr {
+			t.Errorf("%v->%v: sender mismatch: have %x, want %x", jobs[0], jobs[1], sender, addr)
+		}
+		if from, err := types.Sender(types.NewBIP6Signer(chainID), signed); err != nil || from != addr {
+			t.Errorf("%v->%v: recovered sender mismatch: have %x (%v), want %x", jobs[0], jobs[1], from, err, addr)
+		}
+		if signed.Base() != jobs[0] || signed.Target() != jobs[1] {
+			t.Errorf("%v->%v: JobWallet fields changed to %v->%v", jobs[0], jobs[1], signed.Base(), signed.Target())
+		}
+	}
+}
+
+// Tests that Ledger applications unable to sign messages are rejected instead
+// of producing signatures the chain doesn't accept.
+func TestLedgerSignTxOldVersion(t *testing.T) {
+	device, driver := newMockLedger(t, [3]byte{1, 0, 7})
+	tx := types.NewTransaction(0, crypto.PubkeyToAddress(device.key.PublicKey), new(big.Int), 21000, big.NewInt(1), nil, types.Main, types.Stake)
+
+	if _, _, err := driver.SignTx(accounts.DefaultBaseDerivationPath, tx, big.NewInt(206)); err == nil {
+		t.Fatalf("signed a transaction with an outdated application")
+	}
+}
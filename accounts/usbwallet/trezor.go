@@ -152,11 +152,21 @@ func (w *trezorDriver) Derive(path accounts.DerivationPath) (common.Address, err
 
 // SignTx implements usbwallet.driver, sending the transaction to the Trezor and
 // waiting for the user to confirm or deny the transaction.
+//
+// Note, the Trezor can't sign the JobWallet fields of a Berith transaction, so
+// replay protected transactions are signed as a message over their signing hash.
 func (w *trezorDriver) SignTx(path accounts.DerivationPath, tx *types.Transaction, chainID *big.Int) (common.Address, *types.Transaction, error) {
 	if w.device == nil {
 		return common.Address{}, nil, accounts.ErrWalletClosed
 	}
-	return w.trezorSign(path, tx, chainID)
+	if chainID == nil {
+		return w.trezorSign(path, tx, chainID)
+	}
+	signature, err := w.trezorSignMessage(path, types.NewEIP155Signer(chainID).Hash(tx).Bytes())
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return messageSignedTx(tx, chainID, signature)
 }
 
 // trezorDerive sends a derivation request to the Trezor device and returns the
@@ -235,6 +245,24 @@ func (w *trezorDriver) trezorSign(derivationPath []uint32, tx *types.Transaction
 	return sender, signed, nil
 }
 
+// trezorSignMessage sends a message to the Trezor wallet, and waits for the user
+// to confirm or deny signing it. The returned signature is in the [R || S || V]
+// format where V is 0 or 1.
+func (w *trezorDriver) trezorSignMessage(derivationPath []uint32, message []byte) ([]byte, error) {
+	response := new(trezor.EthereumMessageSignature)
+	if _, err := w.trezorExchange(&trezor.EthereumSignMessage{AddressN: derivationPath, Message: message}, response); err != nil {
+		return nil, err
+	}
+	// Extract the Ethereum signature and do a sanity validation
+	signature := response.GetSignature()
+	if len(signature) != 65 || (signature[64] != 27 && signature[64] != 28) {
+		return nil, errors.New("reply lacks signature")
+	}
+	signature = common.CopyBytes(signature)
+	signature[64] -= 27
+	return signature, nil
+}
+
 // trezorExchange performs a data exchange with the Trezor wallet, sending it a
 // message and retrieving the response. If multiple responses are possible, the
 // method will also return the index of the destination object used.
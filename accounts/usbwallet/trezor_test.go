@@ -0,0 +1,119 @@
+package usbwallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/accounts"
+	"github.com/BerithFoundation/berith-chain/accounts/usbwallet/internal/trezor"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/crypto"
+	"github.com/BerithFoundation/berith-chain/log"
+	"github.com/golang/protobuf/proto"
+)
+
+// mockTrezor is a USB transport emulating a Trezor wallet, signing everything
+// with a single key after the user confirmed on the device.
+type mockTrezor struct {
+	key     *ecdsa.PrivateKey
+	pending proto.Message // Reply withheld until the button press is acked
+
+	kind    uint16       // Type of the message being received from the host
+	request []byte       // Message being received from the host
+	reply   bytes.Buffer // Chunks to be read by the host
+}
+
+func (d *mockTrezor) Write(chunk []byte) (int, error) {
+	if d.request == nil {
+		d.kind = binary.BigEndian.Uint16(chunk[3:5])
+		d.request = make([]byte, 0, binary.BigEndian.Uint32(chunk[5:9]))
+		chunk = chunk[9:]
+	} else {
+		chunk = chunk[1:]
+	}
+	if left := cap(d.request) - len(d.request); left > len(chunk) {
+		d.request = append(d.request, chunk...)
+	} else {
+		d.request = append(d.request, chunk[:left]...)
+		d.respond(d.kind, d.request)
+		d.request = nil
+	}
+	return 64, nil
+}
+
+func (d *mockTrezor) Read(chunk []byte) (int, error) {
+	return d.reply.Read(chunk)
+}
+
+// respond handles a message and queues the chunks of the reply.
+func (d *mockTrezor) respond(kind uint16, data []byte) {
+	var reply proto.Message
+	switch kind {
+	case trezor.Type(new(trezor.EthereumGetAddress)):
+		reply = &trezor.EthereumAddress{Address: crypto.PubkeyToAddress(d.key.PublicKey).Bytes()}
+
+	case trezor.Type(new(trezor.EthereumSignMessage)):
+		request := new(trezor.EthereumSignMessage)
+		proto.Unmarshal(data, request)
+
+		sig, _ := crypto.Sign(types.MessageHash(request.GetMessage()).Bytes(), d.key)
+		sig[64] += 27
+		d.pending = &trezor.EthereumMessageSignature{Address: crypto.PubkeyToAddress(d.key.PublicKey).Bytes(), Signature: sig}
+		reply = new(trezor.ButtonRequest)
+
+	case trezor.Type(new(trezor.ButtonAck)):
+		reply, d.pending = d.pending, nil
+
+	default:
+		message := "unexpected message"
+		reply = &trezor.Failure{Message: &message}
+	}
+	blob, _ := proto.Marshal(reply)
+
+	payload := make([]byte, 8, 8+len(blob))
+	copy(payload, []byte{0x23, 0x23})
+	binary.BigEndian.PutUint16(payload[2:], trezor.Type(reply))
+	binary.BigEndian.PutUint32(payload[4:], uint32(len(blob)))
+	payload = append(payload, blob...)
+	for len(payload) > 0 {
+		chunk := make([]byte, 64)
+		chunk[0] = 0x3f
+		payload = payload[copy(chunk[1:], payload):]
+		d.reply.Write(chunk)
+	}
+}
+
+// Tests that Berith transactions, including staking ones, are signed by the
+// Trezor as a message the chain accepts from BIP6 on.
+func TestTrezorSignTx(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(206)
+
+	driver := newTrezorDriver(log.New()).(*trezorDriver)
+	driver.device = &mockTrezor{key: key}
+
+	if have, err := driver.Derive(accounts.DefaultBaseDerivationPath); err != nil || have != addr {
+		t.Fatalf("derived address mismatch: have %x (%v), want %x", have, err, addr)
+	}
+	for _, jobs := range [][2]types.JobWallet{{types.Main, types.Main}, {types.Main, types.Stake}, {types.Stake, types.Main}} {
+		tx := types.NewTransaction(7, addr, big.NewInt(1000), 21000, big.NewInt(1), nil, jobs[0], jobs[1])
+
+		sender, signed, err := driver.SignTx(accounts.DefaultBaseDerivationPath, tx, chainID)
+		if err != nil {
+			t.Fatalf("%v->%v: failed to sign: %v", jobs[0], jobs[1], err)
+		}
+		if sender != addr {
+			t.Errorf("%v->%v: sender mismatch: have %x, want %x", jobs[0], jobs[1], sender, addr)
+		}
+		if from, err := types.Sender(types.NewBIP6Signer(chainID), signed); err != nil || from != addr {
+			t.Errorf("%v->%v: recovered sender mismatch: have %x (%v), want %x", jobs[0], jobs[1], from, err, addr)
+		}
+		if _, err := types.Sender(types.NewEIP155Signer(chainID), signed); err != types.ErrInvalidChainId {
+			t.Errorf("%v->%v: accepted before BIP6: %v", jobs[0], jobs[1], err)
+		}
+	}
+}
@@ -64,7 +64,8 @@ type driver interface {
 	Derive(path accounts.DerivationPath) (common.Address, error)
 
 	// SignTx sends the transaction to the USB device and waits for the user to confirm
-	// or deny the transaction.
+	// or deny the transaction. Replay protected transactions are signed by the
+	// device as a message over their signing hash.
 	SignTx(path accounts.DerivationPath, tx *types.Transaction, chainID *big.Int) (common.Address, *types.Transaction, error)
 }
 
@@ -505,9 +506,10 @@ func (w *wallet) SignHash(account accounts.Account, hash []byte) ([]byte, error)
 // wallet to request a confirmation from the user. It returns either the signed
 // transaction or a failure if the user denied the transaction.
 //
-// Note, if the version of the Ethereum application running on the Ledger wallet is
-// too old to sign EIP-155 transactions, but such is requested nonetheless, an error
-// will be returned opposed to silently signing in Homestead mode.
+// Note, the devices only know the Ethereum transaction layout, which lacks the
+// JobWallet fields of Berith transactions. Replay protected transactions are thus
+// signed as a message over their signing hash (see types.MessageSigner), which
+// the chain accepts from BIP6 on.
 func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
 	w.stateLock.RLock() // Comms have own mutex, this is for the state fields
 	defer w.stateLock.RUnlock()
@@ -547,6 +549,21 @@ func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID
 	return signed, nil
 }
 
+// messageSignedTx injects a signature the device made over the signing hash of
+// tx as a message, returning the recovered sender and the signed transaction.
+func messageSignedTx(tx *types.Transaction, chainID *big.Int, signature []byte) (common.Address, *types.Transaction, error) {
+	signer := types.NewMessageSigner(chainID)
+	signed, err := tx.WithSignature(signer, signature)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	sender, err := types.Sender(signer, signed)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return sender, signed, nil
+}
+
 // SignHashWithPassphrase implements accounts.Wallet, however signing arbitrary
 // data is not supported for Ledger wallets, so this method will always return
 // an error.
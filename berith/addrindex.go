@@ -0,0 +1,93 @@
+package berith
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/params"
+)
+
+const (
+	// addressIndexThrottling is the time to wait between processing two consecutive
+	// address index sections.
+	addressIndexThrottling = 100 * time.Millisecond
+)
+
+// AddressIndexer implements a core.ChainIndexer, building up an index of the
+// transactions sent from and to every address of the canonical chain.
+type AddressIndexer struct {
+	db      berithdb.Database   // database instance to read blocks from and write index data into
+	config  *params.ChainConfig // chain config to recover the transaction senders
+	section uint64              // section is the section number being processed currently
+	entries map[common.Address][]rawdb.AddressTxEntry
+}
+
+// NewAddressIndexer returns a chain indexer that generates the address to
+// transaction index of the canonical chain.
+func NewAddressIndexer(db berithdb.Database, config *params.ChainConfig, size, confirms uint64) *core.ChainIndexer {
+	backend := &AddressIndexer{
+		db:     db,
+		config: config,
+	}
+	table := berithdb.NewTable(db, string(rawdb.AddressIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, size, confirms, addressIndexThrottling, "addrindex")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new address index
+// section.
+func (a *AddressIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	a.section, a.entries = section, make(map[common.Address][]rawdb.AddressTxEntry)
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the senders and recipients
+// of the transactions of a new header's block into the index.
+func (a *AddressIndexer) Process(ctx context.Context, header *types.Header) error {
+	number := header.Number.Uint64()
+	body := rawdb.ReadBody(a.db, header.Hash(), number)
+	if body == nil {
+		return fmt.Errorf("block #%d [%x…] body missing", number, header.Hash().Bytes()[:4])
+	}
+	signer := types.MakeSigner(a.config, header.Number)
+	for i, tx := range body.Transactions {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return err
+		}
+		entry := rawdb.AddressTxEntry{BlockNumber: number, Index: uint64(i), Hash: tx.Hash()}
+		a.entries[from] = append(a.entries[from], entry)
+		if to := tx.To(); to != nil && *to != from {
+			a.entries[*to] = append(a.entries[*to], entry)
+		}
+		// Key migrations are indexed for the new key taking over the account too
+		if types.IsKeyMigration(a.config, header.Number, tx.To(), tx.Base(), tx.Target()) {
+			if to, err := types.KeyMigrationTarget(a.config.ChainID, from, tx.Data()); err == nil {
+				a.entries[to] = append(a.entries[to], entry)
+			}
+		}
+	}
+	return nil
+}
+
+// Commit implements core.ChainIndexerBackend, writing out the entries of every
+// address seen in the section.
+func (a *AddressIndexer) Commit() error {
+	batch := a.db.NewBatch()
+	for address, entries := range a.entries {
+		rawdb.WriteAddressTxEntries(batch, address, a.section, entries)
+		if batch.ValueSize() >= berithdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return batch.Write()
+}
@@ -0,0 +1,146 @@
+package berith
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core"
+	"github.com/BerithFoundation/berith-chain/core/checkpoint"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/state"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/crypto"
+	"github.com/BerithFoundation/berith-chain/event"
+	"github.com/BerithFoundation/berith-chain/params"
+	"github.com/BerithFoundation/berith-chain/rlp"
+)
+
+// testIndexerChain is the chain feeding the head events of the indexers.
+type testIndexerChain struct {
+	head *types.Header
+	feed event.Feed
+}
+
+func (c *testIndexerChain) CurrentHeader() *types.Header { return c.head }
+
+func (c *testIndexerChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+// newIndexerGenesisDB creates a database holding the genesis of the test chain.
+func newIndexerGenesisDB() (berithdb.Database, *types.Block) {
+	db := berithdb.NewMemDatabase()
+	genesis := types.NewBlock(&types.Header{Number: big.NewInt(0), Root: types.EmptyRootHash, Difficulty: big.NewInt(1)}, nil, nil, nil)
+	rawdb.WriteBlock(db, genesis)
+	rawdb.WriteTd(db, genesis.Hash(), 0, genesis.Difficulty())
+	rawdb.WriteCanonicalHash(db, genesis.Hash(), 0)
+	rawdb.WriteHeadBlockHash(db, genesis.Hash())
+	rawdb.WriteHeadHeaderHash(db, genesis.Hash())
+	return db, genesis
+}
+
+// Tests that the address and the bloombits indexers of a chain bootstrapped from
+// a state snapshot start at the chain base, so that the address index can be
+// queried for the blocks of the snapshot.
+func TestIndexersFromSnapshot(t *testing.T) {
+	const (
+		network = 101
+		epoch   = 4
+		number  = 26
+		size    = 8
+	)
+	var (
+		key, _    = crypto.GenerateKey()
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.Address{0xaa}
+		config    = params.TestnetChainConfig
+	)
+	// Every block of the source chain holds a transfer of the staking sender
+	src, genesis := newIndexerGenesisDB()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(src))
+	statedb.AddBalance(sender, big.NewInt(1))
+	statedb.SetStaking(sender, big.NewInt(100), big.NewInt(0))
+
+	blocks := []*types.Block{genesis}
+	td := new(big.Int).Set(genesis.Difficulty())
+	stakers, _ := rlp.EncodeToBytes([]common.Address{sender})
+	for i := uint64(1); i <= number; i++ {
+		header := &types.Header{ParentHash: blocks[i-1].Hash(), Number: new(big.Int).SetUint64(i), Difficulty: big.NewInt(2)}
+		tx, err := types.SignTx(types.NewTransaction(i-1, recipient, big.NewInt(1), 21000, big.NewInt(1), nil, types.Main, types.Main), types.MakeSigner(config, header.Number), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header.Bloom.Add(new(big.Int).SetBytes(recipient.Bytes()))
+
+		statedb.AddBalance(recipient, big.NewInt(1))
+		if header.Root, err = statedb.Commit(true); err != nil {
+			t.Fatal(err)
+		}
+		if err := statedb.Database().TrieDB().Commit(header.Root, false); err != nil {
+			t.Fatal(err)
+		}
+		block := types.NewBlock(header, []*types.Transaction{tx}, nil, nil)
+		td.Add(td, block.Difficulty())
+
+		rawdb.WriteBlock(src, block)
+		rawdb.WriteTd(src, block.Hash(), i, td)
+		rawdb.WriteCanonicalHash(src, block.Hash(), i)
+		rawdb.WriteStakersRLP(src, block.Hash(), stakers)
+		blocks = append(blocks, block)
+	}
+	rawdb.WriteHeadBlockHash(src, blocks[number].Hash())
+
+	var data bytes.Buffer
+	if err := checkpoint.Export(src, &data, network, number, epoch); err != nil {
+		t.Fatal(err)
+	}
+	db, _ := newIndexerGenesisDB()
+	if _, err := checkpoint.Import(db, &data, network, epoch, blocks[number].Hash(), common.Hash{}, td); err != nil {
+		t.Fatal(err)
+	}
+	base := rawdb.ReadChainBase(db)
+	if base == nil || *base%size == 0 || *base/size != number/size-1 {
+		t.Fatal("chain base not inside the last complete section")
+	}
+	// Both indexers skip the sections below the chain base and index the rest
+	chain := &testIndexerChain{head: blocks[number].Header()}
+	addrIndexer := NewAddressIndexer(db, config, size, 0)
+	bloomIndexer := NewBloomIndexer(db, size, 0)
+	defer addrIndexer.Close()
+	defer bloomIndexer.Close()
+	addrIndexer.Start(chain)
+	bloomIndexer.Start(chain)
+
+	for _, indexer := range []*core.ChainIndexer{addrIndexer, bloomIndexer} {
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			if sections, _, _ := indexer.Sections(); sections == number/size {
+				break
+			}
+			if time.Now().After(deadline) {
+				sections, _, _ := indexer.Sections()
+				t.Fatalf("indexed sections mismatch: have %d, want %d", sections, number/size)
+			}
+		}
+	}
+	section := uint64(number/size - 1)
+	entries := rawdb.ReadAddressTxEntries(db, sender, section)
+	if want := (section+1)*size - *base; uint64(len(entries)) != want {
+		t.Fatalf("indexed transactions mismatch: have %d, want %d", len(entries), want)
+	}
+	for i, entry := range entries {
+		block := blocks[*base+uint64(i)]
+		if entry.BlockNumber != block.NumberU64() || entry.Index != 0 || entry.Hash != block.Transactions()[0].Hash() {
+			t.Errorf("entry %d mismatch: have %+v, want transaction %x of block %d", i, entry, block.Transactions()[0].Hash(), block.NumberU64())
+		}
+	}
+	head := blocks[(section+1)*size-1].Hash()
+	for bit := uint(0); bit < types.BloomBitLength; bit++ {
+		if _, err := rawdb.ReadBloomBits(db, bit, section, head); err != nil {
+			t.Fatalf("bloom bits %d of section %d missing: %v", bit, section, err)
+		}
+	}
+}
@@ -30,11 +30,13 @@ import (
 
 	"github.com/BerithFoundation/berith-chain/common"
 	"github.com/BerithFoundation/berith-chain/common/hexutil"
+	"github.com/BerithFoundation/berith-chain/consensus/bsrr"
 	"github.com/BerithFoundation/berith-chain/core"
 	"github.com/BerithFoundation/berith-chain/core/rawdb"
 	"github.com/BerithFoundation/berith-chain/core/state"
 	"github.com/BerithFoundation/berith-chain/core/types"
 	"github.com/BerithFoundation/berith-chain/internal/berithapi"
+	"github.com/BerithFoundation/berith-chain/miner"
 	"github.com/BerithFoundation/berith-chain/params"
 	"github.com/BerithFoundation/berith-chain/rlp"
 	"github.com/BerithFoundation/berith-chain/rpc"
@@ -153,6 +155,62 @@ func (api *PrivateMinerAPI) SetRecommitInterval(interval int) {
 	api.e.Miner().SetRecommitInterval(time.Duration(interval) * time.Millisecond)
 }
 
+// SetBlockBuilder switches the strategy ordering the transactions of new blocks
+// to the registered block builder with the given name.
+func (api *PrivateMinerAPI) SetBlockBuilder(name string, prioritySenders []common.Address) error {
+	builder, err := miner.NewBlockBuilder(name, &miner.BuilderConfig{PrioritySenders: prioritySenders})
+	if err != nil {
+		return err
+	}
+	api.e.Miner().SetBlockBuilder(builder)
+	return nil
+}
+
+// SubmitBundle queues RLP encoded signed transactions for atomic inclusion at
+// the top of the given block, or of the next block if none is given. Either all
+// transactions of the bundle are included in that order or none of them.
+func (api *PrivateMinerAPI) SubmitBundle(encodedTxs []hexutil.Bytes, blockNumber *hexutil.Uint64) error {
+	txs := make(types.Transactions, 0, len(encodedTxs))
+	for i, encoded := range encodedTxs {
+		tx := new(types.Transaction)
+		if err := rlp.DecodeBytes(encoded, tx); err != nil {
+			return fmt.Errorf("invalid transaction at index %d: %v", i, err)
+		}
+		txs = append(txs, tx)
+	}
+	var number uint64
+	if blockNumber != nil {
+		number = uint64(*blockNumber)
+	}
+	return api.e.Miner().SubmitBundle(txs, number)
+}
+
+// GetSealingStatus reports, for the next count block heights (one by default),
+// the expected rank, difficulty and sealing delay of the given signer or of the
+// local berithbase if none is given, together with the stake target block the
+// rank is derived from.
+func (api *PrivateMinerAPI) GetSealingStatus(count *uint64, signer *common.Address) ([]*bsrr.SealingSlot, error) {
+	engine, ok := api.e.Engine().(*bsrr.BSRR)
+	if !ok {
+		return nil, errors.New("sealing status is only available for the bsrr engine")
+	}
+	var address common.Address
+	if signer != nil {
+		address = *signer
+	} else {
+		base, err := api.e.Berithbase()
+		if err != nil {
+			return nil, err
+		}
+		address = base
+	}
+	var n uint64 = 1
+	if count != nil {
+		n = *count
+	}
+	return engine.SealingStatus(api.e.BlockChain(), address, n)
+}
+
 // GetHashrate returns the current hashrate of the miner.
 func (api *PrivateMinerAPI) GetHashrate() uint64 {
 	return api.e.miner.HashRate()
@@ -471,3 +529,77 @@ func (api *PrivateDebugAPI) getModifiedAccounts(startBlock, endBlock *types.Bloc
 	return dirty, nil
 }
 
+
+// maxStateDiffRange is the maximum number of blocks of a debug_stateDiffRange
+// API call.
+const maxStateDiffRange = 1024
+
+// BlockStateDiff is the result of a debug_stateDiff API call, listing the changes
+// of a block to the accounts and storage slots with their previous values.
+type BlockStateDiff struct {
+	Number hexutil.Uint64 `json:"number"`
+	Hash   common.Hash    `json:"hash"`
+	*state.StateDiff
+}
+
+// StateDiff returns the changes of the given block to every account field and
+// storage slot, including the stake, point and reward changes of the consensus
+// engine which produce no logs.
+func (api *PrivateDebugAPI) StateDiff(blockNr rpc.BlockNumber) (*BlockStateDiff, error) {
+	var block *types.Block
+	switch blockNr {
+	case rpc.PendingBlockNumber:
+		return nil, errors.New("state diff of the pending block is not supported")
+	case rpc.LatestBlockNumber:
+		block = api.e.blockchain.CurrentBlock()
+	default:
+		block = api.e.blockchain.GetBlockByNumber(uint64(blockNr))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr)
+	}
+	return api.stateDiff(block)
+}
+
+// StateDiffByHash returns the changes of the block with the given hash to every
+// account field and storage slot.
+func (api *PrivateDebugAPI) StateDiffByHash(hash common.Hash) (*BlockStateDiff, error) {
+	block := api.e.blockchain.GetBlockByHash(hash)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", hash)
+	}
+	return api.stateDiff(block)
+}
+
+// StateDiffRange returns the changes of the canonical blocks between the two
+// given numbers, both included. The ranges are served fast by the nodes which
+// record the state diffs of the imported blocks.
+func (api *PrivateDebugAPI) StateDiffRange(startNum uint64, endNum uint64) ([]*BlockStateDiff, error) {
+	if startNum > endNum {
+		return nil, fmt.Errorf("start block %d is after end block %d", startNum, endNum)
+	}
+	if endNum-startNum >= maxStateDiffRange {
+		return nil, fmt.Errorf("too many blocks requested, the limit is %d", maxStateDiffRange)
+	}
+	var diffs []*BlockStateDiff
+	for number := startNum; number <= endNum; number++ {
+		block := api.e.blockchain.GetBlockByNumber(number)
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+		diff, err := api.stateDiff(block)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+func (api *PrivateDebugAPI) stateDiff(block *types.Block) (*BlockStateDiff, error) {
+	diff, err := api.e.blockchain.StateDiff(block)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockStateDiff{Number: hexutil.Uint64(block.NumberU64()), Hash: block.Hash(), StateDiff: diff}, nil
+}
@@ -181,6 +181,10 @@ func (b *BerAPIBackend) Stats() (pending int, queued int) {
 	return b.e.txPool.Stats()
 }
 
+func (b *BerAPIBackend) TxPoolJournalStats() (core.TxJournalStats, bool) {
+	return b.e.txPool.JournalStats()
+}
+
 func (b *BerAPIBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
 	return b.e.TxPool().Content()
 }
@@ -218,6 +222,14 @@ func (b *BerAPIBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, sections
 }
 
+func (b *BerAPIBackend) AddressIndexStatus() (uint64, uint64) {
+	if b.e.addrIndexer == nil {
+		return 0, 0
+	}
+	sections, _, _ := b.e.addrIndexer.Sections()
+	return params.AddressIndexBlocks, sections
+}
+
 func (b *BerAPIBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	for i := 0; i < bloomFilterThreads; i++ {
 		go session.Multiplex(bloomRetrievalBatch, bloomRetrievalWait, b.e.bloomRequests)
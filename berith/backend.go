@@ -42,6 +42,7 @@ import (
 	"github.com/BerithFoundation/berith-chain/core"
 	"github.com/BerithFoundation/berith-chain/core/bloombits"
 	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/state/pruner"
 	"github.com/BerithFoundation/berith-chain/core/types"
 	"github.com/BerithFoundation/berith-chain/core/vm"
 	"github.com/BerithFoundation/berith-chain/event"
@@ -82,6 +83,7 @@ type Berith struct {
 
 	// DB interfaces
 	chainDb berithdb.Database // Block chain database
+	diffDb  berithdb.Database // State diff database, nil if disabled
 
 	eventMux       *event.TypeMux
 	engine         consensus.Engine
@@ -89,6 +91,7 @@ type Berith struct {
 
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
+	addrIndexer   *core.ChainIndexer             // Address to transaction indexer, nil if disabled
 
 	APIBackend *BerAPIBackend
 
@@ -130,17 +133,23 @@ func New(ctx *node.ServiceContext, config *Config) (*Berith, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Finish a state pruning interrupted while deleting, the retained states
+	// are incomplete until then
+	if err := pruner.RecoverPruning(ctx.ResolvePath(pruner.BloomFileName), chainDb); err != nil {
+		return nil, err
+	}
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.ConstantinopleOverride)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
 	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
-	stakingDB := &stakingdb.StakingDB{}
-	stakingDBPath := ctx.ResolvePath("stakingDB")
-	if stkErr := stakingDB.CreateDB(stakingDBPath, staking.NewStakers); stkErr != nil {
-		return nil, stkErr
+	// The staking lists are stored in the chain database, move those of the
+	// former staking database there
+	if err := stakingdb.Migrate(chainDb, ctx.ResolvePath("stakingDB")); err != nil {
+		return nil, err
 	}
+	stakingDB := stakingdb.NewStakingDB(chainDb, staking.NewStakers)
 	engine := CreateConsensusEngine(chainConfig, chainDb, stakingDB)
 	ber := &Berith{
 		config:         config,
@@ -173,12 +182,18 @@ func New(ctx *node.ServiceContext, config *Config) (*Berith, error) {
 			EWASMInterpreter:        config.EWASMInterpreter,
 			EVMInterpreter:          config.EVMInterpreter,
 		}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieCleanLimit: config.TrieCleanCache, TrieDirtyLimit: config.TrieDirtyCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieCleanLimit: config.TrieCleanCache, TrieDirtyLimit: config.TrieDirtyCache, TrieTimeLimit: config.TrieTimeout, NoSnapshot: config.NoSnapshot}
 	)
 	ber.blockchain, err = core.NewBlockChain(stakingDB, chainDb, cacheConfig, ber.chainConfig, ber.engine, vmConfig, ber.shouldPreserve)
 	if err != nil {
 		return nil, err
 	}
+	if config.StateDiffs {
+		if ber.diffDb, err = ctx.OpenDatabase("statediffs", config.DatabaseCache/4, config.DatabaseHandles/4); err != nil {
+			return nil, err
+		}
+		ber.blockchain.EnableStateDiffs(ber.diffDb)
+	}
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
 		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
@@ -186,11 +201,15 @@ func New(ctx *node.ServiceContext, config *Config) (*Berith, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	ber.bloomIndexer.Start(ber.blockchain)
+	if !config.NoAddressIndex {
+		ber.addrIndexer = NewAddressIndexer(chainDb, chainConfig, params.AddressIndexBlocks, params.AddressIndexConfirms)
+		ber.addrIndexer.Start(ber.blockchain)
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
-	ber.txPool = core.NewTxPool(config.TxPool, ber.chainConfig, ber.blockchain)
+	ber.txPool = core.NewTxPool(config.TxPool, ber.chainConfig, ber.blockchain, chainDb)
 
 	if ber.protocolManager, err = NewProtocolManager(ber.chainConfig, config.SyncMode, config.NetworkId, ber.eventMux, ber.txPool, ber.engine, ber.blockchain, chainDb, config.Whitelist); err != nil {
 		return nil, err
@@ -198,6 +217,11 @@ func New(ctx *node.ServiceContext, config *Config) (*Berith, error) {
 
 	ber.miner = miner.New(ber, ber.chainConfig, ber.EventMux(), ber.engine, config.MinerRecommit, config.MinerGasFloor, config.MinerGasCeil, ber.isLocalBlock)
 	ber.miner.SetExtra(makeExtraData(config.MinerExtraData))
+	builder, err := miner.NewBlockBuilder(config.MinerBuilder, &miner.BuilderConfig{PrioritySenders: config.MinerPriority})
+	if err != nil {
+		return nil, err
+	}
+	ber.miner.SetBlockBuilder(builder)
 
 	ber.APIBackend = &BerAPIBackend{ber, nil}
 	gpoParams := config.GPO
@@ -228,11 +252,16 @@ func makeExtraData(extra []byte) []byte {
 
 // CreateDB creates the chain database.
 func CreateDB(ctx *node.ServiceContext, config *Config, name string) (berithdb.Database, error) {
-	db, err := ctx.OpenDatabase(name, config.DatabaseCache, config.DatabaseHandles)
+	// Light clients don't store the bodies and receipts the freezer moves
+	threshold := config.AncientThreshold
+	if config.SyncMode == downloader.LightSync {
+		threshold = 0
+	}
+	db, err := ctx.OpenDatabaseWithFreezer(name, config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, threshold)
 	if err != nil {
 		return nil, err
 	}
-	if db, ok := db.(*berithdb.LDBDatabase); ok {
+	if db := rawdb.LevelDB(db); db != nil {
 		db.Meter("berith/db/chaindata/")
 	}
 	return db, nil
@@ -488,6 +517,9 @@ func (s *Berith) Start(srvr *p2p.Server) error {
 // Berith protocol.
 func (s *Berith) Stop() error {
 	s.bloomIndexer.Close()
+	if s.addrIndexer != nil {
+		s.addrIndexer.Close()
+	}
 	s.blockchain.Stop()
 	s.engine.Close()
 	s.protocolManager.Stop()
@@ -498,6 +530,9 @@ func (s *Berith) Stop() error {
 	s.miner.Stop()
 	s.eventMux.Stop()
 
+	if s.diffDb != nil {
+		s.diffDb.Close()
+	}
 	s.chainDb.Close()
 	close(s.shutdownChan)
 	return nil
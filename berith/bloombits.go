@@ -111,8 +111,21 @@ func NewBloomIndexer(db berithdb.Database, size, confirms uint64) *core.ChainInd
 // section.
 func (b *BloomIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
 	gen, err := bloombits.NewGenerator(uint(b.size))
+	if err != nil {
+		return err
+	}
 	b.gen, b.section, b.head = gen, section, common.Hash{}
-	return err
+
+	// [BERITH] The blocks below the base of a chain bootstrapped from a state
+	// snapshot are missing, their blooms are left empty
+	if base := rawdb.ReadChainBase(b.db); base != nil {
+		for number := section * b.size; number < *base && number < (section+1)*b.size; number++ {
+			if err := gen.AddBloom(uint(number-section*b.size), types.Bloom{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // Process implements core.ChainIndexerBackend, adding a new header's bloom into
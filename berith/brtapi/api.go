@@ -22,9 +22,13 @@ import (
 	"github.com/BerithFoundation/berith-chain/accounts"
 	"github.com/BerithFoundation/berith-chain/common"
 	"github.com/BerithFoundation/berith-chain/common/hexutil"
+	"github.com/BerithFoundation/berith-chain/core"
+	"github.com/BerithFoundation/berith-chain/core/state"
 	"github.com/BerithFoundation/berith-chain/core/types"
 	"github.com/BerithFoundation/berith-chain/crypto"
 	"github.com/BerithFoundation/berith-chain/log"
+	"github.com/BerithFoundation/berith-chain/params"
+	"github.com/BerithFoundation/berith-chain/rlp"
 	// "github.com/BerithFoundation/berith-chain/berith/stake"
 )
 
@@ -226,6 +230,224 @@ func (s *PrivateBerithAPI) StopStaking(ctx context.Context, args WalletTxArgs) (
 	return s.sendTransaction(ctx, *sendTx)
 }
 
+/*
+[BERITH]
+- 보상 지갑 (BehindBalance) 의 성숙한 보상을 Stake 로 옮기는 Tx 를 만드는 함수
+- value 가 없으면 성숙한 보상 전체를 옮김
+- BIP5 이후 사용 가능
+*/
+func (s *PrivateBerithAPI) RewardToStake(ctx context.Context, args WalletTxArgs) (common.Hash, error) {
+	return s.sendRewardTransaction(ctx, args, types.Stake)
+}
+
+/*
+[BERITH]
+- 보상 지갑 (BehindBalance) 의 성숙한 보상을 Main 으로 옮기는 Tx 를 만드는 함수
+- value 가 없으면 성숙한 보상 전체를 옮김
+- BIP5 이후 사용 가능
+*/
+func (s *PrivateBerithAPI) RewardToBalance(ctx context.Context, args WalletTxArgs) (common.Hash, error) {
+	return s.sendRewardTransaction(ctx, args, types.Main)
+}
+
+/*
+[BERITH]
+- 보상이 성숙할 때 Main 대신 Stake 로 자동 적립(복리) 되도록 설정하는 Tx 를 만드는 함수
+- 스테이킹 중인 계정에만 적용되며 최대 스테이킹 수량을 넘으면 Main 으로 지급
+*/
+func (s *PrivateBerithAPI) SetAutoCompound(ctx context.Context, args WalletTxArgs, enabled bool) (common.Hash, error) {
+	value := new(hexutil.Big)
+	if enabled {
+		value = (*hexutil.Big)(big.NewInt(1))
+	}
+	sendTx := &SendTxArgs{
+		From:     args.From,
+		To:       &args.From,
+		Value:    value,
+		Gas:      args.Gas,
+		GasPrice: args.GasPrice,
+		Nonce:    args.Nonce,
+		base:     types.Reward,
+		target:   types.Reward,
+	}
+	return s.sendTransaction(ctx, *sendTx)
+}
+
+/*
+[BERITH]
+- 성숙한 보상을 조회 하는 함수
+- 주어진 블록에서 Reward 지갑 Tx 로 옮길 수 있는 수량을 반환
+*/
+func (s *PrivateBerithAPI) GetMaturedReward(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*hexutil.Big, error) {
+	state, header, err := s.backend.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	matured := core.MaturedRewardNumber(s.backend.ChainConfig(), header.Number)
+	return (*hexutil.Big)(state.GetMaturedReward(address, matured)), state.Error()
+}
+
+// sendRewardTransaction moves matured rewards of the sender into the target wallet.
+func (s *PrivateBerithAPI) sendRewardTransaction(ctx context.Context, args WalletTxArgs, target types.JobWallet) (common.Hash, error) {
+	config := s.backend.ChainConfig()
+	if !config.IsBIP5(new(big.Int).Add(s.backend.CurrentBlock().Number(), common.Big1)) {
+		return common.Hash{}, errors.New("reward transactions are not enabled before BIP5")
+	}
+	state, header, err := s.backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return common.Hash{}, err
+	}
+	// Rewards maturing in the next block can be moved by this transaction
+	number := new(big.Int).Add(header.Number, common.Big1)
+	matured := state.GetMaturedReward(args.From, core.MaturedRewardNumber(config, number))
+
+	value := args.Value
+	if value == nil {
+		value = (*hexutil.Big)(matured)
+	}
+	if value.ToInt().Sign() <= 0 {
+		return common.Hash{}, errors.New("no matured reward")
+	}
+	if value.ToInt().Cmp(matured) > 0 {
+		return common.Hash{}, errors.New("insufficient matured reward")
+	}
+	if target == types.Stake {
+		total := new(big.Int).Add(state.GetStakeBalance(args.From), value.ToInt())
+		if total.Cmp(config.Bsrr.StakeMinimum) < 0 {
+			return common.Hash{}, errors.New("staking balance failed")
+		}
+	}
+
+	sendTx := &SendTxArgs{
+		From:     args.From,
+		To:       &args.From,
+		Value:    value,
+		Gas:      args.Gas,
+		GasPrice: args.GasPrice,
+		Nonce:    args.Nonce,
+		base:     types.Reward,
+		target:   target,
+	}
+	return s.sendTransaction(ctx, *sendTx)
+}
+
+/*
+[BERITH]
+- 계정의 Main, Stake, 보상 잔액과 선출 포인트, 패널티를 새 키의 주소(to)로 옮기는 Tx 를 만드는 함수
+- 새 키가 교체를 승인하는 서명을 해야 하므로 두 계정 모두 노드에 있고 unlock 되어 있어야 함
+- 새 주소는 받은 잔액 외에 사용한 적이 없어야 함
+- BIP8 이후 사용 가능
+*/
+func (s *PrivateBerithAPI) MigrateAccount(ctx context.Context, args WalletTxArgs, to common.Address) (common.Hash, error) {
+	config := s.backend.ChainConfig()
+	next := new(big.Int).Add(s.backend.CurrentBlock().Number(), common.Big1)
+	if !config.IsBIP8(next) {
+		return common.Hash{}, errors.New("key migration is not enabled before BIP8")
+	}
+	// The new key accepts the migration by signing it
+	account := accounts.Account{Address: to}
+	wallet, err := s.backend.AccountManager().Find(account)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	hash := types.KeyMigrationHash(config.ChainID, args.From)
+	sig, err := wallet.SignHash(account, hash[:])
+	if err != nil {
+		return common.Hash{}, err
+	}
+	state, _, err := s.backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return common.Hash{}, err
+	}
+	if _, err := core.ValidateKeyMigration(state, config.ChainID, next, args.From, new(big.Int), sig); err != nil {
+		return common.Hash{}, err
+	}
+
+	migration := types.KeyMigrationAddress
+	sendTx := &SendTxArgs{
+		From:     args.From,
+		To:       &migration,
+		Value:    new(hexutil.Big),
+		Gas:      args.Gas,
+		GasPrice: args.GasPrice,
+		Nonce:    args.Nonce,
+		Data:     (*hexutil.Bytes)(&sig),
+		base:     types.Main,
+		target:   types.Main,
+	}
+	return s.sendTransaction(ctx, *sendTx)
+}
+
+/*
+[BERITH]
+- value 만큼의 Main 잔액을 to 계정에 베스팅 일정(schedule)으로 잠그는 Tx 를 만드는 함수
+- 잠긴 잔액은 to 계정이 Tx 를 보낼 때 일정에 따라 Main 으로 지급됨
+- BIP9 이후 사용 가능
+*/
+func (s *PrivateBerithAPI) LockVesting(ctx context.Context, args WalletTxArgs, to common.Address, schedule types.VestingSchedule) (common.Hash, error) {
+	if !s.backend.ChainConfig().IsBIP9(new(big.Int).Add(s.backend.CurrentBlock().Number(), common.Big1)) {
+		return common.Hash{}, errors.New("vesting transactions are not enabled before BIP9")
+	}
+	if err := schedule.Validate(); err != nil {
+		return common.Hash{}, err
+	}
+	if args.Value == nil || args.Value.ToInt().Cmp(params.MinVestingAmount) < 0 {
+		return common.Hash{}, types.ErrVestingAmountTooLow
+	}
+	data, err := rlp.EncodeToBytes(&schedule)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	sendTx := &SendTxArgs{
+		From:     args.From,
+		To:       &to,
+		Value:    args.Value,
+		Gas:      args.Gas,
+		GasPrice: args.GasPrice,
+		Nonce:    args.Nonce,
+		Data:     (*hexutil.Bytes)(&data),
+		base:     types.Main,
+		target:   types.Vesting,
+	}
+	return s.sendTransaction(ctx, *sendTx)
+}
+
+/*
+[BERITH]
+- 스테이킹 가능한 베스팅 일정으로 잠긴 잔액을 Stake 로 옮기는 Tx 를 만드는 함수
+- 옮긴 잔액은 지급될 때까지 잠겨 있으며, 스테이킹 해제시 다시 잠긴 잔액으로 돌아감
+- BIP9 이후 사용 가능
+*/
+func (s *PrivateBerithAPI) StakeVesting(ctx context.Context, args WalletTxArgs) (common.Hash, error) {
+	if !s.backend.ChainConfig().IsBIP9(new(big.Int).Add(s.backend.CurrentBlock().Number(), common.Big1)) {
+		return common.Hash{}, errors.New("vesting transactions are not enabled before BIP9")
+	}
+	state, _, err := s.backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return common.Hash{}, err
+	}
+	stakable := state.GetStakableVesting(args.From)
+
+	value := args.Value
+	if value == nil {
+		value = (*hexutil.Big)(stakable)
+	}
+	if value.ToInt().Sign() <= 0 || value.ToInt().Cmp(stakable) > 0 {
+		return common.Hash{}, errors.New("insufficient stakable vesting balance")
+	}
+	sendTx := &SendTxArgs{
+		From:     args.From,
+		To:       &args.From,
+		Value:    value,
+		Gas:      args.Gas,
+		GasPrice: args.GasPrice,
+		Nonce:    args.Nonce,
+		base:     types.Vesting,
+		target:   types.Stake,
+	}
+	return s.sendTransaction(ctx, *sendTx)
+}
+
 /*
 [BERITH]
 - private trasaction function
@@ -279,8 +501,14 @@ func (s *PrivateBerithAPI) GetStakeBalance(ctx context.Context, address common.A
  - 어카운트 정보를 반환 하기 위한 구조체
 */
 type AccountInfo struct {
-	Balance      *big.Int //main balance
-	StakeBalance *big.Int //staking balance
+	Balance        *big.Int        //main balance
+	StakeBalance   *big.Int        //staking balance
+	StakeUpdated   *big.Int        //block number when the stake balance was updated
+	Point          *big.Int        //selection point
+	BehindBalance  []state.Behind  //rewards paid after their block
+	Penalty        uint64          //penalty count
+	PenaltyUpdated *big.Int        //block number when the penalty was updated
+	Vesting        []state.Vesting `json:",omitempty"` //balances locked by vesting schedules
 }
 
 /*
@@ -294,10 +522,15 @@ func (s *PrivateBerithAPI) GetAccountInfo(ctx context.Context, address common.Ad
 		return nil, err
 	}
 
-	account := state.GetAccountInfo(address)
 	info := &AccountInfo{
-		Balance:      account.Balance,
-		StakeBalance: account.StakeBalance,
+		Balance:        state.GetBalance(address),
+		StakeBalance:   state.GetStakeBalance(address),
+		StakeUpdated:   state.GetStakeUpdated(address),
+		Point:          state.GetPoint(address),
+		BehindBalance:  state.GetBehindBalance(address),
+		Penalty:        state.GetPenalty(address),
+		PenaltyUpdated: state.GetPenaltyUpdated(address),
+		Vesting:        state.GetVesting(address),
 	}
 
 	return info, state.Error()
@@ -31,17 +31,18 @@ import (
 )
 
 var DefaultConfig = Config{
-	SyncMode:       downloader.FullSync,
-	NetworkId:      101,
-	LightPeers:     100,
-	DatabaseCache:  512,
-	TrieCleanCache: 256,
-	TrieDirtyCache: 256,
-	TrieTimeout:    60 * time.Minute,
-	MinerGasFloor:  8000000,
-	MinerGasCeil:   8000000,
-	MinerGasPrice:  big.NewInt(params.Gmin),
-	MinerRecommit:  3 * time.Second,
+	SyncMode:         downloader.FullSync,
+	NetworkId:        101,
+	LightPeers:       100,
+	DatabaseCache:    512,
+	AncientThreshold: params.ImmutabilityThreshold,
+	TrieCleanCache:   256,
+	TrieDirtyCache:   256,
+	TrieTimeout:      60 * time.Minute,
+	MinerGasFloor:    8000000,
+	MinerGasCeil:     8000000,
+	MinerGasPrice:    big.NewInt(params.Gmin),
+	MinerRecommit:    3 * time.Second,
 
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
@@ -71,6 +72,15 @@ type Config struct {
 	SyncMode  downloader.SyncMode
 	NoPruning bool
 
+	// Disables the address to transaction index, saving disk space
+	NoAddressIndex bool
+
+	// Disables the flat state snapshot, serving the state reads from the tries
+	NoSnapshot bool
+
+	// Records the state changes of every imported block into a separate database
+	StateDiffs bool
+
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
 
@@ -82,6 +92,8 @@ type Config struct {
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	DatabaseFreezer    string // Directory of the ancient store, inside the chain database by default
+	AncientThreshold   uint64 // Number of recent blocks kept out of the ancient store, 0 disables it
 	TrieCleanCache     int
 	TrieDirtyCache     int
 	TrieTimeout        time.Duration
@@ -95,6 +107,8 @@ type Config struct {
 	MinerGasPrice  *big.Int
 	MinerRecommit  time.Duration
 	MinerNoverify  bool
+	MinerBuilder   string           `toml:",omitempty"` // Name of the block builder ordering transactions
+	MinerPriority  []common.Address `toml:",omitempty"` // Senders served first by the priority builder
 
 	// Transaction pool options
 	TxPool core.TxPoolConfig
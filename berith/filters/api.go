@@ -31,6 +31,7 @@ import (
 	"github.com/BerithFoundation/berith-chain/core/types"
 	"github.com/BerithFoundation/berith-chain/berithdb"
 	"github.com/BerithFoundation/berith-chain/event"
+	"github.com/BerithFoundation/berith-chain/internal/berithapi"
 	"github.com/BerithFoundation/berith-chain/rpc"
 )
 
@@ -168,6 +169,87 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	return rpcSub, nil
 }
 
+// NewFullPendingTransactions creates a subscription that is triggered each time a
+// transaction matching the given criteria enters the transaction pool. Unlike
+// NewPendingTransactions the full transaction is delivered, including the
+// Base and Target job wallets.
+func (api *PublicFilterAPI) NewFullPendingTransactions(ctx context.Context, crit *PendingTxCriteria) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if crit == nil {
+		crit = new(PendingTxCriteria)
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		txs := make(chan []*types.Transaction, 128)
+		pendingTxSub := api.events.SubscribeFullPendingTxs(*crit, txs)
+
+		for {
+			select {
+			case matched := <-txs:
+				for _, tx := range matched {
+					notifier.Notify(rpcSub.ID, berithapi.NewRPCPendingTransaction(tx))
+				}
+			case <-rpcSub.Err():
+				pendingTxSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				pendingTxSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// StakingEvents creates a subscription that fires each time an imported block
+// makes an account join or leave staking, or changes its stake balance or
+// selection point. Staking transitions emit no logs, so this is the only way
+// to be notified about them.
+func (api *PublicFilterAPI) StakingEvents(ctx context.Context, crit *StakingCriteria) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if crit == nil {
+		crit = new(StakingCriteria)
+	}
+
+	var (
+		rpcSub = notifier.CreateSubscription()
+		events = make(chan []*StakingEvent)
+	)
+
+	stakingSub, err := api.events.SubscribeStakingEvents(*crit, events)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			select {
+			case evs := <-events:
+				for _, ev := range evs {
+					notifier.Notify(rpcSub.ID, ev)
+				}
+			case <-rpcSub.Err():
+				stakingSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				stakingSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with berith_getFilterChanges.
 //
@@ -53,6 +53,11 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// FullPendingTransactionsSubscription queries full transactions entering
+	// the pending state that match the pending transaction criteria
+	FullPendingTransactionsSubscription
+	// StakingEventsSubscription queries staking changes of imported blocks
+	StakingEventsSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -68,22 +73,33 @@ const (
 	logsChanSize = 10
 	// chainEvChanSize is the size of channel listening to ChainEvent.
 	chainEvChanSize = 10
+	// stakingQueueLimit is the maximum number of blocks waiting for their staking
+	// events to be derived.
+	stakingQueueLimit = 1024
 )
 
 var (
 	ErrInvalidSubscriptionID = errors.New("invalid id")
+
+	// errLightStakingEvents is returned when staking events are requested
+	// from a light client, which doesn't hold the state to derive them.
+	errLightStakingEvents = errors.New("staking events are not supported in light mode")
 )
 
 type subscription struct {
-	id        rpc.ID
-	typ       Type
-	created   time.Time
-	logsCrit  berith.FilterQuery
-	logs      chan []*types.Log
-	hashes    chan []common.Hash
-	headers   chan *types.Header
-	installed chan struct{} // closed when the filter is installed
-	err       chan error    // closed when the filter is uninstalled
+	id          rpc.ID
+	typ         Type
+	created     time.Time
+	logsCrit    berith.FilterQuery
+	txsCrit     PendingTxCriteria
+	stakingCrit StakingCriteria
+	logs        chan []*types.Log
+	hashes      chan []common.Hash
+	headers     chan *types.Header
+	txs         chan []*types.Transaction
+	staking     chan []*StakingEvent
+	installed   chan struct{} // closed when the filter is installed
+	err         chan error    // closed when the filter is uninstalled
 }
 
 // EventSystem creates subscriptions, processes events and broadcasts them to the
@@ -108,6 +124,12 @@ type EventSystem struct {
 	logsCh    chan []*types.Log          // Channel to receive new log event
 	rmLogsCh  chan core.RemovedLogsEvent // Channel to receive removed log event
 	chainCh   chan core.ChainEvent       // Channel to receive new chain event
+
+	// [BERITH] Staking events are derived from the states of the blocks by a
+	// worker, so that the other subscriptions aren't held up
+	stakingBlockCh chan *types.Block    // Channel to hand blocks over to the staking worker
+	stakingCh      chan []*StakingEvent // Channel to receive the staking events of a block
+	quit           chan struct{}        // Closed when the event loop stops
 }
 
 // NewEventSystem creates a new manager that listens for event on the given mux,
@@ -127,6 +149,10 @@ func NewEventSystem(mux *event.TypeMux, backend Backend, lightMode bool) *EventS
 		logsCh:    make(chan []*types.Log, logsChanSize),
 		rmLogsCh:  make(chan core.RemovedLogsEvent, rmLogsChanSize),
 		chainCh:   make(chan core.ChainEvent, chainEvChanSize),
+
+		stakingBlockCh: make(chan *types.Block),
+		stakingCh:      make(chan []*StakingEvent),
+		quit:           make(chan struct{}),
 	}
 
 	// Subscribe events
@@ -144,6 +170,7 @@ func NewEventSystem(mux *event.TypeMux, backend Backend, lightMode bool) *EventS
 	}
 
 	go m.eventLoop()
+	go m.stakingLoop()
 	return m
 }
 
@@ -175,6 +202,8 @@ func (sub *Subscription) Unsubscribe() {
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
 			case <-sub.f.headers:
+			case <-sub.f.txs:
+			case <-sub.f.staking:
 			}
 		}
 
@@ -314,6 +343,47 @@ func (es *EventSystem) SubscribePendingTxs(hashes chan []common.Hash) *Subscript
 	return es.subscribe(sub)
 }
 
+// SubscribeFullPendingTxs creates a subscription that writes the transactions
+// entering the transaction pool that match the given criteria.
+func (es *EventSystem) SubscribeFullPendingTxs(crit PendingTxCriteria, txs chan []*types.Transaction) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       FullPendingTransactionsSubscription,
+		txsCrit:   crit,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan []common.Hash),
+		headers:   make(chan *types.Header),
+		txs:       txs,
+		staking:   make(chan []*StakingEvent),
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+// SubscribeStakingEvents creates a subscription that writes the staking events
+// of imported blocks that match the given criteria.
+func (es *EventSystem) SubscribeStakingEvents(crit StakingCriteria, events chan []*StakingEvent) (*Subscription, error) {
+	if es.lightMode {
+		return nil, errLightStakingEvents
+	}
+	sub := &subscription{
+		id:          rpc.NewID(),
+		typ:         StakingEventsSubscription,
+		stakingCrit: crit,
+		created:     time.Now(),
+		logs:        make(chan []*types.Log),
+		hashes:      make(chan []common.Hash),
+		headers:     make(chan *types.Header),
+		txs:         make(chan []*types.Transaction),
+		staking:     events,
+		installed:   make(chan struct{}),
+		err:         make(chan error),
+	}
+	return es.subscribe(sub), nil
+}
+
 type filterIndex map[Type]map[rpc.ID]*subscription
 
 // broadcast event to filters that match criteria.
@@ -347,6 +417,12 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 				}
 			}
 		}
+	case []*StakingEvent:
+		for _, f := range filters[StakingEventsSubscription] {
+			if matched := filterStakingEvents(e, &f.stakingCrit); len(matched) > 0 {
+				f.staking <- matched
+			}
+		}
 	case core.NewTxsEvent:
 		hashes := make([]common.Hash, 0, len(e.Txs))
 		for _, tx := range e.Txs {
@@ -355,6 +431,11 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 		for _, f := range filters[PendingTransactionsSubscription] {
 			f.hashes <- hashes
 		}
+		for _, f := range filters[FullPendingTransactionsSubscription] {
+			if matched := filterPendingTxs(e.Txs, &f.txsCrit); len(matched) > 0 {
+				f.txs <- matched
+			}
+		}
 	case core.ChainEvent:
 		for _, f := range filters[BlocksSubscription] {
 			f.headers <- e.Block.Header()
@@ -444,6 +525,30 @@ func (es *EventSystem) lightFilterLogs(header *types.Header, addresses []common.
 	return nil
 }
 
+// stakingLoop derives the staking events of the blocks handed over by the event
+// loop, which broadcasts them to the current subscriptions.
+func (es *EventSystem) stakingLoop() {
+	for {
+		select {
+		case block := <-es.stakingBlockCh:
+			events, err := es.stakingEvents(block)
+			if err != nil {
+				log.Warn("Failed to derive staking events", "number", block.Number(), "hash", block.Hash(), "err", err)
+			}
+			if len(events) == 0 {
+				continue
+			}
+			select {
+			case es.stakingCh <- events:
+			case <-es.quit:
+				return
+			}
+		case <-es.quit:
+			return
+		}
+	}
+}
+
 // eventLoop (un)installs filters and processes mux events.
 func (es *EventSystem) eventLoop() {
 	// Ensure all subscriptions get cleaned up
@@ -453,14 +558,24 @@ func (es *EventSystem) eventLoop() {
 		es.logsSub.Unsubscribe()
 		es.rmLogsSub.Unsubscribe()
 		es.chainSub.Unsubscribe()
+		close(es.quit)
 	}()
 
 	index := make(filterIndex)
 	for i := UnknownSubscription; i < LastIndexSubscription; i++ {
 		index[i] = make(map[rpc.ID]*subscription)
 	}
+	// Blocks waiting to be handed over to the staking worker
+	var stakingQueue []*types.Block
 
 	for {
+		var (
+			stakingNext  chan *types.Block
+			stakingBlock *types.Block
+		)
+		if len(stakingQueue) > 0 {
+			stakingNext, stakingBlock = es.stakingBlockCh, stakingQueue[0]
+		}
 		select {
 		// Handle subscribed events
 		case ev := <-es.txsCh:
@@ -471,6 +586,18 @@ func (es *EventSystem) eventLoop() {
 			es.broadcast(index, ev)
 		case ev := <-es.chainCh:
 			es.broadcast(index, ev)
+			// The staking events are only derived if someone listens to them
+			if len(index[StakingEventsSubscription]) > 0 {
+				if len(stakingQueue) == stakingQueueLimit {
+					log.Warn("Dropping staking events of a block", "number", stakingQueue[0].Number(), "hash", stakingQueue[0].Hash())
+					stakingQueue = stakingQueue[1:]
+				}
+				stakingQueue = append(stakingQueue, ev.Block)
+			}
+		case stakingNext <- stakingBlock:
+			stakingQueue = stakingQueue[1:]
+		case ev := <-es.stakingCh:
+			es.broadcast(index, ev)
 		case ev, active := <-es.pendingLogSub.Chan():
 			if !active { // system stopped
 				return
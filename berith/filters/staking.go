@@ -0,0 +1,331 @@
+package filters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/common/hexutil"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/rlp"
+	"github.com/BerithFoundation/berith-chain/rpc"
+)
+
+/*
+[BERITH]
+PendingTxCriteria selects the pending transactions delivered by the full
+pending transaction subscription. Empty fields match everything, addresses
+inside one field are OR-ed and the fields are AND-ed together.
+*/
+type PendingTxCriteria struct {
+	From   []common.Address
+	To     []common.Address
+	Base   types.JobWallet // zero value matches any base wallet
+	Target types.JobWallet // zero value matches any target wallet
+}
+
+// UnmarshalJSON sets *crit fields with given data. The JobWallet fields are
+// given by name ("main", "stake") as in berith_sendTransaction.
+func (crit *PendingTxCriteria) UnmarshalJSON(data []byte) error {
+	type input struct {
+		From   interface{} `json:"from"`
+		To     interface{} `json:"to"`
+		Base   string      `json:"base"`
+		Target string      `json:"target"`
+	}
+
+	var raw input
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var err error
+	if crit.From, err = decodeAddresses(raw.From); err != nil {
+		return fmt.Errorf("invalid from: %v", err)
+	}
+	if crit.To, err = decodeAddresses(raw.To); err != nil {
+		return fmt.Errorf("invalid to: %v", err)
+	}
+	if crit.Base, err = decodeJobWallet(raw.Base); err != nil {
+		return fmt.Errorf("invalid base: %v", err)
+	}
+	if crit.Target, err = decodeJobWallet(raw.Target); err != nil {
+		return fmt.Errorf("invalid target: %v", err)
+	}
+	return nil
+}
+
+// matches reports whether the transaction sent by from satisfies the criteria.
+func (crit *PendingTxCriteria) matches(from common.Address, tx *types.Transaction) bool {
+	if crit.Base != 0 && tx.Base() != crit.Base {
+		return false
+	}
+	if crit.Target != 0 && tx.Target() != crit.Target {
+		return false
+	}
+	if len(crit.From) > 0 && !includes(crit.From, from) {
+		return false
+	}
+	if len(crit.To) > 0 && (tx.To() == nil || !includes(crit.To, *tx.To())) {
+		return false
+	}
+	return true
+}
+
+// filterPendingTxs returns the transactions matching the given criteria.
+func filterPendingTxs(txs []*types.Transaction, crit *PendingTxCriteria) []*types.Transaction {
+	var matched []*types.Transaction
+	for _, tx := range txs {
+		if crit.matches(txSender(tx), tx) {
+			matched = append(matched, tx)
+		}
+	}
+	return matched
+}
+
+// txSender derives the sender of a pool transaction. The pool has already
+// validated the signature, so the cached sender is returned in most cases.
+func txSender(tx *types.Transaction) common.Address {
+	var signer types.Signer = types.FrontierSigner{}
+	if tx.Protected() {
+		signer = types.NewBIP7Signer(tx.ChainId())
+	}
+	from, _ := types.Sender(signer, tx)
+	return from
+}
+
+/*
+[BERITH]
+StakingCriteria selects the accounts for which staking events are delivered.
+An empty address list matches every account.
+*/
+type StakingCriteria struct {
+	Addresses []common.Address
+}
+
+// UnmarshalJSON sets *crit fields with given data.
+func (crit *StakingCriteria) UnmarshalJSON(data []byte) error {
+	type input struct {
+		Addresses interface{} `json:"address"`
+	}
+
+	var raw input
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var err error
+	if crit.Addresses, err = decodeAddresses(raw.Addresses); err != nil {
+		return fmt.Errorf("invalid address: %v", err)
+	}
+	return nil
+}
+
+// StakingEventKind describes how the staking status of an account changed.
+type StakingEventKind string
+
+const (
+	// StakingJoined is emitted when an account starts staking.
+	StakingJoined StakingEventKind = "joined"
+	// StakingLeft is emitted when an account's stake balance drops to zero.
+	StakingLeft StakingEventKind = "left"
+	// StakingChanged is emitted when the stake balance or the selection
+	// point of a staking account changes.
+	StakingChanged StakingEventKind = "changed"
+)
+
+/*
+[BERITH]
+StakingEvent is a change of an account's staking status caused by a newly
+imported block. Stake transitions don't emit logs, so the events are derived
+by comparing the state of the block with the state of its parent.
+*/
+type StakingEvent struct {
+	Kind             StakingEventKind `json:"kind"`
+	Address          common.Address   `json:"address"`
+	BlockNumber      hexutil.Uint64   `json:"blockNumber"`
+	BlockHash        common.Hash      `json:"blockHash"`
+	TxHashes         []common.Hash    `json:"transactionHashes"`
+	PrevStakeBalance *hexutil.Big     `json:"prevStakeBalance"`
+	StakeBalance     *hexutil.Big     `json:"stakeBalance"`
+	PrevPoint        *hexutil.Big     `json:"prevPoint"`
+	Point            *hexutil.Big     `json:"point"`
+}
+
+// filterStakingEvents returns the events matching the given criteria.
+func filterStakingEvents(events []*StakingEvent, crit *StakingCriteria) []*StakingEvent {
+	if len(crit.Addresses) == 0 {
+		return events
+	}
+	var matched []*StakingEvent
+	for _, ev := range events {
+		if includes(crit.Addresses, ev.Address) {
+			matched = append(matched, ev)
+		}
+	}
+	return matched
+}
+
+// stakingEvents derives the staking events of the given block. Only the
+// senders of staking transactions (any Base/Target other than Main/Main), the
+// two keys of key migrations, which move the stake of the old key to the new one,
+// and from BIP5 on the stakers whose matured rewards are compounded without any
+// transaction can change their stake balance or point, so only those accounts
+// are inspected.
+func (es *EventSystem) stakingEvents(block *types.Block) ([]*StakingEvent, error) {
+	var (
+		config  = es.backend.ChainConfig()
+		signer  = types.MakeSigner(config, block.Number())
+		touched = make(map[common.Address][]common.Hash)
+		order   []common.Address
+	)
+	touch := func(addr common.Address, hash common.Hash) {
+		if _, ok := touched[addr]; !ok {
+			order = append(order, addr)
+		}
+		touched[addr] = append(touched[addr], hash)
+	}
+	for _, tx := range block.Transactions() {
+		migration := types.IsKeyMigration(config, block.Number(), tx.To(), tx.Base(), tx.Target())
+		if tx.Base() == types.Main && tx.Target() == types.Main && !migration {
+			continue
+		}
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return nil, err
+		}
+		touch(from, tx.Hash())
+		if migration {
+			to, err := types.KeyMigrationTarget(config.ChainID, from, tx.Data())
+			if err != nil {
+				return nil, err
+			}
+			touch(to, tx.Hash())
+		}
+	}
+	if block.NumberU64() == 0 {
+		return nil, nil
+	}
+	// Rewards are only compounded into an existing stake, so the candidates
+	// are the stakers of the parent block
+	var compounders []common.Address
+	if config.IsBIP5(block.Number()) {
+		if data := rawdb.ReadStakersRLP(es.backend.ChainDb(), block.ParentHash()); data != nil {
+			if err := rlp.DecodeBytes(data, &compounders); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(order) == 0 && len(compounders) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	number := rpc.BlockNumber(block.NumberU64())
+	current, header, err := es.backend.StateAndHeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil || header.Hash() != block.Hash() {
+		// The block was reorged out before the event got processed, the
+		// new canonical block reports its own events.
+		return nil, nil
+	}
+	prev, _, err := es.backend.StateAndHeaderByNumber(ctx, number-1)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range compounders {
+		if _, ok := touched[addr]; !ok && (prev.GetAutoCompound(addr) || current.GetAutoCompound(addr)) {
+			order = append(order, addr)
+			touched[addr] = []common.Hash{}
+		}
+	}
+
+	var events []*StakingEvent
+	for _, addr := range order {
+		var (
+			prevStake = prev.GetStakeBalance(addr)
+			stake     = current.GetStakeBalance(addr)
+			prevPoint = prev.GetPoint(addr)
+			point     = current.GetPoint(addr)
+		)
+		var kind StakingEventKind
+		switch {
+		case prevStake.Sign() == 0 && stake.Sign() > 0:
+			kind = StakingJoined
+		case prevStake.Sign() > 0 && stake.Sign() == 0:
+			kind = StakingLeft
+		case prevStake.Cmp(stake) != 0 || prevPoint.Cmp(point) != 0:
+			kind = StakingChanged
+		default:
+			continue
+		}
+		events = append(events, &StakingEvent{
+			Kind:             kind,
+			Address:          addr,
+			BlockNumber:      hexutil.Uint64(block.NumberU64()),
+			BlockHash:        block.Hash(),
+			TxHashes:         touched[addr],
+			PrevStakeBalance: (*hexutil.Big)(new(big.Int).Set(prevStake)),
+			StakeBalance:     (*hexutil.Big)(new(big.Int).Set(stake)),
+			PrevPoint:        (*hexutil.Big)(new(big.Int).Set(prevPoint)),
+			Point:            (*hexutil.Big)(new(big.Int).Set(point)),
+		})
+	}
+	return events, nil
+}
+
+// decodeAddresses parses a single address or an array of addresses.
+func decodeAddresses(raw interface{}) ([]common.Address, error) {
+	switch rawAddr := raw.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		addresses := make([]common.Address, 0, len(rawAddr))
+		for i, addr := range rawAddr {
+			strAddr, ok := addr.(string)
+			if !ok {
+				return nil, fmt.Errorf("non-string address at index %d", i)
+			}
+			parsed, err := decodeAddress(strAddr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address at index %d: %v", i, err)
+			}
+			addresses = append(addresses, parsed)
+		}
+		return addresses, nil
+	case string:
+		addr, err := decodeAddress(rawAddr)
+		if err != nil {
+			return nil, err
+		}
+		return []common.Address{addr}, nil
+	default:
+		return nil, fmt.Errorf("invalid addresses in query")
+	}
+}
+
+// decodeJobWallet parses a JobWallet name, the empty string matches any wallet.
+func decodeJobWallet(s string) (types.JobWallet, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "main":
+		return types.Main, nil
+	case "stake":
+		return types.Stake, nil
+	case "reward":
+		return types.Reward, nil
+	case "vesting":
+		return types.Vesting, nil
+	default:
+		return 0, types.ErrInvalidJobWallet
+	}
+}
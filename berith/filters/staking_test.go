@@ -0,0 +1,332 @@
+package filters
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/state"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/crypto"
+	"github.com/BerithFoundation/berith-chain/event"
+	"github.com/BerithFoundation/berith-chain/params"
+	"github.com/BerithFoundation/berith-chain/rlp"
+	"github.com/BerithFoundation/berith-chain/rpc"
+)
+
+func TestPendingTxCriteriaUnmarshal(t *testing.T) {
+	var crit PendingTxCriteria
+	data := `{"from":"0x0000000000000000000000000000000000000001","to":["0x0000000000000000000000000000000000000002"],"base":"main","target":"stake"}`
+	if err := json.Unmarshal([]byte(data), &crit); err != nil {
+		t.Fatal(err)
+	}
+	if len(crit.From) != 1 || crit.From[0] != common.BigToAddress(big.NewInt(1)) {
+		t.Errorf("from mismatch: %v", crit.From)
+	}
+	if len(crit.To) != 1 || crit.To[0] != common.BigToAddress(big.NewInt(2)) {
+		t.Errorf("to mismatch: %v", crit.To)
+	}
+	if crit.Base != types.Main || crit.Target != types.Stake {
+		t.Errorf("job wallet mismatch: base %d target %d", crit.Base, crit.Target)
+	}
+
+	if err := json.Unmarshal([]byte(`{"base":"behind"}`), &crit); err == nil {
+		t.Error("expected error for invalid job wallet")
+	}
+}
+
+func TestPendingTxCriteriaMatches(t *testing.T) {
+	var (
+		from  = common.BigToAddress(big.NewInt(1))
+		to    = common.BigToAddress(big.NewInt(2))
+		other = common.BigToAddress(big.NewInt(3))
+		stake = types.NewTransaction(0, from, big.NewInt(1), 21000, big.NewInt(1), nil, types.Main, types.Stake)
+		send  = types.NewTransaction(1, to, big.NewInt(1), 21000, big.NewInt(1), nil, types.Main, types.Main)
+	)
+	tests := []struct {
+		crit PendingTxCriteria
+		tx   *types.Transaction
+		want bool
+	}{
+		{PendingTxCriteria{}, stake, true},
+		{PendingTxCriteria{Target: types.Stake}, stake, true},
+		{PendingTxCriteria{Target: types.Stake}, send, false},
+		{PendingTxCriteria{From: []common.Address{other, from}}, send, true},
+		{PendingTxCriteria{From: []common.Address{other}}, send, false},
+		{PendingTxCriteria{To: []common.Address{to}, Base: types.Main}, send, true},
+		{PendingTxCriteria{To: []common.Address{to}, Base: types.Stake}, send, false},
+	}
+	for i, tt := range tests {
+		if have := tt.crit.matches(from, tt.tx); have != tt.want {
+			t.Errorf("test %d: match mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+func TestFilterStakingEvents(t *testing.T) {
+	var (
+		a      = common.BigToAddress(big.NewInt(1))
+		b      = common.BigToAddress(big.NewInt(2))
+		events = []*StakingEvent{{Kind: StakingJoined, Address: a}, {Kind: StakingLeft, Address: b}}
+	)
+	if matched := filterStakingEvents(events, &StakingCriteria{}); len(matched) != 2 {
+		t.Errorf("expected all events without criteria, got %d", len(matched))
+	}
+	matched := filterStakingEvents(events, &StakingCriteria{Addresses: []common.Address{b}})
+	if len(matched) != 1 || matched[0].Address != b {
+		t.Errorf("unexpected events: %v", matched)
+	}
+}
+
+// stakingBackend serves the states of a block and of its parent to the staking
+// event derivation.
+type stakingBackend struct {
+	Backend
+	db      berithdb.Database
+	config  *params.ChainConfig
+	header  *types.Header
+	current *state.StateDB
+	prev    *state.StateDB
+}
+
+func (b *stakingBackend) ChainDb() berithdb.Database       { return b.db }
+func (b *stakingBackend) ChainConfig() *params.ChainConfig { return b.config }
+
+func (b *stakingBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	if uint64(number) == b.header.Number.Uint64() {
+		return b.current, b.header, nil
+	}
+	return b.prev, nil, nil
+}
+
+// newStakingState returns a state holding the given stake balances.
+func newStakingState(stakes map[common.Address]int64) *state.StateDB {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(berithdb.NewMemDatabase()))
+	for addr, stake := range stakes {
+		statedb.AddBalance(addr, big.NewInt(1))
+		statedb.SetStaking(addr, big.NewInt(stake), big.NewInt(1))
+	}
+	return statedb
+}
+
+// signStakingTx signs a transaction between the given JobWallets.
+func signStakingTx(t *testing.T, config *params.ChainConfig, key *ecdsa.PrivateKey, to common.Address, data []byte, base, target types.JobWallet) *types.Transaction {
+	tx := types.NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(1), data, base, target)
+	tx, err := types.SignTx(tx, types.MakeSigner(config, big.NewInt(2)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// Tests that staking events are derived for the senders of the stake and unstake
+// transactions of a block, and not for the other accounts.
+func TestStakingEvents(t *testing.T) {
+	var (
+		config     = &params.ChainConfig{ChainID: big.NewInt(1)}
+		joinKey, _ = crypto.GenerateKey()
+		leftKey, _ = crypto.GenerateKey()
+		sendKey, _ = crypto.GenerateKey()
+		join       = crypto.PubkeyToAddress(joinKey.PublicKey)
+		left       = crypto.PubkeyToAddress(leftKey.PublicKey)
+		send       = crypto.PubkeyToAddress(sendKey.PublicKey)
+	)
+	txs := []*types.Transaction{
+		signStakingTx(t, config, joinKey, join, nil, types.Main, types.Stake),
+		signStakingTx(t, config, leftKey, left, nil, types.Stake, types.Main),
+		signStakingTx(t, config, sendKey, join, nil, types.Main, types.Main),
+	}
+	header := &types.Header{Number: big.NewInt(2)}
+	block := types.NewBlock(header, txs, nil, nil)
+
+	es := &EventSystem{backend: &stakingBackend{
+		config:  config,
+		header:  block.Header(),
+		prev:    newStakingState(map[common.Address]int64{left: 100, send: 50}),
+		current: newStakingState(map[common.Address]int64{join: 10, send: 60}),
+	}}
+	events, err := es.stakingEvents(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		kind StakingEventKind
+		addr common.Address
+		tx   common.Hash
+	}{
+		{StakingJoined, join, txs[0].Hash()},
+		{StakingLeft, left, txs[1].Hash()},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("event count mismatch: have %d, want %d", len(events), len(want))
+	}
+	for i, ev := range events {
+		if ev.Kind != want[i].kind || ev.Address != want[i].addr || len(ev.TxHashes) != 1 || ev.TxHashes[0] != want[i].tx || ev.BlockHash != block.Hash() {
+			t.Errorf("event %d mismatch: have %s %x, want %s %x", i, ev.Kind, ev.Address, want[i].kind, want[i].addr)
+		}
+	}
+	if events[1].PrevStakeBalance.ToInt().Int64() != 100 || events[1].StakeBalance.ToInt().Sign() != 0 {
+		t.Errorf("stake balance mismatch: have %v -> %v, want 100 -> 0", events[1].PrevStakeBalance, events[1].StakeBalance)
+	}
+}
+
+// Tests that a key migration reports the old key leaving and the new key joining
+// with the migrated stake.
+func TestStakingEventsKeyMigration(t *testing.T) {
+	var (
+		config    = &params.ChainConfig{ChainID: big.NewInt(1), BIP8Block: big.NewInt(0)}
+		oldKey, _ = crypto.GenerateKey()
+		newKey, _ = crypto.GenerateKey()
+		oldAddr   = crypto.PubkeyToAddress(oldKey.PublicKey)
+		newAddr   = crypto.PubkeyToAddress(newKey.PublicKey)
+	)
+	payload, err := types.SignKeyMigration(config.ChainID, oldAddr, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := signStakingTx(t, config, oldKey, types.KeyMigrationAddress, payload, types.Main, types.Main)
+	block := types.NewBlock(&types.Header{Number: big.NewInt(2)}, []*types.Transaction{tx}, nil, nil)
+
+	es := &EventSystem{backend: &stakingBackend{
+		config:  config,
+		header:  block.Header(),
+		prev:    newStakingState(map[common.Address]int64{oldAddr: 100}),
+		current: newStakingState(map[common.Address]int64{newAddr: 100}),
+	}}
+	events, err := es.stakingEvents(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("event count mismatch: have %d, want 2", len(events))
+	}
+	if events[0].Kind != StakingLeft || events[0].Address != oldAddr || events[0].TxHashes[0] != tx.Hash() {
+		t.Errorf("old key event mismatch: have %s %x", events[0].Kind, events[0].Address)
+	}
+	if events[1].Kind != StakingJoined || events[1].Address != newAddr || events[1].TxHashes[0] != tx.Hash() {
+		t.Errorf("new key event mismatch: have %s %x", events[1].Kind, events[1].Address)
+	}
+}
+
+// Tests that the stakers of the parent block compounding their rewards without
+// any transaction get a staking event, and the other stakers don't.
+func TestStakingEventsCompound(t *testing.T) {
+	var (
+		config   = &params.ChainConfig{ChainID: big.NewInt(1), BIP5Block: big.NewInt(0)}
+		compound = common.BigToAddress(big.NewInt(1))
+		manual   = common.BigToAddress(big.NewInt(2))
+		db       = berithdb.NewMemDatabase()
+		parent   = &types.Header{Number: big.NewInt(1)}
+	)
+	list, _ := rlp.EncodeToBytes([]common.Address{compound, manual})
+	rawdb.WriteStakersRLP(db, parent.Hash(), list)
+	block := types.NewBlock(&types.Header{Number: big.NewInt(2), ParentHash: parent.Hash()}, nil, nil, nil)
+
+	prev := newStakingState(map[common.Address]int64{compound: 100, manual: 100})
+	prev.SetAutoCompound(compound, true)
+	current := newStakingState(map[common.Address]int64{compound: 110, manual: 100})
+	current.SetAutoCompound(compound, true)
+
+	es := &EventSystem{backend: &stakingBackend{db: db, config: config, header: block.Header(), prev: prev, current: current}}
+	events, err := es.stakingEvents(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("event count mismatch: have %d, want 1", len(events))
+	}
+	if ev := events[0]; ev.Kind != StakingChanged || ev.Address != compound || ev.TxHashes == nil || len(ev.TxHashes) != 0 || ev.StakeBalance.ToInt().Int64() != 110 {
+		t.Errorf("compound event mismatch: have %s %x %v %v", ev.Kind, ev.Address, ev.TxHashes, ev.StakeBalance)
+	}
+	// Before BIP5 rewards aren't compounded
+	config.BIP5Block = big.NewInt(3)
+	if events, err := es.stakingEvents(block); err != nil || len(events) != 0 {
+		t.Errorf("events before BIP5: have %d (%v), want none", len(events), err)
+	}
+}
+
+// stakingFeedBackend feeds the chain events of a stakingBackend to an event system.
+type stakingFeedBackend struct {
+	*stakingBackend
+	txFeed     event.Feed
+	chainFeed  event.Feed
+	rmLogsFeed event.Feed
+	logsFeed   event.Feed
+}
+
+func (b *stakingFeedBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
+	return b.txFeed.Subscribe(ch)
+}
+func (b *stakingFeedBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
+	return b.chainFeed.Subscribe(ch)
+}
+func (b *stakingFeedBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
+	return b.rmLogsFeed.Subscribe(ch)
+}
+func (b *stakingFeedBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
+	return b.logsFeed.Subscribe(ch)
+}
+
+// Tests that the staking events of an imported block are delivered to the
+// matching subscriptions, after the new head has been delivered.
+func TestSubscribeStakingEvents(t *testing.T) {
+	var (
+		config     = &params.ChainConfig{ChainID: big.NewInt(1)}
+		joinKey, _ = crypto.GenerateKey()
+		leftKey, _ = crypto.GenerateKey()
+		join       = crypto.PubkeyToAddress(joinKey.PublicKey)
+		left       = crypto.PubkeyToAddress(leftKey.PublicKey)
+		mux        = new(event.TypeMux)
+	)
+	defer mux.Stop()
+
+	txs := []*types.Transaction{
+		signStakingTx(t, config, joinKey, join, nil, types.Main, types.Stake),
+		signStakingTx(t, config, leftKey, left, nil, types.Stake, types.Main),
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(2)}, txs, nil, nil)
+	backend := &stakingFeedBackend{stakingBackend: &stakingBackend{
+		config:  config,
+		header:  block.Header(),
+		prev:    newStakingState(map[common.Address]int64{left: 100}),
+		current: newStakingState(map[common.Address]int64{join: 10}),
+	}}
+	es := NewEventSystem(mux, backend, false)
+
+	var (
+		heads  = make(chan *types.Header)
+		events = make(chan []*StakingEvent)
+	)
+	headSub := es.SubscribeNewHeads(heads)
+	defer headSub.Unsubscribe()
+	stakingSub, err := es.SubscribeStakingEvents(StakingCriteria{Addresses: []common.Address{left}}, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stakingSub.Unsubscribe()
+
+	backend.chainFeed.Send(core.ChainEvent{Block: block, Hash: block.Hash()})
+	select {
+	case head := <-heads:
+		if head.Hash() != block.Hash() {
+			t.Fatalf("head mismatch: have %x, want %x", head.Hash(), block.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("new head not delivered")
+	}
+	select {
+	case matched := <-events:
+		if len(matched) != 1 || matched[0].Kind != StakingLeft || matched[0].Address != left {
+			t.Fatalf("staking events mismatch: have %v", matched)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("staking events not delivered")
+	}
+}
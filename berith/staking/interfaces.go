@@ -34,8 +34,9 @@ type StakingInfo interface {
 스테이킹 리스트 데이터 베이스 인터페이스
 */
 type DataBase interface {
-	GetStakers(key string) (Stakers, error)
-	Commit(key string, stks Stakers) error
+	GetStakers(hash common.Hash) (Stakers, error)
+	Commit(hash common.Hash, stks Stakers) error
+	Stage(sealHash common.Hash, stks Stakers) error
 	NewStakers() Stakers
 	Close()
 }
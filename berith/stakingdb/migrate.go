@@ -0,0 +1,69 @@
+package stakingdb
+
+import (
+	"os"
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/common/hexutil"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/log"
+)
+
+/*
+[BERITH]
+이전 버전이 별도의 디렉터리에 저장한 StakingList 를 체인 DB 로 옮기고 디렉터리를 삭제한다.
+체인 DB 에 이미 있는 목록은 덮어쓰지 않으므로 중단된 이전을 다시 실행해도 된다.
+*/
+func Migrate(db berithdb.Database, dir string) error {
+	if dir == "" || !common.FileExist(dir) {
+		return nil
+	}
+	old, err := berithdb.OpenBackend("", dir, 16, 16)
+	if err != nil {
+		return err
+	}
+	var (
+		start    = time.Now()
+		batch    = db.NewBatch()
+		migrated int
+		skipped  int
+	)
+	it := old.NewIterator()
+	for it.Next() {
+		// The lists were stored under the hex encoded hash of their block
+		hash, err := hexutil.Decode(string(it.Key()))
+		if err != nil || len(hash) != common.HashLength {
+			skipped++
+			continue
+		}
+		if rawdb.HasStakers(db, common.BytesToHash(hash)) {
+			continue
+		}
+		rawdb.WriteStakersRLP(batch, common.BytesToHash(hash), common.CopyBytes(it.Value()))
+		migrated++
+
+		if batch.ValueSize() >= berithdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				it.Release()
+				old.Close()
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		old.Close()
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		old.Close()
+		return err
+	}
+	old.Close()
+
+	log.Info("Migrated staking database into the chain database", "lists", migrated, "skipped", skipped, "elapsed", common.PrettyDuration(time.Since(start)))
+	return os.RemoveAll(dir)
+}
@@ -1,18 +1,32 @@
 package stakingdb
 
 import (
-	"fmt"
+	"errors"
 
 	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
 	"github.com/BerithFoundation/berith-chain/rlp"
+	lru "github.com/hashicorp/golang-lru"
 
 	"github.com/BerithFoundation/berith-chain/berith/staking"
 	"github.com/BerithFoundation/berith-chain/berithdb"
 )
 
+// stagedStakers is the number of staking lists of blocks being finalised kept
+// until the blocks are written.
+const stagedStakers = 128
+
+var errNoStakers = errors.New("staking list not found")
+
+/*
+[BERITH]
+StakingList 를 체인 DB 에 블록 해시별로 저장한다.
+블록을 처리하며 만든 StakingList 는 Stage 로 준비해 두었다가 블록과 같은 batch 로 저장한다.
+*/
 type StakingDB struct {
 	creator createFunc
-	stakeDB *berithdb.LDBDatabase
+	db      berithdb.Database
+	staged  *lru.ARCCache // seal hash -> RLP encoded staking list
 }
 
 type createFunc func() staking.Stakers
@@ -20,67 +34,31 @@ type createFunc func() staking.Stakers
 /**
 DB Create
 */
-func (s *StakingDB) CreateDB(filename string, creator createFunc) error {
-	if s.stakeDB != nil {
-		return nil
-	}
-
-	db, err := berithdb.NewLDBDatabase(filename, 128, 1024)
-	if err != nil {
-		fmt.Println(err.Error())
-		return err
-	}
-
-	s.stakeDB = db
-	s.creator = creator
-	return nil
-}
-
-/**
-DB Get Value
-*/
-func (s *StakingDB) getValue(key string) ([]byte, error) {
-	k := []byte(key)
-
-	bt, err := s.stakeDB.Get(k)
-	if err != nil {
-		return nil, err
-	}
-
-	return bt, nil
-}
-
-/**
-DB Insert Value
-*/
-func (s *StakingDB) pushValue(k string, stakers staking.Stakers) error {
-	key := []byte(k)
-
-	v, err := rlp.EncodeToBytes(stakers)
-
-	if err != nil {
-		return err
+func NewStakingDB(db berithdb.Database, creator createFunc) *StakingDB {
+	staged, _ := lru.NewARC(stagedStakers)
+	return &StakingDB{
+		creator: creator,
+		db:      db,
+		staged:  staged,
 	}
-
-	return s.stakeDB.Put(key, v)
 }
 
 /**
 DB Close
+체인 DB 는 소유자가 닫는다.
 */
-func (s *StakingDB) Close() {
-	if s.stakeDB == nil {
-		return
-	}
-	s.stakeDB.Close()
-}
+func (s *StakingDB) Close() {}
 
-func (s *StakingDB) GetStakers(key string) (staking.Stakers, error) {
-	val, err := s.getValue(key)
-	if err != nil {
-		return nil, err
+func (s *StakingDB) GetStakers(hash common.Hash) (staking.Stakers, error) {
+	val := rawdb.ReadStakersRLP(s.db, hash)
+	if val == nil {
+		return nil, errNoStakers
 	}
+	return s.decode(val)
+}
 
+// decode returns the staking list of an RLP encoded address list.
+func (s *StakingDB) decode(val []byte) (staking.Stakers, error) {
 	holder := make([]common.Address, 0)
 
 	if err := rlp.DecodeBytes(val, &holder); err != nil {
@@ -94,14 +72,42 @@ func (s *StakingDB) GetStakers(key string) (staking.Stakers, error) {
 	return stakers, nil
 }
 
-func (s *StakingDB) Commit(key string, value staking.Stakers) error {
-	if err := s.pushValue(key, value); err != nil {
+func (s *StakingDB) Commit(hash common.Hash, value staking.Stakers) error {
+	data, err := rlp.EncodeToBytes(value)
+	if err != nil {
 		return err
 	}
+	rawdb.WriteStakersRLP(s.db, hash, data)
+	return nil
+}
 
+/*
+[BERITH]
+블록이 저장될 때 함께 저장되도록 seal hash 로 StakingList 를 준비해 둔다.
+*/
+func (s *StakingDB) Stage(sealHash common.Hash, value staking.Stakers) error {
+	data, err := rlp.EncodeToBytes(value)
+	if err != nil {
+		return err
+	}
+	s.staged.Add(sealHash, data)
 	return nil
 }
 
+/*
+[BERITH]
+준비해 둔 StakingList 를 블록 해시로 batch 에 쓴다. 준비된 목록이 없으면 false 를 반환한다.
+*/
+func (s *StakingDB) WriteStaged(batch rawdb.DatabaseWriter, sealHash, hash common.Hash) bool {
+	data, ok := s.staged.Get(sealHash)
+	if !ok {
+		return false
+	}
+	rawdb.WriteStakersRLP(batch, hash, data.([]byte))
+	s.staged.Remove(sealHash)
+	return true
+}
+
 func (s *StakingDB) NewStakers() staking.Stakers {
 	return s.creator()
 }
@@ -2,16 +2,19 @@ package stakingdb
 
 import (
 	"errors"
+	"io/ioutil"
 	"os"
 	"testing"
 
 	"github.com/BerithFoundation/berith-chain/berith/staking"
+	"github.com/BerithFoundation/berith-chain/berithdb"
 	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/rlp"
 )
 
 func TestStakers(t *testing.T) {
-	db := new(StakingDB)
-	db.CreateDB(os.TempDir()+"/stakingdb/", staking.NewStakers)
+	db := NewStakingDB(berithdb.NewMemDatabase(), staking.NewStakers)
 
 	err := errors.New("result is incorrect than expected")
 
@@ -49,7 +52,8 @@ func TestStakers(t *testing.T) {
 		t.Error(err)
 	}
 
-	db.Commit("test", stks)
+	key := common.HexToHash("0x01")
+	db.Commit(key, stks)
 
 	stks = db.NewStakers()
 
@@ -57,7 +61,7 @@ func TestStakers(t *testing.T) {
 		t.Error(err)
 	}
 
-	stks, dbErr := db.GetStakers("test")
+	stks, dbErr := db.GetStakers(key)
 
 	if dbErr != nil {
 		t.Error(dbErr)
@@ -68,3 +72,90 @@ func TestStakers(t *testing.T) {
 	}
 
 }
+
+// Tests that a staged staking list is written with the block it belongs to only.
+func TestStageStakers(t *testing.T) {
+	chainDb := berithdb.NewMemDatabase()
+	db := NewStakingDB(chainDb, staking.NewStakers)
+
+	stks := db.NewStakers()
+	stks.Put(common.BytesToAddress([]byte("1")))
+
+	sealHash, hash := common.HexToHash("0x01"), common.HexToHash("0x02")
+	if err := db.Stage(sealHash, stks); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetStakers(hash); err == nil {
+		t.Fatal("staged list readable before the block is written")
+	}
+	batch := chainDb.NewBatch()
+	if db.WriteStaged(batch, common.HexToHash("0x03"), hash) {
+		t.Fatal("unknown seal hash written")
+	}
+	if !db.WriteStaged(batch, sealHash, hash) {
+		t.Fatal("staged list not written")
+	}
+	if _, err := db.GetStakers(hash); err == nil {
+		t.Fatal("staged list readable before the batch is written")
+	}
+	batch.Write()
+
+	list, err := db.GetStakers(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.AsList()) != 1 || !list.IsContain(common.BytesToAddress([]byte("1"))) {
+		t.Fatalf("list mismatch: %v", list.AsList())
+	}
+	if db.WriteStaged(batch, sealHash, hash) {
+		t.Fatal("staged list written twice")
+	}
+}
+
+// Tests that the lists of the former staking database are moved into the chain
+// database, without overwriting those already there.
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stakingdb-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	old, err := berithdb.NewLDBDatabase(dir, 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		migrated = common.HexToHash("0x01")
+		existing = common.HexToHash("0x02")
+	)
+	encode := func(addrs ...common.Address) []byte {
+		data, _ := rlp.EncodeToBytes(addrs)
+		return data
+	}
+	old.Put([]byte(migrated.Hex()), encode(common.BytesToAddress([]byte("1"))))
+	old.Put([]byte(existing.Hex()), encode(common.BytesToAddress([]byte("2"))))
+	old.Put([]byte("test"), encode())
+	old.Close()
+
+	chainDb := berithdb.NewMemDatabase()
+	rawdb.WriteStakersRLP(chainDb, existing, encode(common.BytesToAddress([]byte("3"))))
+
+	if err := Migrate(chainDb, dir); err != nil {
+		t.Fatal(err)
+	}
+	if common.FileExist(dir) {
+		t.Fatal("former staking database not removed")
+	}
+	db := NewStakingDB(chainDb, staking.NewStakers)
+	if list, err := db.GetStakers(migrated); err != nil || !list.IsContain(common.BytesToAddress([]byte("1"))) {
+		t.Fatalf("list not migrated: %v", err)
+	}
+	if list, _ := db.GetStakers(existing); !list.IsContain(common.BytesToAddress([]byte("3"))) {
+		t.Fatal("existing list overwritten")
+	}
+	// Nothing left to migrate
+	if err := Migrate(chainDb, dir); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -57,3 +57,6 @@ func (s *senderFromServer) Hash(tx *types.Transaction) common.Hash {
 func (s *senderFromServer) SignatureValues(tx *types.Transaction, sig []byte) (R, S, V *big.Int, err error) {
 	panic("can't sign with senderFromServer")
 }
+func (s *senderFromServer) Approvers(tx *types.Transaction) ([]common.Address, error) {
+	return nil, types.ErrNotMultisig
+}
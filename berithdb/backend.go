@@ -0,0 +1,108 @@
+package berithdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BerithFoundation/berith-chain/log"
+)
+
+// DefaultBackend is the storage engine of the databases created without an
+// explicit engine.
+const DefaultBackend = "leveldb"
+
+// engineFile is the file of a database directory naming its storage engine.
+const engineFile = "ENGINE"
+
+// Backend opens or creates an on-disk database of a storage engine in the given
+// directory, with the given memory allowance in megabytes and number of file
+// handles as hints.
+type Backend func(file string, cache int, handles int) (Database, error)
+
+// Compacter is implemented by the databases able to flatten their store for a
+// key range. A nil start is treated as a key before all keys, a nil limit as
+// after all keys.
+type Compacter interface {
+	Compact(start []byte, limit []byte) error
+}
+
+var (
+	backendsLock sync.RWMutex
+	backends     = make(map[string]Backend)
+)
+
+// RegisterBackend makes a storage engine available under the given name. It
+// panics if the name is already taken.
+func RegisterBackend(name string, open Backend) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("berithdb: backend %q registered twice", name))
+	}
+	backends[name] = open
+}
+
+// Backends returns the names of the registered storage engines, sorted.
+func Backends() []string {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OpenBackend opens or creates the database of the given directory. The named
+// storage engine only applies to a new database, the default one if the name is
+// empty. An existing database is opened with the engine it was created with.
+func OpenBackend(name string, file string, cache int, handles int) (Database, error) {
+	existing := detectBackend(file)
+	if existing != "" && name != "" && existing != name {
+		log.Warn("Database keeps its own engine", "database", file, "engine", existing, "requested", name)
+	}
+	if existing != "" {
+		name = existing
+	}
+	if name == "" {
+		name = DefaultBackend
+	}
+	backendsLock.RLock()
+	open, ok := backends[name]
+	backendsLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown database engine %q (available: %s)", name, strings.Join(Backends(), ", "))
+	}
+	db, err := open(file, cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	if existing == "" {
+		if err := ioutil.WriteFile(filepath.Join(file, engineFile), []byte(name+"\n"), 0644); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// detectBackend returns the storage engine of the database of the given
+// directory, the empty string if there is no database.
+func detectBackend(file string) string {
+	if data, err := ioutil.ReadFile(filepath.Join(file, engineFile)); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	// Databases created before the engines were selectable are LevelDB ones
+	if _, err := os.Stat(filepath.Join(file, "CURRENT")); err == nil {
+		return DefaultBackend
+	}
+	return ""
+}
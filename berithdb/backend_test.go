@@ -0,0 +1,149 @@
+package berithdb_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/berithdb/dbtest"
+)
+
+// tempDirs creates temporary directories removed when the test ends.
+func tempDirs(t *testing.T) (func() string, func()) {
+	var dirs []string
+	create := func() string {
+		dir, err := ioutil.TempDir("", "berithdb-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, dir)
+		return dir
+	}
+	cleanup := func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}
+	return create, cleanup
+}
+
+func TestMemDatabase(t *testing.T) {
+	dbtest.TestDatabaseSuite(t, func() berithdb.Database {
+		return berithdb.NewMemDatabase()
+	})
+}
+
+// Tests that every registered storage engine passes the conformance suite.
+func TestBackends(t *testing.T) {
+	for _, name := range berithdb.Backends() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			tempDir, cleanup := tempDirs(t)
+			defer cleanup()
+
+			open := func(dir string) (berithdb.Database, error) {
+				return berithdb.OpenBackend(name, dir, 16, 16)
+			}
+			dbtest.TestDatabaseSuite(t, func() berithdb.Database {
+				db, err := open(tempDir())
+				if err != nil {
+					t.Fatal(err)
+				}
+				return db
+			})
+			dbtest.TestDiskSuite(t, open, tempDir)
+		})
+	}
+}
+
+// Tests that a database is reopened by the engine which created it, whether
+// another engine or none is given.
+func TestBackendDetection(t *testing.T) {
+	tempDir, cleanup := tempDirs(t)
+	defer cleanup()
+
+	dir := tempDir()
+	db, err := berithdb.OpenBackend("logdb", dir, 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Put([]byte("key"), []byte("value"))
+	db.Close()
+
+	for _, name := range []string{berithdb.DefaultBackend, ""} {
+		if db, err = berithdb.OpenBackend(name, dir, 16, 16); err != nil {
+			t.Fatalf("engine %q: %v", name, err)
+		}
+		if _, ok := db.(*berithdb.LogDatabase); !ok {
+			t.Fatalf("engine %q: database reopened with the wrong engine: %T", name, db)
+		}
+		if value, _ := db.Get([]byte("key")); string(value) != "value" {
+			t.Fatalf("engine %q: value lost: %q", name, value)
+		}
+		db.Close()
+	}
+	// A new database is created by the requested engine
+	if db, err = berithdb.OpenBackend("logdb", tempDir(), 16, 16); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, ok := db.(*berithdb.LogDatabase); !ok {
+		t.Fatalf("new database created with the wrong engine: %T", db)
+	}
+	if _, err := berithdb.OpenBackend("unknown", tempDir(), 16, 16); err == nil {
+		t.Fatal("unknown engine accepted")
+	}
+}
+
+// Tests that a frame partially written to the log is dropped on open, and that
+// compaction keeps the live data only.
+func TestLogDatabaseRecovery(t *testing.T) {
+	tempDir, cleanup := tempDirs(t)
+	defer cleanup()
+
+	dir := tempDir()
+	db, err := berithdb.NewLogDatabase(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		db.Put([]byte("key"), bytes.Repeat([]byte{byte(i)}, 100))
+	}
+	db.Put([]byte("torn"), []byte("value"))
+	db.Close()
+
+	// Cut the last frame in the middle
+	path := filepath.Join(dir, "data.log")
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = berithdb.NewLogDatabase(dir); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := db.Has([]byte("torn")); has {
+		t.Fatal("torn write recovered")
+	}
+	if err := db.Compact(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := db.Get([]byte("key")); !bytes.Equal(value, bytes.Repeat([]byte{99}, 100)) {
+		t.Fatalf("value lost by compaction: %x", value)
+	}
+	db.Put([]byte("after"), []byte("compaction"))
+	db.Close()
+
+	if info, _ := os.Stat(path); info.Size() > 1000 {
+		t.Fatalf("log not compacted: %d bytes", info.Size())
+	}
+	if db, err = berithdb.NewLogDatabase(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if value, _ := db.Get([]byte("after")); string(value) != "compaction" {
+		t.Fatalf("write after compaction lost: %q", value)
+	}
+}
@@ -56,6 +56,16 @@ func (db *LDBDatabase) Delete(key []byte) error {
 	return errNotSupported
 }
 
+// NewIterator returns a iterator to iterate over the entire database content.
+func (db *LDBDatabase) NewIterator() Iterator {
+	return nil
+}
+
+// NewIteratorWithPrefix returns a iterator to iterate over subset of database content with a particular prefix.
+func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return nil
+}
+
 func (db *LDBDatabase) Close() {
 }
 
@@ -0,0 +1,280 @@
+// Package dbtest contains the conformance tests every berithdb storage engine
+// must pass.
+package dbtest
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+)
+
+// TestDatabaseSuite runs the tests of the key-value operations, batches and
+// iterators on the databases created by New, every test using a fresh one.
+func TestDatabaseSuite(t *testing.T, New func() berithdb.Database) {
+	t.Run("PutGetDelete", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		if _, err := db.Get([]byte("missing")); err == nil {
+			t.Fatal("missing key retrieved")
+		}
+		if has, err := db.Has([]byte("missing")); err != nil || has {
+			t.Fatalf("missing key present: %v, err %v", has, err)
+		}
+		for _, value := range []string{"", "a", "1251", "\x00123\x00"} {
+			if err := db.Put([]byte("key"), []byte(value)); err != nil {
+				t.Fatalf("put failed: %v", err)
+			}
+			data, err := db.Get([]byte("key"))
+			if err != nil || !bytes.Equal(data, []byte(value)) {
+				t.Fatalf("value mismatch: have %q, want %q, err %v", data, value, err)
+			}
+		}
+		if err := db.Delete([]byte("key")); err != nil {
+			t.Fatalf("delete failed: %v", err)
+		}
+		if has, _ := db.Has([]byte("key")); has {
+			t.Fatal("deleted key present")
+		}
+		if err := db.Delete([]byte("missing")); err != nil {
+			t.Fatalf("delete of a missing key failed: %v", err)
+		}
+	})
+
+	t.Run("Copies", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		key, value := []byte("key"), []byte("value")
+		db.Put(key, value)
+		key[0], value[0] = 'x', 'x'
+
+		data, err := db.Get([]byte("key"))
+		if err != nil || !bytes.Equal(data, []byte("value")) {
+			t.Fatalf("stored value changed by the caller: %q, err %v", data, err)
+		}
+		data[0] = 'x'
+		if data, _ := db.Get([]byte("key")); !bytes.Equal(data, []byte("value")) {
+			t.Fatalf("stored value changed through a returned one: %q", data)
+		}
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		db.Put([]byte("deleted"), []byte("1"))
+		batch := db.NewBatch()
+		batch.Put([]byte("a"), []byte("12"))
+		batch.Put([]byte("b"), []byte("345"))
+		batch.Delete([]byte("deleted"))
+		if batch.ValueSize() < 5 {
+			t.Fatalf("batch size too small: %d", batch.ValueSize())
+		}
+		if has, _ := db.Has([]byte("a")); has {
+			t.Fatal("batch content visible before the write")
+		}
+		if err := batch.Write(); err != nil {
+			t.Fatalf("batch write failed: %v", err)
+		}
+		checkContent(t, db, nil, map[string]string{"a": "12", "b": "345"})
+
+		// A reset batch starts empty and can be reused
+		batch.Reset()
+		if batch.ValueSize() != 0 {
+			t.Fatalf("reset batch not empty: %d", batch.ValueSize())
+		}
+		batch.Put([]byte("c"), []byte("6"))
+		if err := batch.Write(); err != nil {
+			t.Fatalf("batch write failed: %v", err)
+		}
+		checkContent(t, db, nil, map[string]string{"a": "12", "b": "345", "c": "6"})
+
+		// The last operation on a key wins
+		batch.Reset()
+		batch.Put([]byte("d"), []byte("7"))
+		batch.Delete([]byte("d"))
+		batch.Put([]byte("a"), []byte("8"))
+		batch.Write()
+		checkContent(t, db, nil, map[string]string{"a": "8", "b": "345", "c": "6"})
+	})
+
+	t.Run("Iterator", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		content := map[string]string{"": "0", "1": "a", "10": "b", "2": "c", "20": "d", "3": "e", "\xff": "f"}
+		for key, value := range content {
+			db.Put([]byte(key), []byte(value))
+		}
+		checkContent(t, db, nil, content)
+		checkContent(t, db, []byte("1"), map[string]string{"1": "a", "10": "b"})
+		checkContent(t, db, []byte("20"), map[string]string{"20": "d"})
+		checkContent(t, db, []byte("4"), map[string]string{})
+
+		// An exhausted iterator stays exhausted
+		it := db.NewIteratorWithPrefix([]byte("3"))
+		for it.Next() {
+		}
+		if it.Next() || it.Key() != nil || it.Value() != nil {
+			t.Fatal("exhausted iterator moved")
+		}
+		it.Release()
+	})
+
+	t.Run("IteratorSnapshot", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		db.Put([]byte("a"), []byte("1"))
+		db.Put([]byte("b"), []byte("2"))
+
+		it := db.NewIterator()
+		defer it.Release()
+
+		db.Put([]byte("a"), []byte("3"))
+		db.Put([]byte("c"), []byte("4"))
+		db.Delete([]byte("b"))
+
+		var have []string
+		for it.Next() {
+			have = append(have, fmt.Sprintf("%s=%s", it.Key(), it.Value()))
+		}
+		if err := it.Error(); err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"a=1", "b=2"}; fmt.Sprint(have) != fmt.Sprint(want) {
+			t.Fatalf("iterator not isolated from later writes: have %v, want %v", have, want)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					key := []byte(fmt.Sprintf("%d-%03d", i, j))
+					db.Put(key, key)
+					if data, err := db.Get(key); err != nil || !bytes.Equal(data, key) {
+						t.Errorf("concurrent value mismatch: have %q, want %q, err %v", data, key, err)
+						return
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		count := 0
+		it := db.NewIterator()
+		for it.Next() {
+			count++
+		}
+		it.Release()
+		if count != 800 {
+			t.Fatalf("entry count mismatch: have %d, want 800", count)
+		}
+	})
+}
+
+// TestDiskSuite runs the tests of the persistence and close semantics of an
+// on-disk storage engine, open opening or creating the database of a directory.
+func TestDiskSuite(t *testing.T, open func(dir string) (berithdb.Database, error), tempDir func() string) {
+	t.Run("Reopen", func(t *testing.T) {
+		dir := tempDir()
+		db, err := open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Put([]byte("a"), []byte("1"))
+		db.Put([]byte("b"), []byte("2"))
+		db.Delete([]byte("b"))
+
+		batch := db.NewBatch()
+		batch.Put([]byte("c"), []byte("3"))
+		batch.Write()
+
+		// A batch not written is lost
+		batch.Reset()
+		batch.Put([]byte("d"), []byte("4"))
+		db.Close()
+
+		if db, err = open(dir); err != nil {
+			t.Fatalf("reopen failed: %v", err)
+		}
+		defer db.Close()
+		checkContent(t, db, nil, map[string]string{"a": "1", "c": "3"})
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		db, err := open(tempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Put([]byte("a"), []byte("1"))
+		batch := db.NewBatch()
+		batch.Put([]byte("b"), []byte("2"))
+
+		db.Close()
+		if _, err := db.Get([]byte("a")); err == nil {
+			t.Error("get succeeded on a closed database")
+		}
+		if err := db.Put([]byte("c"), []byte("3")); err == nil {
+			t.Error("put succeeded on a closed database")
+		}
+		if err := batch.Write(); err == nil {
+			t.Error("batch write succeeded on a closed database")
+		}
+		it := db.NewIterator()
+		if it.Next() {
+			t.Error("iterator of a closed database moved")
+		}
+		it.Release()
+
+		// Closing again is harmless
+		db.Close()
+	})
+}
+
+// checkContent checks that the entries of the database with the given prefix are
+// exactly the expected ones, both by iteration and by key.
+func checkContent(t *testing.T, db berithdb.Database, prefix []byte, want map[string]string) {
+	t.Helper()
+
+	keys := make([]string, 0, len(want))
+	for key, value := range want {
+		keys = append(keys, key)
+		if data, err := db.Get([]byte(key)); err != nil || !bytes.Equal(data, []byte(value)) {
+			t.Errorf("value of %q mismatch: have %q, want %q, err %v", key, data, value, err)
+		}
+	}
+	sort.Strings(keys)
+
+	it := db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	var i int
+	for ; it.Next(); i++ {
+		if i >= len(keys) {
+			t.Errorf("unexpected entry %q", it.Key())
+			continue
+		}
+		if string(it.Key()) != keys[i] || string(it.Value()) != want[keys[i]] {
+			t.Errorf("entry %d mismatch: have %q=%q, want %q=%q", i, it.Key(), it.Value(), keys[i], want[keys[i]])
+		}
+	}
+	if err := it.Error(); err != nil {
+		t.Errorf("iteration failed: %v", err)
+	}
+	if i < len(keys) {
+		t.Errorf("iteration ended after %d of %d entries", i, len(keys))
+	}
+}
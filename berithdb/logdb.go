@@ -0,0 +1,499 @@
+package berithdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/log"
+)
+
+/*
+[BERITH]
+저장 엔진 비교를 위한 두번째 디스크 엔진
+모든 쓰기를 하나의 로그 파일에 이어 쓰고, 키의 위치는 메모리 인덱스에 둔다
+*/
+
+const (
+	logFileName = "data.log"
+
+	logOpPut    = 0
+	logOpDelete = 1
+
+	// logFrameHeader is the size of the header of a log frame: the length and the
+	// CRC32 checksum of the payload.
+	logFrameHeader = 8
+
+	// logCompactThreshold is the log size above which the log is rewritten on open
+	// if most of it is overwritten or deleted data.
+	logCompactThreshold = 64 * 1024 * 1024
+)
+
+var (
+	errLogClosed  = errors.New("logdb: closed")
+	errLogCorrupt = errors.New("logdb: corrupted frame")
+	errNotFound   = errors.New("not found")
+)
+
+func init() {
+	RegisterBackend("logdb", func(file string, cache int, handles int) (Database, error) {
+		return NewLogDatabase(file)
+	})
+}
+
+// logEntry is the location of a live value in the log file.
+type logEntry struct {
+	offset int64
+	length int
+}
+
+// LogDatabase is an append-only log storage engine. Every write appends a frame
+// holding a batch of puts and deletes to the log file, a batch being applied
+// atomically, and an in-memory index maps every key to the location of its value.
+// The keys must fit in memory. The overwritten data is reclaimed by Compact, or
+// when the database is opened if it takes most of the log.
+type LogDatabase struct {
+	fn      string
+	file    *os.File
+	retired []*os.File // Files replaced by compactions, still read by iterators
+	size    int64      // End of the last complete frame of the log
+	garbage int64      // Bytes of the log holding overwritten or deleted data
+
+	index  map[string]logEntry
+	closed bool
+	lock   sync.RWMutex
+
+	log log.Logger
+}
+
+// NewLogDatabase opens or creates the log database of the given directory. A
+// partially written frame at the end of the log, e.g. after a crash, is dropped.
+func NewLogDatabase(dir string) (*LogDatabase, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	db := &LogDatabase{
+		fn:    dir,
+		file:  file,
+		index: make(map[string]logEntry),
+		log:   log.New("database", dir),
+	}
+	if err := db.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if db.size >= logCompactThreshold && db.garbage*2 > db.size {
+		if err := db.Compact(nil, nil); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	db.log.Info("Opened log database", "size", common.StorageSize(db.size), "keys", len(db.index))
+	return db, nil
+}
+
+// replay rebuilds the index from the frames of the log, truncating a trailing
+// incomplete or corrupted frame.
+func (db *LogDatabase) replay() error {
+	if _, err := db.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var (
+		reader = bufio.NewReaderSize(db.file, 1024*1024)
+		header = make([]byte, logFrameHeader)
+	)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(header[:4]))
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+			break
+		}
+		if err := db.apply(payload, db.size+logFrameHeader); err != nil {
+			break
+		}
+		db.size += logFrameHeader + int64(len(payload))
+	}
+	info, err := db.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > db.size {
+		db.log.Warn("Dropping incomplete log frames", "offset", db.size, "size", info.Size())
+		if err := db.file.Truncate(db.size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeLogOps calls fn for every operation of a frame payload, with the offset
+// of the value within the payload.
+func decodeLogOps(payload []byte, fn func(op byte, key []byte, offset int, length int)) error {
+	for pos := 0; pos < len(payload); {
+		op := payload[pos]
+		pos++
+		keyLen, n := binary.Uvarint(payload[pos:])
+		if n <= 0 || uint64(len(payload)-pos-n) < keyLen {
+			return errLogCorrupt
+		}
+		pos += n
+		key := payload[pos : pos+int(keyLen)]
+		pos += int(keyLen)
+
+		switch op {
+		case logOpPut:
+			valueLen, n := binary.Uvarint(payload[pos:])
+			if n <= 0 || uint64(len(payload)-pos-n) < valueLen {
+				return errLogCorrupt
+			}
+			pos += n
+			fn(op, key, pos, int(valueLen))
+			pos += int(valueLen)
+		case logOpDelete:
+			fn(op, key, 0, 0)
+		default:
+			return errLogCorrupt
+		}
+	}
+	return nil
+}
+
+// apply updates the index with the operations of a frame payload stored at the
+// given offset of the log.
+func (db *LogDatabase) apply(payload []byte, offset int64) error {
+	if err := decodeLogOps(payload, func(op byte, key []byte, _ int, _ int) {}); err != nil {
+		return err
+	}
+	return decodeLogOps(payload, func(op byte, key []byte, pos int, length int) {
+		if old, ok := db.index[string(key)]; ok {
+			db.garbage += int64(len(key) + old.length)
+		}
+		if op == logOpDelete {
+			delete(db.index, string(key))
+			db.garbage += int64(len(key))
+			return
+		}
+		db.index[string(key)] = logEntry{offset: offset + int64(pos), length: length}
+	})
+}
+
+// appendLogOp encodes an operation into a frame payload.
+func appendLogOp(payload []byte, op byte, key []byte, value []byte) []byte {
+	var buf [binary.MaxVarintLen64]byte
+
+	payload = append(payload, op)
+	payload = append(payload, buf[:binary.PutUvarint(buf[:], uint64(len(key)))]...)
+	payload = append(payload, key...)
+	if op == logOpPut {
+		payload = append(payload, buf[:binary.PutUvarint(buf[:], uint64(len(value)))]...)
+		payload = append(payload, value...)
+	}
+	return payload
+}
+
+// write appends a frame with the given payload to the log and applies it.
+func (db *LogDatabase) write(payload []byte) error {
+	frame := make([]byte, logFrameHeader+len(payload))
+	binary.BigEndian.PutUint32(frame[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:], crc32.ChecksumIEEE(payload))
+	copy(frame[logFrameHeader:], payload)
+
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.closed {
+		return errLogClosed
+	}
+	if _, err := db.file.WriteAt(frame, db.size); err != nil {
+		return err
+	}
+	if err := db.apply(payload, db.size+logFrameHeader); err != nil {
+		return err
+	}
+	db.size += int64(len(frame))
+	return nil
+}
+
+// Path returns the path to the database directory.
+func (db *LogDatabase) Path() string {
+	return db.fn
+}
+
+// Put inserts the given value into the database.
+func (db *LogDatabase) Put(key []byte, value []byte) error {
+	return db.write(appendLogOp(nil, logOpPut, key, value))
+}
+
+// Delete removes the key from the database.
+func (db *LogDatabase) Delete(key []byte) error {
+	return db.write(appendLogOp(nil, logOpDelete, key, nil))
+}
+
+// Has retrieves whether a key is present in the database.
+func (db *LogDatabase) Has(key []byte) (bool, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.closed {
+		return false, errLogClosed
+	}
+	_, ok := db.index[string(key)]
+	return ok, nil
+}
+
+// Get retrieves the value of the given key if it's present.
+func (db *LogDatabase) Get(key []byte) ([]byte, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.closed {
+		return nil, errLogClosed
+	}
+	entry, ok := db.index[string(key)]
+	if !ok {
+		return nil, errNotFound
+	}
+	value := make([]byte, entry.length)
+	if _, err := db.file.ReadAt(value, entry.offset); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// NewIterator returns a iterator to iterate over the entire database content.
+func (db *LogDatabase) NewIterator() Iterator {
+	return db.NewIteratorWithPrefix(nil)
+}
+
+// NewIteratorWithPrefix returns a iterator to iterate over subset of database
+// content with a particular prefix. The iterator walks a snapshot of the
+// content, the writes done after its creation are not visible to it.
+func (db *LogDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.closed {
+		return &logIterator{err: errLogClosed, index: -1}
+	}
+	pr := string(prefix)
+	keys := make([]string, 0, len(db.index))
+	for key := range db.index {
+		if strings.HasPrefix(key, pr) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	entries := make([]logEntry, len(keys))
+	for i, key := range keys {
+		entries[i] = db.index[key]
+	}
+	return &logIterator{file: db.file, keys: keys, entries: entries, index: -1}
+}
+
+// NewBatch creates a batch of writes applied atomically to the database.
+func (db *LogDatabase) NewBatch() Batch {
+	return &logBatch{db: db}
+}
+
+// Compact rewrites the log with only the live values, reclaiming the space of
+// the overwritten and deleted data. The whole log is rewritten whatever the
+// given range.
+func (db *LogDatabase) Compact(start []byte, limit []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.closed {
+		return errLogClosed
+	}
+	keys := make([]string, 0, len(db.index))
+	for key := range db.index {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tmp := filepath.Join(db.fn, logFileName+".tmp")
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	var (
+		index   = make(map[string]logEntry, len(keys))
+		size    int64
+		payload []byte
+		pending []string
+	)
+	flush := func() error {
+		if len(payload) == 0 {
+			return nil
+		}
+		frame := make([]byte, logFrameHeader, logFrameHeader+len(payload))
+		binary.BigEndian.PutUint32(frame[:4], uint32(len(payload)))
+		binary.BigEndian.PutUint32(frame[4:], crc32.ChecksumIEEE(payload))
+		if _, err := file.WriteAt(append(frame, payload...), size); err != nil {
+			return err
+		}
+		i := 0
+		decodeLogOps(payload, func(op byte, key []byte, pos int, length int) {
+			index[pending[i]] = logEntry{offset: size + logFrameHeader + int64(pos), length: length}
+			i++
+		})
+		size += logFrameHeader + int64(len(payload))
+		payload, pending = payload[:0], pending[:0]
+		return nil
+	}
+	for _, key := range keys {
+		entry := db.index[key]
+		value := make([]byte, entry.length)
+		if _, err := db.file.ReadAt(value, entry.offset); err != nil {
+			file.Close()
+			return err
+		}
+		payload = appendLogOp(payload, logOpPut, []byte(key), value)
+		pending = append(pending, key)
+		if len(payload) >= IdealBatchSize {
+			if err := flush(); err != nil {
+				file.Close()
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(db.fn, logFileName)); err != nil {
+		file.Close()
+		return err
+	}
+	db.log.Info("Compacted log database", "size", common.StorageSize(size), "reclaimed", common.StorageSize(db.size-size))
+
+	db.retired = append(db.retired, db.file)
+	db.file, db.index, db.size, db.garbage = file, index, size, 0
+	return nil
+}
+
+// Close flushes the log to disk and closes the database. Operations on a closed
+// database fail, as do the reads of its iterators.
+func (db *LogDatabase) Close() {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.closed {
+		return
+	}
+	db.closed = true
+	if err := db.file.Sync(); err != nil {
+		db.log.Error("Failed to flush database", "err", err)
+	}
+	for _, file := range append(db.retired, db.file) {
+		file.Close()
+	}
+	db.retired, db.index = nil, nil
+	db.log.Info("Database closed")
+}
+
+// logBatch is a write-only batch applied atomically to a log database.
+type logBatch struct {
+	db      *LogDatabase
+	payload []byte
+	size    int
+}
+
+func (b *logBatch) Put(key, value []byte) error {
+	b.payload = appendLogOp(b.payload, logOpPut, key, value)
+	b.size += len(value)
+	return nil
+}
+
+func (b *logBatch) Delete(key []byte) error {
+	b.payload = appendLogOp(b.payload, logOpDelete, key, nil)
+	b.size += 1
+	return nil
+}
+
+func (b *logBatch) Write() error {
+	if len(b.payload) == 0 {
+		return nil
+	}
+	return b.db.write(b.payload)
+}
+
+func (b *logBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *logBatch) Reset() {
+	b.payload = b.payload[:0]
+	b.size = 0
+}
+
+// logIterator is an iterator over a snapshot of the keys of a log database, the
+// values being read from the log on demand.
+type logIterator struct {
+	file    *os.File
+	keys    []string
+	entries []logEntry
+	index   int
+	value   []byte
+	err     error
+}
+
+func (it *logIterator) Next() bool {
+	if it.err != nil || it.index >= len(it.keys) {
+		return false
+	}
+	it.index++
+	it.value = nil
+	if it.index >= len(it.keys) {
+		return false
+	}
+	entry := it.entries[it.index]
+	it.value = make([]byte, entry.length)
+	if _, err := it.file.ReadAt(it.value, entry.offset); err != nil {
+		it.err, it.value = err, nil
+		it.index = len(it.keys)
+		return false
+	}
+	return true
+}
+
+func (it *logIterator) Error() error {
+	return it.err
+}
+
+func (it *logIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return []byte(it.keys[it.index])
+}
+
+func (it *logIterator) Value() []byte {
+	return it.value
+}
+
+func (it *logIterator) Release() {
+	it.keys, it.entries, it.value = nil, nil, nil
+}
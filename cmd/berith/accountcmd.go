@@ -110,6 +110,9 @@ Print a short summary of all accounts`,
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.MnemonicFlag,
+					utils.MnemonicPasswordFileFlag,
+					utils.HDPathFlag,
 				},
 				Description: `
     berith account new
@@ -124,6 +127,14 @@ For non-interactive use the passphrase can be specified with the --password flag
 
 Note, this is meant to be used for testing only, it is a bad idea to save your
 password to file or expose in any other way.
+
+    berith account new --mnemonic
+
+Derives the account from a newly generated 24 word BIP-39 mnemonic and prints
+the mnemonic once. Write it down, it is the only way to recover the account
+with "berith account recover" if the key file is lost. The mnemonic can be
+protected by an additional passphrase read from the --mnemonic.password file,
+and the key is derived at the --hdpath derivation path.
 `,
 			},
 			{
@@ -186,6 +197,33 @@ Note:
 As you can directly copy your encrypted accounts to another berith instance,
 this import mechanism is not needed when you transfer an account between
 nodes.
+`,
+			},
+			{
+				Name:   "recover",
+				Usage:  "Recover an account from a BIP-39 mnemonic",
+				Action: utils.MigrateFlags(accountRecover),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+					utils.MnemonicPasswordFileFlag,
+					utils.HDPathFlag,
+				},
+				ArgsUsage: "[ <mnemonicFile> ]",
+				Description: `
+    berith account recover [<mnemonicfile>]
+
+Derives the account of a BIP-39 mnemonic and stores it as a new account.
+Prints the address.
+
+The mnemonic is read from <mnemonicfile> if given, otherwise you are prompted
+for it. If the mnemonic was protected by a passphrase, it is read from the
+--mnemonic.password file. Accounts other than the first one of the mnemonic
+are recovered with the --hdpath flag, e.g. --hdpath "m/44'/60'/0'/0/1".
+
+The account is saved in encrypted format, you are prompted for a passphrase.
 `,
 			},
 		},
@@ -307,6 +345,18 @@ func accountCreate(ctx *cli.Context) error {
 
 	password := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
 
+	if ctx.GlobalBool(utils.MnemonicFlag.Name) {
+		ks := keystore.NewKeyStore(keydir, scryptN, scryptP)
+		account, mnemonic, err := ks.NewMnemonicAccount(utils.MakeMnemonicPassphrase(ctx), utils.MakeDerivationPath(ctx), password)
+		if err != nil {
+			utils.Fatalf("Failed to create account: %v", err)
+		}
+		fmt.Printf("Address: {%x}\n", account.Address)
+		fmt.Printf("\nMnemonic: %s\n\n", mnemonic)
+		fmt.Println("Write the mnemonic down and keep it safe, it will not be shown again.")
+		fmt.Println("Anyone knowing it can take over the account.")
+		return nil
+	}
 	address, err := keystore.StoreKey(keydir, password, scryptN, scryptP)
 
 	if err != nil {
@@ -377,3 +427,33 @@ func accountImport(ctx *cli.Context) error {
 	fmt.Printf("Address: {%x}\n", acct.Address)
 	return nil
 }
+
+func accountRecover(ctx *cli.Context) error {
+	var mnemonic string
+	if file := ctx.Args().First(); file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			utils.Fatalf("Failed to read the mnemonic: %v", err)
+		}
+		mnemonic = string(content)
+	} else {
+		input, err := console.Stdin.PromptPassword("Mnemonic: ")
+		if err != nil {
+			utils.Fatalf("Failed to read the mnemonic: %v", err)
+		}
+		mnemonic = input
+	}
+	if err := keystore.ValidateMnemonic(mnemonic); err != nil {
+		utils.Fatalf("%v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	passphrase := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	acct, err := ks.ImportMnemonic(mnemonic, utils.MakeMnemonicPassphrase(ctx), utils.MakeDerivationPath(ctx), passphrase)
+	if err != nil {
+		utils.Fatalf("Could not recover the account: %v", err)
+	}
+	fmt.Printf("Address: {%x}\n", acct.Address)
+	return nil
+}
@@ -27,8 +27,11 @@ import (
 
 	"github.com/BerithFoundation/berith-chain/cmd/utils"
 	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/common/hexutil"
 	"github.com/BerithFoundation/berith-chain/console"
 	"github.com/BerithFoundation/berith-chain/core"
+	"github.com/BerithFoundation/berith-chain/core/checkpoint"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
 	"github.com/BerithFoundation/berith-chain/core/state"
 	"github.com/BerithFoundation/berith-chain/core/types"
 	"github.com/BerithFoundation/berith-chain/berith/downloader"
@@ -56,6 +59,45 @@ This is a destructive action and changes the network in which you will be
 participating.
 
 It expects the genesis file as argument.`,
+	}
+	initFromSnapshotCommand = cli.Command{
+		Action:    utils.MigrateFlags(initFromSnapshot),
+		Name:      "init-from-snapshot",
+		Usage:     "Bootstrap a new node from a trusted state snapshot",
+		ArgsUsage: "<filename>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.AncientThresholdFlag,
+			utils.DBEngineFlag,
+			utils.SyncModeFlag,
+			utils.NetworkIdFlag,
+			utils.TestnetFlag,
+			utils.SnapshotHashFlag,
+			utils.SnapshotRootFlag,
+			utils.SnapshotTDFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+berith init-from-snapshot --snapshot.hash <hash> --snapshot.td <td> <filename>
+
+The init-from-snapshot command starts the chain of a new node at the block of a
+snapshot file written by "berith snapshot export", instead of the genesis. The
+node then syncs forward from that block as usual.
+
+The blocks of the snapshot must lead to the trusted block hash --snapshot.hash,
+or at least to a block of the trusted state root --snapshot.root, with the
+trusted total difficulty --snapshot.td, and every state of the snapshot is
+rebuilt and checked against the root of its block. The export logs the hash,
+the state root and the total difficulty of the snapshot block. The
+staking lists can't be checked this way, a wrong list makes the following
+blocks fail to import.
+
+The database must only hold the genesis of the network, which is written if
+missing. Blocks below the snapshot are not available to the node, and the
+transactions of the snapshot blocks have no receipts and can't be looked up.
+Since the ancient store can't start above the genesis, the command and every
+later run of the node require --ancient.threshold 0.`,
 	}
 	importCommand = cli.Command{
 		Action:    utils.MigrateFlags(importChain),
@@ -64,6 +106,7 @@ It expects the genesis file as argument.`,
 		ArgsUsage: "<filename> (<filename 2> ... <filename N>) ",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
@@ -85,6 +128,7 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 		ArgsUsage: "<filename> [<blockNumFirst> <blockNumLast>]",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 		},
@@ -95,6 +139,89 @@ Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing. If the file ends with .gz, the output will
 be gzipped.`,
+	}
+	importArchiveCommand = cli.Command{
+		Action:    utils.MigrateFlags(importArchive),
+		Name:      "import-archive",
+		Usage:     "Import a blockchain archive",
+		ArgsUsage: "<directory>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.GCModeFlag,
+			utils.CacheDatabaseFlag,
+			utils.CacheGCFlag,
+			utils.NetworkIdFlag,
+			utils.TestnetFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The import-archive command imports the blocks of an archive created by
+export-archive. The archive must belong to the network and the genesis of the
+node.
+
+The chunks of the archive are read and verified in parallel against the hashes
+of its manifest, then their blocks are inserted in order. The staking lists of
+the archive are checked against the ones derived from the inserted blocks, and
+ignored for the blocks the node already had. An interrupted import resumes
+after the last chunk imported.`,
+	}
+	exportArchiveCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportArchive),
+		Name:      "export-archive",
+		Usage:     "Export blockchain into an archive",
+		ArgsUsage: "<directory> [<blockNumFirst> <blockNumLast>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.NetworkIdFlag,
+			utils.TestnetFlag,
+			utils.ArchiveChunkSizeFlag,
+			utils.ArchiveCompressionFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Requires a first argument of the directory to create the archive in.
+Optional second and third arguments control the first and last block
+to export, by default all the blocks after the genesis.
+
+The archive holds a manifest.json file describing the network, the
+genesis and the block range of the archive, and the block range and
+content hash of every chunk file. The chunks hold the blocks and the
+staking lists of the blocks at the epoch boundaries, gzip compressed
+unless --archive.compression is none.`,
+	}
+	exportStateDiffsCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportStateDiffs),
+		Name:      "export-statediffs",
+		Usage:     "Export the state changes of blocks into a file",
+		ArgsUsage: "<filename> [<blockNumFirst> <blockNumLast>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.StateDiffsFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Requires a first argument of the file to write to.
+Optional second and third arguments control the first and
+last block to export, by default all the blocks after the genesis.
+
+Every block is written as a JSON line listing the accounts it changed,
+with the before and after values of their fields, including the stake,
+point and reward changes of the consensus engine, and of their storage
+slots. The blocks are re-executed on the state of their parent, which
+must be available. The file will be appended if already existing. If the
+file ends with .gz, the output will be gzipped.
+
+With --statediffs, the diffs are also stored into the state diff database
+of the node, so that debug_stateDiff serves them without re-execution.`,
 	}
 	importPreimagesCommand = cli.Command{
 		Action:    utils.MigrateFlags(importPreimages),
@@ -103,6 +230,7 @@ be gzipped.`,
 		ArgsUsage: "<datafile>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 		},
@@ -117,6 +245,7 @@ be gzipped.`,
 		ArgsUsage: "<dumpfile>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 		},
@@ -131,6 +260,7 @@ The export-preimages command export hash preimages to an RLP encoded stream`,
 		ArgsUsage: "<sourceChaindataDir>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 			utils.FakePoWFlag,
@@ -147,6 +277,7 @@ The first argument must be the directory containing the blockchain to download f
 		ArgsUsage: " ",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -159,6 +290,7 @@ Remove blockchain and state databases`,
 		ArgsUsage: "[<blockHash> | <blockNum>]...",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 		},
@@ -203,6 +335,65 @@ func initGenesis(ctx *cli.Context) error {
 	return nil
 }
 
+// initFromSnapshot starts the chain of an empty database at the block of a
+// trusted state snapshot.
+func initFromSnapshot(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	hash := parseTrustedHash(ctx, utils.SnapshotHashFlag)
+	root := parseTrustedHash(ctx, utils.SnapshotRootFlag)
+	if hash == (common.Hash{}) && root == (common.Hash{}) {
+		utils.Fatalf("A trusted --%s or --%s is required", utils.SnapshotHashFlag.Name, utils.SnapshotRootFlag.Name)
+	}
+	td := utils.GlobalBig(ctx, utils.SnapshotTDFlag.Name)
+	if td == nil || td.Sign() == 0 {
+		utils.Fatalf("A trusted --%s is required", utils.SnapshotTDFlag.Name)
+	}
+	if ctx.GlobalUint64(utils.AncientThresholdFlag.Name) != 0 {
+		utils.Fatalf("A chain started from a snapshot can't use the ancient store, set --%s 0", utils.AncientThresholdFlag.Name)
+	}
+	stack, cfg := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	config, genesis, err := core.SetupGenesisBlock(chainDb, utils.MakeGenesis(ctx))
+	if err != nil {
+		utils.Fatalf("Failed to write genesis block: %v", err)
+	}
+	var epoch uint64
+	if config.Bsrr != nil {
+		epoch = config.Bsrr.Epoch
+	}
+	fh, err := os.Open(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer fh.Close()
+
+	start := time.Now()
+	block, err := checkpoint.Import(chainDb, fh, cfg.Ber.NetworkId, epoch, hash, root, td)
+	if err != nil {
+		utils.Fatalf("Import error: %v", err)
+	}
+	log.Info("Successfully bootstrapped chain from snapshot", "genesis", genesis, "number", block.Number(), "hash", block.Hash())
+	fmt.Printf("Import done in %v\n", time.Since(start))
+	return nil
+}
+
+// parseTrustedHash decodes the hash given by a flag, if any.
+func parseTrustedHash(ctx *cli.Context, flag cli.StringFlag) common.Hash {
+	arg := ctx.GlobalString(flag.Name)
+	if arg == "" {
+		return common.Hash{}
+	}
+	data, err := hexutil.Decode(arg)
+	if err != nil || len(data) != common.HashLength {
+		utils.Fatalf("Invalid --%s %q: expected a 0x prefixed 32 byte hash", flag.Name, arg)
+	}
+	return common.BytesToHash(data)
+}
+
 func importChain(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
 		utils.Fatalf("This command requires an argument.")
@@ -244,7 +435,10 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Import done in %v.\n\n", time.Since(start))
 
 	// Output pre-compaction stats mostly to see the import trashing
-	db := chainDb.(*berithdb.LDBDatabase)
+	db := rawdb.LevelDB(chainDb)
+	if db == nil {
+		return nil
+	}
 
 	stats, err := db.LDB().GetProperty("leveldb.stats")
 	if err != nil {
@@ -297,6 +491,51 @@ func importChain(ctx *cli.Context) error {
 	return nil
 }
 
+func importArchive(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, cfg := makeConfigNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	start := time.Now()
+	err := utils.ImportArchive(chain, chainDb, ctx.Args().First(), cfg.Ber.NetworkId)
+	chain.Stop()
+	if err != nil {
+		utils.Fatalf("Import error: %v", err)
+	}
+	fmt.Printf("Import done in %v\n", time.Since(start))
+	return nil
+}
+
+func exportArchive(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 && len(ctx.Args()) != 3 {
+		utils.Fatalf("This command requires one or three arguments.")
+	}
+	stack, cfg := makeConfigNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	first, last := uint64(1), chain.CurrentBlock().NumberU64()
+	if len(ctx.Args()) == 3 {
+		var ferr, lerr error
+		first, ferr = strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		last, lerr = strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+		if ferr != nil || lerr != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+		}
+	}
+	start := time.Now()
+	err := utils.ExportArchive(chain, chainDb, ctx.Args().First(), cfg.Ber.NetworkId, first, last,
+		ctx.GlobalUint64(utils.ArchiveChunkSizeFlag.Name), ctx.GlobalString(utils.ArchiveCompressionFlag.Name))
+	if err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
 func exportChain(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
 		utils.Fatalf("This command requires an argument.")
@@ -329,13 +568,49 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+// exportStateDiffs exports the state changes of a range of blocks.
+func exportStateDiffs(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack := makeFullNode(ctx)
+	chain, _ := utils.MakeChain(ctx, stack)
+	defer chain.Stop()
+	start := time.Now()
+
+	first, last := uint64(1), chain.CurrentBlock().NumberU64()
+	if len(ctx.Args()) >= 3 {
+		var ferr, lerr error
+		first, ferr = strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		last, lerr = strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+		if ferr != nil || lerr != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+		}
+		if first == 0 {
+			utils.Fatalf("Export error: the genesis block has no state diff\n")
+		}
+	}
+	var diffdb berithdb.Database
+	if ctx.GlobalBool(utils.StateDiffsFlag.Name) {
+		db := utils.MakeStateDiffDatabase(ctx, stack)
+		defer db.Close()
+		chain.EnableStateDiffs(db)
+		diffdb = db
+	}
+	if err := utils.ExportStateDiffs(chain, ctx.Args().First(), first, last, diffdb); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
 // importPreimages imports preimage data from the specified file.
 func importPreimages(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
 		utils.Fatalf("This command requires an argument.")
 	}
 	stack := makeFullNode(ctx)
-	diskdb := utils.MakeChainDatabase(ctx, stack).(*berithdb.LDBDatabase)
+	diskdb := utils.MakeChainDatabase(ctx, stack)
 
 	start := time.Now()
 	if err := utils.ImportPreimages(diskdb, ctx.Args().First()); err != nil {
@@ -351,7 +626,7 @@ func exportPreimages(ctx *cli.Context) error {
 		utils.Fatalf("This command requires an argument.")
 	}
 	stack := makeFullNode(ctx)
-	diskdb := utils.MakeChainDatabase(ctx, stack).(*berithdb.LDBDatabase)
+	diskdb := utils.MakeChainDatabase(ctx, stack)
 
 	start := time.Now()
 	if err := utils.ExportPreimages(diskdb, ctx.Args().First()); err != nil {
@@ -374,7 +649,7 @@ func copyDb(ctx *cli.Context) error {
 	dl := downloader.New(syncmode, chainDb, new(event.TypeMux), chain, nil, nil)
 
 	// Create a source peer to satisfy downloader requests from
-	db, err := berithdb.NewLDBDatabase(ctx.Args().First(), ctx.GlobalInt(utils.CacheFlag.Name), 256)
+	db, err := berithdb.OpenBackend("", ctx.Args().First(), ctx.GlobalInt(utils.CacheFlag.Name), 256)
 	if err != nil {
 		return err
 	}
@@ -401,7 +676,7 @@ func copyDb(ctx *cli.Context) error {
 	// Compact the entire database to remove any sync overhead
 	start = time.Now()
 	fmt.Println("Compacting entire database...")
-	if err = chainDb.(*berithdb.LDBDatabase).LDB().CompactRange(util.Range{}); err != nil {
+	if err = rawdb.KeyValueStore(chainDb).(berithdb.Compacter).Compact(nil, nil); err != nil {
 		utils.Fatalf("Compaction failed: %v", err)
 	}
 	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
@@ -435,6 +710,28 @@ func removeDB(ctx *cli.Context) error {
 			logger.Info("Database successfully deleted", "elapsed", common.PrettyDuration(time.Since(start)))
 		}
 	}
+	// The ancient store lives in chaindata unless it was moved elsewhere
+	if ctx.GlobalIsSet(utils.AncientFlag.Name) {
+		logger := log.New("database", "ancient")
+
+		dbdir := stack.ResolvePath(ctx.GlobalString(utils.AncientFlag.Name))
+		if !common.FileExist(dbdir) {
+			logger.Info("Database doesn't exist, skipping", "path", dbdir)
+			return nil
+		}
+		fmt.Println(dbdir)
+		confirm, err := console.Stdin.PromptConfirm("Remove this database?")
+		switch {
+		case err != nil:
+			utils.Fatalf("%v", err)
+		case !confirm:
+			logger.Warn("Database deletion aborted")
+		default:
+			start := time.Now()
+			os.RemoveAll(dbdir)
+			logger.Info("Database successfully deleted", "elapsed", common.PrettyDuration(time.Since(start)))
+		}
+	}
 	return nil
 }
 
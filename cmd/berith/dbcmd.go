@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/cmd/utils"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/common/hexutil"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/log"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbCommand = cli.Command{
+		Name:      "db",
+		Usage:     "Low level database operations",
+		ArgsUsage: "",
+		Category:  "DATABASE COMMANDS",
+		Subcommands: []cli.Command{
+			dbInspectCmd,
+			dbGetCmd,
+			dbPutCmd,
+			dbDeleteCmd,
+		},
+	}
+	dbInspectCmd = cli.Command{
+		Action:    utils.MigrateFlags(inspect),
+		Name:      "inspect",
+		Usage:     "Inspect the storage size for each type of data in the database",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+		},
+		Description: `
+The inspect command walks the chain database, and the former staking database
+if it isn't migrated yet, and reports the number of entries and their size per
+type of data.`,
+	}
+	dbGetCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbGet),
+		Name:      "get",
+		Usage:     "Show the value of a database key",
+		ArgsUsage: "<hex-key>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.SyncModeFlag,
+		},
+		Description: `
+The get command prints the value stored under a key of the chain database. Keys
+starting with 0x are decoded from hex, other keys are used as they are.`,
+	}
+	dbPutCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbPut),
+		Name:      "put",
+		Usage:     "Set the value of a database key (WARNING: may corrupt your database)",
+		ArgsUsage: "<hex-key> <hex-value>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.SyncModeFlag,
+		},
+		Description: `
+The put command stores a hex encoded value under a key of the chain database,
+printing the previous value if there was one. This is a low level operation
+meant for debugging, it may corrupt the database.`,
+	}
+	dbDeleteCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbDelete),
+		Name:      "delete",
+		Usage:     "Delete a database key (WARNING: may corrupt your database)",
+		ArgsUsage: "<hex-key>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.SyncModeFlag,
+		},
+		Description: `
+The delete command removes a key of the chain database, printing its value if
+there was one. This is a low level operation meant for debugging, it may corrupt
+the database.`,
+	}
+)
+
+// inspect prints the size of each type of data of the chain database and of the
+// former staking database.
+func inspect(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	stats, err := rawdb.InspectDatabase(db)
+	if err != nil {
+		return err
+	}
+	var (
+		table = tablewriter.NewWriter(os.Stdout)
+		count uint64
+		total common.StorageSize
+	)
+	table.SetHeader([]string{"Database", "Category", "Items", "Size"})
+	for _, stat := range stats {
+		table.Append([]string{"Chain", stat.Category, fmt.Sprint(stat.Count), stat.Size.String()})
+		count, total = count+stat.Count, total+stat.Size
+	}
+	// The staking snapshots were kept in their own database before being moved into
+	// the chain database when the node starts
+	if path := stack.ResolvePath("stakingDB"); common.FileExist(path) {
+		stakingDB, err := berithdb.OpenBackend("", path, 0, 0)
+		if err != nil {
+			return err
+		}
+		defer stakingDB.Close()
+
+		snapshots, unaccounted, err := inspectStakingDB(stakingDB)
+		if err != nil {
+			return err
+		}
+		for _, stat := range []*rawdb.DatabaseStat{snapshots, unaccounted} {
+			table.Append([]string{"Staking (legacy)", stat.Category, fmt.Sprint(stat.Count), stat.Size.String()})
+			count, total = count+stat.Count, total+stat.Size
+		}
+	}
+	table.SetFooter([]string{"", "Total", fmt.Sprint(count), total.String()})
+	table.Render()
+	return nil
+}
+
+// inspectStakingDB returns the number and the size of the staking snapshots of
+// the staking database and of its other entries.
+func inspectStakingDB(db berithdb.Database) (*rawdb.DatabaseStat, *rawdb.DatabaseStat, error) {
+	var (
+		snapshots   = &rawdb.DatabaseStat{Category: "Staking snapshots"}
+		unaccounted = &rawdb.DatabaseStat{Category: "Unaccounted"}
+	)
+	it := db.NewIterator()
+	defer it.Release()
+
+	for it.Next() {
+		key, size := it.Key(), common.StorageSize(len(it.Key())+len(it.Value()))
+
+		// Snapshots are stored under the hex encoded hash of their block
+		stat := unaccounted
+		if len(key) == 2+2*common.HashLength && bytes.HasPrefix(key, []byte("0x")) {
+			stat = snapshots
+		}
+		stat.Count++
+		stat.Size += size
+	}
+	return snapshots, unaccounted, it.Error()
+}
+
+// parseDBKey decodes a database key given on the command line, 0x prefixed keys
+// are hex encoded.
+func parseDBKey(arg string) ([]byte, error) {
+	if strings.HasPrefix(arg, "0x") {
+		return hexutil.Decode(arg)
+	}
+	return []byte(arg), nil
+}
+
+// dbGet prints the value of a chain database key.
+func dbGet(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	key, err := parseDBKey(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	data, err := db.Get(key)
+	if err != nil {
+		log.Info("Get operation failed", "key", hexutil.Encode(key), "error", err)
+		return err
+	}
+	fmt.Printf("key %x: %#x\n", key, data)
+	return nil
+}
+
+// dbPut stores a value under a chain database key.
+func dbPut(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	key, err := parseDBKey(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	value, err := hexutil.Decode(ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	if data, err := db.Get(key); err == nil {
+		fmt.Printf("Previous value: %#x\n", data)
+	}
+	return db.Put(key, value)
+}
+
+// dbDelete removes a chain database key.
+func dbDelete(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	key, err := parseDBKey(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	if data, err := db.Get(key); err == nil {
+		fmt.Printf("Previous value: %#x\n", data)
+	}
+	if err := db.Delete(key); err != nil {
+		log.Info("Delete operation returned an error", "key", hexutil.Encode(key), "error", err)
+		return err
+	}
+	return nil
+}
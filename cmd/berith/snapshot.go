@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/cmd/utils"
+	"github.com/BerithFoundation/berith-chain/core/checkpoint"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/state/pruner"
+	"github.com/BerithFoundation/berith-chain/core/state/snapshot"
+	"github.com/BerithFoundation/berith-chain/log"
+	"github.com/BerithFoundation/berith-chain/trie"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// defaultPruneRetain is the number of recent block states kept by pruning on
+// chains without the BSRR engine.
+const defaultPruneRetain = 128
+
+var (
+	snapshotCommand = cli.Command{
+		Name:      "snapshot",
+		Usage:     "A set of commands based on the state of the chain",
+		ArgsUsage: "",
+		Category:  "DATABASE COMMANDS",
+		Subcommands: []cli.Command{
+			pruneStateCmd,
+			verifyStateCmd,
+			exportSnapshotCmd,
+		},
+	}
+	pruneStateCmd = cli.Command{
+		Action:    utils.MigrateFlags(pruneState),
+		Name:      "prune-state",
+		Usage:     "Prune the stale state data of the chain database",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.SyncModeFlag,
+			utils.BloomFilterSizeFlag,
+			utils.PruneRetainFlag,
+		},
+		Description: `
+berith snapshot prune-state
+
+The prune-state command deletes the state trie nodes and contract codes which
+are not reachable from the genesis state or from the states of the last
+--prune.retain blocks. By default twice the BSRR epoch is retained, since the
+engine reads the stakes of the state one epoch below the head.
+
+The reachable state is marked in a bloom filter of --bloomfilter.size megabytes
+before anything is deleted. The node must be stopped while pruning. If pruning
+is interrupted, it is resumed by running the command again or by starting the
+node.`,
+	}
+	verifyStateCmd = cli.Command{
+		Action:    utils.MigrateFlags(verifyState),
+		Name:      "verify-state",
+		Usage:     "Verify the flat state snapshot against the state trie",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.SyncModeFlag,
+		},
+		Description: `
+berith snapshot verify-state
+
+The verify-state command checks that every account and storage slot of the flat
+state snapshot matches the state trie of the snapshot root, and that no trie
+leaf is missing from the snapshot. The snapshot must be fully generated and the
+node must be stopped.`,
+	}
+	exportSnapshotCmd = cli.Command{
+		Action:    utils.MigrateFlags(exportSnapshot),
+		Name:      "export",
+		Usage:     "Export the state of a block into a snapshot file",
+		ArgsUsage: "<filename>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.SyncModeFlag,
+			utils.NetworkIdFlag,
+			utils.TestnetFlag,
+			utils.SnapshotBlockFlag,
+		},
+		Description: `
+berith snapshot export --block <number> <filename>
+
+The export command writes the state of the canonical block --block, or of the
+head block, with every account, storage slot, contract code and staking list,
+into a snapshot file bootstrapping new nodes with init-from-snapshot. Since the
+BSRR engine reads the state one epoch below the blocks it processes, the states
+of the blocks of the previous epoch are included as differences, along with
+their blocks. They must all be present in the database, which is only the case
+for archive nodes (--gcmode archive).
+
+The command works on the database of a stopped node and doesn't need network
+access.`,
+	}
+)
+
+// pruneState deletes the state data unreachable from the retained states.
+func pruneState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	retain := ctx.GlobalUint64(utils.PruneRetainFlag.Name)
+	config := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0))
+	switch {
+	case config != nil && config.Bsrr != nil && retain == 0:
+		retain = 2 * config.Bsrr.Epoch
+	case config != nil && config.Bsrr != nil && retain <= config.Bsrr.Epoch:
+		return fmt.Errorf("retaining %d blocks breaks the BSRR engine, which needs the states of the last %d blocks", retain, config.Bsrr.Epoch)
+	case retain == 0:
+		retain = defaultPruneRetain
+	}
+	p := pruner.NewPruner(db, stack.ResolvePath(pruner.BloomFileName), ctx.GlobalUint64(utils.BloomFilterSizeFlag.Name), retain)
+	if err := p.Prune(); err != nil {
+		log.Error("Failed to prune state", "err", err)
+		return err
+	}
+	return nil
+}
+
+// verifyState checks the flat state snapshot against the state trie.
+func verifyState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	root, err := snapshot.Verify(db, trie.NewDatabase(db))
+	if err != nil {
+		log.Error("Failed to verify state snapshot", "root", root, "err", err)
+		return err
+	}
+	return nil
+}
+
+// exportSnapshot writes the state snapshot of a block into a file.
+func exportSnapshot(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, cfg := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	number := ctx.GlobalUint64(utils.SnapshotBlockFlag.Name)
+	if number == 0 {
+		head := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadBlockHash(db))
+		if head == nil {
+			return fmt.Errorf("head block not found")
+		}
+		number = *head
+	}
+	var epoch uint64
+	if config := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0)); config != nil && config.Bsrr != nil {
+		epoch = config.Bsrr.Epoch
+	}
+	start := time.Now()
+	fn := ctx.Args().First()
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	err = checkpoint.Export(db, fh, cfg.Ber.NetworkId, number, epoch)
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(fn)
+		log.Error("Failed to export state snapshot", "number", number, "err", err)
+		return err
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
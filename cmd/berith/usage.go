@@ -69,12 +69,19 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			configFileFlag,
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.KeyStoreDirFlag,
 			utils.NoUSBFlag,
+			utils.ExternalSignerFlag,
 			utils.NetworkIdFlag,
 			utils.TestnetFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.NoAddressIndexFlag,
+			utils.NoSnapshotFlag,
+			utils.StateDiffsFlag,
+			utils.DBEngineFlag,
+			utils.AncientThresholdFlag,
 			utils.BerithStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightServFlag,
@@ -107,6 +114,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TxPoolNoLocalsFlag,
 			utils.TxPoolJournalFlag,
 			utils.TxPoolRejournalFlag,
+			utils.TxPoolJournalRemotesFlag,
 			utils.TxPoolPriceLimitFlag,
 			utils.TxPoolPriceBumpFlag,
 			utils.TxPoolAccountSlotsFlag,
@@ -183,6 +191,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.MinerBerithbaseFlag,
 			utils.MinerExtraDataFlag,
 			utils.MinerRecommitIntervalFlag,
+			utils.MinerBuilderFlag,
+			utils.MinerPriorityFlag,
 			utils.MinerNoVerfiyFlag,
 		},
 	},
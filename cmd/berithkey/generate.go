@@ -25,6 +25,7 @@ import (
 
 	"github.com/BerithFoundation/berith-chain/accounts/keystore"
 	"github.com/BerithFoundation/berith-chain/cmd/utils"
+	"github.com/BerithFoundation/berith-chain/common"
 	"github.com/BerithFoundation/berith-chain/crypto"
 	"github.com/pborman/uuid"
 	"gopkg.in/urfave/cli.v1"
@@ -33,6 +34,7 @@ import (
 type outputGenerate struct {
 	Address      string
 	AddressEIP55 string
+	Mnemonic     string `json:",omitempty"`
 }
 
 var commandGenerate = cli.Command{
@@ -44,6 +46,10 @@ Generate a new keyfile.
 
 If you want to encrypt an existing private key, it can be specified by setting
 --privatekey with the location of the file containing the private key.
+
+With --mnemonic, the key is derived from a newly generated BIP-39 mnemonic,
+which is printed once. The key file can be restored from the mnemonic with
+"berithkey recover".
 `,
 	Flags: []cli.Flag{
 		passphraseFlag,
@@ -52,6 +58,12 @@ If you want to encrypt an existing private key, it can be specified by setting
 			Name:  "privatekey",
 			Usage: "file containing a raw private key to encrypt",
 		},
+		cli.BoolFlag{
+			Name:  "mnemonic",
+			Usage: "derive the key from a newly generated BIP-39 mnemonic",
+		},
+		mnemonicPassphraseFlag,
+		hdpathFlag,
 	},
 	Action: func(ctx *cli.Context) error {
 		// Check if keyfile path given and make sure it doesn't already exist.
@@ -65,54 +77,78 @@ If you want to encrypt an existing private key, it can be specified by setting
 			utils.Fatalf("Error checking if keyfile exists: %v", err)
 		}
 
-		var privateKey *ecdsa.PrivateKey
-		var err error
-		if file := ctx.String("privatekey"); file != "" {
+		var (
+			privateKey *ecdsa.PrivateKey
+			mnemonic   string
+			err        error
+		)
+		switch {
+		case ctx.String("privatekey") != "":
 			// Load private key from file.
-			privateKey, err = crypto.LoadECDSA(file)
+			privateKey, err = crypto.LoadECDSA(ctx.String("privatekey"))
 			if err != nil {
 				utils.Fatalf("Can't load private key: %v", err)
 			}
-		} else {
+		case ctx.Bool("mnemonic"):
+			// Derive the key from a new mnemonic.
+			mnemonic, err = keystore.NewMnemonic(keystore.DefaultMnemonicBits)
+			if err != nil {
+				utils.Fatalf("Failed to generate mnemonic: %v", err)
+			}
+			privateKey, err = keystore.DeriveKeyFromMnemonic(mnemonic, getMnemonicPassphrase(ctx), getDerivationPath(ctx))
+			if err != nil {
+				utils.Fatalf("Failed to derive private key: %v", err)
+			}
+		default:
 			// If not loaded, generate random.
 			privateKey, err = crypto.GenerateKey()
 			if err != nil {
 				utils.Fatalf("Failed to generate random private key: %v", err)
 			}
 		}
-
-		// Create the keyfile object with a random UUID.
-		id := uuid.NewRandom()
-		key := &keystore.Key{
-			Id:         id,
-			Address:    crypto.PubkeyToAddress(privateKey.PublicKey),
-			PrivateKey: privateKey,
-		}
-
-		// Encrypt key with passphrase.
-		passphrase := promptPassphrase(true)
-		keyjson, err := keystore.EncryptKey(key, passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
-		if err != nil {
-			utils.Fatalf("Error encrypting key: %v", err)
-		}
-
-		// Store the file to disk.
-		if err := os.MkdirAll(filepath.Dir(keyfilepath), 0700); err != nil {
-			utils.Fatalf("Could not create directory %s", filepath.Dir(keyfilepath))
-		}
-		if err := ioutil.WriteFile(keyfilepath, keyjson, 0600); err != nil {
-			utils.Fatalf("Failed to write keyfile to %s: %v", keyfilepath, err)
-		}
+		address := storeKeyfile(keyfilepath, privateKey)
 
 		// Output some information.
 		out := outputGenerate{
-			Address: key.Address.Hex(),
+			Address:  address.Hex(),
+			Mnemonic: mnemonic,
 		}
 		if ctx.Bool(jsonFlag.Name) {
 			mustPrintJSON(out)
 		} else {
 			fmt.Println("Address:", out.Address)
+			if mnemonic != "" {
+				fmt.Println("Mnemonic:", mnemonic)
+			}
 		}
 		return nil
 	},
 }
+
+// storeKeyfile encrypts the private key with a passphrase given by the user and
+// writes it to the keyfile path.
+func storeKeyfile(keyfilepath string, privateKey *ecdsa.PrivateKey) common.Address {
+	// Create the keyfile object with a random UUID.
+	id := uuid.NewRandom()
+	key := &keystore.Key{
+		Id:         id,
+		Address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+		PrivateKey: privateKey,
+	}
+
+	// Encrypt key with passphrase.
+	passphrase := promptPassphrase(true)
+	keyjson, err := keystore.EncryptKey(key, passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		utils.Fatalf("Error encrypting key: %v", err)
+	}
+
+	// Store the file to disk.
+	if err := os.MkdirAll(filepath.Dir(keyfilepath), 0700); err != nil {
+		utils.Fatalf("Could not create directory %s", filepath.Dir(keyfilepath))
+	}
+	if err := ioutil.WriteFile(keyfilepath, keyjson, 0600); err != nil {
+		utils.Fatalf("Failed to write keyfile to %s: %v", keyfilepath, err)
+	}
+	return key.Address
+}
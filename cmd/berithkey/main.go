@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/BerithFoundation/berith-chain/accounts"
 	"github.com/BerithFoundation/berith-chain/cmd/utils"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -37,6 +38,7 @@ func init() {
 	app = utils.NewAppWithHelpTemplate(gitCommit, "an Berith key manager", false)
 	app.Commands = []cli.Command{
 		commandGenerate,
+		commandRecover,
 		commandInspect,
 		commandChangePassphrase,
 		commandSignMessage,
@@ -54,6 +56,15 @@ var (
 		Name:  "json",
 		Usage: "output JSON instead of human-readable format",
 	}
+	mnemonicPassphraseFlag = cli.StringFlag{
+		Name:  "mnemonicpassfile",
+		Usage: "the file that contains the optional BIP-39 mnemonic passphrase",
+	}
+	hdpathFlag = cli.StringFlag{
+		Name:  "hdpath",
+		Usage: "the BIP-32 derivation path of the key within the mnemonic",
+		Value: accounts.DefaultBaseDerivationPath.String(),
+	}
 )
 
 func main() {
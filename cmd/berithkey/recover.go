@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/BerithFoundation/berith-chain/accounts"
+	"github.com/BerithFoundation/berith-chain/accounts/keystore"
+	"github.com/BerithFoundation/berith-chain/cmd/utils"
+	"github.com/BerithFoundation/berith-chain/console"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var commandRecover = cli.Command{
+	Name:      "recover",
+	Usage:     "recover a keyfile from a BIP-39 mnemonic",
+	ArgsUsage: "[ <keyfile> ]",
+	Description: `
+Derive the private key of a BIP-39 mnemonic, as printed by "berithkey generate
+--mnemonic" or "berith account new --mnemonic", and store it in a new keyfile.
+If you want to recover the keyfile to a custom location, specify the path as
+the only argument.
+
+The mnemonic is read from the file given with --mnemonicfile, or else prompted
+for. The optional mnemonic passphrase and the derivation path must match the
+ones used when the mnemonic was generated.
+`,
+	Flags: []cli.Flag{
+		jsonFlag,
+		cli.StringFlag{
+			Name:  "mnemonicfile",
+			Usage: "file containing the mnemonic to recover",
+		},
+		mnemonicPassphraseFlag,
+		hdpathFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		// Check if keyfile path given and make sure it doesn't already exist.
+		keyfilepath := ctx.Args().First()
+		if keyfilepath == "" {
+			keyfilepath = defaultKeyfileName
+		}
+		if _, err := os.Stat(keyfilepath); err == nil {
+			utils.Fatalf("Keyfile already exists at %s.", keyfilepath)
+		} else if !os.IsNotExist(err) {
+			utils.Fatalf("Error checking if keyfile exists: %v", err)
+		}
+
+		// Read the mnemonic and derive the key.
+		var mnemonic string
+		if file := ctx.String("mnemonicfile"); file != "" {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				utils.Fatalf("Failed to read mnemonic file '%s': %v", file, err)
+			}
+			mnemonic = string(content)
+		} else {
+			input, err := console.Stdin.PromptPassword("Mnemonic: ")
+			if err != nil {
+				utils.Fatalf("Failed to read mnemonic: %v", err)
+			}
+			mnemonic = input
+		}
+		privateKey, err := keystore.DeriveKeyFromMnemonic(mnemonic, getMnemonicPassphrase(ctx), getDerivationPath(ctx))
+		if err != nil {
+			utils.Fatalf("Failed to derive private key: %v", err)
+		}
+		address := storeKeyfile(keyfilepath, privateKey)
+
+		// Output some information.
+		out := outputGenerate{
+			Address: address.Hex(),
+		}
+		if ctx.Bool(jsonFlag.Name) {
+			mustPrintJSON(out)
+		} else {
+			fmt.Println("Address:", out.Address)
+		}
+		return nil
+	},
+}
+
+// getMnemonicPassphrase reads the optional mnemonic passphrase from the file
+// given with --mnemonicpassfile, defaulting to no passphrase.
+func getMnemonicPassphrase(ctx *cli.Context) string {
+	file := ctx.String(mnemonicPassphraseFlag.Name)
+	if file == "" {
+		return ""
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		utils.Fatalf("Failed to read mnemonic passphrase file '%s': %v", file, err)
+	}
+	return strings.TrimRight(string(content), "\r\n")
+}
+
+// getDerivationPath parses the derivation path given with --hdpath.
+func getDerivationPath(ctx *cli.Context) accounts.DerivationPath {
+	path, err := accounts.ParseDerivationPath(ctx.String(hdpathFlag.Name))
+	if err != nil {
+		utils.Fatalf("Invalid derivation path: %v", err)
+	}
+	return path
+}
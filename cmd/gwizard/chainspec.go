@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math/big"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/common/hexutil"
+	"github.com/BerithFoundation/berith-chain/core"
+)
+
+// chainSpec is a flattened description of a Berith network, exported next to
+// the native genesis so other tooling doesn't need to understand its layout.
+type chainSpec struct {
+	Name    string   `json:"name"`
+	ChainID *big.Int `json:"chainId"`
+	Engine  struct {
+		Name         string       `json:"name"`
+		Period       uint64       `json:"period"`
+		Epoch        uint64       `json:"epoch"`
+		Rewards      *big.Int     `json:"rewards"`
+		StakeMinimum *hexutil.Big `json:"stakeMinimum"`
+		StakeMaximum *hexutil.Big `json:"stakeMaximum,omitempty"`
+		SlashRound   uint64       `json:"slashRound"`
+		ForkFactor   float64      `json:"forkFactor"`
+	} `json:"engine"`
+	Forks struct {
+		Homestead      *big.Int `json:"homestead,omitempty"`
+		EIP150         *big.Int `json:"eip150,omitempty"`
+		EIP155         *big.Int `json:"eip155,omitempty"`
+		EIP158         *big.Int `json:"eip158,omitempty"`
+		Byzantium      *big.Int `json:"byzantium,omitempty"`
+		Constantinople *big.Int `json:"constantinople,omitempty"`
+		BIP1           *big.Int `json:"bip1,omitempty"`
+		BIP2           *big.Int `json:"bip2,omitempty"`
+		BIP3           *big.Int `json:"bip3,omitempty"`
+		BIP4           *big.Int `json:"bip4,omitempty"`
+		BIP5           *big.Int `json:"bip5,omitempty"`
+		BIP6           *big.Int `json:"bip6,omitempty"`
+		BIP7           *big.Int `json:"bip7,omitempty"`
+		BIP8           *big.Int `json:"bip8,omitempty"`
+		BIP9           *big.Int `json:"bip9,omitempty"`
+	} `json:"forks"`
+	Genesis struct {
+		Timestamp hexutil.Uint64   `json:"timestamp"`
+		GasLimit  hexutil.Uint64   `json:"gasLimit"`
+		ExtraData hexutil.Bytes    `json:"extraData"`
+		Signers   []common.Address `json:"signers"`
+	} `json:"genesis"`
+	Accounts map[common.Address]*hexutil.Big `json:"accounts"`
+}
+
+// newChainSpec converts a BSRR genesis of the named network into its flattened chain spec.
+func newChainSpec(network string, genesis *core.Genesis) *chainSpec {
+	spec := &chainSpec{
+		Name:     network,
+		ChainID:  genesis.Config.ChainID,
+		Accounts: make(map[common.Address]*hexutil.Big),
+	}
+	if bsrr := genesis.Config.Bsrr; bsrr != nil {
+		spec.Engine.Name = bsrr.String()
+		spec.Engine.Period = bsrr.Period
+		spec.Engine.Epoch = bsrr.Epoch
+		spec.Engine.Rewards = bsrr.Rewards
+		spec.Engine.StakeMinimum = (*hexutil.Big)(bsrr.StakeMinimum)
+		spec.Engine.StakeMaximum = (*hexutil.Big)(bsrr.StakeMaximum)
+		spec.Engine.SlashRound = bsrr.SlashRound
+		spec.Engine.ForkFactor = bsrr.ForkFactor
+	}
+	spec.Forks.Homestead = genesis.Config.HomesteadBlock
+	spec.Forks.EIP150 = genesis.Config.EIP150Block
+	spec.Forks.EIP155 = genesis.Config.EIP155Block
+	spec.Forks.EIP158 = genesis.Config.EIP158Block
+	spec.Forks.Byzantium = genesis.Config.ByzantiumBlock
+	spec.Forks.Constantinople = genesis.Config.ConstantinopleBlock
+	spec.Forks.BIP1 = genesis.Config.BIP1Block
+	spec.Forks.BIP2 = genesis.Config.BIP2Block
+	spec.Forks.BIP3 = genesis.Config.BIP3Block
+	spec.Forks.BIP4 = genesis.Config.BIP4Block
+	spec.Forks.BIP5 = genesis.Config.BIP5Block
+	spec.Forks.BIP6 = genesis.Config.BIP6Block
+	spec.Forks.BIP7 = genesis.Config.BIP7Block
+	spec.Forks.BIP8 = genesis.Config.BIP8Block
+	spec.Forks.BIP9 = genesis.Config.BIP9Block
+
+	spec.Genesis.Timestamp = hexutil.Uint64(genesis.Timestamp)
+	spec.Genesis.GasLimit = hexutil.Uint64(genesis.GasLimit)
+	spec.Genesis.ExtraData = genesis.ExtraData
+
+	// The genesis signers sit between the vanity and the seal of the extra data
+	if n := (len(genesis.ExtraData) - 32 - 65) / common.AddressLength; n > 0 {
+		for i := 0; i < n; i++ {
+			spec.Genesis.Signers = append(spec.Genesis.Signers, common.BytesToAddress(genesis.ExtraData[32+i*common.AddressLength:32+(i+1)*common.AddressLength]))
+		}
+	}
+	for address, account := range genesis.Alloc {
+		spec.Accounts[address] = (*hexutil.Big)(account.Balance)
+	}
+	return spec
+}
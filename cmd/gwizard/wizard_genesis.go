@@ -19,12 +19,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/BerithFoundation/berith-chain/common"
@@ -57,8 +59,10 @@ func (w *wizard) makeGenesis() {
 		Period:       30,
 		Epoch:        300,
 		Rewards:      big.NewInt(500),
-		StakeMinimum: new(big.Int).Mul(big.NewInt(100000), big.NewInt(1e+18)),
+		StakeMinimum: new(big.Int).Mul(big.NewInt(100000), big.NewInt(params.Ber)),
+		StakeMaximum: new(big.Int).Mul(big.NewInt(30000000), big.NewInt(params.Ber)),
 		SlashRound:   uint64(1),
+		ForkFactor:   1.0,
 	}
 
 	fmt.Println()
@@ -88,6 +92,18 @@ func (w *wizard) makeGenesis() {
 		copy(genesis.ExtraData[32+i*common.AddressLength:], signer[:])
 	}
 
+	// Configure the staking rules and the fork schedule
+	fmt.Println()
+	fmt.Println("Do you want to configure the BSRR staking parameters? (y/n, default = no)")
+	if w.readDefaultYesNo(false) {
+		w.configureBSRR(genesis.Config)
+	}
+	fmt.Println()
+	fmt.Println("Do you want to schedule the BIP forks? (y/n, default = no)")
+	if w.readDefaultYesNo(false) {
+		w.scheduleForks(genesis.Config)
+	}
+
 	// Consensus all set, just ask for initial funds and go
 	fmt.Println()
 	fmt.Println("Which accounts should be pre-funded? (advisable at least one)")
@@ -107,6 +123,17 @@ func (w *wizard) makeGenesis() {
 	fmt.Println("Specify your chain/network ID if you want an explicit one (default = random)")
 	genesis.Config.ChainID = new(big.Int).SetUint64(uint64(w.readDefaultInt(rand.Intn(65536))))
 
+	// Keep asking for the consensus parameters and the fork schedule until they are usable
+	for {
+		err := validateGenesis(genesis)
+		if err == nil {
+			break
+		}
+		log.Error("Invalid genesis configuration, please retry", "err", err)
+		w.configureBSRR(genesis.Config)
+		w.scheduleForks(genesis.Config)
+	}
+
 	// All done.
 	log.Info("Configured new genesis block")
 	w.conf.Genesis = genesis
@@ -114,16 +141,162 @@ func (w *wizard) makeGenesis() {
 	// w.conf.flush()
 
 	// Save whatever genesis configuration we currently have
+	w.exportGenesis()
+}
+
+// manageGenesis loads an existing genesis spec and permits the modification of
+// its BSRR parameters and fork rules, as well as exporting it.
+func (w *wizard) manageGenesis() {
+	fmt.Println()
+	fmt.Println("Which genesis file do you want to manage?")
+	path := w.readString()
+
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Error("Failed to read genesis file", "path", path, "err", err)
+		return
+	}
+	genesis := new(core.Genesis)
+	if err := json.Unmarshal(blob, genesis); err != nil {
+		log.Error("Failed to parse genesis file", "path", path, "err", err)
+		return
+	}
+	if genesis.Config == nil || genesis.Config.Bsrr == nil {
+		log.Error("Genesis file does not use the BSRR engine", "path", path)
+		return
+	}
+	w.network = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	w.conf.Genesis = genesis
+
+	for {
+		fmt.Println()
+		fmt.Println(" 1. Modify BSRR parameters")
+		fmt.Println(" 2. Modify BIP fork rules")
+		fmt.Println(" 3. Export genesis configurations")
+		fmt.Println(" 4. Save and quit")
+
+		switch w.read() {
+		case "1":
+			w.configureBSRR(genesis.Config)
+		case "2":
+			w.scheduleForks(genesis.Config)
+		case "3":
+			w.exportGenesis()
+		case "4":
+			if err := validateGenesis(genesis); err != nil {
+				log.Error("Refusing to save invalid genesis", "err", err)
+				continue
+			}
+			out, err := json.MarshalIndent(genesis, "", "  ")
+			if err != nil {
+				log.Error("Failed to encode genesis", "err", err)
+				return
+			}
+			if err := ioutil.WriteFile(path, out, 0644); err != nil {
+				log.Error("Failed to save genesis file", "err", err)
+				return
+			}
+			log.Info("Saved genesis chain spec", "path", path)
+			return
+		default:
+			log.Error("That's not something I can do")
+		}
+	}
+}
+
+// configureBSRR queries the user for the BSRR consensus parameters, using the
+// current values as defaults.
+func (w *wizard) configureBSRR(config *params.ChainConfig) {
+	bsrr := config.Bsrr
+	for {
+		fmt.Println()
+		fmt.Printf("How many seconds should blocks take? (default = %d)\n", bsrr.Period)
+		bsrr.Period = uint64(w.readDefaultInt(int(bsrr.Period)))
+
+		fmt.Println()
+		fmt.Printf("How many blocks should an epoch span? (default = %d)\n", bsrr.Epoch)
+		bsrr.Epoch = uint64(w.readDefaultInt(int(bsrr.Epoch)))
+
+		fmt.Println()
+		fmt.Printf("From which block should mining be rewarded? (default = %v)\n", bsrr.Rewards)
+		bsrr.Rewards = w.readDefaultBigInt(bsrr.Rewards)
+
+		fmt.Println()
+		fmt.Printf("What is the minimum stake in BER? (default = %v)\n", toBer(bsrr.StakeMinimum))
+		bsrr.StakeMinimum = fromBer(w.readDefaultBigInt(toBer(bsrr.StakeMinimum)))
+
+		fmt.Println()
+		fmt.Printf("What is the maximum stake in BER? (default = %v)\n", toBer(bsrr.StakeMaximum))
+		bsrr.StakeMaximum = fromBer(w.readDefaultBigInt(toBer(bsrr.StakeMaximum)))
+
+		fmt.Println()
+		fmt.Printf("How many epochs should a slash round span? (default = %d)\n", bsrr.SlashRound)
+		bsrr.SlashRound = uint64(w.readDefaultInt(int(bsrr.SlashRound)))
+
+		fmt.Println()
+		fmt.Printf("Which ratio of the stakers may seal a block? (default = %v)\n", bsrr.ForkFactor)
+		bsrr.ForkFactor = w.readDefaultFloat(bsrr.ForkFactor)
+
+		if err := validateBSRR(bsrr); err != nil {
+			log.Error("Invalid BSRR parameters, please retry", "err", err)
+			continue
+		}
+		return
+	}
+}
+
+// scheduleForks queries the user for the activation blocks of the BIP forks,
+// using the current schedule as defaults.
+func (w *wizard) scheduleForks(config *params.ChainConfig) {
+	forks := []struct {
+		name  string
+		block **big.Int
+	}{
+		{"BIP1", &config.BIP1Block},
+		{"BIP2", &config.BIP2Block},
+		{"BIP3", &config.BIP3Block},
+		{"BIP4", &config.BIP4Block},
+		{"BIP5", &config.BIP5Block},
+		{"BIP6", &config.BIP6Block},
+		{"BIP7", &config.BIP7Block},
+		{"BIP8", &config.BIP8Block},
+		{"BIP9", &config.BIP9Block},
+	}
+	for {
+		for _, fork := range forks {
+			fmt.Println()
+			fmt.Printf("Which block should %s come into effect? (default = %v, -1 = disabled)\n", fork.name, *fork.block)
+			block := w.readDefaultBigInt(*fork.block)
+			if block != nil && block.Sign() < 0 {
+				block = nil
+			}
+			*fork.block = block
+		}
+		if err := validateForks(config); err != nil {
+			log.Error("Invalid fork schedule, please retry", "err", err)
+			continue
+		}
+		return
+	}
+}
+
+// exportGenesis saves the current genesis spec into a user selected folder,
+// along with the chain spec summary used by external tooling.
+func (w *wizard) exportGenesis() {
 	fmt.Println()
 	fmt.Printf("Which folder to save the genesis specs into? (default = current)\n")
-	fmt.Printf("  Will create %s.json,  %s-harmony.json\n", w.network, w.network)
+	fmt.Printf("  Will create %s.json, %s-harmony.json, %s-spec.json\n", w.network, w.network, w.network)
 
 	folder := w.readDefaultString(".")
 	if err := os.MkdirAll(folder, 0755); err != nil {
 		log.Error("Failed to create spec folder", "folder", folder, "err", err)
 		return
 	}
-	out, _ := json.MarshalIndent(w.conf.Genesis, "", "  ")
+	out, err := json.MarshalIndent(w.conf.Genesis, "", "  ")
+	if err != nil {
+		log.Error("Failed to encode genesis", "err", err)
+		return
+	}
 
 	// Export the native genesis spec
 	json := filepath.Join(folder, fmt.Sprintf("%s.json", w.network))
@@ -135,13 +308,97 @@ func (w *wizard) makeGenesis() {
 
 	// Export the genesis spec used by Harmony (formerly EthereumJ
 	saveGenesis(folder, w.network, "harmony", w.conf.Genesis)
+
+	// Export the flattened chain spec for other tooling
+	saveGenesis(folder, w.network, "spec", newChainSpec(w.network, w.conf.Genesis))
+}
+
+// validateGenesis checks that the consensus parameters and the fork schedule
+// of the genesis spec can be used to run a network.
+func validateGenesis(genesis *core.Genesis) error {
+	if genesis.Config == nil || genesis.Config.Bsrr == nil {
+		return errors.New("missing bsrr configuration")
+	}
+	if err := validateBSRR(genesis.Config.Bsrr); err != nil {
+		return err
+	}
+	return validateForks(genesis.Config)
+}
+
+// validateBSRR checks the combination of the BSRR consensus parameters.
+func validateBSRR(bsrr *params.BSRRConfig) error {
+	switch {
+	case bsrr.Period == 0:
+		return errors.New("block period must be positive")
+	case bsrr.Epoch == 0:
+		return errors.New("epoch must be positive")
+	case bsrr.Rewards == nil || bsrr.Rewards.Sign() < 0:
+		return errors.New("reward block must not be negative")
+	case bsrr.StakeMinimum == nil || bsrr.StakeMinimum.Sign() <= 0:
+		return errors.New("minimum stake must be positive")
+	case bsrr.StakeMaximum != nil && bsrr.StakeMinimum.Cmp(bsrr.StakeMaximum) >= 0:
+		return fmt.Errorf("minimum stake %v must be less than maximum stake %v", bsrr.StakeMinimum, bsrr.StakeMaximum)
+	case bsrr.ForkFactor <= 0 || bsrr.ForkFactor > 1:
+		return fmt.Errorf("fork factor %v must be in (0, 1]", bsrr.ForkFactor)
+	}
+	return nil
+}
+
+// validateForks checks that the scheduled BIP forks activate in order.
+func validateForks(config *params.ChainConfig) error {
+	forks := []struct {
+		name  string
+		block *big.Int
+	}{
+		{"bip1Block", config.BIP1Block},
+		{"bip2Block", config.BIP2Block},
+		{"bip3Block", config.BIP3Block},
+		{"bip4Block", config.BIP4Block},
+		{"bip5Block", config.BIP5Block},
+		{"bip6Block", config.BIP6Block},
+		{"bip7Block", config.BIP7Block},
+		{"bip8Block", config.BIP8Block},
+		{"bip9Block", config.BIP9Block},
+	}
+	var last *big.Int
+	var lastName string
+	for _, fork := range forks {
+		if fork.block == nil {
+			continue
+		}
+		if last != nil && fork.block.Cmp(last) < 0 {
+			return fmt.Errorf("%s %v is scheduled before %s %v", fork.name, fork.block, lastName, last)
+		}
+		last, lastName = fork.block, fork.name
+	}
+	return nil
+}
+
+// toBer converts an amount in wei into whole BER.
+func toBer(wei *big.Int) *big.Int {
+	if wei == nil {
+		return nil
+	}
+	return new(big.Int).Div(wei, big.NewInt(params.Ber))
+}
+
+// fromBer converts an amount in BER into wei.
+func fromBer(ber *big.Int) *big.Int {
+	if ber == nil {
+		return nil
+	}
+	return new(big.Int).Mul(ber, big.NewInt(params.Ber))
 }
 
 // saveGenesis JSON encodes an arbitrary genesis spec into a pre-defined file.
 func saveGenesis(folder, network, client string, spec interface{}) {
 	path := filepath.Join(folder, fmt.Sprintf("%s-%s.json", network, client))
 
-	out, _ := json.Marshal(spec)
+	out, err := json.Marshal(spec)
+	if err != nil {
+		log.Error("Failed to encode genesis", "client", client, "err", err)
+		return
+	}
 	if err := ioutil.WriteFile(path, out, 0644); err != nil {
 		log.Error("Failed to save genesis file", "client", client, "err", err)
 		return
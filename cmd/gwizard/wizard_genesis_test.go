@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/core"
+	"github.com/BerithFoundation/berith-chain/params"
+)
+
+func TestValidateGenesis(t *testing.T) {
+	valid := func() *core.Genesis {
+		return &core.Genesis{
+			Config: &params.ChainConfig{
+				Bsrr: &params.BSRRConfig{
+					Period:       10,
+					Epoch:        360,
+					Rewards:      big.NewInt(0),
+					StakeMinimum: fromBer(big.NewInt(100000)),
+					StakeMaximum: fromBer(big.NewInt(30000000)),
+					SlashRound:   1,
+					ForkFactor:   1.0,
+				},
+				BIP1Block: big.NewInt(10),
+				BIP3Block: big.NewInt(30),
+			},
+		}
+	}
+	tests := []struct {
+		modify func(*core.Genesis)
+		valid  bool
+	}{
+		{func(*core.Genesis) {}, true},
+		{func(g *core.Genesis) { g.Config.Bsrr.StakeMaximum = nil }, true},
+		{func(g *core.Genesis) { g.Config.Bsrr.Epoch = 0 }, false},
+		{func(g *core.Genesis) { g.Config.Bsrr.Period = 0 }, false},
+		{func(g *core.Genesis) { g.Config.Bsrr.StakeMaximum = g.Config.Bsrr.StakeMinimum }, false},
+		{func(g *core.Genesis) { g.Config.Bsrr.ForkFactor = 0 }, false},
+		{func(g *core.Genesis) { g.Config.Bsrr.ForkFactor = 1.5 }, false},
+		{func(g *core.Genesis) { g.Config.BIP2Block = big.NewInt(5) }, false},
+		{func(g *core.Genesis) { g.Config.BIP4Block = big.NewInt(30) }, true},
+		{func(g *core.Genesis) { g.Config.Bsrr = nil }, false},
+	}
+	for i, tt := range tests {
+		genesis := valid()
+		tt.modify(genesis)
+		if err := validateGenesis(genesis); (err == nil) != tt.valid {
+			t.Errorf("test #%d: expected valid %v, got err %v", i, tt.valid, err)
+		}
+	}
+}
+
+// Tests that the wizard asks for the consensus parameters again instead of
+// exporting an invalid genesis.
+func TestMakeGenesisInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gwizard-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := []string{
+		"test", "0", strings.Repeat("11", 20), // network, invalid block period, signer
+		"", "", "", "", // no staking parameters, no forks, no funds, random chain ID
+		"5", "", "", "", "", "", "", // staking parameters asked again
+		"", "", "", "", "", "", "", "", "", // fork schedule asked again
+		dir,
+	}
+	w := &wizard{in: bufio.NewReader(strings.NewReader(strings.Join(input, "\n") + "\n"))}
+	w.makeGenesis()
+
+	if period := w.conf.Genesis.Config.Bsrr.Period; period != 5 {
+		t.Fatalf("block period mismatch: have %d, want 5", period)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test.json")); err != nil {
+		t.Fatalf("genesis not exported: %v", err)
+	}
+}
@@ -21,6 +21,8 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+
+	"github.com/BerithFoundation/berith-chain/log"
 )
 
 // makeWizard creates and returns a new puppeth wizard.
@@ -41,5 +43,16 @@ func (w *wizard) run() {
 	fmt.Println("+-----------------------------------------------------------+")
 	fmt.Println()
 
-	w.makeGenesis()
+	fmt.Println("What would you like to do? (default = create)")
+	fmt.Println(" 1. Create a new genesis file")
+	fmt.Println(" 2. Manage an existing genesis file")
+
+	switch w.read() {
+	case "", "1":
+		w.makeGenesis()
+	case "2":
+		w.manageGenesis()
+	default:
+		log.Error("That's not something I can do")
+	}
 }
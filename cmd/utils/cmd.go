@@ -19,6 +19,7 @@ package utils
 
 import (
 	"compress/gzip"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -26,16 +27,20 @@ import (
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/BerithFoundation/berith-chain/common"
 	"github.com/BerithFoundation/berith-chain/core"
+	"github.com/BerithFoundation/berith-chain/core/archive"
 	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/state"
 	"github.com/BerithFoundation/berith-chain/core/types"
 	"github.com/BerithFoundation/berith-chain/crypto"
 	"github.com/BerithFoundation/berith-chain/berithdb"
 	"github.com/BerithFoundation/berith-chain/internal/debug"
 	"github.com/BerithFoundation/berith-chain/log"
 	"github.com/BerithFoundation/berith-chain/node"
+	"github.com/BerithFoundation/berith-chain/params"
 	"github.com/BerithFoundation/berith-chain/rlp"
 )
 
@@ -237,8 +242,65 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 	return nil
 }
 
+// ExportStateDiffs exports the state changes of a range of canonical blocks into
+// the specified file as JSON lines, appending to the file if data already exists
+// in it. If a state diff database is given, the diffs missing from it are stored
+// into it as well.
+func ExportStateDiffs(blockchain *core.BlockChain, fn string, first uint64, last uint64, diffdb berithdb.Database) error {
+	log.Info("Exporting state diffs", "file", fn, "first", first, "last", last)
+
+	// Open the file handle and potentially wrap with a gzip stream
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_APPEND|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	var (
+		enc    = json.NewEncoder(writer)
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for number := first; number <= last; number++ {
+		block := blockchain.GetBlockByNumber(number)
+		if block == nil {
+			return fmt.Errorf("block #%d not found", number)
+		}
+		diff, err := blockchain.StateDiff(block)
+		if err != nil {
+			return fmt.Errorf("block #%d: %v", number, err)
+		}
+		if diffdb != nil && len(rawdb.ReadStateDiffRLP(diffdb, block.Hash(), number)) == 0 {
+			data, err := rlp.EncodeToBytes(diff)
+			if err != nil {
+				return err
+			}
+			rawdb.WriteStateDiffRLP(diffdb, block.Hash(), number, data)
+		}
+		err = enc.Encode(&struct {
+			Number   uint64               `json:"number"`
+			Hash     common.Hash          `json:"hash"`
+			Accounts []*state.AccountDiff `json:"accounts"`
+		}{number, block.Hash(), diff.Accounts})
+		if err != nil {
+			return err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state diffs", "number", number, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Exported state diffs", "file", fn, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
 // ImportPreimages imports a batch of exported hash preimages into the database.
-func ImportPreimages(db *berithdb.LDBDatabase, fn string) error {
+func ImportPreimages(db berithdb.Database, fn string) error {
 	log.Info("Importing preimages", "file", fn)
 
 	// Open the file handle and potentially unwrap the gzip stream
@@ -285,7 +347,7 @@ func ImportPreimages(db *berithdb.LDBDatabase, fn string) error {
 
 // ExportPreimages exports all known hash preimages into the specified file,
 // truncating any data already present in the file.
-func ExportPreimages(db *berithdb.LDBDatabase, fn string) error {
+func ExportPreimages(db berithdb.Database, fn string) error {
 	log.Info("Exporting preimages", "file", fn)
 
 	// Open the file handle and potentially wrap with a gzip stream
@@ -310,3 +372,228 @@ func ExportPreimages(db *berithdb.LDBDatabase, fn string) error {
 	log.Info("Exported preimages", "file", fn)
 	return nil
 }
+
+// ExportArchive exports the blocks first to last of the chain into an archive
+// created in the given directory, in chunks of chunkSize blocks, together with
+// the staking lists stored for the blocks at the BSRR epoch boundaries.
+func ExportArchive(chain *core.BlockChain, db berithdb.Database, dir string, networkID uint64, first uint64, last uint64, chunkSize uint64, compression string) error {
+	if first == 0 {
+		return fmt.Errorf("the genesis block can't be exported")
+	}
+	if first > last || last > chain.CurrentBlock().NumberU64() {
+		return fmt.Errorf("invalid block range %d-%d, head is %d", first, last, chain.CurrentBlock().NumberU64())
+	}
+	if chunkSize == 0 {
+		return fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+	log.Info("Exporting blockchain archive", "dir", dir, "first", first, "last", last)
+
+	w, err := archive.NewWriter(dir, networkID, chain.Genesis().Hash(), compression)
+	if err != nil {
+		return err
+	}
+	var epoch uint64
+	if chain.Config().Bsrr != nil {
+		epoch = chain.Config().Bsrr.Epoch
+	}
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		stakers int
+	)
+	for from := first; from <= last; from += chunkSize {
+		to := from + chunkSize - 1
+		if to > last {
+			to = last
+		}
+		chunk := new(archive.Chunk)
+		for nr := from; nr <= to; nr++ {
+			block := chain.GetBlockByNumber(nr)
+			if block == nil {
+				return fmt.Errorf("export failed on #%d: not found", nr)
+			}
+			chunk.Blocks = append(chunk.Blocks, block)
+
+			if epoch == 0 || nr%epoch != 0 {
+				continue
+			}
+			// Staking lists not stored yet are derived again by the importing node
+			if data := rawdb.ReadStakersRLP(db, block.Hash()); data != nil {
+				list := &archive.Stakers{Number: nr, Hash: block.Hash()}
+				if err := rlp.DecodeBytes(data, &list.List); err != nil {
+					return fmt.Errorf("invalid staking list of block %d: %v", nr, err)
+				}
+				chunk.Stakers = append(chunk.Stakers, list)
+			}
+		}
+		if err := w.WriteChunk(chunk); err != nil {
+			return err
+		}
+		stakers += len(chunk.Stakers)
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting blockchain archive", "exported", to-first+1, "total", last-first+1, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	log.Info("Exported blockchain archive", "dir", dir, "blocks", last-first+1, "stakers", stakers, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// archiveChunk is a chunk read from an archive, or the failure to read it.
+type archiveChunk struct {
+	chunk *archive.Chunk
+	err   error
+}
+
+// ImportArchive imports the blocks of the archive of a directory into the chain.
+// The chunks are read and verified in parallel, then inserted in order. The
+// chunks already present in the chain are skipped without being read, so that an
+// interrupted import resumes where it stopped. The staking lists of the archive
+// are checked against the ones derived while inserting the blocks, and ignored
+// for the blocks which were already present.
+func ImportArchive(chain *core.BlockChain, db berithdb.Database, dir string, networkID uint64) error {
+	// Watch for Ctrl-C while the import is running.
+	// If a signal is received, the import will stop at the next chunk.
+	interrupt := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	defer close(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted during import, stopping at next chunk")
+		}
+		close(stop)
+	}()
+
+	manifest, err := archive.ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+	if manifest.Genesis != chain.Genesis().Hash() {
+		return fmt.Errorf("archive of another chain: genesis %x, want %x", manifest.Genesis, chain.Genesis().Hash())
+	}
+	if manifest.NetworkID != networkID {
+		return fmt.Errorf("archive of another network: id %d, want %d", manifest.NetworkID, networkID)
+	}
+	log.Info("Importing blockchain archive", "dir", dir, "first", manifest.First, "last", manifest.Last, "chunks", len(manifest.Chunks))
+
+	// Skip the chunks imported by a previous run
+	var (
+		head    = chain.CurrentBlock().NumberU64()
+		pending []int
+	)
+	for i, info := range manifest.Chunks {
+		if header := chain.GetHeaderByNumber(info.Last); info.Last <= head && header != nil && header.Hash() == info.Head {
+			continue
+		}
+		pending = append(pending, i)
+	}
+	if skipped := len(manifest.Chunks) - len(pending); skipped > 0 {
+		log.Info("Skipping chunks already imported", "chunks", skipped)
+	}
+	// Read and verify the chunks in parallel, keeping a bounded number of them
+	// waiting for insertion
+	var (
+		results = make([]chan archiveChunk, len(pending))
+		slots   = make(chan struct{}, runtime.NumCPU())
+		quit    = make(chan struct{})
+	)
+	defer close(quit)
+
+	for i := range results {
+		results[i] = make(chan archiveChunk, 1)
+	}
+	go func() {
+		for i, index := range pending {
+			select {
+			case slots <- struct{}{}:
+			case <-quit:
+				return
+			}
+			go func(i, index int) {
+				chunk, err := manifest.ReadChunk(dir, index)
+				if err == nil {
+					cacheSenders(chain.Config(), chunk.Blocks)
+				}
+				results[i] <- archiveChunk{chunk, err}
+			}(i, index)
+		}
+	}()
+	var (
+		start     = time.Now()
+		imported  int
+		processed = make(map[common.Hash]bool)
+		unchecked []*archive.Stakers
+	)
+	for i, index := range pending {
+		var res archiveChunk
+		select {
+		case res = <-results[i]:
+		case <-stop:
+			return fmt.Errorf("interrupted")
+		}
+		<-slots
+		if res.err != nil {
+			return res.err
+		}
+		if missing := missingBlocks(chain, res.chunk.Blocks); len(missing) > 0 {
+			if _, err := chain.InsertChain(missing); err != nil {
+				return fmt.Errorf("chunk %d: %v", index, err)
+			}
+			imported += len(missing)
+			for _, block := range missing {
+				processed[block.Hash()] = true
+			}
+		}
+		// The staking list of a block is final once its child is processed
+		unchecked = append(unchecked, res.chunk.Stakers...)
+		last := manifest.Chunks[index].Last
+		for len(unchecked) > 0 && unchecked[0].Number < last {
+			if err := checkStakers(db, unchecked[0], processed); err != nil {
+				return err
+			}
+			unchecked = unchecked[1:]
+		}
+		log.Info("Imported archive chunk", "chunk", index, "first", manifest.Chunks[index].First, "last", last, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	for _, stakers := range unchecked {
+		if err := checkStakers(db, stakers, processed); err != nil {
+			return err
+		}
+	}
+	log.Info("Imported blockchain archive", "dir", dir, "blocks", imported, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// cacheSenders recovers the senders of the transactions of the blocks, caching
+// them in the transactions for the insertion.
+func cacheSenders(config *params.ChainConfig, blocks []*types.Block) {
+	for _, block := range blocks {
+		signer := types.MakeSigner(config, block.Number())
+		for _, tx := range block.Transactions() {
+			types.Sender(signer, tx)
+		}
+	}
+}
+
+// checkStakers checks a staking list of an archive against the one derived for
+// its block by the import. The lists of the blocks the node already had are
+// skipped, the archive can't be trusted to fill them in.
+func checkStakers(db berithdb.Database, stakers *archive.Stakers, processed map[common.Hash]bool) error {
+	if !processed[stakers.Hash] {
+		return nil
+	}
+	found, err := stakers.Verify(db)
+	if err != nil {
+		return err
+	}
+	if !found {
+		log.Warn("No staking list derived for archived block", "number", stakers.Number, "hash", stakers.Hash)
+	}
+	return nil
+}
@@ -43,6 +43,7 @@ import (
 	"github.com/BerithFoundation/berith-chain/common/fdlimit"
 	"github.com/BerithFoundation/berith-chain/consensus"
 	"github.com/BerithFoundation/berith-chain/core"
+	"github.com/BerithFoundation/berith-chain/core/archive"
 	"github.com/BerithFoundation/berith-chain/core/state"
 	"github.com/BerithFoundation/berith-chain/core/vm"
 	"github.com/BerithFoundation/berith-chain/crypto"
@@ -50,6 +51,7 @@ import (
 	"github.com/BerithFoundation/berith-chain/log"
 	"github.com/BerithFoundation/berith-chain/metrics"
 	"github.com/BerithFoundation/berith-chain/metrics/influxdb"
+	"github.com/BerithFoundation/berith-chain/miner"
 	"github.com/BerithFoundation/berith-chain/node"
 	"github.com/BerithFoundation/berith-chain/p2p"
 	"github.com/BerithFoundation/berith-chain/p2p/discv5"
@@ -124,6 +126,10 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -132,6 +138,10 @@ var (
 		Name:  "nousb",
 		Usage: "Disables monitoring for and managing USB hardware wallets",
 	}
+	ExternalSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "External signer (url or path to ipc file)",
+	}
 	NetworkIdFlag = cli.Uint64Flag{
 		Name:  "networkid",
 		Usage: "Network identifier (integer, 1=Frontier, 2=Morden (disused), 3=Ropsten, 4=Rinkeby)",
@@ -173,6 +183,64 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "archive",
 	}
+	NoAddressIndexFlag = cli.BoolFlag{
+		Name:  "noaddressindex",
+		Usage: "Disables the address to transaction index (saves disk space, disables berith_getTransactionsByAddress)",
+	}
+	NoSnapshotFlag = cli.BoolFlag{
+		Name:  "nosnapshot",
+		Usage: "Disables the flat state snapshot (saves disk space, state reads go through the tries)",
+	}
+	StateDiffsFlag = cli.BoolFlag{
+		Name:  "statediffs",
+		Usage: "Records the state changes of every imported block into a separate database (serves debug_stateDiff)",
+	}
+	DBEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: "Storage engine of the new databases, existing ones keep their own (" + strings.Join(berithdb.Backends(), ", ") + ")",
+		Value: berithdb.DefaultBackend,
+	}
+	AncientThresholdFlag = cli.Uint64Flag{
+		Name:  "ancient.threshold",
+		Usage: "Number of recent blocks kept in the chain database, older ones move to the ancient store (0 = disabled)",
+		Value: berith.DefaultConfig.AncientThreshold,
+	}
+	BloomFilterSizeFlag = cli.Uint64Flag{
+		Name:  "bloomfilter.size",
+		Usage: "Megabytes of memory allocated to the bloom filter marking the retained state during pruning",
+		Value: 2048,
+	}
+	PruneRetainFlag = cli.Uint64Flag{
+		Name:  "prune.retain",
+		Usage: "Number of recent block states kept by pruning (0 = twice the BSRR epoch)",
+	}
+	ArchiveChunkSizeFlag = cli.Uint64Flag{
+		Name:  "archive.chunksize",
+		Usage: "Number of blocks per chunk of an exported chain archive",
+		Value: 1000,
+	}
+	ArchiveCompressionFlag = cli.StringFlag{
+		Name:  "archive.compression",
+		Usage: `Compression of the chunks of an exported chain archive ("gzip", "none")`,
+		Value: archive.CompressionGzip,
+	}
+	SnapshotBlockFlag = cli.Uint64Flag{
+		Name:  "block",
+		Usage: "Number of the block whose state snapshot is exported (0 = head block)",
+	}
+	SnapshotHashFlag = cli.StringFlag{
+		Name:  "snapshot.hash",
+		Usage: "Trusted hash of the block of a state snapshot",
+	}
+	SnapshotRootFlag = cli.StringFlag{
+		Name:  "snapshot.root",
+		Usage: "Trusted state root of the block of a state snapshot",
+	}
+	SnapshotTDFlag = BigFlag{
+		Name:  "snapshot.td",
+		Usage: "Trusted total difficulty of the block of a state snapshot",
+		Value: new(big.Int),
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -210,6 +278,10 @@ var (
 		Usage: "Time interval to regenerate the local transaction journal",
 		Value: core.DefaultTxPoolConfig.Rejournal,
 	}
+	TxPoolJournalRemotesFlag = cli.BoolFlag{
+		Name:  "txpool.journalremotes",
+		Usage: "Persist the whole pool, including remote transactions, into the chain database instead of the local journal",
+	}
 	TxPoolPriceLimitFlag = cli.Uint64Flag{
 		Name:  "txpool.pricelimit",
 		Usage: "Minimum gas price limit to enforce for acceptance into the pool",
@@ -342,6 +414,15 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerBuilderFlag = cli.StringFlag{
+		Name:  "miner.builder",
+		Usage: "Block builder ordering the mined transactions (price, fcfs, priority)",
+		Value: miner.PriceBuilder,
+	}
+	MinerPriorityFlag = cli.StringFlag{
+		Name:  "miner.priority",
+		Usage: "Comma separated senders served first by the priority block builder",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -353,6 +434,20 @@ var (
 		Usage: "Password file to use for non-interactive password input",
 		Value: "",
 	}
+	MnemonicFlag = cli.BoolFlag{
+		Name:  "mnemonic",
+		Usage: "Derive the new account from a generated BIP-39 mnemonic",
+	}
+	MnemonicPasswordFileFlag = cli.StringFlag{
+		Name:  "mnemonic.password",
+		Usage: "File containing the optional BIP-39 mnemonic passphrase",
+		Value: "",
+	}
+	HDPathFlag = cli.StringFlag{
+		Name:  "hdpath",
+		Usage: "BIP-32 derivation path of the mnemonic account",
+		Value: accounts.DefaultBaseDerivationPath.String(),
+	}
 
 	VMEnableDebugFlag = cli.BoolFlag{
 		Name:  "vmdebug",
@@ -855,6 +950,32 @@ func MakePasswordList(ctx *cli.Context) []string {
 	return lines
 }
 
+// MakeMnemonicPassphrase reads the optional BIP-39 mnemonic passphrase from the
+// file given on the command line, the empty passphrase if none was given.
+func MakeMnemonicPassphrase(ctx *cli.Context) string {
+	path := ctx.GlobalString(MnemonicPasswordFileFlag.Name)
+	if path == "" {
+		return ""
+	}
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		Fatalf("Failed to read mnemonic password file: %v", err)
+	}
+	return strings.TrimRight(string(text), "\r\n")
+}
+
+// MakeDerivationPath parses the BIP-32 derivation path given on the command line.
+func MakeDerivationPath(ctx *cli.Context) accounts.DerivationPath {
+	path := accounts.DefaultBaseDerivationPath
+	if ctx.GlobalIsSet(HDPathFlag.Name) {
+		var err error
+		if path, err = accounts.ParseDerivationPath(ctx.GlobalString(HDPathFlag.Name)); err != nil {
+			Fatalf("Invalid derivation path: %v", err)
+		}
+	}
+	return path
+}
+
 func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	setNodeKey(ctx, cfg)
 	setNAT(ctx, cfg)
@@ -932,6 +1053,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 
 	setDataDir(ctx, cfg)
 
+	if ctx.GlobalIsSet(DBEngineFlag.Name) {
+		cfg.DBEngine = ctx.GlobalString(DBEngineFlag.Name)
+	}
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
 	}
@@ -941,6 +1065,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(NoUSBFlag.Name) {
 		cfg.NoUSB = ctx.GlobalBool(NoUSBFlag.Name)
 	}
+	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
+		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
+	}
 }
 
 func setDataDir(ctx *cli.Context, cfg *node.Config) {
@@ -983,6 +1110,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolJournalRemotesFlag.Name) {
+		cfg.JournalRemotes = ctx.GlobalBool(TxPoolJournalRemotesFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.GlobalUint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -1116,6 +1246,16 @@ func SetBerithConfig(ctx *cli.Context, stack *node.Node, cfg *berith.Config) {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
+	cfg.NoAddressIndex = ctx.GlobalBool(NoAddressIndexFlag.Name)
+	cfg.NoSnapshot = ctx.GlobalBool(NoSnapshotFlag.Name)
+	cfg.StateDiffs = ctx.GlobalBool(StateDiffsFlag.Name)
+
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+	}
+	if ctx.GlobalIsSet(AncientThresholdFlag.Name) {
+		cfg.AncientThreshold = ctx.GlobalUint64(AncientThresholdFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
@@ -1156,6 +1296,19 @@ func SetBerithConfig(ctx *cli.Context, stack *node.Node, cfg *berith.Config) {
 	if ctx.GlobalIsSet(MinerNoVerfiyFlag.Name) {
 		cfg.MinerNoverify = ctx.Bool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerBuilderFlag.Name) {
+		cfg.MinerBuilder = ctx.GlobalString(MinerBuilderFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerPriorityFlag.Name) {
+		senders := strings.Split(ctx.GlobalString(MinerPriorityFlag.Name), ",")
+		for _, account := range senders {
+			if trimmed := strings.TrimSpace(account); !common.IsHexAddress(trimmed) {
+				Fatalf("Invalid account in --miner.priority: %s", trimmed)
+			} else {
+				cfg.MinerPriority = append(cfg.MinerPriority, common.HexToAddress(trimmed))
+			}
+		}
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
@@ -1283,17 +1436,35 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) berithdb.Database {
 		cache   = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
 		handles = makeDatabaseHandles()
 	)
-	name := "chaindata"
+	var (
+		chainDb berithdb.Database
+		err     error
+	)
 	if ctx.GlobalString(SyncModeFlag.Name) == "light" {
-		name = "lightchaindata"
+		chainDb, err = stack.OpenDatabase("lightchaindata", cache, handles)
+	} else {
+		chainDb, err = stack.OpenDatabaseWithFreezer("chaindata", cache, handles, ctx.GlobalString(AncientFlag.Name), ctx.GlobalUint64(AncientThresholdFlag.Name))
 	}
-	chainDb, err := stack.OpenDatabase(name, cache, handles)
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
 	return chainDb
 }
 
+// MakeStateDiffDatabase opens the database recording the state changes of the
+// imported blocks.
+func MakeStateDiffDatabase(ctx *cli.Context, stack *node.Node) berithdb.Database {
+	var (
+		cache   = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100 / 4
+		handles = makeDatabaseHandles() / 4
+	)
+	db, err := stack.OpenDatabase("statediffs", cache, handles)
+	if err != nil {
+		Fatalf("Could not open state diff database: %v", err)
+	}
+	return db
+}
+
 func MakeGenesis(ctx *cli.Context) *core.Genesis {
 	var genesis *core.Genesis
 	switch {
@@ -1313,8 +1484,13 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if err != nil {
 		Fatalf("%v", err)
 	}
+	if err := stakingdb.Migrate(chainDb, stack.ResolvePath("stakingDB")); err != nil {
+		Fatalf("Failed to migrate staking database: %v", err)
+	}
+	stakingDB := stakingdb.NewStakingDB(chainDb, staking.NewStakers)
+
 	var engine consensus.Engine
-	engine = bsrr.New(config.Bsrr, chainDb)
+	engine = bsrr.NewCliqueWithStakingDB(stakingDB, config.Bsrr, chainDb)
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
@@ -1323,6 +1499,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		TrieCleanLimit: berith.DefaultConfig.TrieCleanCache,
 		TrieDirtyLimit: berith.DefaultConfig.TrieDirtyCache,
 		TrieTimeLimit:  berith.DefaultConfig.TrieTimeout,
+		NoSnapshot:     ctx.GlobalBool(NoSnapshotFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cache.TrieCleanLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
@@ -1332,10 +1509,6 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	}
 	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
 
-	stakingDB := &stakingdb.StakingDB{}
-	stakingDBPath := stack.ResolvePath("stakingDB")
-	stakingDB.CreateDB(stakingDBPath, staking.NewStakers)
-
 	chain, err = core.NewBlockChain(stakingDB, chainDb, cache, config, engine, vmcfg, nil)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
@@ -368,16 +368,27 @@ func (ma *MixedcaseAddress) UnmarshalJSON(input []byte) error {
 	//if err := hexutil.UnmarshalFixedJSON(addressT, input, ma.addr[:]); err != nil {
 	//	return err
 	//}
-	// [BERITH] Unmarshal directly because berith address has no hex prefix
-	if err := ma.addr.UnmarshalJSON(input); err != nil {
+	// [BERITH] Unmarshal directly because berith address has no hex prefix,
+	// the hex prefix written by MarshalJSON is accepted as well
+	var original string
+	if err := json.Unmarshal(input, &original); err != nil {
 		return err
 	}
-	return json.Unmarshal(input, &ma.original)
+	text := original
+	if hasHexPrefix(text) {
+		text = AddressPrefix + text[2:]
+	}
+	if err := ma.addr.UnmarshalText([]byte(text)); err != nil {
+		return err
+	}
+	ma.original = original
+	return nil
 }
 
 // MarshalJSON marshals the original value
 func (ma *MixedcaseAddress) MarshalJSON() ([]byte, error) {
-	if strings.HasPrefix(ma.original, "0x") || strings.HasPrefix(ma.original, "0X") {
+	// [BERITH] The berith address prefix is replaced by the hex prefix as well
+	if strings.HasPrefix(ma.original, "0x") || strings.HasPrefix(ma.original, "0X") || HasAddressPrefix(ma.original) {
 		return json.Marshal(fmt.Sprintf("0x%s", ma.original[2:]))
 	}
 	return json.Marshal(fmt.Sprintf("0x%s", ma.original))
@@ -398,7 +409,12 @@ func (ma *MixedcaseAddress) String() string {
 
 // ValidChecksum returns true if the address has valid checksum
 func (ma *MixedcaseAddress) ValidChecksum() bool {
-	return ma.original == ma.addr.Hex()
+	// [BERITH] The checksum is in the hex digits, whatever the prefix is
+	original := ma.original
+	if hasHexPrefix(original) {
+		original = AddressPrefix + original[2:]
+	}
+	return original == ma.addr.Hex()
 }
 
 // Original returns the mixed-case input string
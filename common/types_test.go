@@ -204,7 +204,8 @@ func TestMixedcaseAccount_Address(t *testing.T) {
 	if err := json.Unmarshal([]byte(`[
 		{"A" : "Bxae967917c465db8578ca9024c205720b1a3651A9", "Valid": false},
 		{"A" : "BxAe967917c465db8578ca9024c205720b1a3651A9", "Valid": true},
-		{"A" : "Bx1111111111111111111112222222222223333323", "Valid": true}
+		{"A" : "Bx1111111111111111111112222222222223333323", "Valid": true},
+		{"A" : "0xAe967917c465db8578ca9024c205720b1a3651A9", "Valid": true}
 		]`), &res); err != nil {
 		t.Fatal(err)
 	}
@@ -232,6 +233,24 @@ func TestMixedcaseAccount_Address(t *testing.T) {
 	}
 }
 
+func TestMixedcaseAddressJSON(t *testing.T) {
+	addr := NewMixedcaseAddress(HexToAddress("0xAe967917c465db8578ca9024c205720b1a3651A9"))
+	enc, err := json.Marshal(&addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"0xAe967917c465db8578ca9024c205720b1a3651A9"`; string(enc) != want {
+		t.Errorf("encoding mismatch: have %s, want %s", enc, want)
+	}
+	var dec MixedcaseAddress
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec.Address() != addr.Address() || !dec.ValidChecksum() {
+		t.Errorf("decoding mismatch: have %v, want %v", dec.String(), addr.String())
+	}
+}
+
 func TestHash_Scan(t *testing.T) {
 	type args struct {
 		src interface{}
@@ -580,7 +580,15 @@ func (c *BSRR) Finalize(chain consensus.ChainReader, header *types.Header, state
 	header.UncleHash = types.CalcUncleHash(nil)
 
 	// Assemble and return the final block for sealing
-	return types.NewBlock(header, txs, nil, receipts), nil
+	block := types.NewBlock(header, txs, nil, receipts)
+
+	//[BERITH] 블록과 같은 batch 로 저장되도록 블록의 StakingList 를 준비해 둔다.
+	if len(header.Extra) >= extraSeal {
+		if err := c.stakingDB.Stage(c.SealHash(block.Header()), stks); err != nil {
+			return nil, errStakingList
+		}
+	}
+	return block, nil
 }
 
 // Authorize injects a private key into the consensus engine to mint new blocks
@@ -736,22 +744,12 @@ func (c *BSRR) calcDifficultyAndRank(signer common.Address, chain consensus.Chai
 		return big.NewInt(diffWithoutStaker), 1
 	}
 
-	stks, err := c.getStakers(chain, target.Number.Uint64(), target.Hash())
-
-	if err != nil {
-		log.Error("failed to get stakers", "err", err.Error())
-		return big.NewInt(0), -1
-	}
-
-	stateDB, err := chain.StateAt(target.Root)
-
+	results, err := c.voteResults(chain, target)
 	if err != nil {
-		log.Error("failed to get state", "err", err.Error())
+		log.Error("failed to select block creators", "err", err.Error())
 		return big.NewInt(0), -1
 	}
 
-	results := selection.SelectBlockCreator(chain.Config(), target.Number.Uint64(), target.Hash(), stks, stateDB)
-
 	max := c.getMaxMiningCandidates(len(results))
 
 	if results[signer].Rank > max {
@@ -762,6 +760,21 @@ func (c *BSRR) calcDifficultyAndRank(signer common.Address, chain consensus.Chai
 	return results[signer].Score, results[signer].Rank
 }
 
+// [BERITH] voteResults 주어진 target 블록의 스테이킹 리스트와 상태로 블록 생성자 선출 결과를 반환한다.
+func (c *BSRR) voteResults(chain consensus.ChainReader, target *types.Header) (selection.VoteResults, error) {
+	stks, err := c.getStakers(chain, target.Number.Uint64(), target.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	stateDB, err := chain.StateAt(target.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	return selection.SelectBlockCreator(chain.Config(), target.Number.Uint64(), target.Hash(), stks, stateDB), nil
+}
+
 // getDelay 주어진 rank에 따라 블록 Sealing에 대한 지연 시간을 반환한다.
 // 항상 0보다 크거나 같은 값을 반환
 func (c *BSRR) getDelay(rank int) time.Duration {
@@ -846,6 +859,12 @@ func (c *BSRR) accumulateRewards(chain consensus.ChainReader, state *state.State
 			continue
 		}
 
+		//bihind --> stake, 자동 복리가 설정된 스테이킹 계정
+		if c.compoundReward(config, state, addr, behind.Balance, header.Number) {
+			state.RemoveFirstBehindBalance(addr)
+			continue
+		}
+
 		//bihind --> main
 		state.AddBalance(addr, behind.Balance)
 
@@ -853,6 +872,29 @@ func (c *BSRR) accumulateRewards(chain consensus.ChainReader, state *state.State
 	}
 }
 
+// compoundReward moves a matured reward into the stake balance of a staking
+// account that enabled auto-compounding, updating its selection point. It
+// reports false if the reward has to be paid into the main balance instead,
+// including when compounding would exceed the maximum stake. No transaction
+// records the change, the staking events inspect the stakers of the parent block
+// for it.
+func (c *BSRR) compoundReward(config *params.ChainConfig, state *state.StateDB, addr common.Address, reward, number *big.Int) bool {
+	if !config.IsBIP5(number) || !state.GetAutoCompound(addr) {
+		return false
+	}
+	prevStake := state.GetStakeBalance(addr)
+	if prevStake.Sign() <= 0 {
+		return false
+	}
+	if max := c.config.StakeMaximum; max != nil && new(big.Int).Add(prevStake, reward).Cmp(max) >= 0 {
+		return false
+	}
+	prevStake = new(big.Int).Set(prevStake)
+	state.AddStakeBalance(addr, reward, number)
+	state.SetPoint(addr, c.calcPoint(state, addr, prevStake, number))
+	return true
+}
+
 func (c *BSRR) supportBIP1(chain consensus.ChainReader, parent *types.Header, stks staking.Stakers) (staking.Stakers, error) {
 	st, err := chain.StateAt(parent.Root)
 	if err != nil {
@@ -870,7 +912,7 @@ func (c *BSRR) supportBIP1(chain consensus.ChainReader, parent *types.Header, st
 		return nil, err
 	}
 	c.cache.Add(parent.Hash(), bytes)
-	c.stakingDB.Commit(parent.Hash().Hex(), stks)
+	c.stakingDB.Commit(parent.Hash(), stks)
 
 	return stks, nil
 }
@@ -907,7 +949,7 @@ func (c *BSRR) getStakers(chain consensus.ChainReader, number uint64, hash commo
 		//[BERITH] DB에 저장된 StakingList를 찾은 경우
 
 		var err error
-		list, err = c.stakingDB.GetStakers(prevHash.Hex())
+		list, err = c.stakingDB.GetStakers(prevHash)
 		if err == nil {
 			break
 		}
@@ -941,7 +983,7 @@ func (c *BSRR) getStakers(chain consensus.ChainReader, number uint64, hash commo
 		return nil, err
 	}
 	c.cache.Add(hash, bytes)
-	c.stakingDB.Commit(hash.Hex(), list)
+	c.stakingDB.Commit(hash, list)
 
 	return list, nil
 }
@@ -979,12 +1021,38 @@ func (c *BSRR) setStakersWithTxs(state *state.StateDB, chain consensus.ChainRead
 
 	stkChanged := make(map[common.Address]bool)
 
+	//[BERITH] 키 교체로 생성된 주소와 교체 전 주소
+	migrated := make(map[common.Address]common.Address)
+
 	for _, tx := range txs {
 		msg, err := tx.AsMessage(types.MakeSigner(chain.Config(), number))
 		if err != nil {
 			return err
 		}
 
+		//[BERITH] 키 교체 TX 는 스테이킹 리스트의 주소를 새 키의 주소로 바꾼다.
+		//블록에 포함된 키 교체 TX 는 모두 성공하므로 TX 만으로 판단할 수 있다.
+		if types.IsKeyMigration(chain.Config(), number, msg.To(), msg.Base(), msg.Target()) {
+			to, err := types.KeyMigrationTarget(chain.Config().ChainID, msg.From(), msg.Data())
+			if err != nil {
+				return err
+			}
+			if stks.IsContain(msg.From()) {
+				stks.Remove(msg.From())
+				stks.Put(to)
+			}
+			if isAdd, ok := stkChanged[msg.From()]; ok {
+				stkChanged[to] = isAdd
+				delete(stkChanged, msg.From())
+			}
+			if origin, ok := migrated[msg.From()]; ok {
+				migrated[to] = origin
+			} else {
+				migrated[to] = msg.From()
+			}
+			continue
+		}
+
 		//Main -> Main (일반 TX)
 		if msg.Base() == types.Main && msg.Target() == types.Main {
 			continue
@@ -994,7 +1062,7 @@ func (c *BSRR) setStakersWithTxs(state *state.StateDB, chain consensus.ChainRead
 		//마지막 Staking의 블록번호가 저장되도록 수정
 		//일반 Tx가 아닌 경우 Stake or Unstake
 		if chain.Config().IsBIP1(number) {
-			if msg.Base() == types.Main && msg.Target() == types.Stake {
+			if msg.Base() != types.Stake && msg.Target() == types.Stake {
 				stkChanged[msg.From()] = true
 			} else if msg.Base() == types.Stake && msg.Target() == types.Main {
 				stkChanged[msg.From()] = false
@@ -1012,18 +1080,12 @@ func (c *BSRR) setStakersWithTxs(state *state.StateDB, chain consensus.ChainRead
 
 	for addr, isAdd := range stkChanged {
 		if state != nil {
-			point := big.NewInt(0)
-			currentStkBal := state.GetStakeBalance(addr)
-			if currentStkBal.Cmp(big.NewInt(0)) == 1 {
-				currentStkBal = new(big.Int).Div(currentStkBal, big.NewInt(1e+18))
-				prevStkBal := new(big.Int).Div(prevState.GetStakeBalance(addr), big.NewInt(1e+18))
-				additionalStkBal := new(big.Int).Sub(currentStkBal, prevStkBal)
-				currentBlock := header.Number
-				lastStkBlock := new(big.Int).Set(state.GetStakeUpdated(addr))
-				period := c.config.Period
-				point = staking.CalcPointBigint(prevStkBal, additionalStkBal, currentBlock, lastStkBlock, period)
+			//[BERITH] 키 교체된 주소는 교체 전 주소의 스테이킹 수량을 이어받는다.
+			prev := addr
+			if origin, ok := migrated[addr]; ok {
+				prev = origin
 			}
-			state.SetPoint(addr, point)
+			state.SetPoint(addr, c.calcPoint(state, addr, prevState.GetStakeBalance(prev), header.Number))
 		}
 
 		if isAdd {
@@ -1059,6 +1121,19 @@ func (c *BSRR) setStakersWithTxs(state *state.StateDB, chain consensus.ChainRead
 	return nil
 }
 
+//[BERITH] calcPoint 스테이크 수량이 prevStake 에서 변경된 계정의 선출 포인트를 계산한다.
+func (c *BSRR) calcPoint(state *state.StateDB, addr common.Address, prevStake, number *big.Int) *big.Int {
+	currentStkBal := state.GetStakeBalance(addr)
+	if currentStkBal.Cmp(big.NewInt(0)) != 1 {
+		return big.NewInt(0)
+	}
+	currentStkBal = new(big.Int).Div(currentStkBal, big.NewInt(1e+18))
+	prevStkBal := new(big.Int).Div(prevStake, big.NewInt(1e+18))
+	additionalStkBal := new(big.Int).Sub(currentStkBal, prevStkBal)
+	lastStkBlock := new(big.Int).Set(state.GetStakeUpdated(addr))
+	return staking.CalcPointBigint(prevStkBal, additionalStkBal, number, lastStkBlock, c.config.Period)
+}
+
 type signers []common.Address
 
 func (s signers) signersMap() map[common.Address]struct{} {
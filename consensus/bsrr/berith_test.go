@@ -1,11 +1,15 @@
 package bsrr
 
 import (
+	"math/big"
 	"testing"
 	"time"
 
 	"github.com/BerithFoundation/berith-chain/berith/selection"
+	"github.com/BerithFoundation/berith-chain/berith/staking"
+	"github.com/BerithFoundation/berith-chain/berithdb"
 	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/state"
 	"github.com/BerithFoundation/berith-chain/params"
 )
 
@@ -77,3 +81,87 @@ func TestGetDelay(t *testing.T) {
 		}
 	}
 }
+
+func TestStakeTargetNumber(t *testing.T) {
+	var c = &BSRR{
+		config: &params.BSRRConfig{
+			Period: 10,
+			Epoch:  10,
+		},
+	}
+	tests := []struct {
+		parent   uint64
+		expected uint64
+	}{
+		{0, 0},   // genesis parent
+		{9, 0},   // first epoch
+		{10, 10}, // second epoch
+		{19, 10}, // end of second epoch
+		{20, 10}, // epoch ancestor
+		{35, 25}, // epoch ancestor
+	}
+
+	for i, tt := range tests {
+		result := c.stakeTargetNumber(tt.parent)
+		if result != tt.expected {
+			t.Errorf("test #%d: expected : %d but %d", i, tt.expected, result)
+		}
+	}
+}
+
+// Tests that matured rewards are compounded into the stake of the accounts that
+// enabled it from BIP5 on, and paid into the main balance otherwise.
+func TestCompoundReward(t *testing.T) {
+	var (
+		c = &BSRR{
+			config: &params.BSRRConfig{
+				Period:       10,
+				Epoch:        10,
+				StakeMaximum: stakeAmount(200),
+			},
+		}
+		config = &params.ChainConfig{BIP5Block: big.NewInt(30)}
+		addr   = common.BigToAddress(big.NewInt(1))
+		number = big.NewInt(30)
+	)
+	tests := []struct {
+		name     string
+		number   int64
+		stake    int64
+		reward   int64
+		compound bool
+		want     bool
+	}{
+		{"before BIP5", 29, 100, 10, true, false},
+		{"disabled", 30, 100, 10, false, false},
+		{"not staking", 30, 0, 10, true, false},
+		{"above maximum", 30, 150, 50, true, false},
+		{"compounded", 30, 100, 10, true, true},
+	}
+	for _, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(berithdb.NewMemDatabase()))
+		statedb.AddBalance(addr, big.NewInt(1))
+		if tt.stake > 0 {
+			statedb.SetStaking(addr, stakeAmount(tt.stake), big.NewInt(1))
+		}
+		statedb.SetAutoCompound(addr, tt.compound)
+
+		if have := c.compoundReward(config, statedb, addr, stakeAmount(tt.reward), big.NewInt(tt.number)); have != tt.want {
+			t.Errorf("%s: compounded %v, want %v", tt.name, have, tt.want)
+			continue
+		}
+		stake := tt.stake
+		if tt.want {
+			stake += tt.reward
+		}
+		if have := statedb.GetStakeBalance(addr); have.Cmp(stakeAmount(stake)) != 0 {
+			t.Errorf("%s: stake balance %v, want %v", tt.name, have, stakeAmount(stake))
+		}
+		if tt.want {
+			point := staking.CalcPointBigint(big.NewInt(tt.stake), big.NewInt(tt.reward), number, number, c.config.Period)
+			if have := statedb.GetPoint(addr); have.Cmp(point) != 0 {
+				t.Errorf("%s: point %v, want %v", tt.name, have, point)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package bsrr
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/common/hexutil"
+	"github.com/BerithFoundation/berith-chain/consensus"
+)
+
+// maxSealingSlots is the maximum number of future heights inspected at once.
+const maxSealingSlots = 128
+
+// errNoSigner is returned if the sealing status is requested without a signer.
+var errNoSigner = errors.New("no signer to inspect")
+
+/*
+[BERITH]
+SealingSlot describes what Seal would decide for a signer at a future height:
+whether the signer may seal it, with which rank and difficulty, and how long it
+would wait before propagating the block.
+*/
+type SealingSlot struct {
+	Number        hexutil.Uint64 `json:"number"`
+	TargetNumber  hexutil.Uint64 `json:"stakeTargetNumber"`
+	TargetHash    common.Hash    `json:"stakeTargetHash"`
+	Candidates    int            `json:"candidates"`
+	MaxCandidates int            `json:"maxCandidates"`
+	Signer        bool           `json:"signer"`     // Whether the signer is among the signers of the stake target block
+	Rank          int            `json:"rank"`       // Selection rank, 0 if not selected
+	InRange       bool           `json:"inRange"`    // Whether the rank is within the maximum mining candidates
+	Authorized    bool           `json:"authorized"` // Whether Seal would sign the block
+	Difficulty    *hexutil.Big   `json:"difficulty"`
+	Delay         string         `json:"delay"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// SealingStatus reports the expected sealing slots of the given signer for the
+// next count block heights on top of the current head. Heights whose stake
+// target block isn't available yet are reported with an error.
+func (c *BSRR) SealingStatus(chain consensus.ChainReader, signer common.Address, count uint64) ([]*SealingSlot, error) {
+	if signer == (common.Address{}) {
+		return nil, errNoSigner
+	}
+	if count == 0 {
+		count = 1
+	}
+	if count > maxSealingSlots {
+		count = maxSealingSlots
+	}
+	head := chain.CurrentHeader().Number.Uint64()
+
+	slots := make([]*SealingSlot, 0, count)
+	for number := head + 1; number <= head+count; number++ {
+		slots = append(slots, c.sealingSlot(chain, signer, number))
+	}
+	return slots, nil
+}
+
+// sealingSlot mirrors the checks of Prepare and Seal for the given height.
+func (c *BSRR) sealingSlot(chain consensus.ChainReader, signer common.Address, number uint64) *SealingSlot {
+	targetNumber := c.stakeTargetNumber(number - 1)
+	slot := &SealingSlot{
+		Number:       hexutil.Uint64(number),
+		TargetNumber: hexutil.Uint64(targetNumber),
+		Difficulty:   (*hexutil.Big)(new(big.Int)),
+		Delay:        "0s",
+	}
+	target := chain.GetHeaderByNumber(targetNumber)
+	if target == nil || !chain.HasBlockAndState(target.Hash(), targetNumber) {
+		slot.Error = consensus.ErrUnknownAncestor.Error()
+		return slot
+	}
+	slot.TargetHash = target.Hash()
+
+	signers, err := c.getSigners(chain, target)
+	if err != nil {
+		slot.Error = err.Error()
+		return slot
+	}
+	_, slot.Signer = signers.signersMap()[signer]
+
+	if targetNumber == 0 {
+		// Blocks of the first epoch are sealed by the genesis signers
+		slot.Candidates, slot.MaxCandidates = len(signers), len(signers)
+		if slot.Signer {
+			slot.Rank, slot.InRange, slot.Authorized = 1, true, true
+			slot.Difficulty = (*hexutil.Big)(big.NewInt(diffWithoutStaker))
+		}
+		return slot
+	}
+	results, err := c.voteResults(chain, target)
+	if err != nil {
+		slot.Error = err.Error()
+		return slot
+	}
+	slot.Candidates = len(results)
+	slot.MaxCandidates = c.getMaxMiningCandidates(len(results))
+
+	result, ok := results[signer]
+	if !ok || result.Rank < 1 {
+		return slot
+	}
+	slot.Rank = result.Rank
+	slot.InRange = result.Rank <= slot.MaxCandidates
+	slot.Authorized = slot.Signer && slot.InRange
+	if slot.InRange {
+		slot.Difficulty = (*hexutil.Big)(new(big.Int).Set(result.Score))
+		slot.Delay = c.getDelay(result.Rank).String()
+	}
+	return slot
+}
+
+// stakeTargetNumber returns the number of the canonical stake target block of
+// the given parent, following the rules of getStakeTargetBlock.
+func (c *BSRR) stakeTargetNumber(parent uint64) uint64 {
+	switch d := parent / c.config.Epoch; {
+	case d > 1:
+		return parent - c.config.Epoch
+	case d == 1:
+		return c.config.Epoch
+	default:
+		return 0
+	}
+}
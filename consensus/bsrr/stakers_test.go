@@ -0,0 +1,177 @@
+package bsrr
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/berith/staking"
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/consensus"
+	"github.com/BerithFoundation/berith-chain/core/state"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/crypto"
+	"github.com/BerithFoundation/berith-chain/params"
+)
+
+// stakersChain is a chain reader serving the parent header and state of the
+// block whose transactions update the staking list.
+type stakersChain struct {
+	consensus.ChainReader
+	config *params.ChainConfig
+	parent *types.Header
+	state  *state.StateDB
+}
+
+func (c *stakersChain) Config() *params.ChainConfig { return c.config }
+
+func (c *stakersChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if hash == c.parent.Hash() {
+		return c.parent
+	}
+	return nil
+}
+
+func (c *stakersChain) StateAt(root common.Hash) (*state.StateDB, error) {
+	return c.state, nil
+}
+
+// stakersTest holds the keys, the chain and the engine of a staking list test.
+type stakersTest struct {
+	t       *testing.T
+	engine  *BSRR
+	chain   *stakersChain
+	header  *types.Header
+	oldKey  *ecdsa.PrivateKey
+	newKey  *ecdsa.PrivateKey
+	oldAddr common.Address
+	newAddr common.Address
+}
+
+// newStakersTest creates a chain whose parent state gives the old key a stake.
+func newStakersTest(t *testing.T) *stakersTest {
+	oldKey, _ := crypto.GenerateKey()
+	newKey, _ := crypto.GenerateKey()
+	st := &stakersTest{
+		t:       t,
+		engine:  &BSRR{config: &params.BSRRConfig{Period: 10, Epoch: 10}},
+		oldKey:  oldKey,
+		newKey:  newKey,
+		oldAddr: crypto.PubkeyToAddress(oldKey.PublicKey),
+		newAddr: crypto.PubkeyToAddress(newKey.PublicKey),
+	}
+	prev, _ := state.New(common.Hash{}, state.NewDatabase(berithdb.NewMemDatabase()))
+	prev.AddBalance(st.oldAddr, big.NewInt(1))
+	prev.SetStaking(st.oldAddr, stakeAmount(100), big.NewInt(1))
+
+	parent := &types.Header{Number: big.NewInt(20)}
+	st.chain = &stakersChain{
+		config: &params.ChainConfig{ChainID: big.NewInt(1), BIP1Block: big.NewInt(0), BIP8Block: big.NewInt(0)},
+		parent: parent,
+		state:  prev,
+	}
+	st.header = &types.Header{ParentHash: parent.Hash(), Number: big.NewInt(21)}
+	return st
+}
+
+// stakeAmount returns the given number of whole coins.
+func stakeAmount(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18))
+}
+
+// tx signs a transaction of the given key between the given JobWallets.
+func (st *stakersTest) tx(key *ecdsa.PrivateKey, nonce uint64, base, target types.JobWallet) *types.Transaction {
+	tx := types.NewTransaction(nonce, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1), 21000, big.NewInt(1), nil, base, target)
+	signed, err := types.SignTx(tx, types.MakeSigner(st.chain.config, st.header.Number), key)
+	if err != nil {
+		st.t.Fatal(err)
+	}
+	return signed
+}
+
+// migration signs the transaction migrating the old key to the new one.
+func (st *stakersTest) migration(nonce uint64) *types.Transaction {
+	payload, err := types.SignKeyMigration(st.chain.config.ChainID, st.oldAddr, st.newKey)
+	if err != nil {
+		st.t.Fatal(err)
+	}
+	tx := types.NewTransaction(nonce, types.KeyMigrationAddress, big.NewInt(0), 21000, big.NewInt(1), payload, types.Main, types.Main)
+	signed, err := types.SignTx(tx, types.MakeSigner(st.chain.config, st.header.Number), st.oldKey)
+	if err != nil {
+		st.t.Fatal(err)
+	}
+	return signed
+}
+
+// Tests that a key migration replaces the old key by the new one in the staking
+// list, and leaves the list alone for keys which aren't staking.
+func TestSetStakersKeyMigration(t *testing.T) {
+	st := newStakersTest(t)
+
+	stks := staking.NewStakers()
+	stks.Put(st.oldAddr)
+	if err := st.engine.setStakersWithTxs(nil, st.chain, stks, []*types.Transaction{st.migration(0)}, st.header); err != nil {
+		t.Fatal(err)
+	}
+	if stks.IsContain(st.oldAddr) || !stks.IsContain(st.newAddr) || len(stks.AsList()) != 1 {
+		t.Fatalf("staking list mismatch: have %x, want [%x]", stks.AsList(), st.newAddr)
+	}
+	// A key outside of the list doesn't enter it by migrating
+	stks = staking.NewStakers()
+	if err := st.engine.setStakersWithTxs(nil, st.chain, stks, []*types.Transaction{st.migration(0)}, st.header); err != nil {
+		t.Fatal(err)
+	}
+	if len(stks.AsList()) != 0 {
+		t.Fatalf("staking list mismatch: have %x, want empty", stks.AsList())
+	}
+}
+
+// Tests the staking list and the selection point when a key migration and stake
+// or unstake transactions of the same account share a block.
+func TestSetStakersKeyMigrationAndStaking(t *testing.T) {
+	// Migrating then staking more with the new key keeps the stake of the old key
+	// as the previous stake of the point
+	st := newStakersTest(t)
+	current := st.chain.state.Copy()
+	current.AddBalance(st.newAddr, big.NewInt(1))
+	current.SetStaking(st.newAddr, stakeAmount(150), st.header.Number)
+	current.RemoveStakeBalance(st.oldAddr)
+
+	stks := staking.NewStakers()
+	stks.Put(st.oldAddr)
+	txs := []*types.Transaction{st.migration(0), st.tx(st.newKey, 0, types.Main, types.Stake)}
+	if err := st.engine.setStakersWithTxs(current, st.chain, stks, txs, st.header); err != nil {
+		t.Fatal(err)
+	}
+	if stks.IsContain(st.oldAddr) || !stks.IsContain(st.newAddr) {
+		t.Fatalf("staking list mismatch: have %x, want [%x]", stks.AsList(), st.newAddr)
+	}
+	want := staking.CalcPointBigint(big.NewInt(100), big.NewInt(50), st.header.Number, st.header.Number, st.engine.config.Period)
+	if point := current.GetPoint(st.newAddr); point.Cmp(want) != 0 {
+		t.Fatalf("point mismatch: have %v, want %v", point, want)
+	}
+
+	// Staking then migrating moves the addition to the new key
+	st = newStakersTest(t)
+	stks = staking.NewStakers()
+	txs = []*types.Transaction{st.tx(st.oldKey, 0, types.Main, types.Stake), st.migration(1)}
+	if err := st.engine.setStakersWithTxs(nil, st.chain, stks, txs, st.header); err != nil {
+		t.Fatal(err)
+	}
+	if stks.IsContain(st.oldAddr) || !stks.IsContain(st.newAddr) || len(stks.AsList()) != 1 {
+		t.Fatalf("staking list mismatch: have %x, want [%x]", stks.AsList(), st.newAddr)
+	}
+
+	// Migrating then unstaking with the new key leaves the list
+	st = newStakersTest(t)
+	stks = staking.NewStakers()
+	stks.Put(st.oldAddr)
+	txs = []*types.Transaction{st.migration(0), st.tx(st.newKey, 0, types.Stake, types.Main)}
+	if err := st.engine.setStakersWithTxs(nil, st.chain, stks, txs, st.header); err != nil {
+		t.Fatal(err)
+	}
+	if len(stks.AsList()) != 0 {
+		t.Fatalf("staking list mismatch: have %x, want empty", stks.AsList())
+	}
+}
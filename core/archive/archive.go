@@ -0,0 +1,334 @@
+/*
+[BERITH]
+블록과 epoch 경계의 StakingList 를 chunk 파일로 나누어 저장하는 체인 아카이브.
+manifest 에 네트워크, genesis, 블록 범위와 chunk 별 해시를 기록하여 가져올 때 검증한다.
+*/
+
+// Package archive implements a portable and verifiable format for exporting a
+// range of the chain, together with the staking lists at the epoch boundaries.
+//
+// An archive is a directory holding a manifest and chunk files. Every chunk is
+// the RLP encoding of a run of consecutive blocks and of their staking lists,
+// optionally gzip compressed. The manifest records the network and the genesis
+// the blocks belong to, the block range of every chunk, the hash of its last
+// block and the hash of its uncompressed content, so that chunks can be read and
+// verified independently and in parallel.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/crypto"
+	"github.com/BerithFoundation/berith-chain/rlp"
+)
+
+const (
+	// ManifestName is the name of the manifest file of an archive directory.
+	ManifestName = "manifest.json"
+
+	// Version is the version of the archive format written.
+	Version = 1
+
+	// CompressionNone stores the chunks as they are.
+	CompressionNone = "none"
+
+	// CompressionGzip stores the chunks gzip compressed.
+	CompressionGzip = "gzip"
+)
+
+var (
+	errEmptyChunk    = errors.New("empty chunk")
+	errUnknownFormat = errors.New("unknown archive version")
+)
+
+// Manifest describes the content of an archive.
+type Manifest struct {
+	Version     int          `json:"version"`
+	NetworkID   uint64       `json:"networkId"`
+	Genesis     common.Hash  `json:"genesis"`
+	First       uint64       `json:"first"`
+	Last        uint64       `json:"last"`
+	Compression string       `json:"compression"`
+	Chunks      []*ChunkInfo `json:"chunks"`
+}
+
+// ChunkInfo describes a chunk file of an archive.
+type ChunkInfo struct {
+	File    string      `json:"file"`
+	First   uint64      `json:"first"`
+	Last    uint64      `json:"last"`
+	Head    common.Hash `json:"head"`    // Hash of the last block of the chunk
+	Stakers int         `json:"stakers"` // Number of staking lists of the chunk
+	Hash    common.Hash `json:"hash"`    // Keccak256 hash of the uncompressed chunk
+}
+
+// Chunk is the content of a chunk file.
+type Chunk struct {
+	Blocks  []*types.Block
+	Stakers []*Stakers
+}
+
+// Stakers is the staking list stored for a block of a chunk.
+type Stakers struct {
+	Number uint64
+	Hash   common.Hash
+	List   []common.Address
+}
+
+// Verify checks the staking list against the one the consensus engine derived
+// for its block and stored in the database, regardless of their order. It returns
+// false if the database holds no list for the block.
+func (s *Stakers) Verify(db rawdb.DatabaseReader) (bool, error) {
+	data := rawdb.ReadStakersRLP(db, s.Hash)
+	if data == nil {
+		return false, nil
+	}
+	var local []common.Address
+	if err := rlp.DecodeBytes(data, &local); err != nil {
+		return false, fmt.Errorf("invalid staking list of block %d: %v", s.Number, err)
+	}
+	set := make(map[common.Address]struct{}, len(local))
+	for _, addr := range local {
+		set[addr] = struct{}{}
+	}
+	mismatch := len(set) != len(s.List)
+	for _, addr := range s.List {
+		if _, ok := set[addr]; !ok {
+			mismatch = true
+		}
+	}
+	if mismatch {
+		return true, fmt.Errorf("staking list mismatch at block %d [%x]: %d stakers in the archive, %d derived", s.Number, s.Hash, len(s.List), len(local))
+	}
+	return true, nil
+}
+
+// Writer creates an archive, chunk by chunk.
+type Writer struct {
+	dir      string
+	manifest *Manifest
+}
+
+// NewWriter creates an empty archive in the given directory for the blocks of the
+// given network and genesis. The directory must not hold an archive already.
+func NewWriter(dir string, networkID uint64, genesis common.Hash, compression string) (*Writer, error) {
+	if compression != CompressionNone && compression != CompressionGzip {
+		return nil, fmt.Errorf("unknown compression %q", compression)
+	}
+	if common.FileExist(filepath.Join(dir, ManifestName)) {
+		return nil, fmt.Errorf("archive already exists in %s", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		dir: dir,
+		manifest: &Manifest{
+			Version:     Version,
+			NetworkID:   networkID,
+			Genesis:     genesis,
+			Compression: compression,
+			Chunks:      []*ChunkInfo{},
+		},
+	}, nil
+}
+
+// WriteChunk appends a chunk to the archive. Its blocks must follow the blocks of
+// the previous chunk.
+func (w *Writer) WriteChunk(chunk *Chunk) error {
+	if len(chunk.Blocks) == 0 {
+		return errEmptyChunk
+	}
+	var parent *ChunkInfo
+	if n := len(w.manifest.Chunks); n > 0 {
+		parent = w.manifest.Chunks[n-1]
+	}
+	info := &ChunkInfo{
+		File:    fmt.Sprintf("blocks-%06d.rlp", len(w.manifest.Chunks)),
+		First:   chunk.Blocks[0].NumberU64(),
+		Last:    chunk.Blocks[len(chunk.Blocks)-1].NumberU64(),
+		Head:    chunk.Blocks[len(chunk.Blocks)-1].Hash(),
+		Stakers: len(chunk.Stakers),
+	}
+	if w.manifest.Compression == CompressionGzip {
+		info.File += ".gz"
+	}
+	if err := verifyChunk(chunk, info, parent); err != nil {
+		return err
+	}
+	data, err := rlp.EncodeToBytes(chunk)
+	if err != nil {
+		return err
+	}
+	info.Hash = crypto.Keccak256Hash(data)
+
+	if w.manifest.Compression == CompressionGzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	if err := writeFile(filepath.Join(w.dir, info.File), data); err != nil {
+		return err
+	}
+	if parent == nil {
+		w.manifest.First = info.First
+	}
+	w.manifest.Last = info.Last
+	w.manifest.Chunks = append(w.manifest.Chunks, info)
+	return nil
+}
+
+// Close completes the archive by writing its manifest.
+func (w *Writer) Close() error {
+	if len(w.manifest.Chunks) == 0 {
+		return errEmptyChunk
+	}
+	data, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(w.dir, ManifestName), data)
+}
+
+// writeFile replaces the content of a file atomically.
+func writeFile(path string, data []byte) error {
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// ReadManifest reads and checks the manifest of the archive of a directory.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return nil, err
+	}
+	manifest := new(Manifest)
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if manifest.Version != Version {
+		return nil, errUnknownFormat
+	}
+	if manifest.Compression != CompressionNone && manifest.Compression != CompressionGzip {
+		return nil, fmt.Errorf("unknown compression %q", manifest.Compression)
+	}
+	if len(manifest.Chunks) == 0 {
+		return nil, errEmptyChunk
+	}
+	next := manifest.First
+	for i, info := range manifest.Chunks {
+		if info.First != next || info.Last < info.First {
+			return nil, fmt.Errorf("chunk %d: invalid block range %d-%d, expected to start at %d", i, info.First, info.Last, next)
+		}
+		if filepath.Base(info.File) != info.File {
+			return nil, fmt.Errorf("chunk %d: invalid file name %q", i, info.File)
+		}
+		next = info.Last + 1
+	}
+	if next-1 != manifest.Last {
+		return nil, fmt.Errorf("chunks end at block %d, manifest at %d", next-1, manifest.Last)
+	}
+	return manifest, nil
+}
+
+// ReadChunk reads the chunk of the given index of the archive of a directory,
+// checking its content hash, the linkage of its blocks with each other and with
+// the previous chunk, the transactions and uncles of its blocks, and that its
+// staking lists belong to its blocks.
+func (m *Manifest) ReadChunk(dir string, index int) (*Chunk, error) {
+	info := m.Chunks[index]
+
+	fh, err := os.Open(filepath.Join(dir, info.File))
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if m.Compression == CompressionGzip {
+		zr, err := gzip.NewReader(fh)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %v", index, err)
+		}
+		defer zr.Close()
+		reader = zr
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %d: %v", index, err)
+	}
+	if hash := crypto.Keccak256Hash(data); hash != info.Hash {
+		return nil, fmt.Errorf("chunk %d: hash mismatch: have %x, want %x", index, hash, info.Hash)
+	}
+	chunk := new(Chunk)
+	if err := rlp.DecodeBytes(data, chunk); err != nil {
+		return nil, fmt.Errorf("chunk %d: %v", index, err)
+	}
+	var parent *ChunkInfo
+	if index > 0 {
+		parent = m.Chunks[index-1]
+	}
+	if err := verifyChunk(chunk, info, parent); err != nil {
+		return nil, fmt.Errorf("chunk %d: %v", index, err)
+	}
+	return chunk, nil
+}
+
+// verifyChunk checks that the content of a chunk matches its description and
+// follows the previous chunk, if any.
+func verifyChunk(chunk *Chunk, info *ChunkInfo, parent *ChunkInfo) error {
+	if len(chunk.Blocks) == 0 {
+		return errEmptyChunk
+	}
+	if uint64(len(chunk.Blocks)) != info.Last-info.First+1 || len(chunk.Stakers) != info.Stakers {
+		return fmt.Errorf("content mismatch: %d blocks and %d staking lists, want blocks %d-%d and %d staking lists",
+			len(chunk.Blocks), len(chunk.Stakers), info.First, info.Last, info.Stakers)
+	}
+	hashes := make(map[uint64]common.Hash, len(chunk.Blocks))
+	for i, block := range chunk.Blocks {
+		if block.NumberU64() != info.First+uint64(i) {
+			return fmt.Errorf("block %d found at the position of %d", block.NumberU64(), info.First+uint64(i))
+		}
+		switch {
+		case i > 0 && block.ParentHash() != chunk.Blocks[i-1].Hash():
+			return fmt.Errorf("block %d doesn't follow its predecessor", block.NumberU64())
+		case i == 0 && parent != nil && block.ParentHash() != parent.Head:
+			return fmt.Errorf("block %d doesn't follow the previous chunk", block.NumberU64())
+		}
+		if hash := types.DeriveSha(block.Transactions()); hash != block.TxHash() {
+			return fmt.Errorf("block %d: transaction root mismatch: have %x, want %x", block.NumberU64(), hash, block.TxHash())
+		}
+		if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+			return fmt.Errorf("block %d: uncle root mismatch: have %x, want %x", block.NumberU64(), hash, block.UncleHash())
+		}
+		hashes[block.NumberU64()] = block.Hash()
+	}
+	if head := chunk.Blocks[len(chunk.Blocks)-1].Hash(); head != info.Head {
+		return fmt.Errorf("last block hash mismatch: have %x, want %x", head, info.Head)
+	}
+	for _, stakers := range chunk.Stakers {
+		if hash, ok := hashes[stakers.Number]; !ok || hash != stakers.Hash {
+			return fmt.Errorf("staking list of unknown block %d [%x]", stakers.Number, stakers.Hash)
+		}
+	}
+	return nil
+}
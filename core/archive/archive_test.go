@@ -0,0 +1,199 @@
+package archive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/rlp"
+)
+
+// makeBlocks creates a chain of blocks with transactions following the genesis.
+func makeBlocks(n int) (*types.Block, []*types.Block) {
+	genesis := types.NewBlock(&types.Header{Number: big.NewInt(0)}, nil, nil, nil)
+	blocks := make([]*types.Block, n)
+	parent := genesis
+	for i := range blocks {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     big.NewInt(int64(i + 1)),
+			Extra:      []byte("archive test"),
+		}
+		tx := types.NewTransaction(uint64(i), common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil, types.Main, types.Main)
+		blocks[i] = types.NewBlock(header, []*types.Transaction{tx}, nil, nil)
+		parent = blocks[i]
+	}
+	return genesis, blocks
+}
+
+// writeArchive exports the blocks in chunks of the given size, with a staking list
+// for every other block.
+func writeArchive(t *testing.T, dir string, genesis *types.Block, blocks []*types.Block, size int, compression string) {
+	w, err := NewWriter(dir, 101, genesis.Hash(), compression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(blocks); i += size {
+		end := i + size
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+		chunk := &Chunk{Blocks: blocks[i:end]}
+		for _, block := range chunk.Blocks {
+			if block.NumberU64()%2 == 0 {
+				chunk.Stakers = append(chunk.Stakers, &Stakers{
+					Number: block.NumberU64(),
+					Hash:   block.Hash(),
+					List:   []common.Address{common.BigToAddress(block.Number())},
+				})
+			}
+		}
+		if err := w.WriteChunk(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that the chunks of an archive are read back as they were written.
+func TestArchiveRoundtrip(t *testing.T) {
+	for _, compression := range []string{CompressionNone, CompressionGzip} {
+		dir, err := ioutil.TempDir("", "archive-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		genesis, blocks := makeBlocks(25)
+		writeArchive(t, dir, genesis, blocks, 10, compression)
+
+		manifest, err := ReadManifest(dir)
+		if err != nil {
+			t.Fatalf("%s: %v", compression, err)
+		}
+		if manifest.Genesis != genesis.Hash() || manifest.NetworkID != 101 || manifest.First != 1 || manifest.Last != 25 || len(manifest.Chunks) != 3 {
+			t.Fatalf("%s: manifest mismatch: %+v", compression, manifest)
+		}
+		var number uint64 = 1
+		for i := range manifest.Chunks {
+			chunk, err := manifest.ReadChunk(dir, i)
+			if err != nil {
+				t.Fatalf("%s: %v", compression, err)
+			}
+			for _, block := range chunk.Blocks {
+				if block.Hash() != blocks[number-1].Hash() {
+					t.Fatalf("%s: block %d mismatch", compression, number)
+				}
+				number++
+			}
+			for _, stakers := range chunk.Stakers {
+				if stakers.Number%2 != 0 || len(stakers.List) != 1 || stakers.List[0] != common.BigToAddress(new(big.Int).SetUint64(stakers.Number)) {
+					t.Fatalf("%s: staking list of block %d mismatch", compression, stakers.Number)
+				}
+			}
+		}
+		if number != 26 {
+			t.Fatalf("%s: read %d blocks, want 25", compression, number-1)
+		}
+		// An archive is never overwritten
+		if _, err := NewWriter(dir, 101, genesis.Hash(), compression); err == nil {
+			t.Fatalf("%s: existing archive overwritten", compression)
+		}
+	}
+}
+
+// Tests that altered chunks and manifests are rejected.
+func TestArchiveVerification(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	genesis, blocks := makeBlocks(20)
+	writeArchive(t, dir, genesis, blocks, 10, CompressionNone)
+
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Alter a byte of the second chunk
+	path := filepath.Join(dir, manifest.Chunks[1].File)
+	data, _ := ioutil.ReadFile(path)
+	data[len(data)/2] ^= 0xff
+	ioutil.WriteFile(path, data, 0644)
+
+	if _, err := manifest.ReadChunk(dir, 0); err != nil {
+		t.Fatalf("intact chunk rejected: %v", err)
+	}
+	if _, err := manifest.ReadChunk(dir, 1); err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Fatalf("altered chunk accepted: %v", err)
+	}
+	// Chunks not following each other
+	manifest.Chunks[1].First++
+	data, _ = json.Marshal(manifest)
+	ioutil.WriteFile(filepath.Join(dir, ManifestName), data, 0644)
+	if _, err := ReadManifest(dir); err == nil {
+		t.Fatal("manifest with a gap accepted")
+	}
+	// Blocks not following the previous chunk
+	w, err := NewWriter(filepath.Join(dir, "gap"), 101, genesis.Hash(), CompressionNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteChunk(&Chunk{Blocks: blocks[:5]}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteChunk(&Chunk{Blocks: blocks[6:10]}); err == nil {
+		t.Fatal("chunk not following the previous one accepted")
+	}
+	// Staking list of a block outside of the chunk
+	stakers := &Stakers{Number: 8, Hash: blocks[7].Hash()}
+	if err := w.WriteChunk(&Chunk{Blocks: blocks[5:7], Stakers: []*Stakers{stakers}}); err == nil {
+		t.Fatal("staking list of another chunk accepted")
+	}
+}
+
+// Tests that staking lists are checked against the lists derived for their blocks.
+func TestStakersVerify(t *testing.T) {
+	var (
+		db      = berithdb.NewMemDatabase()
+		hash    = common.Hash{0x01}
+		a, b, c = common.Address{0x0a}, common.Address{0x0b}, common.Address{0x0c}
+	)
+	// Without a derived list nothing can be checked
+	stakers := &Stakers{Number: 1, Hash: hash, List: []common.Address{a, b}}
+	if found, err := stakers.Verify(db); found || err != nil {
+		t.Fatalf("list without derived one: have %v, %v, want false, nil", found, err)
+	}
+	data, _ := rlp.EncodeToBytes([]common.Address{b, a})
+	rawdb.WriteStakersRLP(db, hash, data)
+
+	tests := []struct {
+		list []common.Address
+		ok   bool
+	}{
+		{[]common.Address{a, b}, true},
+		{[]common.Address{b, a}, true},
+		{[]common.Address{a}, false},
+		{[]common.Address{a, c}, false},
+		{[]common.Address{a, b, c}, false},
+	}
+	for i, tt := range tests {
+		stakers := &Stakers{Number: 1, Hash: hash, List: tt.list}
+		found, err := stakers.Verify(db)
+		if !found || (err == nil) != tt.ok {
+			t.Errorf("test %d: have %v, %v, want match %v", i, found, err, tt.ok)
+		}
+	}
+}
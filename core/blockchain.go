@@ -35,6 +35,7 @@ import (
 	"github.com/BerithFoundation/berith-chain/consensus"
 	"github.com/BerithFoundation/berith-chain/core/rawdb"
 	"github.com/BerithFoundation/berith-chain/core/state"
+	"github.com/BerithFoundation/berith-chain/core/state/snapshot"
 	"github.com/BerithFoundation/berith-chain/core/types"
 	"github.com/BerithFoundation/berith-chain/core/vm"
 	"github.com/BerithFoundation/berith-chain/crypto"
@@ -65,6 +66,7 @@ const (
 	maxTimeFutureBlocks = 30
 	badBlockLimit       = 10
 	triesInMemory       = 128
+	stakersCheckDepth   = 1024 // Number of blocks below the head searched for a stored staking list at startup
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	BlockChainVersion = 3
@@ -77,6 +79,7 @@ type CacheConfig struct {
 	TrieCleanLimit int           // Memory allowance (MB) to use for caching trie nodes in memory
 	TrieDirtyLimit int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
 	TrieTimeLimit  time.Duration // Time limit after which to flush the current in-memory trie to disk
+	NoSnapshot     bool          // Whether to disable the flat state snapshot
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -119,6 +122,7 @@ type BlockChain struct {
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
 	stateCache    state.Database // State database to reuse between imports (contains state cache)
+	snaps         *snapshot.Tree // Flat snapshot of the recent states serving the state reads
 	bodyCache     *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache  *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	receiptsCache *lru.Cache     // Cache for the most recent receipts per block
@@ -140,6 +144,7 @@ type BlockChain struct {
 	shouldPreserve func(*types.Block) bool // Function used to determine whether should preserve the given block.
 
 	stakingDB *stakingdb.StakingDB
+	diffdb    berithdb.Database // Database recording the state changes of the imported blocks, if enabled
 }
 
 // NewBlockChain returns a fully initialised block chain using information
@@ -207,6 +212,14 @@ func NewBlockChain(stakingDB *stakingdb.StakingDB, db berithdb.Database, cacheCo
 		}
 	}
 
+	// Make sure the staking list the consensus engine starts from is readable
+	if stakingDB != nil {
+		bc.checkStakers()
+	}
+	// Load the flat state snapshot, it is regenerated in the background if needed
+	if !cacheConfig.NoSnapshot {
+		bc.snaps = snapshot.New(db, bc.stateCache.TrieDB(), bc.CurrentBlock().Root(), snapshotDepth(chainConfig, cacheConfig))
+	}
 	// Take ownership of this particular state
 	go bc.update()
 	return bc, nil
@@ -221,6 +234,31 @@ func (bc *BlockChain) GetVMConfig() *vm.Config {
 	return &bc.vmConfig
 }
 
+// checkStakers verifies that a readable staking list is stored close to the head
+// block. Undecodable lists are deleted so that the consensus engine derives them
+// again from the blocks, the lists being derived from the last stored one.
+func (bc *BlockChain) checkStakers() {
+	head := bc.CurrentBlock().Header()
+	for header, depth := head, 0; header != nil && header.Number.Sign() > 0; depth++ {
+		if depth == stakersCheckDepth {
+			log.Warn("No staking list stored near the head, replaying blocks to derive it", "number", head.Number, "depth", depth)
+			return
+		}
+		if data := rawdb.ReadStakersRLP(bc.db, header.Hash()); data != nil {
+			var list []common.Address
+			if err := rlp.DecodeBytes(data, &list); err == nil {
+				if depth > 0 {
+					log.Info("Staking lists missing below the head", "number", head.Number, "stored", header.Number, "missing", depth)
+				}
+				return
+			}
+			log.Error("Deleting corrupted staking list", "number", header.Number, "hash", header.Hash())
+			rawdb.DeleteStakers(bc.db, header.Hash())
+		}
+		header = bc.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+}
+
 // loadLastState loads the last known chain state from the database. This method
 // assumes that the chain manager mutex is held.
 func (bc *BlockChain) loadLastState() error {
@@ -297,6 +335,13 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
 
+	// Frozen blocks can't be deleted, drop the ones above the new head instead
+	if ancients, ok := bc.db.(rawdb.AncientStore); ok {
+		if err := ancients.TruncateAncients(currentHeader.Number.Uint64() + 1); err != nil {
+			return err
+		}
+	}
+
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
@@ -331,6 +376,7 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	rawdb.WriteHeadBlockHash(bc.db, currentBlock.Hash())
 	rawdb.WriteHeadFastBlockHash(bc.db, currentFastBlock.Hash())
 
+	bc.resetSnapshot(currentBlock.Root())
 	return bc.loadLastState()
 }
 
@@ -348,6 +394,7 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	// If all checks out, manually set the head block
 	bc.mu.Lock()
 	bc.currentBlock.Store(block)
+	bc.resetSnapshot(block.Root())
 	bc.mu.Unlock()
 
 	log.Info("Committed new head block", "number", block.Number(), "hash", hash)
@@ -406,7 +453,78 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache)
+	return state.NewWithSnapshot(root, bc.stateCache, bc.snaps)
+}
+
+// EnableStateDiffs makes the chain record the state changes of every imported
+// or mined block into the given database. It must be called before blocks are
+// imported.
+func (bc *BlockChain) EnableStateDiffs(db berithdb.Database) {
+	bc.diffdb = db
+}
+
+// StateDiffsEnabled returns whether the chain records the state changes of the
+// blocks, in which case the states of the mined blocks must record them too.
+func (bc *BlockChain) StateDiffsEnabled() bool {
+	return bc.diffdb != nil
+}
+
+// StateDiff returns the changes of a block to the accounts and storage slots,
+// including the ones of the consensus engine. The changes recorded during the
+// import are returned if available, otherwise the block is re-executed on the
+// state of its parent.
+func (bc *BlockChain) StateDiff(block *types.Block) (*state.StateDiff, error) {
+	if bc.diffdb != nil {
+		if data := rawdb.ReadStateDiffRLP(bc.diffdb, block.Hash(), block.NumberU64()); len(data) > 0 {
+			diff := new(state.StateDiff)
+			if err := rlp.DecodeBytes(data, diff); err != nil {
+				return nil, err
+			}
+			return diff, nil
+		}
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis block has no state diff")
+	}
+	parent := bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	before, err := bc.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := bc.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	statedb.EnableStateDiff()
+	if _, _, _, err := bc.processor.Process(block, statedb, bc.vmConfig); err != nil {
+		return nil, err
+	}
+	if root := statedb.IntermediateRoot(bc.chainConfig.IsEIP158(block.Number())); root != block.Root() {
+		return nil, fmt.Errorf("re-executed state root mismatch: have %x, want %x", root, block.Root())
+	}
+	return statedb.StateDiff(before), nil
+}
+
+// writeStateDiff records the state changes of a block against the state of its
+// parent, the state being finalised already.
+func (bc *BlockChain) writeStateDiff(block *types.Block, statedb *state.StateDB) error {
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	before, err := bc.StateAt(parent.Root)
+	if err != nil {
+		return err
+	}
+	data, err := rlp.EncodeToBytes(statedb.StateDiff(before))
+	if err != nil {
+		return err
+	}
+	rawdb.WriteStateDiffRLP(bc.diffdb, block.Hash(), block.NumberU64(), data)
+	return nil
 }
 
 // StateCache returns the caching database underpinning the blockchain instance.
@@ -441,10 +559,31 @@ func (bc *BlockChain) ResetWithGenesisBlock(genesis *types.Block) error {
 	bc.hc.SetGenesis(bc.genesisBlock.Header())
 	bc.hc.SetCurrentHeader(bc.genesisBlock.Header())
 	bc.currentFastBlock.Store(bc.genesisBlock)
+	bc.resetSnapshot(bc.genesisBlock.Root())
 
 	return nil
 }
 
+// resetSnapshot rebuilds the flat state snapshot for a new head state which the
+// snapshot tree doesn't maintain, e.g. after a rewind or a fast sync.
+func (bc *BlockChain) resetSnapshot(root common.Hash) {
+	if bc.snaps != nil && bc.snaps.Snapshot(root) == nil {
+		bc.snaps.Rebuild(root)
+	}
+}
+
+// snapshotDepth returns the number of diff layers kept by the flat state
+// snapshot. Archive nodes keep enough of them for the state read by the BSRR
+// engine one epoch below the head, the other nodes being limited by the states
+// kept in memory.
+func snapshotDepth(config *params.ChainConfig, cacheConfig *CacheConfig) int {
+	depth := triesInMemory - 1
+	if cacheConfig.Disabled && config.Bsrr != nil && int(config.Bsrr.Epoch)+1 > depth {
+		depth = int(config.Bsrr.Epoch) + 1
+	}
+	return depth
+}
+
 // repair tries to repair the current blockchain by rolling back the current block
 // until one with associated state is found. This is needed to fix incomplete db
 // writes caused either by crashes/power outages, or simply non-committed tries.
@@ -706,6 +845,12 @@ func (bc *BlockChain) Stop() {
 
 	bc.wg.Wait()
 
+	// Persist the diff layers of the snapshot, so that it needn't be regenerated
+	if bc.snaps != nil {
+		if err := bc.snaps.Journal(bc.CurrentBlock().Root()); err != nil {
+			log.Error("Failed to journal state snapshot", "err", err)
+		}
+	}
 	// Ensure the state of a recent block is also stored to disk before exiting.
 	// We're writing three different states to catch different restart scenarios:
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
@@ -951,8 +1096,14 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	if err := bc.hc.WriteTd(block.Hash(), block.NumberU64(), externTd); err != nil {
 		return NonStatTy, err
 	}
-	rawdb.WriteBlock(bc.db, block)
-
+	// Record the state changes of the block, unless the state wasn't tracking them
+	if bc.diffdb != nil {
+		if !state.StateDiffEnabled() {
+			log.Warn("State diff not recorded", "number", block.Number(), "hash", block.Hash())
+		} else if err := bc.writeStateDiff(block, state); err != nil {
+			log.Error("Failed to write state diff", "number", block.Number(), "hash", block.Hash(), "err", err)
+		}
+	}
 	root, err := state.Commit(bc.chainConfig.IsEIP158(block.Number()))
 	if err != nil {
 		return NonStatTy, err
@@ -1006,9 +1157,14 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		}
 	}
 
-	// Write other block data using a batch.
+	// Write the block, its staking list and other block data using a batch, so
+	// that they are stored atomically.
 	batch := bc.db.NewBatch()
+	rawdb.WriteBlock(batch, block)
 	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
+	if bc.stakingDB != nil {
+		bc.stakingDB.WriteStaged(batch, bc.engine.SealHash(block.Header()), block.Hash())
+	}
 
 	// If the total difficulty is higher than our known, add it to the canonical chain
 	// Second clause in the if statement reduces the vulnerability to selfish mining.
@@ -1202,10 +1358,14 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, []
 		if parent == nil {
 			parent = bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
 		}
-		state, err := state.New(parent.Root(), bc.stateCache)
+		state, err := state.NewWithSnapshot(parent.Root(), bc.stateCache, bc.snaps)
 		if err != nil {
 			return it.index, events, coalescedLogs, err
 		}
+		// Track the state changes of the block, written along with its state
+		if bc.diffdb != nil {
+			state.EnableStateDiff()
+		}
 		// Process block using the parent state as reference point.
 		t0 := time.Now()
 		receipts, logs, usedGas, err := bc.processor.Process(block, state, bc.vmConfig)
@@ -88,6 +88,8 @@ type ChainIndexer struct {
 	checkpointSections uint64      // Number of sections covered by the checkpoint
 	checkpointHead     common.Hash // Section head belonging to the checkpoint
 
+	chainBase uint64 // [BERITH] First block of a chain bootstrapped from a state snapshot
+
 	throttling time.Duration // Disk throttling to prevent a heavy upgrade from hogging resources
 
 	log  log.Logger
@@ -111,6 +113,15 @@ func NewChainIndexer(chainDb, indexDb berithdb.Database, backend ChainIndexerBac
 	}
 	// Initialize database dependent fields and start the updater
 	c.loadValidSections()
+
+	// [BERITH] The sections below the base of a chain bootstrapped from a state
+	// snapshot have no blocks, they are skipped like the ones of a checkpoint
+	if base := rawdb.ReadChainBase(chainDb); base != nil {
+		c.chainBase = *base
+		if sections := c.chainBase / section; c.storedSections < sections {
+			c.setValidSections(sections)
+		}
+	}
 	c.ctx, c.ctxCancel = context.WithCancel(context.Background())
 
 	go c.updateLoop()
@@ -390,6 +401,11 @@ func (c *ChainIndexer) processSection(section uint64, lastHead common.Hash) (com
 	}
 
 	for number := section * c.sectionSize; number < (section+1)*c.sectionSize; number++ {
+		// [BERITH] The blocks below the chain base are missing, and so is the
+		// parent of the chain base
+		if number < c.chainBase {
+			continue
+		}
 		hash := rawdb.ReadCanonicalHash(c.chainDb, number)
 		if hash == (common.Hash{}) {
 			return common.Hash{}, fmt.Errorf("canonical block #%d unknown", number)
@@ -397,7 +413,7 @@ func (c *ChainIndexer) processSection(section uint64, lastHead common.Hash) (com
 		header := rawdb.ReadHeader(c.chainDb, hash, number)
 		if header == nil {
 			return common.Hash{}, fmt.Errorf("block #%d [%x…] not found", number, hash[:4])
-		} else if header.ParentHash != lastHead {
+		} else if number != c.chainBase && header.ParentHash != lastHead {
 			return common.Hash{}, fmt.Errorf("chain reorged during section processing")
 		}
 		if err := c.backend.Process(c.ctx, header); err != nil {
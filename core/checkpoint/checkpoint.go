@@ -0,0 +1,643 @@
+/*
+[BERITH]
+신뢰하는 블록의 state 와 StakingList 를 하나의 파일로 내보내고, 새 노드가 genesis 부터 동기화하지 않고
+그 블록에서 시작할 수 있도록 검증하여 가져오는 state snapshot 파일.
+BSRR 은 블록을 처리할 때 epoch 이전 블록의 state 를 읽으므로 그 블록들의 state 도 차이로 함께 담는다.
+*/
+
+// Package checkpoint implements the state snapshot files bootstrapping a node at
+// a trusted block instead of syncing from the genesis.
+//
+// A snapshot file is a gzip compressed stream of RLP items:
+//
+//   - a header naming the network, the genesis and the snapshot block,
+//   - the blocks, with their total difficulty, from the oldest block whose state
+//     is included up to the snapshot block,
+//   - the staking lists stored for these blocks, which must include the lists
+//     of the blocks returned by StateBlocks,
+//   - the states of the blocks returned by StateBlocks, from the snapshot block
+//     down. Every state is a state item followed by the accounts differing from
+//     the previous state of the file, or from the empty state for the first one,
+//     and terminated by an account with the zero hash. An account holds the full
+//     RLP encoding of the account with all of its Berith fields, the storage slots
+//     differing from the previous state and the code of the account when it
+//     hasn't appeared before.
+//
+// Every state is rebuilt on import and checked against the state root of its
+// block, whose header is linked to the trusted snapshot block.
+package checkpoint
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/state"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/crypto"
+	"github.com/BerithFoundation/berith-chain/log"
+	"github.com/BerithFoundation/berith-chain/rlp"
+	"github.com/BerithFoundation/berith-chain/trie"
+)
+
+// Version is the version of the snapshot format written.
+const Version = 1
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+
+	errUnknownFormat = errors.New("unknown snapshot version")
+	errNoTrust       = errors.New("no trusted block hash or state root")
+	errNoTrustedTD   = errors.New("no trusted total difficulty")
+	errNotEmpty      = errors.New("database already holds blocks beyond the genesis")
+)
+
+// header is the first item of a snapshot file.
+type header struct {
+	Version   uint64
+	NetworkID uint64
+	Genesis   common.Hash
+	Number    uint64      // Number of the snapshot block
+	Hash      common.Hash // Hash of the snapshot block
+	Blocks    uint64      // Number of blocks up to the snapshot block
+	Stakers   uint64      // Number of staking lists
+	States    uint64      // Number of states
+}
+
+// blockEntry is a block of a snapshot file.
+type blockEntry struct {
+	Block *types.Block
+	TD    *big.Int
+}
+
+// stakersEntry is the staking list stored for a block of a snapshot file.
+type stakersEntry struct {
+	Hash common.Hash
+	List []common.Address
+}
+
+// stateEntry starts the accounts of the state of a block.
+type stateEntry struct {
+	Number uint64
+	Root   common.Hash
+}
+
+// accountEntry is an account differing from the previous state of the file.
+type accountEntry struct {
+	Hash    common.Hash  // Hash of the address of the account
+	Data    []byte       // RLP encoded account, empty if the account doesn't exist
+	Code    []byte       // Code of the account, if it didn't appear before
+	Storage []*slotEntry // Storage slots differing from the previous state
+}
+
+// slotEntry is a storage slot differing from the previous state of the file.
+type slotEntry struct {
+	Hash  common.Hash // Hash of the slot key
+	Value []byte      // RLP encoded value, empty if the slot is deleted
+}
+
+// StateBlocks returns the blocks, from the highest down, whose states a node
+// starting at the given block needs to process the following blocks with the
+// BSRR engine of the given epoch: the block itself and the stake targets of the
+// blocks of the next epoch up to it. The genesis, whose state is always present,
+// is left out.
+func StateBlocks(number, epoch uint64) []uint64 {
+	targets := map[uint64]struct{}{number: {}}
+	for parent := number; epoch > 0 && parent < number+epoch; parent++ {
+		switch parent / epoch {
+		case 0:
+			// Blocks of the first epoch follow the genesis
+		case 1:
+			targets[epoch] = struct{}{}
+		default:
+			targets[parent-epoch] = struct{}{}
+		}
+	}
+	numbers := make([]uint64, 0, len(targets))
+	for n := range targets {
+		if n > 0 && n <= number {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] > numbers[j] })
+	return numbers
+}
+
+// Export writes the snapshot of the canonical block of the given number, along
+// with the states of the earlier blocks the BSRR engine of the given epoch needs.
+// The states must all be present in the database, which is the case for archive
+// nodes only.
+func Export(db berithdb.Database, w io.Writer, networkID, number, epoch uint64) error {
+	start := time.Now()
+
+	if number == 0 {
+		return errors.New("the genesis can't be exported")
+	}
+	hash := rawdb.ReadCanonicalHash(db, number)
+	if hash == (common.Hash{}) {
+		return fmt.Errorf("block %d not found", number)
+	}
+	states := StateBlocks(number, epoch)
+	first := states[len(states)-1]
+
+	// Gather the blocks and the staking lists of the snapshot, BSRR can't process
+	// the following blocks without the lists of the blocks whose states it reads
+	var (
+		blocks   []*blockEntry
+		stakers  []*stakersEntry
+		required = make(map[uint64]bool, len(states))
+	)
+	for _, n := range states {
+		required[n] = true
+	}
+	for n := first; n <= number; n++ {
+		hash := rawdb.ReadCanonicalHash(db, n)
+		block := rawdb.ReadBlock(db, hash, n)
+		if block == nil {
+			return fmt.Errorf("block %d not found", n)
+		}
+		td := rawdb.ReadTd(db, hash, n)
+		if td == nil {
+			return fmt.Errorf("total difficulty of block %d not found", n)
+		}
+		blocks = append(blocks, &blockEntry{Block: block, TD: td})
+
+		if data := rawdb.ReadStakersRLP(db, hash); data != nil {
+			entry := &stakersEntry{Hash: hash}
+			if err := rlp.DecodeBytes(data, &entry.List); err != nil {
+				return fmt.Errorf("invalid staking list of block %d: %v", n, err)
+			}
+			stakers = append(stakers, entry)
+		} else if required[n] {
+			return fmt.Errorf("staking list of block %d not found", n)
+		}
+	}
+	zw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(zw)
+
+	items := []interface{}{&header{
+		Version:   Version,
+		NetworkID: networkID,
+		Genesis:   rawdb.ReadCanonicalHash(db, 0),
+		Number:    number,
+		Hash:      hash,
+		Blocks:    uint64(len(blocks)),
+		Stakers:   uint64(len(stakers)),
+		States:    uint64(len(states)),
+	}}
+	for _, block := range blocks {
+		items = append(items, block)
+	}
+	for _, list := range stakers {
+		items = append(items, list)
+	}
+	for _, item := range items {
+		if err := rlp.Encode(bw, item); err != nil {
+			return err
+		}
+	}
+	// Write every state as its difference with the one before in the file
+	var (
+		triedb = trie.NewDatabase(db)
+		codes  = make(map[common.Hash]struct{})
+		prev   = emptyRoot
+	)
+	for _, n := range states {
+		root := blocks[n-first].Block.Root()
+		if err := rlp.Encode(bw, &stateEntry{Number: n, Root: root}); err != nil {
+			return err
+		}
+		accounts, err := exportState(bw, triedb, prev, root, codes)
+		if err != nil {
+			return fmt.Errorf("state of block %d: %v", n, err)
+		}
+		if err := rlp.Encode(bw, &accountEntry{}); err != nil {
+			return err
+		}
+		log.Info("Exported state", "number", n, "root", root, "accounts", accounts, "elapsed", common.PrettyDuration(time.Since(start)))
+		prev = root
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	head := blocks[len(blocks)-1]
+	log.Info("Exported state snapshot", "number", number, "hash", hash, "root", head.Block.Root(), "td", head.TD, "blocks", len(blocks), "stakers", len(stakers), "states", len(states), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// exportState writes the accounts of the state of root differing from the state
+// of base, and returns their number.
+func exportState(w io.Writer, triedb *trie.Database, base, root common.Hash, codes map[common.Hash]struct{}) (int, error) {
+	baseTrie, err := trie.New(base, triedb)
+	if err != nil {
+		return 0, err
+	}
+	accounts := 0
+	err = diffTrie(triedb, base, root, func(key, value []byte) error {
+		entry := &accountEntry{Hash: common.BytesToHash(key), Data: value}
+
+		// Look up the storage of the account in the previous state
+		baseStorage := emptyRoot
+		if prev, err := baseTrie.TryGet(key); err != nil {
+			return err
+		} else if len(prev) > 0 {
+			var account state.Account
+			if err := rlp.DecodeBytes(prev, &account); err != nil {
+				return err
+			}
+			baseStorage = account.Root
+		}
+		if value != nil {
+			var account state.Account
+			if err := rlp.DecodeBytes(value, &account); err != nil {
+				return err
+			}
+			if account.Root != baseStorage {
+				err := diffTrie(triedb, baseStorage, account.Root, func(key, value []byte) error {
+					entry.Storage = append(entry.Storage, &slotEntry{Hash: common.BytesToHash(key), Value: value})
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+			if hash := common.BytesToHash(account.CodeHash); hash != emptyCode {
+				if _, ok := codes[hash]; !ok {
+					code, err := triedb.DiskDB().Get(hash[:])
+					if err != nil {
+						return fmt.Errorf("code %x not found", hash)
+					}
+					entry.Code = code
+					codes[hash] = struct{}{}
+				}
+			}
+		}
+		accounts++
+		return rlp.Encode(w, entry)
+	})
+	return accounts, err
+}
+
+// diffTrie calls fn with the leaves of the trie of root which aren't leaves of
+// the trie of base, then with a nil value for the keys of base missing from root.
+func diffTrie(triedb *trie.Database, base, root common.Hash, fn func(key, value []byte) error) error {
+	baseTrie, err := trie.New(base, triedb)
+	if err != nil {
+		return err
+	}
+	rootTrie, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	// Leaves of root which are new or updated
+	it := trie.NewIterator(difference(baseTrie, rootTrie, base))
+	for it.Next() {
+		if err := fn(it.Key, it.Value); err != nil {
+			return err
+		}
+	}
+	if it.Err != nil {
+		return it.Err
+	}
+	// Leaves of base which aren't in root any more
+	it = trie.NewIterator(difference(rootTrie, baseTrie, root))
+	for it.Next() {
+		value, err := rootTrie.TryGet(it.Key)
+		if err != nil {
+			return err
+		}
+		if len(value) == 0 {
+			if err := fn(it.Key, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Err
+}
+
+// difference returns an iterator over the nodes of b which aren't in a, the trie
+// of root a.
+func difference(a, b *trie.Trie, aRoot common.Hash) trie.NodeIterator {
+	if aRoot == emptyRoot {
+		// The difference iterator can't compare with an exhausted iterator
+		return b.NodeIterator(nil)
+	}
+	it, _ := trie.NewDifferenceIterator(a.NodeIterator(nil), b.NodeIterator(nil))
+	return it
+}
+
+// Import reads a snapshot into a database holding only the genesis of the same
+// network, checking that the blocks of the snapshot lead to the trusted block
+// hash or state root and that every state matches the root of its block. Since
+// the total difficulty of the snapshot block can't be recomputed without the
+// blocks below the snapshot, it must match the trusted one as well, and the
+// total difficulties of the other blocks are derived from it. The
+// states must be the ones needed by the BSRR engine of the given epoch, and their
+// blocks must come with staking lists whose stakers all hold a stake. The
+// snapshot block becomes the head of the chain, and the imported block is
+// returned. The transactions of the imported blocks have no receipts and aren't
+// indexed, and the database can't be used with an ancient store afterwards.
+//
+// Nothing but unreferenced trie nodes is written if the snapshot is rejected.
+func Import(db berithdb.Database, r io.Reader, networkID, epoch uint64, trustedHash, trustedRoot common.Hash, trustedTD *big.Int) (*types.Block, error) {
+	start := time.Now()
+
+	if trustedHash == (common.Hash{}) && trustedRoot == (common.Hash{}) {
+		return nil, errNoTrust
+	}
+	if trustedTD == nil || trustedTD.Sign() <= 0 {
+		return nil, errNoTrustedTD
+	}
+	genesis := rawdb.ReadCanonicalHash(db, 0)
+	if genesis == (common.Hash{}) {
+		return nil, errors.New("genesis not found")
+	}
+	if rawdb.ReadHeadBlockHash(db) != genesis || rawdb.ReadCanonicalHash(db, 1) != (common.Hash{}) {
+		return nil, errNotEmpty
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	stream := rlp.NewStream(bufio.NewReader(zr), 0)
+
+	// Check the header and the blocks against the trusted block
+	head := new(header)
+	if err := stream.Decode(head); err != nil {
+		return nil, fmt.Errorf("invalid header: %v", err)
+	}
+	switch {
+	case head.Version != Version:
+		return nil, errUnknownFormat
+	case head.NetworkID != networkID:
+		return nil, fmt.Errorf("network mismatch: have %d, want %d", head.NetworkID, networkID)
+	case head.Genesis != genesis:
+		return nil, fmt.Errorf("genesis mismatch: have %x, want %x", head.Genesis, genesis)
+	case trustedHash != (common.Hash{}) && head.Hash != trustedHash:
+		return nil, fmt.Errorf("snapshot of untrusted block %x, want %x", head.Hash, trustedHash)
+	}
+	states := StateBlocks(head.Number, epoch)
+	first := states[len(states)-1]
+	if head.Blocks != head.Number-first+1 || head.States != uint64(len(states)) {
+		return nil, fmt.Errorf("snapshot of %d blocks and %d states, want %d and %d", head.Blocks, head.States, head.Number-first+1, len(states))
+	}
+	blocks := make([]*blockEntry, head.Blocks)
+	for i := range blocks {
+		entry := new(blockEntry)
+		if err := stream.Decode(entry); err != nil {
+			return nil, fmt.Errorf("invalid block: %v", err)
+		}
+		if err := verifyBlock(entry, first+uint64(i)); err != nil {
+			return nil, err
+		}
+		blocks[i] = entry
+	}
+	for i := len(blocks) - 1; i > 0; i-- {
+		if blocks[i].Block.ParentHash() != blocks[i-1].Block.Hash() {
+			return nil, fmt.Errorf("block %d doesn't follow its predecessor", blocks[i].Block.NumberU64())
+		}
+		if td := new(big.Int).Add(blocks[i-1].TD, blocks[i].Block.Difficulty()); blocks[i].TD.Cmp(td) != 0 {
+			return nil, fmt.Errorf("block %d: total difficulty mismatch: have %v, want %v", blocks[i].Block.NumberU64(), blocks[i].TD, td)
+		}
+	}
+	block := blocks[len(blocks)-1].Block
+	switch {
+	case block.Hash() != head.Hash:
+		return nil, fmt.Errorf("snapshot block hash mismatch: have %x, want %x", block.Hash(), head.Hash)
+	case trustedRoot != (common.Hash{}) && block.Root() != trustedRoot:
+		return nil, fmt.Errorf("snapshot of untrusted state %x, want %x", block.Root(), trustedRoot)
+	case blocks[len(blocks)-1].TD.Cmp(trustedTD) != 0:
+		return nil, fmt.Errorf("snapshot of untrusted total difficulty %v, want %v", blocks[len(blocks)-1].TD, trustedTD)
+	case trustedHash == (common.Hash{}):
+		log.Warn("Only the state of the snapshot is trusted, not its blocks", "number", block.Number(), "hash", block.Hash())
+	}
+	hashes := make(map[common.Hash]bool, len(blocks))
+	for _, entry := range blocks {
+		hashes[entry.Block.Hash()] = true
+	}
+	// The staking lists can't be checked against the blocks, only against the
+	// states rebuilt below. A wrong list makes the following blocks fail to import
+	stakers := make([]*stakersEntry, head.Stakers)
+	lists := make(map[common.Hash][]common.Address, len(stakers))
+	for i := range stakers {
+		entry := new(stakersEntry)
+		if err := stream.Decode(entry); err != nil {
+			return nil, fmt.Errorf("invalid staking list: %v", err)
+		}
+		if !hashes[entry.Hash] {
+			return nil, fmt.Errorf("staking list of unknown block %x", entry.Hash)
+		}
+		stakers[i] = entry
+		lists[entry.Hash] = entry.List
+	}
+	for _, n := range states {
+		if _, ok := lists[blocks[n-first].Block.Hash()]; !ok {
+			return nil, fmt.Errorf("staking list of block %d missing", n)
+		}
+	}
+	// Rebuild every state on top of the previous one and check its root
+	var (
+		triedb = trie.NewDatabase(db)
+		codes  = make(map[common.Hash]struct{})
+		prev   = emptyRoot
+	)
+	for _, n := range states {
+		entry := new(stateEntry)
+		if err := stream.Decode(entry); err != nil {
+			return nil, fmt.Errorf("invalid state: %v", err)
+		}
+		if root := blocks[n-first].Block.Root(); entry.Number != n || entry.Root != root {
+			return nil, fmt.Errorf("state of block %d [%x] found, want block %d [%x]", entry.Number, entry.Root, n, root)
+		}
+		accounts, err := importState(stream, db, triedb, prev, entry.Root, codes)
+		if err != nil {
+			return nil, fmt.Errorf("state of block %d: %v", n, err)
+		}
+		log.Info("Imported state", "number", n, "root", entry.Root, "accounts", accounts, "elapsed", common.PrettyDuration(time.Since(start)))
+		prev = entry.Root
+	}
+	// Every listed staker must hold a stake in the state of its block
+	for _, n := range states {
+		block := blocks[n-first].Block
+		statedb, err := state.New(block.Root(), state.NewDatabase(db))
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range lists[block.Hash()] {
+			if statedb.GetStakeBalance(addr).Sign() == 0 {
+				return nil, fmt.Errorf("staker %x of block %d holds no stake", addr, n)
+			}
+		}
+	}
+	// Write the blocks and the staking lists, then make the snapshot block the head.
+	// The receipts of the blocks are unknown, so their transactions aren't indexed
+	batch := db.NewBatch()
+	for _, entry := range blocks {
+		rawdb.WriteBlock(batch, entry.Block)
+		rawdb.WriteTd(batch, entry.Block.Hash(), entry.Block.NumberU64(), entry.TD)
+		rawdb.WriteCanonicalHash(batch, entry.Block.Hash(), entry.Block.NumberU64())
+	}
+	for _, entry := range stakers {
+		data, err := rlp.EncodeToBytes(entry.List)
+		if err != nil {
+			return nil, err
+		}
+		rawdb.WriteStakersRLP(batch, entry.Hash, data)
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	batch.Reset()
+	rawdb.WriteHeadBlockHash(batch, block.Hash())
+	rawdb.WriteHeadHeaderHash(batch, block.Hash())
+	rawdb.WriteHeadFastBlockHash(batch, block.Hash())
+	rawdb.WriteChainBase(batch, first)
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	log.Info("Imported state snapshot", "number", block.Number(), "hash", block.Hash(), "blocks", len(blocks), "stakers", len(stakers), "states", len(states), "elapsed", common.PrettyDuration(time.Since(start)))
+	return block, nil
+}
+
+// verifyBlock checks the number, the total difficulty, the transactions and the
+// uncles of a block of a snapshot.
+func verifyBlock(entry *blockEntry, number uint64) error {
+	block := entry.Block
+	switch {
+	case block.NumberU64() != number:
+		return fmt.Errorf("block %d found at the position of %d", block.NumberU64(), number)
+	case entry.TD == nil || entry.TD.Cmp(block.Difficulty()) < 0:
+		return fmt.Errorf("block %d: invalid total difficulty %v", number, entry.TD)
+	}
+	if hash := types.DeriveSha(block.Transactions()); hash != block.TxHash() {
+		return fmt.Errorf("block %d: transaction root mismatch: have %x, want %x", number, hash, block.TxHash())
+	}
+	if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+		return fmt.Errorf("block %d: uncle root mismatch: have %x, want %x", number, hash, block.UncleHash())
+	}
+	return nil
+}
+
+// importState applies the accounts of a state of the stream to the state of base,
+// writes the resulting tries and the codes not among the known ones, and checks
+// the root of the state. It returns the number of accounts read.
+func importState(stream *rlp.Stream, db berithdb.Database, triedb *trie.Database, base, root common.Hash, codes map[common.Hash]struct{}) (int, error) {
+	accountTrie, err := trie.New(base, triedb)
+	if err != nil {
+		return 0, err
+	}
+	batch := db.NewBatch()
+	accounts := 0
+	for ; ; accounts++ {
+		entry := new(accountEntry)
+		if err := stream.Decode(entry); err != nil {
+			return accounts, fmt.Errorf("invalid account: %v", err)
+		}
+		if entry.Hash == (common.Hash{}) {
+			break
+		}
+		if len(entry.Data) == 0 {
+			if err := accountTrie.TryDelete(entry.Hash[:]); err != nil {
+				return accounts, err
+			}
+			continue
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(entry.Data, &account); err != nil {
+			return accounts, fmt.Errorf("account %x: %v", entry.Hash, err)
+		}
+		// Update the storage of the account from its previous state
+		baseStorage := emptyRoot
+		if prev, err := accountTrie.TryGet(entry.Hash[:]); err != nil {
+			return accounts, err
+		} else if len(prev) > 0 {
+			var prevAccount state.Account
+			if err := rlp.DecodeBytes(prev, &prevAccount); err != nil {
+				return accounts, err
+			}
+			baseStorage = prevAccount.Root
+		}
+		if account.Root != baseStorage {
+			if err := importStorage(triedb, entry, baseStorage, account.Root); err != nil {
+				return accounts, err
+			}
+		}
+		// Write the code of the account unless known already
+		if hash := common.BytesToHash(account.CodeHash); hash != emptyCode {
+			if len(entry.Code) > 0 {
+				if crypto.Keccak256Hash(entry.Code) != hash {
+					return accounts, fmt.Errorf("account %x: code hash mismatch", entry.Hash)
+				}
+				batch.Put(hash[:], entry.Code)
+				if batch.ValueSize() >= berithdb.IdealBatchSize {
+					if err := batch.Write(); err != nil {
+						return accounts, err
+					}
+					batch.Reset()
+				}
+				codes[hash] = struct{}{}
+			} else if _, ok := codes[hash]; !ok {
+				if has, _ := db.Has(hash[:]); !has {
+					return accounts, fmt.Errorf("account %x: code %x missing", entry.Hash, hash)
+				}
+			}
+		}
+		if err := accountTrie.TryUpdate(entry.Hash[:], entry.Data); err != nil {
+			return accounts, err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return accounts, err
+	}
+	hash, err := accountTrie.Commit(nil)
+	if err != nil {
+		return accounts, err
+	}
+	if hash != root {
+		return accounts, fmt.Errorf("state root mismatch: have %x, want %x", hash, root)
+	}
+	return accounts, triedb.Commit(hash, false)
+}
+
+// importStorage applies the storage slots of an account to its storage trie of
+// base, and writes the resulting trie of the given root.
+func importStorage(triedb *trie.Database, entry *accountEntry, base, root common.Hash) error {
+	storageTrie, err := trie.New(base, triedb)
+	if err != nil {
+		return err
+	}
+	for _, slot := range entry.Storage {
+		if len(slot.Value) == 0 {
+			err = storageTrie.TryDelete(slot.Hash[:])
+		} else {
+			err = storageTrie.TryUpdate(slot.Hash[:], slot.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	hash, err := storageTrie.Commit(nil)
+	if err != nil {
+		return err
+	}
+	if hash != root {
+		return fmt.Errorf("account %x: storage root mismatch: have %x, want %x", entry.Hash, hash, root)
+	}
+	return triedb.Commit(hash, false)
+}
@@ -0,0 +1,361 @@
+package checkpoint
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/state"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/rlp"
+)
+
+const (
+	testNetwork = 101
+	testEpoch   = 4
+	testNumber  = 10
+)
+
+var (
+	testContract = common.Address{0xcc}
+	testStaker   = common.Address{0x55}
+)
+
+// newGenesisDB creates a database holding the genesis of the test chain.
+func newGenesisDB() (berithdb.Database, *types.Block) {
+	db := berithdb.NewMemDatabase()
+	genesis := types.NewBlock(&types.Header{Number: big.NewInt(0), Root: emptyRoot, Difficulty: big.NewInt(1)}, nil, nil, nil)
+	rawdb.WriteBlock(db, genesis)
+	rawdb.WriteTd(db, genesis.Hash(), 0, genesis.Difficulty())
+	rawdb.WriteCanonicalHash(db, genesis.Hash(), 0)
+	rawdb.WriteHeadBlockHash(db, genesis.Hash())
+	rawdb.WriteHeadHeaderHash(db, genesis.Hash())
+	return db, genesis
+}
+
+// newTestChain creates a chain whose blocks each modify accounts, the Berith
+// fields of a staker and the storage of a contract, and delete an account.
+func newTestChain(t *testing.T) (berithdb.Database, []*types.Block) {
+	db, genesis := newGenesisDB()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	blocks := []*types.Block{genesis}
+	td := new(big.Int).Set(genesis.Difficulty())
+	for i := 1; i <= testNumber; i++ {
+		number := big.NewInt(int64(i))
+
+		statedb.AddBalance(common.BigToAddress(number), big.NewInt(int64(i)))
+		statedb.AddBalance(testStaker, big.NewInt(1))
+		statedb.SetStaking(testStaker, big.NewInt(int64(100*i)), number)
+		statedb.AddPoint(testStaker, number)
+		statedb.AddBehindBalance(testStaker, number, big.NewInt(int64(i)))
+		statedb.SetCode(testContract, []byte{0x60, byte(i % 3)})
+		statedb.SetState(testContract, common.BigToHash(number), common.BigToHash(number))
+		statedb.SetState(testContract, common.BigToHash(big.NewInt(int64(i-2))), common.Hash{})
+		if i%3 == 0 {
+			statedb.Suicide(common.BigToAddress(big.NewInt(int64(i - 1))))
+		}
+		root, err := statedb.Commit(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+			t.Fatal(err)
+		}
+		block := types.NewBlock(&types.Header{
+			ParentHash: blocks[i-1].Hash(),
+			Number:     number,
+			Root:       root,
+			Difficulty: big.NewInt(2),
+		}, nil, nil, nil)
+		td.Add(td, block.Difficulty())
+
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteTd(db, block.Hash(), block.NumberU64(), td)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		data, _ := rlp.EncodeToBytes([]common.Address{testStaker})
+		rawdb.WriteStakersRLP(db, block.Hash(), data)
+		blocks = append(blocks, block)
+	}
+	rawdb.WriteHeadBlockHash(db, blocks[testNumber].Hash())
+	return db, blocks
+}
+
+// exportSnapshot exports the snapshot of the head of the test chain.
+func exportSnapshot(t *testing.T, db berithdb.Database) []byte {
+	var buf bytes.Buffer
+	if err := Export(db, &buf, testNetwork, testNumber, testEpoch); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Tests the blocks whose states are needed to process the following epoch.
+func TestStateBlocks(t *testing.T) {
+	tests := []struct {
+		number, epoch uint64
+		want          []uint64
+	}{
+		{2, 4, []uint64{2}},
+		{3, 4, []uint64{3}},
+		{5, 4, []uint64{5, 4}},
+		{7, 4, []uint64{7, 6, 5, 4}},
+		{10, 4, []uint64{10, 9, 8, 7, 6}},
+		{10, 0, []uint64{10}},
+	}
+	for _, tt := range tests {
+		if have := StateBlocks(tt.number, tt.epoch); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("block %d, epoch %d: have %v, want %v", tt.number, tt.epoch, have, tt.want)
+		}
+	}
+}
+
+// Tests that an imported snapshot holds the blocks, the staking lists and the
+// states of the exported one.
+func TestSnapshotRoundtrip(t *testing.T) {
+	src, blocks := newTestChain(t)
+	data := exportSnapshot(t, src)
+
+	db, _ := newGenesisDB()
+	td := rawdb.ReadTd(src, blocks[testNumber].Hash(), testNumber)
+	head, err := Import(db, bytes.NewReader(data), testNetwork, testEpoch, blocks[testNumber].Hash(), common.Hash{}, td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Hash() != blocks[testNumber].Hash() || rawdb.ReadHeadBlockHash(db) != head.Hash() || rawdb.ReadHeadHeaderHash(db) != head.Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head.Hash(), blocks[testNumber].Hash())
+	}
+	for _, n := range StateBlocks(testNumber, testEpoch) {
+		block := blocks[n]
+		if rawdb.ReadCanonicalHash(db, n) != block.Hash() || rawdb.ReadTd(db, block.Hash(), n).Cmp(rawdb.ReadTd(src, block.Hash(), n)) != 0 {
+			t.Fatalf("block %d not imported", n)
+		}
+		if !bytes.Equal(rawdb.ReadStakersRLP(db, block.Hash()), rawdb.ReadStakersRLP(src, block.Hash())) {
+			t.Fatalf("staking list of block %d not imported", n)
+		}
+		have, err := state.New(block.Root(), state.NewDatabase(db))
+		if err != nil {
+			t.Fatalf("state of block %d not imported: %v", n, err)
+		}
+		want, _ := state.New(block.Root(), state.NewDatabase(src))
+		if !reflect.DeepEqual(stateNodes(have), stateNodes(want)) {
+			t.Fatalf("state of block %d mismatch", n)
+		}
+		if have.GetStakeBalance(testStaker).Cmp(want.GetStakeBalance(testStaker)) != 0 || have.GetPoint(testStaker).Cmp(want.GetPoint(testStaker)) != 0 ||
+			!reflect.DeepEqual(have.GetBehindBalance(testStaker), want.GetBehindBalance(testStaker)) || have.GetStakeBalance(testStaker).Sign() == 0 {
+			t.Fatalf("staker of block %d mismatch", n)
+		}
+	}
+	if rawdb.ReadCanonicalHash(db, 5) != (common.Hash{}) {
+		t.Fatal("block outside of the snapshot imported")
+	}
+	if base := rawdb.ReadChainBase(db); base == nil || *base != testNumber-testEpoch {
+		t.Fatalf("chain base mismatch: have %v, want %d", base, testNumber-testEpoch)
+	}
+	// Only an empty chain can be bootstrapped
+	if _, err := Import(db, bytes.NewReader(data), testNetwork, testEpoch, blocks[testNumber].Hash(), common.Hash{}, td); err != errNotEmpty {
+		t.Fatalf("import over a chain: have %v, want %v", err, errNotEmpty)
+	}
+}
+
+// Tests that snapshots which don't match the trusted block are rejected.
+func TestSnapshotVerification(t *testing.T) {
+	src, blocks := newTestChain(t)
+	data := exportSnapshot(t, src)
+	td := rawdb.ReadTd(src, blocks[testNumber].Hash(), testNumber)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		network uint64
+		epoch   uint64
+		hash    common.Hash
+		root    common.Hash
+		td      *big.Int
+		err     string
+	}{
+		{"no trust", data, testNetwork, testEpoch, common.Hash{}, common.Hash{}, td, errNoTrust.Error()},
+		{"no td", data, testNetwork, testEpoch, blocks[testNumber].Hash(), common.Hash{}, nil, errNoTrustedTD.Error()},
+		{"network", data, 1, testEpoch, blocks[testNumber].Hash(), common.Hash{}, td, "network mismatch"},
+		{"epoch", data, testNetwork, 3, blocks[testNumber].Hash(), common.Hash{}, td, "states"},
+		{"hash", data, testNetwork, testEpoch, blocks[testNumber-1].Hash(), common.Hash{}, td, "untrusted block"},
+		{"root", data, testNetwork, testEpoch, common.Hash{}, blocks[testNumber-1].Root(), td, "untrusted state"},
+		{"td", data, testNetwork, testEpoch, common.Hash{}, blocks[testNumber].Root(), new(big.Int).Add(td, common.Big1), "untrusted total difficulty"},
+		{"block td", alterFirstTD(t, data), testNetwork, testEpoch, blocks[testNumber].Hash(), common.Hash{}, td, "total difficulty mismatch"},
+		{"account", alterAccount(t, data), testNetwork, testEpoch, blocks[testNumber].Hash(), common.Hash{}, td, "state root mismatch"},
+	}
+	for _, tt := range tests {
+		db, _ := newGenesisDB()
+		_, err := Import(db, bytes.NewReader(tt.data), tt.network, tt.epoch, tt.hash, tt.root, tt.td)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: have error %v, want %q", tt.name, err, tt.err)
+		}
+		if rawdb.ReadHeadBlockHash(db) != blocks[0].Hash() {
+			t.Errorf("%s: head moved by a rejected snapshot", tt.name)
+		}
+	}
+	// Lists of the state blocks naming accounts without stake are rejected
+	src, blocks = newTestChain(t)
+	list, _ := rlp.EncodeToBytes([]common.Address{testStaker, testContract})
+	rawdb.WriteStakersRLP(src, blocks[testEpoch*2].Hash(), list)
+
+	db, _ := newGenesisDB()
+	if _, err := Import(db, bytes.NewReader(exportSnapshot(t, src)), testNetwork, testEpoch, blocks[testNumber].Hash(), common.Hash{}, td); err == nil || !strings.Contains(err.Error(), "holds no stake") {
+		t.Fatalf("staking list with unstaked account: have error %v, want %q", err, "holds no stake")
+	}
+	if rawdb.ReadHeadBlockHash(db) != blocks[0].Hash() || rawdb.HasStakers(db, blocks[testEpoch*2].Hash()) {
+		t.Fatal("rejected staking list imported")
+	}
+	// The trusted state root alone is enough
+	db, _ = newGenesisDB()
+	if _, err := Import(db, bytes.NewReader(data), testNetwork, testEpoch, common.Hash{}, blocks[testNumber].Root(), td); err != nil {
+		t.Fatalf("snapshot of trusted state rejected: %v", err)
+	}
+}
+
+// Tests that the staking lists of the blocks whose states are exported are
+// required on both ends.
+func TestSnapshotStakers(t *testing.T) {
+	src, blocks := newTestChain(t)
+	data := exportSnapshot(t, src)
+
+	// A snapshot without the list of the snapshot block is rejected
+	db, _ := newGenesisDB()
+	td := rawdb.ReadTd(src, blocks[testNumber].Hash(), testNumber)
+	if _, err := Import(db, bytes.NewReader(dropLastStakers(t, data)), testNetwork, testEpoch, blocks[testNumber].Hash(), common.Hash{}, td); err == nil || !strings.Contains(err.Error(), "staking list of block 10 missing") {
+		t.Fatalf("snapshot without staking list: have error %v", err)
+	}
+	// The list of a stake target can't be left out of an export
+	rawdb.DeleteStakers(src, blocks[testNumber-testEpoch].Hash())
+	if err := Export(src, ioutil.Discard, testNetwork, testNumber, testEpoch); err == nil || !strings.Contains(err.Error(), "staking list of block 6 not found") {
+		t.Fatalf("export without staking list: have error %v", err)
+	}
+}
+
+// stateNodes returns the hashes of the trie nodes and codes of a state.
+func stateNodes(statedb *state.StateDB) map[common.Hash]bool {
+	nodes := make(map[common.Hash]bool)
+	for it := state.NewNodeIterator(statedb); it.Next(); {
+		if it.Hash != (common.Hash{}) {
+			nodes[it.Hash] = true
+		}
+	}
+	return nodes
+}
+
+// alterAccount changes the balance of the first account of a snapshot.
+func alterAccount(t *testing.T, data []byte) []byte {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var head header
+	if err := rlp.DecodeBytes(firstItem(t, raw), &head); err != nil {
+		t.Fatal(err)
+	}
+	// Skip the header, the blocks, the staking lists and the first state item
+	rest := raw
+	for i := uint64(0); i < 1+head.Blocks+head.Stakers+1; i++ {
+		rest = rest[len(firstItem(t, rest)):]
+	}
+	item := firstItem(t, rest)
+	var entry accountEntry
+	if err := rlp.DecodeBytes(item, &entry); err != nil {
+		t.Fatal(err)
+	}
+	var account state.Account
+	if err := rlp.DecodeBytes(entry.Data, &account); err != nil {
+		t.Fatal(err)
+	}
+	account.Balance = new(big.Int).Add(account.Balance, big.NewInt(1))
+	entry.Data, _ = rlp.EncodeToBytes(&account)
+	altered, _ := rlp.EncodeToBytes(&entry)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(raw[:len(raw)-len(rest)])
+	zw.Write(altered)
+	zw.Write(rest[len(item):])
+	zw.Close()
+	return buf.Bytes()
+}
+
+// alterFirstTD changes the total difficulty of the first block of a snapshot.
+func alterFirstTD(t *testing.T, data []byte) []byte {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest := raw[len(firstItem(t, raw)):]
+	item := firstItem(t, rest)
+	var entry blockEntry
+	if err := rlp.DecodeBytes(item, &entry); err != nil {
+		t.Fatal(err)
+	}
+	entry.TD = new(big.Int).Add(entry.TD, big.NewInt(1))
+	altered, _ := rlp.EncodeToBytes(&entry)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(raw[:len(raw)-len(rest)])
+	zw.Write(altered)
+	zw.Write(rest[len(item):])
+	zw.Close()
+	return buf.Bytes()
+}
+
+// dropLastStakers removes the last staking list of a snapshot.
+func dropLastStakers(t *testing.T, data []byte) []byte {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var head header
+	if err := rlp.DecodeBytes(firstItem(t, raw), &head); err != nil {
+		t.Fatal(err)
+	}
+	// Skip the header, the blocks and all but the last staking list
+	rest := raw[len(firstItem(t, raw)):]
+	for i := uint64(0); i < head.Blocks+head.Stakers-1; i++ {
+		rest = rest[len(firstItem(t, rest)):]
+	}
+	items := raw[len(firstItem(t, raw)) : len(raw)-len(rest)]
+	head.Stakers--
+	encoded, _ := rlp.EncodeToBytes(&head)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(encoded)
+	zw.Write(items)
+	zw.Write(rest[len(firstItem(t, rest)):])
+	zw.Close()
+	return buf.Bytes()
+}
+
+// firstItem returns the encoding of the first RLP item of a stream.
+func firstItem(t *testing.T, data []byte) []byte {
+	_, _, rest, err := rlp.Split(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data[:len(data)-len(rest)]
+}
@@ -32,4 +32,8 @@ var (
 	// ErrNonceTooHigh is returned if the nonce of a transaction is higher than the
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
+
+	// ErrKeyMigrationTargetUsed is returned if the new key of a key migration
+	// already has a nonce, code or a staking position of its own.
+	ErrKeyMigrationTargetUsed = errors.New("key migration target already in use")
 )
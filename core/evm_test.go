@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -16,7 +17,9 @@ import (
 	"github.com/BerithFoundation/berith-chain/common"
 	"github.com/BerithFoundation/berith-chain/core/state"
 	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/crypto"
 	"github.com/BerithFoundation/berith-chain/params"
+	"github.com/BerithFoundation/berith-chain/rlp"
 )
 
 type txdata struct {
@@ -255,3 +258,230 @@ func checkBigInt(a, b *big.Int) error {
 	}
 	return nil
 }
+
+// applyTx signs a transaction with the given key and applies it to statedb as
+// part of the block of the given number.
+func applyTx(t *testing.T, config *params.ChainConfig, statedb *state.StateDB, key *ecdsa.PrivateKey, tx *types.Transaction, number int64) (bool, error) {
+	tx, err := types.SignTx(tx, types.NewBIP7Signer(config.ChainID), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return applySignedTx(t, config, statedb, tx, number)
+}
+
+// applySignedTx applies a signed transaction to statedb as part of the block of
+// the given number.
+func applySignedTx(t *testing.T, config *params.ChainConfig, statedb *state.StateDB, tx *types.Transaction, number int64) (bool, error) {
+	msg, err := tx.AsMessage(types.NewBIP7Signer(config.ChainID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := header
+	head.Number = big.NewInt(number)
+	author := common.BytesToAddress([]byte("gas"))
+	ctx := NewEVMContext(msg, &head, nil, &author)
+	gp := new(GasPool).AddGas(head.GasLimit)
+	_, _, failed, err := ApplyMessage(vm.NewEVM(ctx, statedb, config, vmConfig), msg, gp)
+	return failed, err
+}
+
+// Tests that Reward wallet transactions move the matured rewards only, into the
+// main or the stake balance of the sender, and toggle auto-compounding.
+func TestRewardTransaction(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	config := *params.TestnetChainConfig
+	config.BIP5Block = big.NewInt(0)
+
+	// Block 100 of an epoch of 40 blocks pays the rewards of block 60 and before
+	newState := func() *state.StateDB {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(berithdb.NewMemDatabase()))
+		statedb.AddBalance(from, eth)
+		statedb.AddBehindBalance(from, big.NewInt(50), new(big.Int).Mul(big.NewInt(10), eth))
+		statedb.AddBehindBalance(from, big.NewInt(90), new(big.Int).Mul(big.NewInt(5), eth))
+		return statedb
+	}
+	rewardTx := func(statedb *state.StateDB, to common.Address, value *big.Int, target types.JobWallet) *types.Transaction {
+		return types.NewTransaction(statedb.GetNonce(from), to, value, 21000, big.NewInt(1), nil, types.Reward, target)
+	}
+	fee := big.NewInt(21000)
+	ten := new(big.Int).Mul(big.NewInt(10), eth)
+
+	// Withdrawing the matured rewards
+	statedb := newState()
+	if failed, err := applyTx(t, &config, statedb, key, rewardTx(statedb, from, ten, types.Main), 100); failed || err != nil {
+		t.Fatalf("withdraw: failed %v, err %v", failed, err)
+	}
+	if have, want := statedb.GetBalance(from), new(big.Int).Sub(new(big.Int).Add(eth, ten), fee); have.Cmp(want) != 0 {
+		t.Errorf("withdraw: balance %v, want %v", have, want)
+	}
+	if have := statedb.GetMaturedReward(from, big.NewInt(60)); have.Sign() != 0 {
+		t.Errorf("withdraw: matured reward %v left", have)
+	}
+	// Rewards which aren't matured yet stay behind
+	statedb = newState()
+	if _, err := applyTx(t, &config, statedb, key, rewardTx(statedb, from, new(big.Int).Add(ten, eth), types.Main), 100); err != vm.ErrInsufficientBalance {
+		t.Fatalf("withdraw immature: have %v, want %v", err, vm.ErrInsufficientBalance)
+	}
+	if have := statedb.GetMaturedReward(from, big.NewInt(60)); have.Cmp(ten) != 0 {
+		t.Errorf("withdraw immature: matured reward %v, want %v", have, ten)
+	}
+	// Compounding the matured rewards
+	statedb = newState()
+	if failed, err := applyTx(t, &config, statedb, key, rewardTx(statedb, from, ten, types.Stake), 100); failed || err != nil {
+		t.Fatalf("compound: failed %v, err %v", failed, err)
+	}
+	if have := statedb.GetStakeBalance(from); have.Cmp(ten) != 0 {
+		t.Errorf("compound: stake %v, want %v", have, ten)
+	}
+	// Switching auto-compounding on and off
+	statedb = newState()
+	for _, enabled := range []bool{true, false} {
+		value := big.NewInt(0)
+		if enabled {
+			value = big.NewInt(1)
+		}
+		if failed, err := applyTx(t, &config, statedb, key, rewardTx(statedb, from, value, types.Reward), 100); failed || err != nil {
+			t.Fatalf("auto-compound %v: failed %v, err %v", enabled, failed, err)
+		}
+		if statedb.GetAutoCompound(from) != enabled {
+			t.Errorf("auto-compound mismatch: have %v, want %v", !enabled, enabled)
+		}
+	}
+	// Rewards can only be moved into the sender's own wallets, from BIP5 on
+	statedb = newState()
+	if _, err := applyTx(t, &config, statedb, key, rewardTx(statedb, common.Address{1}, ten, types.Main), 100); err != ErrInvalidStakeReceiver {
+		t.Errorf("withdraw to another account: have %v, want %v", err, ErrInvalidStakeReceiver)
+	}
+	config.BIP5Block = big.NewInt(101)
+	if _, err := applyTx(t, &config, statedb, key, rewardTx(statedb, from, ten, types.Main), 100); err != types.ErrInvalidJobWallet {
+		t.Errorf("withdraw before BIP5: have %v, want %v", err, types.ErrInvalidJobWallet)
+	}
+}
+
+// Tests that a setup transaction persists the configuration of a new multisig
+// account, whose transactions then need the approval of enough of its owners.
+func TestMultisigTransaction(t *testing.T) {
+	var (
+		keys   = make([]*ecdsa.PrivateKey, 4)
+		owners = make([]common.Address, 4)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		owners[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	config := *params.TestnetChainConfig
+	config.BIP7Block = big.NewInt(0)
+	signer := types.NewBIP7Signer(config.ChainID)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(berithdb.NewMemDatabase()))
+	statedb.AddBalance(owners[0], new(big.Int).Mul(big.NewInt(100), eth))
+
+	// Owners 0 to 2 set up a 2-of-3 account, owner 3 isn't part of it
+	multisig := &types.MultisigConfig{Threshold: 2, Owners: owners[:3]}
+	payload, err := rlp.EncodeToBytes(multisig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := new(big.Int).Mul(big.NewInt(50), eth)
+	setup := types.NewTransaction(0, types.MultisigSetupAddress, value, 100000+params.MultisigSetupGas, big.NewInt(1), payload, types.Main, types.Main)
+	if failed, err := applyTx(t, &config, statedb, keys[0], setup, 1); failed || err != nil {
+		t.Fatalf("setup: failed %v, err %v", failed, err)
+	}
+	account := types.MultisigAddress(owners[0], 0)
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb, _ = state.New(root, statedb.Database())
+	if have := statedb.GetMultisig(account); have == nil || have.Threshold != multisig.Threshold || !reflect.DeepEqual(have.Owners, multisig.Owners) {
+		t.Fatalf("multisig configuration mismatch: have %v, want %v", have, multisig)
+	}
+	if have := statedb.GetBalance(account); have.Cmp(value) != 0 {
+		t.Fatalf("multisig balance mismatch: have %v, want %v", have, value)
+	}
+	// stakeTx returns the stake transaction of the account approved by the given owners
+	stake := new(big.Int).Mul(big.NewInt(10), eth)
+	stakeTx := func(approvers ...int) *types.Transaction {
+		tx := types.NewTransaction(statedb.GetNonce(account), account, stake, 21000+2*params.TxMultisigApprovalGas, big.NewInt(1), nil, types.Main, types.Stake)
+		tx = types.NewMultisigTransaction(config.ChainID, account, tx)
+		for _, i := range approvers {
+			if tx, err = types.SignMultisigTx(tx, signer, keys[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return tx
+	}
+	if _, err := applySignedTx(t, &config, statedb, stakeTx(1), 2); err != types.ErrMultisigThreshold {
+		t.Errorf("under threshold: have %v, want %v", err, types.ErrMultisigThreshold)
+	}
+	if _, err := applySignedTx(t, &config, statedb, stakeTx(1, 3), 2); err != types.ErrUnknownMultisigOwner {
+		t.Errorf("non owner approval: have %v, want %v", err, types.ErrUnknownMultisigOwner)
+	}
+	if have := statedb.GetStakeBalance(account); have.Sign() != 0 {
+		t.Fatalf("rejected transactions staked %v", have)
+	}
+	if failed, err := applySignedTx(t, &config, statedb, stakeTx(2, 1), 2); failed || err != nil {
+		t.Fatalf("approved stake: failed %v, err %v", failed, err)
+	}
+	if have := statedb.GetStakeBalance(account); have.Cmp(stake) != 0 {
+		t.Errorf("stake mismatch: have %v, want %v", have, stake)
+	}
+	if have := statedb.GetNonce(account); have != 1 {
+		t.Errorf("nonce mismatch: have %d, want 1", have)
+	}
+}
+
+// Tests that vesting transactions lock at least the minimum amount with a
+// limited number of schedules per account, and that the vested balances are
+// released when the account sends a transaction.
+func TestVestingTransaction(t *testing.T) {
+	fromKey, _ := crypto.GenerateKey()
+	toKey, _ := crypto.GenerateKey()
+	from, to := crypto.PubkeyToAddress(fromKey.PublicKey), crypto.PubkeyToAddress(toKey.PublicKey)
+
+	config := *params.TestnetChainConfig
+	config.BIP9Block = big.NewInt(0)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(berithdb.NewMemDatabase()))
+	statedb.AddBalance(from, new(big.Int).Mul(big.NewInt(100), eth))
+
+	payload, err := rlp.EncodeToBytes(&types.VestingSchedule{Start: 1, Cliff: 10, End: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockTx := func(value *big.Int) *types.Transaction {
+		return types.NewTransaction(statedb.GetNonce(from), to, value, 100000, big.NewInt(1), payload, types.Main, types.Vesting)
+	}
+	if failed, err := applyTx(t, &config, statedb, fromKey, lockTx(new(big.Int).Div(params.MinVestingAmount, big.NewInt(2))), 1); !failed || err != nil {
+		t.Fatalf("lock under the minimum: failed %v, err %v", failed, err)
+	}
+	for i := 0; i < params.MaxVestingSchedules; i++ {
+		if failed, err := applyTx(t, &config, statedb, fromKey, lockTx(eth), 1); failed || err != nil {
+			t.Fatalf("lock %d: failed %v, err %v", i, failed, err)
+		}
+	}
+	if failed, err := applyTx(t, &config, statedb, fromKey, lockTx(eth), 1); !failed || err != nil {
+		t.Fatalf("lock over the limit: failed %v, err %v", failed, err)
+	}
+	if have := statedb.GetVestingCount(to); have != params.MaxVestingSchedules {
+		t.Fatalf("schedule count mismatch: have %d, want %d", have, params.MaxVestingSchedules)
+	}
+	// Nothing is released until the account sends a transaction, which pays
+	// for its gas with the released balance
+	if have := statedb.GetBalance(to); have.Sign() != 0 {
+		t.Fatalf("balance released before a transaction: %v", have)
+	}
+	transfer := types.NewTransaction(0, from, eth, 21000, big.NewInt(1), nil, types.Main, types.Main)
+	if failed, err := applyTx(t, &config, statedb, toKey, transfer, 10); failed || err != nil {
+		t.Fatalf("transfer: failed %v, err %v", failed, err)
+	}
+	want := new(big.Int).Mul(big.NewInt(params.MaxVestingSchedules-1), eth)
+	if have := statedb.GetBalance(to); have.Cmp(want.Sub(want, big.NewInt(21000))) != 0 {
+		t.Errorf("released balance mismatch: have %v, want %v", have, want)
+	}
+	if have := statedb.GetVestingCount(to); have != 0 {
+		t.Errorf("released schedules left: %d", have)
+	}
+}
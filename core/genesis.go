@@ -40,7 +40,12 @@ import (
 //go:generate gencodec -type Genesis -field-override genesisSpecMarshaling -out gen_genesis.go
 //go:generate gencodec -type GenesisAccount -field-override genesisAccountMarshaling -out gen_genesis_account.go
 
-var errGenesisNoConfig = errors.New("genesis has no chain configuration")
+var (
+	errGenesisNoConfig = errors.New("genesis has no chain configuration")
+
+	// [BERITH]
+	errGenesisVestingBeforeBIP9 = errors.New("genesis vesting needs BIP9 at the genesis block")
+)
 
 // Genesis specifies the header fields, state of a genesis block. It also defines hard
 // fork switch-over blocks through the chain configuration.
@@ -84,6 +89,13 @@ type GenesisAccount struct {
 	Balance    *big.Int                    `json:"balance" gencodec:"required"`
 	Nonce      uint64                      `json:"nonce,omitempty"`
 	PrivateKey []byte                      `json:"secretKey,omitempty"` // for tests
+	Vesting    []GenesisVesting            `json:"vesting,omitempty"`   // [BERITH] locked on top of the balance
+}
+
+// GenesisVesting is a balance of a genesis account locked by a vesting schedule.
+type GenesisVesting struct {
+	types.VestingSchedule
+	Amount *math.HexOrDecimal256 `json:"amount"`
 }
 
 // field type overrides for gencodec
@@ -157,6 +169,11 @@ func SetupGenesisBlockWithOverride(db berithdb.Database, genesis *Genesis, const
 	if genesis != nil && genesis.Config == nil {
 		return params.MainnetChainConfig, common.Hash{}, errGenesisNoConfig
 	}
+	if genesis != nil {
+		if err := genesis.validateVesting(genesis.Config); err != nil {
+			return genesis.Config, common.Hash{}, err
+		}
+	}
 
 	// Just commit the new block if there is no stored genesis block.
 	stored := rawdb.ReadCanonicalHash(db, 0)
@@ -226,8 +243,34 @@ func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 	}
 }
 
+// validateVesting checks the vesting allocations of the genesis accounts, which
+// are only allowed if BIP9 is enabled from the genesis block on.
+func (g *Genesis) validateVesting(config *params.ChainConfig) error {
+	for addr, account := range g.Alloc {
+		if len(account.Vesting) == 0 {
+			continue
+		}
+		if !config.IsBIP9(common.Big0) {
+			return errGenesisVestingBeforeBIP9
+		}
+		if len(account.Vesting) > params.MaxVestingSchedules {
+			return fmt.Errorf("genesis account %x: %v", addr, types.ErrTooManyVesting)
+		}
+		for _, vesting := range account.Vesting {
+			if err := vesting.Validate(); err != nil {
+				return fmt.Errorf("genesis account %x: %v", addr, err)
+			}
+			if vesting.Amount == nil || (*big.Int)(vesting.Amount).Cmp(params.MinVestingAmount) < 0 {
+				return fmt.Errorf("genesis account %x: %v", addr, types.ErrVestingAmountTooLow)
+			}
+		}
+	}
+	return nil
+}
+
 // ToBlock creates the genesis block and writes state of a genesis specification
-// to the given database (or discards it if nil).
+// to the given database (or discards it if nil). The vesting allocations are
+// checked by Commit and SetupGenesisBlock.
 func (g *Genesis) ToBlock(db berithdb.Database) *types.Block {
 	if db == nil {
 		db = berithdb.NewMemDatabase()
@@ -240,6 +283,11 @@ func (g *Genesis) ToBlock(db berithdb.Database) *types.Block {
 		for key, value := range account.Storage {
 			statedb.SetState(addr, key, value)
 		}
+		for _, vesting := range account.Vesting {
+			if vesting.Amount != nil {
+				statedb.AddVesting(addr, vesting.VestingSchedule, (*big.Int)(vesting.Amount))
+			}
+		}
 	}
 	root := statedb.IntermediateRoot(false)
 	head := &types.Header{
@@ -270,6 +318,13 @@ func (g *Genesis) ToBlock(db berithdb.Database) *types.Block {
 // Commit writes the block and state of a genesis specification to the database.
 // The block is committed as the canonical head block.
 func (g *Genesis) Commit(db berithdb.Database) (*types.Block, error) {
+	config := g.Config
+	if config == nil {
+		config = params.MainnetChainConfig
+	}
+	if err := g.validateVesting(config); err != nil {
+		return nil, err
+	}
 	block := g.ToBlock(db)
 	if block.Number().Sign() != 0 {
 		return nil, fmt.Errorf("can't commit genesis block with number > 0")
@@ -280,11 +335,6 @@ func (g *Genesis) Commit(db berithdb.Database) (*types.Block, error) {
 	rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
 	rawdb.WriteHeadBlockHash(db, block.Hash())
 	rawdb.WriteHeadHeaderHash(db, block.Hash())
-
-	config := g.Config
-	if config == nil {
-		config = params.MainnetChainConfig
-	}
 	rawdb.WriteChainConfig(db, block.Hash(), config)
 	return block, nil
 }
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/common/math"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/params"
+)
+
+// Tests that the vesting allocations of a genesis are checked, and need BIP9 to
+// be enabled at the genesis block.
+func TestGenesisVesting(t *testing.T) {
+	addr := common.BytesToAddress([]byte("vesting"))
+	newGenesis := func(bip9 *big.Int, vesting GenesisVesting) *Genesis {
+		config := *params.TestnetChainConfig
+		config.BIP9Block = bip9
+		return &Genesis{
+			Config: &config,
+			Alloc:  GenesisAlloc{addr: {Balance: big.NewInt(1), Vesting: []GenesisVesting{vesting}}},
+		}
+	}
+	amount := (*math.HexOrDecimal256)(params.MinVestingAmount)
+	valid := GenesisVesting{types.VestingSchedule{Start: 0, Cliff: 10, End: 100}, amount}
+
+	if _, _, err := SetupGenesisBlock(berithdb.NewMemDatabase(), newGenesis(big.NewInt(0), valid)); err != nil {
+		t.Fatalf("valid vesting rejected: %v", err)
+	}
+	if _, _, err := SetupGenesisBlock(berithdb.NewMemDatabase(), newGenesis(big.NewInt(1), valid)); err != errGenesisVestingBeforeBIP9 {
+		t.Errorf("vesting before BIP9: have %v, want %v", err, errGenesisVestingBeforeBIP9)
+	}
+	if _, _, err := SetupGenesisBlock(berithdb.NewMemDatabase(), newGenesis(nil, valid)); err != errGenesisVestingBeforeBIP9 {
+		t.Errorf("vesting without BIP9: have %v, want %v", err, errGenesisVestingBeforeBIP9)
+	}
+	invalid := []GenesisVesting{
+		{types.VestingSchedule{Start: 20, Cliff: 10, End: 100}, amount},
+		{types.VestingSchedule{Start: 0, Cliff: 10, End: 100}, nil},
+		{types.VestingSchedule{Start: 0, Cliff: 10, End: 100}, (*math.HexOrDecimal256)(big.NewInt(1))},
+	}
+	for i, vesting := range invalid {
+		if _, err := newGenesis(big.NewInt(0), vesting).Commit(berithdb.NewMemDatabase()); err == nil {
+			t.Errorf("invalid vesting %d accepted", i)
+		}
+	}
+}
@@ -105,3 +105,30 @@ func WriteBloomBits(db DatabaseWriter, bit uint, section uint64, head common.Has
 		log.Crit("Failed to store bloom bits", "err", err)
 	}
 }
+
+// ReadAddressTxEntries retrieves the transactions sent from or to an address
+// within the given address index section.
+func ReadAddressTxEntries(db DatabaseReader, address common.Address, section uint64) []AddressTxEntry {
+	data, _ := db.Get(addressTxKey(address, section))
+	if len(data) == 0 {
+		return nil
+	}
+	var entries []AddressTxEntry
+	if err := rlp.DecodeBytes(data, &entries); err != nil {
+		log.Error("Invalid address index entries RLP", "address", address, "section", section, "err", err)
+		return nil
+	}
+	return entries
+}
+
+// WriteAddressTxEntries stores the transactions sent from or to an address
+// within the given address index section.
+func WriteAddressTxEntries(db DatabaseWriter, address common.Address, section uint64, entries []AddressTxEntry) {
+	data, err := rlp.EncodeToBytes(entries)
+	if err != nil {
+		log.Crit("Failed to encode address index entries", "err", err)
+	}
+	if err := db.Put(addressTxKey(address, section), data); err != nil {
+		log.Crit("Failed to store address index entries", "err", err)
+	}
+}
@@ -0,0 +1,35 @@
+package rawdb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+)
+
+// Tests that address index entries are stored per address and section.
+func TestAddressTxEntriesStorage(t *testing.T) {
+	db := berithdb.NewMemDatabase()
+
+	sender, recipient := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	entries := []AddressTxEntry{
+		{BlockNumber: 1030, Index: 0, Hash: common.HexToHash("0x11")},
+		{BlockNumber: 1031, Index: 2, Hash: common.HexToHash("0x22")},
+	}
+	if have := ReadAddressTxEntries(db, sender, 1); have != nil {
+		t.Fatalf("non existent entries returned: %v", have)
+	}
+	WriteAddressTxEntries(db, sender, 1, entries)
+	WriteAddressTxEntries(db, recipient, 1, entries[1:])
+
+	if have := ReadAddressTxEntries(db, sender, 1); !reflect.DeepEqual(have, entries) {
+		t.Fatalf("sender entries mismatch: have %v, want %v", have, entries)
+	}
+	if have := ReadAddressTxEntries(db, recipient, 1); !reflect.DeepEqual(have, entries[1:]) {
+		t.Fatalf("recipient entries mismatch: have %v, want %v", have, entries[1:])
+	}
+	if have := ReadAddressTxEntries(db, sender, 0); have != nil {
+		t.Fatalf("entries of other section returned: %v", have)
+	}
+}
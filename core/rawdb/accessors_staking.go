@@ -0,0 +1,33 @@
+package rawdb
+
+import (
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/log"
+)
+
+// ReadStakersRLP retrieves the RLP encoded staking list of a block, nil if it
+// isn't stored.
+func ReadStakersRLP(db DatabaseReader, hash common.Hash) []byte {
+	data, _ := db.Get(stakersKey(hash))
+	return data
+}
+
+// HasStakers checks whether the staking list of a block is stored.
+func HasStakers(db DatabaseReader, hash common.Hash) bool {
+	has, err := db.Has(stakersKey(hash))
+	return err == nil && has
+}
+
+// WriteStakersRLP stores the RLP encoded staking list of a block.
+func WriteStakersRLP(db DatabaseWriter, hash common.Hash, data []byte) {
+	if err := db.Put(stakersKey(hash), data); err != nil {
+		log.Crit("Failed to store staking list", "err", err)
+	}
+}
+
+// DeleteStakers removes the staking list of a block.
+func DeleteStakers(db DatabaseDeleter, hash common.Hash) {
+	if err := db.Delete(stakersKey(hash)); err != nil {
+		log.Crit("Failed to delete staking list", "err", err)
+	}
+}
@@ -0,0 +1,29 @@
+package rawdb
+
+import (
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/log"
+)
+
+// ReadStateDiffRLP retrieves the RLP encoded state changes of a block from the
+// state diff database, nil if they aren't recorded.
+func ReadStateDiffRLP(db DatabaseReader, hash common.Hash, number uint64) []byte {
+	data, _ := db.Get(stateDiffKey(number, hash))
+	return data
+}
+
+// WriteStateDiffRLP stores the RLP encoded state changes of a block into the
+// state diff database.
+func WriteStateDiffRLP(db DatabaseWriter, hash common.Hash, number uint64, data []byte) {
+	if err := db.Put(stateDiffKey(number, hash), data); err != nil {
+		log.Crit("Failed to store state diff", "err", err)
+	}
+}
+
+// DeleteStateDiff removes the state changes of a block from the state diff
+// database.
+func DeleteStateDiff(db DatabaseDeleter, hash common.Hash, number uint64) {
+	if err := db.Delete(stateDiffKey(number, hash)); err != nil {
+		log.Crit("Failed to delete state diff", "err", err)
+	}
+}
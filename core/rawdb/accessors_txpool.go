@@ -0,0 +1,81 @@
+package rawdb
+
+import (
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/log"
+	"github.com/BerithFoundation/berith-chain/rlp"
+)
+
+// TxPoolEntry is a transaction persisted by the transaction pool journal
+// together with the locality it was accepted with.
+type TxPoolEntry struct {
+	Tx    *types.Transaction
+	Local bool
+}
+
+// txPoolJournalRange is the half open sequence range [Start, Next) of the
+// persisted transaction pool entries.
+type txPoolJournalRange struct {
+	Start uint64
+	Next  uint64
+}
+
+// ReadTxPoolJournalRange retrieves the sequence range of the persisted
+// transaction pool entries.
+func ReadTxPoolJournalRange(db DatabaseReader) (uint64, uint64) {
+	data, _ := db.Get(txPoolJournalKey)
+	if len(data) == 0 {
+		return 0, 0
+	}
+	var r txPoolJournalRange
+	if err := rlp.DecodeBytes(data, &r); err != nil {
+		log.Error("Invalid transaction pool journal range RLP", "err", err)
+		return 0, 0
+	}
+	return r.Start, r.Next
+}
+
+// WriteTxPoolJournalRange stores the sequence range of the persisted
+// transaction pool entries.
+func WriteTxPoolJournalRange(db DatabaseWriter, start, next uint64) error {
+	data, err := rlp.EncodeToBytes(txPoolJournalRange{Start: start, Next: next})
+	if err != nil {
+		return err
+	}
+	return db.Put(txPoolJournalKey, data)
+}
+
+// ReadTxPoolEntry retrieves the persisted pool transaction with the given
+// sequence number.
+func ReadTxPoolEntry(db DatabaseReader, seq uint64) (*TxPoolEntry, error) {
+	data, err := db.Get(txPoolEntryKey(seq))
+	if err != nil {
+		return nil, err
+	}
+	entry := new(TxPoolEntry)
+	if err := rlp.DecodeBytes(data, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// WriteTxPoolEntry stores a pool transaction under the given sequence number.
+func WriteTxPoolEntry(db DatabaseWriter, seq uint64, entry *TxPoolEntry) error {
+	data, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		return err
+	}
+	return db.Put(txPoolEntryKey(seq), data)
+}
+
+// DeleteTxPoolEntry removes the persisted pool transaction with the given
+// sequence number.
+func DeleteTxPoolEntry(db DatabaseDeleter, seq uint64) error {
+	return db.Delete(txPoolEntryKey(seq))
+}
+
+// TxPoolEntryKeyRange returns the key range [start, limit) covering the pool
+// transaction entries, to be used for database compaction.
+func TxPoolEntryKeyRange() ([]byte, []byte) {
+	return txPoolEntryKey(0), txPoolEntryKey(^uint64(0))
+}
@@ -0,0 +1,237 @@
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/log"
+)
+
+// freezerdb is a database wrapper that enables freezer data retrievals.
+type freezerdb struct {
+	berithdb.Database
+	*freezer
+}
+
+// Close implements berithdb.Database, closing both the fast key-value store and
+// the slow ancient tables.
+func (frdb *freezerdb) Close() {
+	if err := frdb.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	frdb.Database.Close()
+}
+
+// NewDatabaseWithFreezer creates a high level database on top of a given
+// key-value data store with a freezer moving the blocks older than threshold
+// blocks below the head into the ancient directory.
+func NewDatabaseWithFreezer(db berithdb.Database, ancient string, namespace string, threshold uint64) (berithdb.Database, error) {
+	frdb, err := newFreezer(ancient, namespace, threshold)
+	if err != nil {
+		return nil, err
+	}
+	// Since the freezer can be stored separately from the user's key-value database,
+	// there's a fairly high probability that the user requests invalid combinations
+	// of the freezer and database. Ensure that we don't shoot ourselves in the foot
+	// by serving up conflicting data, leading to both datastores getting corrupted.
+	if kvgenesis, _ := db.Get(headerHashKey(0)); len(kvgenesis) > 0 {
+		// A chain bootstrapped from a state snapshot lacks the blocks between the
+		// genesis and the snapshot, which the append-only tables can't skip, and
+		// the receipts of the snapshot blocks.
+		if base := ReadChainBase(db); base != nil {
+			frdb.Close()
+			return nil, fmt.Errorf("chain starting at snapshot block #%d can't use the ancient store, disable it with a zero threshold", *base)
+		}
+		if frozen, _ := frdb.Ancients(); frozen > 0 {
+			// If the freezer already contains something, ensure that the genesis blocks
+			// match, otherwise we might mix up freezers across chains and destroy both
+			// the freezer and the key-value store.
+			if frgenesis, _ := frdb.Ancient(freezerHashTable, 0); common.BytesToHash(kvgenesis) != common.BytesToHash(frgenesis) {
+				frdb.Close()
+				return nil, fmt.Errorf("genesis mismatch: %#x (leveldb) != %#x (ancients)", kvgenesis, frgenesis)
+			}
+			// Key-value store and freezer belong to the same network, ensure that they
+			// are contiguous, otherwise we might end up with a non-functional freezer.
+			if kvhash, _ := db.Get(headerHashKey(frozen)); len(kvhash) == 0 {
+				// Subsequent header after the freezer limit is missing from the database,
+				// the freezer is stale if the key-value store is ahead of it.
+				if head := ReadHeaderNumber(db, ReadHeadHeaderHash(db)); head != nil && *head >= frozen {
+					frdb.Close()
+					return nil, fmt.Errorf("gap (#%d) in the chain between ancients and leveldb", frozen)
+				}
+			}
+		} else {
+			// The freezer is empty, ensure the key-value store hasn't been migrated to
+			// another ancient directory already.
+			if kvblob, _ := db.Get(headerHashKey(1)); len(kvblob) == 0 {
+				if head := ReadHeaderNumber(db, ReadHeadHeaderHash(db)); head != nil && *head > 0 {
+					frdb.Close()
+					return nil, fmt.Errorf("ancient chain segments already extracted, please set --datadir.ancient to the correct path")
+				}
+			}
+		}
+	}
+	// Freezer is consistent with the key-value database, permit combining the two
+	frdb.wg.Add(1)
+	go frdb.freeze(db)
+
+	return &freezerdb{
+		Database: db,
+		freezer:  frdb,
+	}, nil
+}
+
+// KeyValueStore returns the key-value store backing the chain database, without
+// the ancient tables.
+func KeyValueStore(db berithdb.Database) berithdb.Database {
+	if frdb, ok := db.(*freezerdb); ok {
+		return frdb.Database
+	}
+	return db
+}
+
+// LevelDB returns the LevelDB key-value store backing the chain database, nil
+// for an in-memory database or one stored by another engine.
+func LevelDB(db berithdb.Database) *berithdb.LDBDatabase {
+	ldb, _ := KeyValueStore(db).(*berithdb.LDBDatabase)
+	return ldb
+}
+
+// DatabaseStat is the number of entries and their total size for a category of
+// the database content.
+type DatabaseStat struct {
+	Category string
+	Count    uint64
+	Size     common.StorageSize
+}
+
+// add accounts an entry of the given size to the stat.
+func (s *DatabaseStat) add(size int) {
+	s.Count++
+	s.Size += common.StorageSize(size)
+}
+
+// InspectDatabase walks the whole key space of the chain database and returns
+// the number of entries and their size per schema prefix, followed by the size
+// of the ancient tables if the database has some.
+func InspectDatabase(db berithdb.Database) ([]*DatabaseStat, error) {
+	it := db.NewIterator()
+	defer it.Release()
+
+	var (
+		count  uint64
+		start  = time.Now()
+		logged = time.Now()
+
+		headers      = &DatabaseStat{Category: "Headers"}
+		bodies       = &DatabaseStat{Category: "Bodies"}
+		receipts     = &DatabaseStat{Category: "Receipts"}
+		tds          = &DatabaseStat{Category: "Difficulties"}
+		numHashPairs = &DatabaseStat{Category: "Block number->hash"}
+		hashNumPairs = &DatabaseStat{Category: "Block hash->number"}
+		txLookups    = &DatabaseStat{Category: "Transaction lookups"}
+		bloomBits    = &DatabaseStat{Category: "Bloombit index"}
+		addressTxs   = &DatabaseStat{Category: "Address index"}
+		txPool       = &DatabaseStat{Category: "Transaction pool journal"}
+		tries        = &DatabaseStat{Category: "Trie nodes and codes"}
+		preimages    = &DatabaseStat{Category: "Trie preimages"}
+		accountSnaps = &DatabaseStat{Category: "Account snapshot"}
+		storageSnaps = &DatabaseStat{Category: "Storage snapshot"}
+		stakers      = &DatabaseStat{Category: "Staking snapshots"}
+		configs      = &DatabaseStat{Category: "Chain configs"}
+		metadata     = &DatabaseStat{Category: "Metadata"}
+		unaccounted  = &DatabaseStat{Category: "Unaccounted"}
+
+		metadataKeys = [][]byte{databaseVerisionKey, headHeaderKey, headBlockKey, headFastBlockKey, fastTrieProgressKey, txPoolJournalKey, snapshotRootKey, snapshotGeneratorKey, snapshotJournalKey}
+	)
+	for it.Next() {
+		var (
+			key  = it.Key()
+			size = len(key) + len(it.Value())
+		)
+		switch {
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+common.HashLength:
+			headers.add(size)
+		case bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerTDSuffix) && len(key) == len(headerPrefix)+8+common.HashLength+len(headerTDSuffix):
+			tds.add(size)
+		case bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerHashSuffix) && len(key) == len(headerPrefix)+8+len(headerHashSuffix):
+			numHashPairs.add(size)
+		case bytes.HasPrefix(key, headerNumberPrefix) && len(key) == len(headerNumberPrefix)+common.HashLength:
+			hashNumPairs.add(size)
+		case bytes.HasPrefix(key, blockBodyPrefix) && len(key) == len(blockBodyPrefix)+8+common.HashLength:
+			bodies.add(size)
+		case bytes.HasPrefix(key, blockReceiptsPrefix) && len(key) == len(blockReceiptsPrefix)+8+common.HashLength:
+			receipts.add(size)
+		case bytes.HasPrefix(key, txLookupPrefix) && len(key) == len(txLookupPrefix)+common.HashLength:
+			txLookups.add(size)
+		case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == len(bloomBitsPrefix)+10+common.HashLength,
+			bytes.HasPrefix(key, BloomBitsIndexPrefix):
+			bloomBits.add(size)
+		case bytes.HasPrefix(key, addressTxPrefix) && len(key) == len(addressTxPrefix)+common.AddressLength+8,
+			bytes.HasPrefix(key, AddressIndexPrefix):
+			addressTxs.add(size)
+		case bytes.HasPrefix(key, txPoolEntryPrefix):
+			txPool.add(size)
+		case bytes.HasPrefix(key, SnapshotAccountPrefix) && len(key) == len(SnapshotAccountPrefix)+common.HashLength:
+			accountSnaps.add(size)
+		case bytes.HasPrefix(key, SnapshotStoragePrefix) && len(key) == len(SnapshotStoragePrefix)+2*common.HashLength:
+			storageSnaps.add(size)
+		case bytes.HasPrefix(key, stakersPrefix) && len(key) == len(stakersPrefix)+common.HashLength:
+			stakers.add(size)
+		case bytes.HasPrefix(key, preimagePrefix) && len(key) == len(preimagePrefix)+common.HashLength:
+			preimages.add(size)
+		case bytes.HasPrefix(key, configPrefix) && len(key) == len(configPrefix)+common.HashLength:
+			configs.add(size)
+		case len(key) == common.HashLength:
+			tries.add(size)
+		default:
+			var accounted bool
+			for _, meta := range metadataKeys {
+				if bytes.Equal(key, meta) {
+					metadata.add(size)
+					accounted = true
+					break
+				}
+			}
+			if !accounted {
+				unaccounted.add(size)
+			}
+		}
+		count++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Inspecting database", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	stats := []*DatabaseStat{
+		headers, bodies, receipts, tds, numHashPairs, hashNumPairs, txLookups, bloomBits,
+		addressTxs, txPool, tries, accountSnaps, storageSnaps, stakers, preimages, configs, metadata, unaccounted,
+	}
+	// Inspect the ancient store as well, every table holds one item per block
+	if ancients, ok := db.(AncientReader); ok {
+		frozen, err := ancients.Ancients()
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range []struct{ kind, category string }{
+			{freezerHeaderTable, "Ancient headers"},
+			{freezerBodiesTable, "Ancient bodies"},
+			{freezerReceiptTable, "Ancient receipts"},
+			{freezerDifficultyTable, "Ancient difficulties"},
+			{freezerHashTable, "Ancient block number->hash"},
+		} {
+			size, err := ancients.AncientSize(table.kind)
+			if err != nil {
+				return nil, err
+			}
+			stats = append(stats, &DatabaseStat{Category: table.category, Count: frozen, Size: common.StorageSize(size)})
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,58 @@
+package rawdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/types"
+)
+
+// Tests that the database inspection accounts the entries to their schema
+// category, both directly and through a table.
+func TestInspectDatabase(t *testing.T) {
+	db := berithdb.NewMemDatabase()
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Extra: []byte("test block")})
+	WriteBlock(db, block)
+	WriteCanonicalHash(db, block.Hash(), 1)
+	WriteHeadBlockHash(db, block.Hash())
+	WritePreimages(db, map[common.Hash][]byte{common.HexToHash("0x01"): []byte("preimage")})
+	db.Put(common.HexToHash("0x02").Bytes(), []byte("trie node"))
+	db.Put([]byte("unknown"), []byte("value"))
+
+	stats, err := InspectDatabase(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]uint64{
+		"Headers":              1,
+		"Bodies":               1,
+		"Block number->hash":   1,
+		"Block hash->number":   1,
+		"Trie preimages":       1,
+		"Trie nodes and codes": 1,
+		"Metadata":             1,
+		"Unaccounted":          1,
+	}
+	for _, stat := range stats {
+		if stat.Count != want[stat.Category] {
+			t.Errorf("%s: count mismatch: have %d, want %d", stat.Category, stat.Count, want[stat.Category])
+		}
+	}
+	// Iterating a table only returns its own entries, without the prefix
+	table := berithdb.NewTable(db, "table-")
+	table.Put([]byte("key"), []byte("value"))
+
+	it := table.NewIterator()
+	defer it.Release()
+
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	if len(keys) != 1 || keys[0] != "key" {
+		t.Errorf("table iteration mismatch: have %q, want %q", keys, []string{"key"})
+	}
+}
@@ -0,0 +1,239 @@
+package rawdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/metrics"
+)
+
+// getChunk returns a chunk of data of the given size, filled with b.
+func getChunk(size int, b int) []byte {
+	return bytes.Repeat([]byte{byte(b)}, size)
+}
+
+// Tests that items written to a freezer table spanning several data files can
+// be retrieved after reopening the table, and that truncation drops them.
+func TestFreezerTableBasics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	meter := metrics.NewMeter()
+	f, err := newCustomTable(dir, "test", meter, meter, 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write 15 bytes 255 times, results in 85 files
+	for x := 0; x < 255; x++ {
+		if err := f.Append(uint64(x), getChunk(15, x)); err != nil {
+			t.Fatalf("failed to append item %d: %v", x, err)
+		}
+	}
+	if err := f.Append(0, getChunk(15, 0)); err == nil {
+		t.Fatalf("out of order append succeeded")
+	}
+	f.Close()
+
+	// Reopen and read the items back
+	if f, err = newCustomTable(dir, "test", meter, meter, 50, true); err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 255; y++ {
+		got, err := f.Retrieve(uint64(y))
+		if err != nil {
+			t.Fatalf("failed to retrieve item %d: %v", y, err)
+		}
+		if !bytes.Equal(got, getChunk(15, y)) {
+			t.Fatalf("item %d mismatch: have %x, want %x", y, got, getChunk(15, y))
+		}
+	}
+	if _, err := f.Retrieve(255); err != errOutOfBounds {
+		t.Fatalf("out of bounds retrieval error mismatch: have %v, want %v", err, errOutOfBounds)
+	}
+	// Truncate back into an earlier file and append again
+	if err := f.truncate(10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Retrieve(10); err != errOutOfBounds {
+		t.Fatalf("truncated item retrievable: %v", err)
+	}
+	if err := f.Append(10, getChunk(15, 0xaa)); err != nil {
+		t.Fatalf("failed to append after truncation: %v", err)
+	}
+	if got, _ := f.Retrieve(10); !bytes.Equal(got, getChunk(15, 0xaa)) {
+		t.Fatalf("item mismatch after truncation: have %x", got)
+	}
+	f.Close()
+}
+
+// Tests that a freezer table whose data file lost its tail is repaired to the
+// items still fully stored, even if compressed.
+func TestFreezerTableRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	meter := metrics.NewMeter()
+	f, err := newTable(dir, "test", meter, meter, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 10; x++ {
+		if err := f.Append(uint64(x), getChunk(20, x)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	// Cut the data file in the middle of the last item
+	path := filepath.Join(dir, "test.0000.cdat")
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, stat.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = newTable(dir, "test", meter, meter, false); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if f.items != 9 {
+		t.Fatalf("repaired item count mismatch: have %d, want %d", f.items, 9)
+	}
+	if got, err := f.Retrieve(8); err != nil || !bytes.Equal(got, getChunk(20, 8)) {
+		t.Fatalf("item mismatch after repair: have %x, err %v", got, err)
+	}
+	if err := f.Append(9, getChunk(20, 9)); err != nil {
+		t.Fatalf("failed to append after repair: %v", err)
+	}
+}
+
+// Tests that the blocks below the threshold are moved from the key-value store
+// into the ancient store and stay readable through the accessors.
+func TestFreezerDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kvdb, err := berithdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write a canonical chain of 10 blocks and a side block at height 3
+	var blocks []*types.Block
+	parent := common.Hash{}
+	for i := 0; i < 10; i++ {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(int64(i)), ParentHash: parent, Extra: []byte("canonical")})
+		WriteBlock(kvdb, block)
+		WriteReceipts(kvdb, block.Hash(), block.NumberU64(), nil)
+		WriteTd(kvdb, block.Hash(), block.NumberU64(), big.NewInt(int64(i+1)))
+		WriteCanonicalHash(kvdb, block.Hash(), block.NumberU64())
+
+		blocks = append(blocks, block)
+		parent = block.Hash()
+	}
+	side := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(3), ParentHash: blocks[2].Hash(), Extra: []byte("side")})
+	WriteBlock(kvdb, side)
+	WriteHeadHeaderHash(kvdb, parent)
+	WriteHeadBlockHash(kvdb, parent)
+
+	db, err := NewDatabaseWithFreezer(kvdb, filepath.Join(dir, "ancient"), "", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ancients := db.(AncientStore)
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		// The side chains are wiped last, after the canonical blocks
+		if frozen, _ := ancients.Ancients(); frozen == 5 {
+			if has, _ := kvdb.Has(headerKey(3, side.Hash())); !has {
+				break
+			}
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("blocks not frozen in time")
+		}
+	}
+	for i, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+
+		if have := ReadCanonicalHash(db, number); have != hash {
+			t.Errorf("block %d: canonical hash mismatch: have %x, want %x", i, have, hash)
+		}
+		if have := ReadBlock(db, hash, number); have == nil || have.Hash() != hash {
+			t.Errorf("block %d: block mismatch: have %v", i, have)
+		}
+		if !HasReceipts(db, hash, number) {
+			t.Errorf("block %d: receipts missing", i)
+		}
+		if have := ReadTd(db, hash, number); have == nil || have.Int64() != int64(i+1) {
+			t.Errorf("block %d: total difficulty mismatch: have %v, want %d", i, have, i+1)
+		}
+		// The frozen blocks except the genesis are gone from the key-value store
+		if has, _ := kvdb.Has(headerKey(number, hash)); has != (i == 0 || i >= 5) {
+			t.Errorf("block %d: key-value store header presence mismatch: have %v", i, has)
+		}
+	}
+	if HasHeader(db, side.Hash(), 3) {
+		t.Errorf("frozen side block not deleted")
+	}
+	// Rewinding below the frozen blocks drops them from the ancient store
+	if err := ancients.TruncateAncients(3); err != nil {
+		t.Fatal(err)
+	}
+	if hash := ReadCanonicalHash(db, 4); hash != (common.Hash{}) {
+		t.Errorf("truncated canonical hash returned: %x", hash)
+	}
+	if block := ReadBlock(db, blocks[2].Hash(), 2); block == nil {
+		t.Errorf("ancient block below the truncation missing")
+	}
+}
+
+// Tests that a chain bootstrapped from a state snapshot, which lacks the blocks
+// below the snapshot, can't be combined with an ancient store.
+func TestFreezerChainBase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kvdb, err := berithdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer kvdb.Close()
+
+	genesis := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0)})
+	WriteBlock(kvdb, genesis)
+	WriteCanonicalHash(kvdb, genesis.Hash(), 0)
+	head := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(100)})
+	WriteBlock(kvdb, head)
+	WriteCanonicalHash(kvdb, head.Hash(), 100)
+	WriteHeadHeaderHash(kvdb, head.Hash())
+	WriteHeadBlockHash(kvdb, head.Hash())
+	WriteChainBase(kvdb, 100)
+
+	if _, err := NewDatabaseWithFreezer(kvdb, filepath.Join(dir, "ancient"), "", 4); err == nil || !strings.Contains(err.Error(), "snapshot block #100") {
+		t.Fatalf("ancient store over a snapshot chain: have error %v", err)
+	}
+}
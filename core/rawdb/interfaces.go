@@ -31,3 +31,41 @@ type DatabaseWriter interface {
 type DatabaseDeleter interface {
 	Delete(key []byte) error
 }
+
+// AncientReader wraps the methods reading the frozen chain segment of an ancient
+// store.
+type AncientReader interface {
+	// HasAncient returns an indicator whether the specified data exists in the
+	// ancient store.
+	HasAncient(kind string, number uint64) (bool, error)
+
+	// Ancient retrieves an ancient binary blob from the append-only immutable files.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of blocks in the ancient store.
+	Ancients() (uint64, error)
+
+	// AncientSize returns the size of the specified table of the ancient store.
+	AncientSize(kind string) (uint64, error)
+}
+
+// AncientWriter wraps the methods writing the frozen chain segment of an ancient
+// store.
+type AncientWriter interface {
+	// AppendAncient injects all binary blobs belong to block at the end of the
+	// append-only immutable table files.
+	AppendAncient(number uint64, hash, header, body, receipts, td []byte) error
+
+	// TruncateAncients discards all but the first n ancient blocks.
+	TruncateAncients(n uint64) error
+
+	// Sync flushes all in-memory ancient store data to disk.
+	Sync() error
+}
+
+// AncientStore contains all the methods required to read from and write to the
+// ancient store.
+type AncientStore interface {
+	AncientReader
+	AncientWriter
+}
@@ -41,6 +41,23 @@ var (
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
+	// chainBaseKey tracks the first block of a chain bootstrapped from a state
+	// snapshot, which lacks the blocks below it.
+	chainBaseKey = []byte("ChainBase")
+
+	// txPoolJournalKey tracks the sequence range of the persisted transaction pool.
+	txPoolJournalKey = []byte("TxPoolJournal")
+
+	// snapshotRootKey tracks the state root of the flat state snapshot on disk.
+	snapshotRootKey = []byte("SnapshotRoot")
+
+	// snapshotGeneratorKey tracks the generation progress of the flat state snapshot.
+	snapshotGeneratorKey = []byte("SnapshotGenerator")
+
+	// snapshotJournalKey tracks the in-memory diff layers of the flat state snapshot
+	// persisted across restarts.
+	snapshotJournalKey = []byte("SnapshotJournal")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -52,12 +69,23 @@ var (
 
 	txLookupPrefix  = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	addressTxPrefix = []byte("A") // addressTxPrefix + address + section (uint64 big endian) -> address transaction entries
+
+	txPoolEntryPrefix = []byte("txpool-") // txPoolEntryPrefix + seq (uint64 big endian) -> persisted pool transaction
+
+	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
+	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
+
+	stateDiffPrefix = []byte("d") // stateDiffPrefix + num (uint64 big endian) + hash -> state diff, kept in the state diff database
+
+	stakersPrefix = []byte("k") // stakersPrefix + hash -> staking list of the block
 
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("berith-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	AddressIndexPrefix   = []byte("iA") // AddressIndexPrefix is the data table of the address index chain indexer
 
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
@@ -71,6 +99,14 @@ type TxLookupEntry struct {
 	Index      uint64
 }
 
+// AddressTxEntry is a positional metadata of a transaction sent from or to an
+// address, stored in the address index.
+type AddressTxEntry struct {
+	BlockNumber uint64
+	Index       uint64
+	Hash        common.Hash
+}
+
 // encodeBlockNumber encodes a block number as big endian uint64
 func encodeBlockNumber(number uint64) []byte {
 	enc := make([]byte, 8)
@@ -78,6 +114,11 @@ func encodeBlockNumber(number uint64) []byte {
 	return enc
 }
 
+// headerKeyPrefix = headerPrefix + num (uint64 big endian)
+func headerKeyPrefix(number uint64) []byte {
+	return append(append([]byte{}, headerPrefix...), encodeBlockNumber(number)...)
+}
+
 // headerKey = headerPrefix + num (uint64 big endian) + hash
 func headerKey(number uint64, hash common.Hash) []byte {
 	return append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
@@ -123,6 +164,41 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// addressTxKey = addressTxPrefix + address + section (uint64 big endian)
+func addressTxKey(address common.Address, section uint64) []byte {
+	return append(append(append([]byte{}, addressTxPrefix...), address.Bytes()...), encodeBlockNumber(section)...)
+}
+
+// txPoolEntryKey = txPoolEntryPrefix + seq (uint64 big endian)
+func txPoolEntryKey(seq uint64) []byte {
+	return append(append([]byte{}, txPoolEntryPrefix...), encodeBlockNumber(seq)...)
+}
+
+// accountSnapshotKey = SnapshotAccountPrefix + hash
+func accountSnapshotKey(hash common.Hash) []byte {
+	return append(append([]byte{}, SnapshotAccountPrefix...), hash.Bytes()...)
+}
+
+// storageSnapshotKey = SnapshotStoragePrefix + account hash + storage hash
+func storageSnapshotKey(accountHash, storageHash common.Hash) []byte {
+	return append(append(append([]byte{}, SnapshotStoragePrefix...), accountHash.Bytes()...), storageHash.Bytes()...)
+}
+
+// storageSnapshotsKey = SnapshotStoragePrefix + account hash
+func storageSnapshotsKey(accountHash common.Hash) []byte {
+	return append(append([]byte{}, SnapshotStoragePrefix...), accountHash.Bytes()...)
+}
+
+// stateDiffKey = stateDiffPrefix + num (uint64 big endian) + hash
+func stateDiffKey(number uint64, hash common.Hash) []byte {
+	return append(append(append([]byte{}, stateDiffPrefix...), encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// stakersKey = stakersPrefix + hash
+func stakersKey(hash common.Hash) []byte {
+	return append(append([]byte{}, stakersPrefix...), hash.Bytes()...)
+}
+
 // preimageKey = preimagePrefix + hash
 func preimageKey(hash common.Hash) []byte {
 	return append(preimagePrefix, hash.Bytes()...)
@@ -0,0 +1,99 @@
+/*
+[BERITH]
+RPC 로 반환하는 account 의 JSON 표현. berith_getProof, berith_getAccount 와
+state diff 가 같은 형식을 사용한다.
+*/
+
+package state
+
+import (
+	"math/big"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/common/hexutil"
+	"github.com/BerithFoundation/berith-chain/core/types"
+)
+
+// AccountJSON is the RPC representation of an account with all its Berith
+// fields. Together with an account proof it allows to verify the account
+// against a state root, by re-encoding the account leaf.
+type AccountJSON struct {
+	Balance        *hexutil.Big          `json:"balance"`
+	CodeHash       common.Hash           `json:"codeHash"`
+	Nonce          hexutil.Uint64        `json:"nonce"`
+	StorageHash    common.Hash           `json:"storageHash"`
+	StakeBalance   *hexutil.Big          `json:"stakeBalance"`
+	StakeUpdated   *hexutil.Big          `json:"stakeUpdated"`
+	Point          *hexutil.Big          `json:"point"`
+	BehindBalance  []BehindJSON          `json:"behindBalance"`
+	Penalty        hexutil.Uint64        `json:"penalty"`
+	PenaltyUpdated *hexutil.Big          `json:"penaltyUpdated"`
+	Multisig       *types.MultisigConfig `json:"multisig,omitempty"`
+	Vesting        []VestingJSON         `json:"vesting,omitempty"`
+}
+
+// BehindJSON is a reward of the behind balance, paid at the given block.
+type BehindJSON struct {
+	Number  *hexutil.Big `json:"number"`
+	Balance *hexutil.Big `json:"balance"`
+}
+
+// VestingJSON is a balance locked by a vesting schedule.
+type VestingJSON struct {
+	Start    hexutil.Uint64 `json:"start"`
+	Cliff    hexutil.Uint64 `json:"cliff"`
+	End      hexutil.Uint64 `json:"end"`
+	Stakable bool           `json:"stakable"`
+	Amount   *hexutil.Big   `json:"amount"`
+	Released *hexutil.Big   `json:"released"`
+	Staked   *hexutil.Big   `json:"staked"`
+}
+
+// hexBig converts an account value to its RPC representation, zero if unset.
+func hexBig(x *big.Int) *hexutil.Big {
+	if x == nil {
+		return new(hexutil.Big)
+	}
+	return (*hexutil.Big)(new(big.Int).Set(x))
+}
+
+// NewAccountJSON converts an account to its RPC representation, nil if the
+// account doesn't exist.
+func NewAccountJSON(account *Account) *AccountJSON {
+	if account == nil {
+		return nil
+	}
+	enc := &AccountJSON{
+		Balance:        hexBig(account.Balance),
+		CodeHash:       common.BytesToHash(account.CodeHash),
+		Nonce:          hexutil.Uint64(account.Nonce),
+		StorageHash:    account.Root,
+		StakeBalance:   hexBig(account.StakeBalance),
+		StakeUpdated:   hexBig(account.StakeUpdated),
+		Point:          hexBig(account.Point),
+		BehindBalance:  []BehindJSON{},
+		Penalty:        hexutil.Uint64(account.Penalty),
+		PenaltyUpdated: hexBig(account.PenlatyUpdated),
+	}
+	for _, behind := range account.BehindBalance {
+		enc.BehindBalance = append(enc.BehindBalance, BehindJSON{Number: hexBig(behind.Number), Balance: hexBig(behind.Balance)})
+	}
+	if len(account.Ext) > 0 {
+		if len(account.Ext[0].Multisig) > 0 {
+			config := account.Ext[0].Multisig[0]
+			enc.Multisig = &types.MultisigConfig{Threshold: config.Threshold, Owners: append([]common.Address{}, config.Owners...)}
+		}
+		for _, vesting := range account.Ext[0].Vesting {
+			enc.Vesting = append(enc.Vesting, VestingJSON{
+				Start:    hexutil.Uint64(vesting.Start),
+				Cliff:    hexutil.Uint64(vesting.Cliff),
+				End:      hexutil.Uint64(vesting.End),
+				Stakable: vesting.Stakable,
+				Amount:   hexBig(vesting.Amount),
+				Released: hexBig(vesting.Released),
+				Staked:   hexBig(vesting.Staked),
+			})
+		}
+	}
+	return enc
+}
@@ -38,6 +38,7 @@ type journalEntry interface {
 type journal struct {
 	entries []journalEntry         // Current changes tracked by the journal
 	dirties map[common.Address]int // Dirty accounts and the number of changes
+	diff    *diffTracker           // Records the touched accounts and slots, if enabled
 }
 
 // newJournal create a new initialized journal.
@@ -52,6 +53,9 @@ func (j *journal) append(entry journalEntry) {
 	j.entries = append(j.entries, entry)
 	if addr := entry.dirtied(); addr != nil {
 		j.dirties[*addr]++
+		if j.diff != nil {
+			j.diff.touch(*addr, entry)
+		}
 	}
 }
 
@@ -77,6 +81,9 @@ func (j *journal) revert(statedb *StateDB, snapshot int) {
 // precompile consensus exception.
 func (j *journal) dirty(addr common.Address) {
 	j.dirties[addr]++
+	if j.diff != nil {
+		j.diff.touch(addr, nil)
+	}
 }
 
 // length returns the current number of entries in the journal.
@@ -90,7 +97,8 @@ type (
 		account *common.Address
 	}
 	resetObjectChange struct {
-		prev *stateObject
+		prev         *stateObject
+		prevdestruct bool // whether the account was already destructed in the snapshot changes
 	}
 	suicideChange struct {
 		account     *common.Address
@@ -154,6 +162,11 @@ type (
 		prevPenalty uint64
 		prevBlock   *big.Int
 	}
+
+	extChange struct {
+		account *common.Address
+		prev    []AccountExt
+	}
 )
 
 func (ch createObjectChange) revert(s *StateDB) {
@@ -167,6 +180,9 @@ func (ch createObjectChange) dirtied() *common.Address {
 
 func (ch resetObjectChange) revert(s *StateDB) {
 	s.setStateObject(ch.prev)
+	if !ch.prevdestruct && s.snap != nil {
+		delete(s.snapDestructs, ch.prev.addrHash)
+	}
 }
 
 func (ch resetObjectChange) dirtied() *common.Address {
@@ -288,3 +304,11 @@ func (ch penaltyChange) revert(s *StateDB) {
 func (ch penaltyChange) dirtied() *common.Address {
 	return ch.account
 }
+
+func (ch extChange) revert(s *StateDB) {
+	s.getStateObject(*ch.account).setExt(ch.prev)
+}
+
+func (ch extChange) dirtied() *common.Address {
+	return ch.account
+}
@@ -0,0 +1,156 @@
+package pruner
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/BerithFoundation/berith-chain/common"
+)
+
+// stateBloomHashes is the number of bit positions set per marked hash.
+const stateBloomHashes = 4
+
+// stateBloomMagic identifies the files holding a committed state bloom.
+var stateBloomMagic = []byte("berith-statebloom-1")
+
+var errInvalidStateBloom = errors.New("invalid state bloom file")
+
+// stateBloom is a bloom filter of the trie nodes and contract codes reachable
+// from the retained states. The keys are keccak hashes, so the bit positions are
+// taken from the key itself instead of hashing it again.
+//
+// There are no false negatives: a node reported as not contained is garbage and
+// can be deleted. False positives only leave a little garbage behind.
+type stateBloom struct {
+	bits []uint64
+	root common.Hash // State root of the head block the bloom was built for
+}
+
+// newStateBloom creates a state bloom of the given size in megabytes.
+func newStateBloom(size uint64) *stateBloom {
+	if size == 0 {
+		size = 1
+	}
+	return &stateBloom{bits: make([]uint64, size*1024*1024/8)}
+}
+
+// positions returns the bit positions of a hash.
+func (b *stateBloom) positions(hash []byte) [stateBloomHashes]uint64 {
+	var (
+		pos  [stateBloomHashes]uint64
+		bits = uint64(len(b.bits)) * 64
+	)
+	for i := range pos {
+		pos[i] = binary.BigEndian.Uint64(hash[i*8:]) % bits
+	}
+	return pos
+}
+
+// add marks a trie node or contract code hash as reachable.
+func (b *stateBloom) add(hash []byte) {
+	for _, pos := range b.positions(hash) {
+		b.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+// contain returns whether a trie node or contract code hash is possibly
+// reachable, false meaning that it is garbage for sure.
+func (b *stateBloom) contain(hash []byte) bool {
+	for _, pos := range b.positions(hash) {
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// commit persists the bloom into the given file. The bloom is written into a
+// temporary file first and renamed afterwards, so an existing file always holds
+// a complete bloom.
+func (b *stateBloom) commit(path string) error {
+	tmp := path + ".tmp"
+	if err := b.write(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// write writes the bloom into the given file.
+func (b *stateBloom) write(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	w := bufio.NewWriter(gz)
+
+	header := make([]byte, 0, len(stateBloomMagic)+common.HashLength+8)
+	header = append(header, stateBloomMagic...)
+	header = append(header, b.root.Bytes()...)
+	header = append(header, make([]byte, 8)...)
+	binary.BigEndian.PutUint64(header[len(header)-8:], uint64(len(b.bits)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	word := make([]byte, 8)
+	for _, bits := range b.bits {
+		binary.BigEndian.PutUint64(word, bits)
+		if _, err := w.Write(word); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// loadStateBloom reads a bloom persisted by commit.
+func loadStateBloom(path string) (*stateBloom, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(gz)
+
+	header := make([]byte, len(stateBloomMagic)+common.HashLength+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:len(stateBloomMagic)]) != string(stateBloomMagic) {
+		return nil, errInvalidStateBloom
+	}
+	var (
+		root  = common.BytesToHash(header[len(stateBloomMagic) : len(stateBloomMagic)+common.HashLength])
+		words = binary.BigEndian.Uint64(header[len(header)-8:])
+	)
+	if words == 0 {
+		return nil, errInvalidStateBloom
+	}
+	bloom := &stateBloom{bits: make([]uint64, words), root: root}
+
+	word := make([]byte, 8)
+	for i := range bloom.bits {
+		if _, err := io.ReadFull(r, word); err != nil {
+			return nil, err
+		}
+		bloom.bits[i] = binary.BigEndian.Uint64(word)
+	}
+	return bloom, nil
+}
@@ -0,0 +1,268 @@
+/*
+[BERITH]
+정지된 노드의 chain DB 에서 최근 state 와 genesis state 로부터 도달할 수 없는 trie 노드를 지우는 offline pruner
+*/
+
+// Package pruner implements the offline pruning of the state tries of a full
+// node, keeping only the states of the recent blocks and of the genesis.
+package pruner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/state"
+	"github.com/BerithFoundation/berith-chain/crypto"
+	"github.com/BerithFoundation/berith-chain/log"
+	"github.com/BerithFoundation/berith-chain/rlp"
+	"github.com/BerithFoundation/berith-chain/trie"
+)
+
+// BloomFileName is the name of the file holding the bloom of an ongoing pruning,
+// resolved in the instance directory of the node.
+const BloomFileName = "statebloom.bf.gz"
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+
+	errNoHeadBlock = errors.New("head block not found")
+)
+
+// Pruner deletes the trie nodes and contract codes which are not reachable from
+// the state of the genesis or of the last retained blocks. It must only be run
+// while the node is stopped.
+//
+// Pruning happens in two phases: the reachable nodes are first marked in a bloom
+// filter of bounded size which is persisted once complete, then every node not
+// contained in the bloom is deleted. If the pruning is interrupted during the
+// second phase, it is resumed from the persisted bloom, since the deletion has
+// already made the marking impossible to redo.
+type Pruner struct {
+	db        berithdb.Database
+	bloomPath string
+	bloomSize uint64 // Bloom filter size in megabytes
+	retain    uint64 // Number of recent block states to keep
+}
+
+// NewPruner creates a pruner keeping the states of the genesis and of the last
+// retain blocks of the database.
+func NewPruner(db berithdb.Database, bloomPath string, bloomSize, retain uint64) *Pruner {
+	return &Pruner{
+		db:        db,
+		bloomPath: bloomPath,
+		bloomSize: bloomSize,
+		retain:    retain,
+	}
+}
+
+// Prune marks the retained states and deletes every other trie node from the
+// database. An interrupted pruning is resumed instead of started over.
+func (p *Pruner) Prune() error {
+	if common.FileExist(p.bloomPath) {
+		log.Info("Resuming interrupted state pruning", "bloom", p.bloomPath)
+		return RecoverPruning(p.bloomPath, p.db)
+	}
+	head := rawdb.ReadHeadBlockHash(p.db)
+	number := rawdb.ReadHeaderNumber(p.db, head)
+	if number == nil {
+		return errNoHeadBlock
+	}
+	headHeader := rawdb.ReadHeader(p.db, head, *number)
+	if headHeader == nil {
+		return errNoHeadBlock
+	}
+	// The state of the head block must be complete, the older retained states
+	// are kept as far as they still exist.
+	tdb := trie.NewDatabase(p.db)
+	if _, err := trie.New(headHeader.Root, tdb); err != nil {
+		return fmt.Errorf("head state missing: %v", err)
+	}
+	var (
+		bloom = newStateBloom(p.bloomSize)
+		start = time.Now()
+	)
+	bloom.root = headHeader.Root
+
+	if genesis := rawdb.ReadHeader(p.db, rawdb.ReadCanonicalHash(p.db, 0), 0); genesis != nil {
+		if err := markState(tdb, bloom, common.Hash{}, genesis.Root); err != nil {
+			return err
+		}
+	}
+	first := uint64(0)
+	if *number > p.retain {
+		first = *number - p.retain
+	}
+	prev := common.Hash{}
+	for n := first; n <= *number; n++ {
+		header := rawdb.ReadHeader(p.db, rawdb.ReadCanonicalHash(p.db, n), n)
+		if header == nil || header.Root == prev {
+			continue
+		}
+		if _, err := trie.New(header.Root, tdb); err != nil {
+			log.Debug("Skipping missing state", "number", n, "root", header.Root)
+			continue
+		}
+		if err := markState(tdb, bloom, prev, header.Root); err != nil {
+			return err
+		}
+		prev = header.Root
+	}
+	log.Info("Marked retained states", "from", first, "to", *number, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Persist the bloom before deleting anything, the deletion can only be
+	// resumed from it once started.
+	if err := bloom.commit(p.bloomPath); err != nil {
+		return err
+	}
+	return sweep(p.db, bloom, p.bloomPath)
+}
+
+// RecoverPruning finishes a pruning interrupted while deleting the unmarked
+// trie nodes. It does nothing if there is no persisted bloom, i.e. no pruning
+// was interrupted.
+func RecoverPruning(bloomPath string, db berithdb.Database) error {
+	if !common.FileExist(bloomPath) {
+		return nil
+	}
+	bloom, err := loadStateBloom(bloomPath)
+	if err != nil {
+		// The bloom is only renamed into place once fully written, a broken one
+		// is not a pruning we can recover from.
+		return fmt.Errorf("failed to load state bloom %s: %v", bloomPath, err)
+	}
+	log.Info("Recovering interrupted state pruning", "root", bloom.root)
+	return sweep(db, bloom, bloomPath)
+}
+
+// markState adds the trie nodes, the storage trie nodes and the contract codes
+// of the state with the given root to the bloom. Only the parts of the state
+// which differ from the previously marked state prev are walked, the others
+// being marked already.
+func markState(tdb *trie.Database, bloom *stateBloom, prev, root common.Hash) error {
+	cur, err := trie.New(root, tdb)
+	if err != nil {
+		return err
+	}
+	prevTrie, err := trie.New(prev, tdb)
+	if err != nil {
+		return err
+	}
+	it, _ := trie.NewDifferenceIterator(prevTrie.NodeIterator(nil), cur.NodeIterator(nil))
+	for it.Next(true) {
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			bloom.add(hash.Bytes())
+		}
+		if !it.Leaf() {
+			continue
+		}
+		var acc state.Account
+		if err := rlp.DecodeBytes(it.LeafBlob(), &acc); err != nil {
+			return err
+		}
+		if hash := common.BytesToHash(acc.CodeHash); hash != emptyCode {
+			bloom.add(hash.Bytes())
+		}
+		if acc.Root == emptyRoot {
+			continue
+		}
+		// Only walk the storage changed since the previous state of the account
+		prevStorage := common.Hash{}
+		if blob, err := prevTrie.TryGet(it.LeafKey()); err == nil && len(blob) > 0 {
+			var prevAcc state.Account
+			if err := rlp.DecodeBytes(blob, &prevAcc); err == nil {
+				prevStorage = prevAcc.Root
+			}
+		}
+		if prevStorage == acc.Root {
+			continue
+		}
+		if err := markStorage(tdb, bloom, prevStorage, acc.Root); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// markStorage adds the nodes of a storage trie differing from the previous
+// storage trie of the account to the bloom.
+func markStorage(tdb *trie.Database, bloom *stateBloom, prev, root common.Hash) error {
+	cur, err := trie.New(root, tdb)
+	if err != nil {
+		return err
+	}
+	prevTrie, err := trie.New(prev, tdb)
+	if err != nil {
+		// The previous storage may have been pruned, walk the whole trie
+		prevTrie, _ = trie.New(common.Hash{}, tdb)
+	}
+	it, _ := trie.NewDifferenceIterator(prevTrie.NodeIterator(nil), cur.NodeIterator(nil))
+	for it.Next(true) {
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			bloom.add(hash.Bytes())
+		}
+	}
+	return it.Error()
+}
+
+// sweep deletes every trie node and contract code not contained in the bloom,
+// then compacts the database and removes the bloom file.
+func sweep(db berithdb.Database, bloom *stateBloom, bloomPath string) error {
+	var (
+		count  int
+		size   common.StorageSize
+		start  = time.Now()
+		logged = time.Now()
+		batch  = db.NewBatch()
+	)
+	it := db.NewIterator()
+	for it.Next() {
+		key := it.Key()
+		if len(key) != common.HashLength || bloom.contain(key) {
+			continue
+		}
+		count++
+		size += common.StorageSize(len(key) + len(it.Value()))
+		batch.Delete(common.CopyBytes(key))
+
+		if batch.ValueSize() >= berithdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				it.Release()
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning state data", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	err := it.Error()
+	it.Release()
+	if err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Pruned state data", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Compact the database to actually release the space of the deleted nodes
+	if store, ok := rawdb.KeyValueStore(db).(berithdb.Compacter); ok {
+		cstart := time.Now()
+		log.Info("Compacting database")
+		if err := store.Compact(nil, nil); err != nil {
+			return err
+		}
+		log.Info("Compacted database", "elapsed", common.PrettyDuration(time.Since(cstart)))
+	}
+	return os.Remove(bloomPath)
+}
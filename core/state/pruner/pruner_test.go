@@ -0,0 +1,147 @@
+package pruner
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/state"
+	"github.com/BerithFoundation/berith-chain/core/types"
+)
+
+// makePrunableChain writes a chain of the given length into db, each block
+// changing a balance, a storage slot and a contract code. It returns the state
+// roots of the blocks.
+func makePrunableChain(t *testing.T, db berithdb.Database, length int) []common.Hash {
+	var (
+		sdb    = state.NewDatabase(db)
+		roots  []common.Hash
+		root   common.Hash
+		parent common.Hash
+	)
+	for i := 0; i < length; i++ {
+		statedb, err := state.New(root, sdb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := common.BytesToAddress([]byte{byte(i % 3)})
+		statedb.AddBalance(addr, big.NewInt(int64(i+1)))
+		statedb.SetState(addr, common.BytesToHash([]byte{byte(i)}), common.BytesToHash([]byte{byte(i + 1)}))
+		statedb.SetCode(addr, []byte{byte(i), 0x01})
+
+		if root, err = statedb.Commit(false); err != nil {
+			t.Fatal(err)
+		}
+		if err := sdb.TrieDB().Commit(root, false); err != nil {
+			t.Fatal(err)
+		}
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(int64(i)), ParentHash: parent, Root: root})
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+
+		roots = append(roots, root)
+		parent = block.Hash()
+	}
+	return roots
+}
+
+// checkState returns an error if the state with the given root isn't complete.
+func checkState(db berithdb.Database, root common.Hash) error {
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	return it.Error
+}
+
+// Tests that pruning keeps the genesis and the retained states complete and
+// deletes the older states, both directly and when resumed from a bloom.
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pruner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, resume := range []bool{false, true} {
+		var (
+			db        = berithdb.NewMemDatabase()
+			roots     = makePrunableChain(t, db, 10)
+			bloomPath = filepath.Join(dir, "statebloom.bf.gz")
+			garbage   = common.HexToHash("0xdeadbeef")
+		)
+		db.Put(garbage.Bytes(), []byte("garbage"))
+
+		pruner := NewPruner(db, bloomPath, 1, 3)
+		if resume {
+			// Simulate an interruption after the bloom was persisted by
+			// committing the marked bloom without sweeping
+			bloom := newStateBloom(1)
+			tdb := state.NewDatabase(db).TrieDB()
+			for _, root := range append([]common.Hash{roots[0]}, roots[6:]...) {
+				if err := markState(tdb, bloom, common.Hash{}, root); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := bloom.commit(bloomPath); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := pruner.Prune(); err != nil {
+			t.Fatalf("resume %v: prune failed: %v", resume, err)
+		}
+		if common.FileExist(bloomPath) {
+			t.Errorf("resume %v: bloom not removed", resume)
+		}
+		for i, root := range roots {
+			err := checkState(db, root)
+			if retained := i == 0 || i >= 6; retained && err != nil {
+				t.Errorf("resume %v: state %d: retained state incomplete: %v", resume, i, err)
+			} else if !retained && err == nil {
+				t.Errorf("resume %v: state %d: pruned state still complete", resume, i)
+			}
+		}
+		if has, _ := db.Has(garbage.Bytes()); has {
+			t.Errorf("resume %v: garbage node not deleted", resume)
+		}
+	}
+}
+
+// Tests that a committed state bloom is loaded back identically.
+func TestStateBloomCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pruner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bloom := newStateBloom(1)
+	bloom.root = common.HexToHash("0x01")
+	bloom.add(common.HexToHash("0x0102030405060708091011121314151617181920212223242526272829303132").Bytes())
+
+	path := filepath.Join(dir, "statebloom.bf.gz")
+	if err := bloom.commit(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadStateBloom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.root != bloom.root || len(loaded.bits) != len(bloom.bits) {
+		t.Fatalf("bloom header mismatch: have %x/%d, want %x/%d", loaded.root, len(loaded.bits), bloom.root, len(bloom.bits))
+	}
+	for i := range bloom.bits {
+		if loaded.bits[i] != bloom.bits[i] {
+			t.Fatalf("bloom word %d mismatch: have %x, want %x", i, loaded.bits[i], bloom.bits[i])
+		}
+	}
+}
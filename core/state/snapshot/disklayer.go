@@ -0,0 +1,174 @@
+package snapshot
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/log"
+	"github.com/BerithFoundation/berith-chain/trie"
+)
+
+// diskLayer is a low level persistent snapshot built on top of a key-value store.
+// While being generated, it only covers the accounts up to the generation marker.
+type diskLayer struct {
+	diskdb berithdb.Database // Key-value store containing the base snapshot
+	triedb *trie.Database    // Trie node cache for reconstructing the snapshot
+	root   common.Hash       // Root hash of the base snapshot
+	stale  bool              // Signals that the layer is no longer valid
+
+	genMarker []byte             // Last account fully generated, nil once the generation is done
+	genWiping bool               // Whether the previous snapshot data is still being wiped
+	genAbort  chan chan struct{} // Notification channel to abort the running generator
+
+	lock sync.RWMutex
+}
+
+// Root returns the root hash for which this snapshot was made.
+func (dl *diskLayer) Root() common.Hash {
+	return dl.root
+}
+
+// parentLayer always returns nil as there's no layer below the disk.
+func (dl *diskLayer) parentLayer() snapshot {
+	return nil
+}
+
+// markStale invalidates the layer.
+func (dl *diskLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+// covered returns whether the account with the given hash is generated already.
+// The caller must hold the lock.
+func (dl *diskLayer) covered(hash common.Hash) bool {
+	return dl.genMarker == nil || bytes.Compare(hash[:], dl.genMarker) <= 0
+}
+
+// Account retrieves the account trie value of an account from the key-value
+// store.
+func (dl *diskLayer) Account(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if !dl.covered(hash) {
+		return nil, ErrNotCoveredYet
+	}
+	return rawdb.ReadAccountSnapshot(dl.diskdb, hash), nil
+}
+
+// Storage retrieves the storage trie value of a storage slot from the key-value
+// store.
+func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if !dl.covered(accountHash) {
+		return nil, ErrNotCoveredYet
+	}
+	return rawdb.ReadStorageSnapshot(dl.diskdb, accountHash, storageHash), nil
+}
+
+// stopGeneration aborts the generator of the layer if it is running, waiting for
+// it to persist its progress. The caller must hold the lock of the tree.
+func (dl *diskLayer) stopGeneration() {
+	if dl.genAbort == nil {
+		return
+	}
+	abort := make(chan struct{})
+	dl.genAbort <- abort
+	<-abort
+	dl.genAbort = nil
+}
+
+// diffToDisk merges a bottom-most diff layer into the persistent disk layer
+// below it, returning the new disk layer. The merged layers are marked stale.
+// Only the accounts covered by the generator are written, the generator going
+// on with the others from the state of the new layer.
+func diffToDisk(bottom *diffLayer) *diskLayer {
+	base := bottom.parentLayer().(*diskLayer)
+	base.stopGeneration()
+
+	// Invalidate the layer before changing the data under its readers
+	base.markStale()
+	bottom.markStale()
+
+	// The root is deleted until the merge is complete, so that an interrupted
+	// merge leaves a snapshot which is regenerated at the next start
+	batch := base.diskdb.NewBatch()
+	rawdb.DeleteSnapshotRoot(batch)
+
+	flush := func() {
+		if batch.ValueSize() >= berithdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to write snapshot", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	for hash := range bottom.destructs {
+		if !base.covered(hash) {
+			continue
+		}
+		rawdb.DeleteAccountSnapshot(batch, hash)
+
+		it := base.diskdb.NewIteratorWithPrefix(rawdb.StorageSnapshotsPrefix(hash))
+		for it.Next() {
+			batch.Delete(common.CopyBytes(it.Key()))
+			flush()
+		}
+		it.Release()
+	}
+	for hash, data := range bottom.accounts {
+		if !base.covered(hash) {
+			continue
+		}
+		if len(data) == 0 {
+			rawdb.DeleteAccountSnapshot(batch, hash)
+		} else {
+			rawdb.WriteAccountSnapshot(batch, hash, data)
+		}
+		flush()
+	}
+	for accountHash, storage := range bottom.storage {
+		if !base.covered(accountHash) {
+			continue
+		}
+		for storageHash, data := range storage {
+			if len(data) == 0 {
+				rawdb.DeleteStorageSnapshot(batch, accountHash, storageHash)
+			} else {
+				rawdb.WriteStorageSnapshot(batch, accountHash, storageHash, data)
+			}
+			flush()
+		}
+	}
+	rawdb.WriteSnapshotRoot(batch, bottom.root)
+	writeGeneratorProgress(batch, base.genWiping, base.genMarker)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write snapshot", "err", err)
+	}
+	res := &diskLayer{
+		diskdb:    base.diskdb,
+		triedb:    base.triedb,
+		root:      bottom.root,
+		genMarker: base.genMarker,
+		genWiping: base.genWiping,
+	}
+	if res.genMarker != nil {
+		res.genAbort = make(chan chan struct{})
+		go res.generate()
+	}
+	return res
+}
@@ -0,0 +1,246 @@
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/log"
+	"github.com/BerithFoundation/berith-chain/rlp"
+	"github.com/BerithFoundation/berith-chain/trie"
+)
+
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+// generatorProgress is the persisted progress of the snapshot generation.
+type generatorProgress struct {
+	Wiping bool   // Whether the previous snapshot data is still being wiped
+	Done   bool   // Whether the generation is complete
+	Marker []byte // Last account fully generated
+}
+
+// accountRoot is the prefix of the account trie values up to the storage root,
+// the Berith specific fields being left encoded.
+type accountRoot struct {
+	Nonce   uint64
+	Balance *big.Int
+	Root    common.Hash
+	Rest    []rlp.RawValue `rlp:"tail"`
+}
+
+// writeGeneratorProgress stores the progress of the snapshot generation, a nil
+// marker meaning that it is complete.
+func writeGeneratorProgress(db rawdb.DatabaseWriter, wiping bool, marker []byte) {
+	data, err := rlp.EncodeToBytes(&generatorProgress{Wiping: wiping, Done: marker == nil, Marker: marker})
+	if err != nil {
+		panic(err) // Cannot happen, here to catch dev errors
+	}
+	rawdb.WriteSnapshotGenerator(db, data)
+}
+
+// readGeneratorProgress retrieves the progress of the snapshot generation.
+func readGeneratorProgress(db rawdb.DatabaseReader) (*generatorProgress, error) {
+	progress := new(generatorProgress)
+	if err := rlp.DecodeBytes(rawdb.ReadSnapshotGenerator(db), progress); err != nil {
+		return nil, err
+	}
+	if !progress.Done && progress.Marker == nil {
+		progress.Marker = []byte{}
+	}
+	if progress.Done {
+		progress.Marker = nil
+	}
+	return progress, nil
+}
+
+// generateSnapshot regenerates a brand new snapshot based on an existing state
+// database and head block asynchronously. The snapshot is returned immediately,
+// covering nothing until the previous snapshot data is wiped and the accounts
+// are generated.
+func generateSnapshot(diskdb berithdb.Database, triedb *trie.Database, root common.Hash) *diskLayer {
+	batch := diskdb.NewBatch()
+	rawdb.WriteSnapshotRoot(batch, root)
+	writeGeneratorProgress(batch, true, []byte{})
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write snapshot generator", "err", err)
+	}
+	base := &diskLayer{
+		diskdb:    diskdb,
+		triedb:    triedb,
+		root:      root,
+		genMarker: []byte{},
+		genWiping: true,
+		genAbort:  make(chan chan struct{}),
+	}
+	go base.generate()
+	return base
+}
+
+// generate is a background thread that iterates over the state trie of the disk
+// layer and writes the flat accounts and storage slots into the database. It
+// persists its progress at account boundaries, so that it can be aborted when
+// the disk layer is replaced and resumed on the new one.
+func (dl *diskLayer) generate() {
+	if dl.genWiping {
+		if aborted := dl.wipe(); aborted {
+			return
+		}
+	}
+	accTrie, err := trie.NewSecure(dl.root, dl.triedb, 0)
+	if err != nil {
+		dl.stall(err)
+		return
+	}
+	var (
+		batch    = dl.diskdb.NewBatch()
+		start    = time.Now()
+		logged   = time.Now()
+		accounts uint64
+		slots    uint64
+	)
+	it := trie.NewIterator(accTrie.NodeIterator(dl.genMarker))
+	for it.Next() {
+		// The marker account is generated already, resume after it
+		if bytes.Equal(it.Key, dl.genMarker) {
+			continue
+		}
+		hash := common.BytesToHash(it.Key)
+		rawdb.WriteAccountSnapshot(batch, hash, it.Value)
+		accounts++
+
+		var acc accountRoot
+		if err := rlp.DecodeBytes(it.Value, &acc); err != nil {
+			dl.stall(err)
+			return
+		}
+		if acc.Root != emptyRoot && acc.Root != (common.Hash{}) {
+			storeTrie, err := trie.NewSecure(acc.Root, dl.triedb, 0)
+			if err != nil {
+				dl.stall(err)
+				return
+			}
+			storeIt := trie.NewIterator(storeTrie.NodeIterator(nil))
+			for storeIt.Next() {
+				rawdb.WriteStorageSnapshot(batch, hash, common.BytesToHash(storeIt.Key), storeIt.Value)
+				slots++
+
+				// Large storage tries are flushed, the marker not moving until done
+				if batch.ValueSize() >= berithdb.IdealBatchSize {
+					if err := batch.Write(); err != nil {
+						log.Crit("Failed to write snapshot", "err", err)
+					}
+					batch.Reset()
+				}
+			}
+			if storeIt.Err != nil {
+				dl.stall(storeIt.Err)
+				return
+			}
+		}
+		if batch.ValueSize() >= berithdb.IdealBatchSize {
+			dl.checkpoint(batch, hash[:])
+		}
+		select {
+		case abort := <-dl.genAbort:
+			dl.checkpoint(batch, hash[:])
+			close(abort)
+			return
+		default:
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Generating state snapshot", "root", dl.root, "at", hash, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if it.Err != nil {
+		dl.stall(it.Err)
+		return
+	}
+	dl.checkpoint(batch, nil)
+	log.Info("Generated state snapshot", "root", dl.root, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// The layer is complete, wait for the abort signal of its replacement
+	abort := <-dl.genAbort
+	close(abort)
+}
+
+// checkpoint writes the generated entries together with the progress, then
+// publishes the new marker to the readers.
+func (dl *diskLayer) checkpoint(batch berithdb.Batch, marker []byte) {
+	writeGeneratorProgress(batch, false, marker)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write snapshot", "err", err)
+	}
+	batch.Reset()
+
+	dl.lock.Lock()
+	dl.genMarker = marker
+	dl.lock.Unlock()
+}
+
+// stall reports a failed generation, e.g. because of a missing state trie, and
+// waits for the abort signal. The layer keeps serving the covered accounts, the
+// generation being resumed on the next disk layer.
+func (dl *diskLayer) stall(err error) {
+	log.Error("Snapshot generation stalled", "root", dl.root, "err", err)
+	abort := <-dl.genAbort
+	close(abort)
+}
+
+// wipe deletes the data of a previous snapshot before generating the new one.
+// It returns whether it was aborted, the wiping being resumed on the next disk
+// layer then.
+func (dl *diskLayer) wipe() bool {
+	var (
+		batch = dl.diskdb.NewBatch()
+		start = time.Now()
+		count int
+	)
+	for _, prefix := range []struct {
+		prefix []byte
+		length int
+	}{
+		{rawdb.SnapshotAccountPrefix, len(rawdb.SnapshotAccountPrefix) + common.HashLength},
+		{rawdb.SnapshotStoragePrefix, len(rawdb.SnapshotStoragePrefix) + 2*common.HashLength},
+	} {
+		it := dl.diskdb.NewIteratorWithPrefix(prefix.prefix)
+		for it.Next() {
+			if len(it.Key()) != prefix.length {
+				continue
+			}
+			batch.Delete(common.CopyBytes(it.Key()))
+			count++
+
+			if batch.ValueSize() < berithdb.IdealBatchSize {
+				continue
+			}
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to wipe snapshot", "err", err)
+			}
+			batch.Reset()
+
+			select {
+			case abort := <-dl.genAbort:
+				it.Release()
+				close(abort)
+				return true
+			default:
+			}
+		}
+		it.Release()
+	}
+	writeGeneratorProgress(batch, false, []byte{})
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to wipe snapshot", "err", err)
+	}
+	dl.genWiping = false
+
+	if count > 0 {
+		log.Info("Wiped previous state snapshot", "entries", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/rlp"
+	"github.com/BerithFoundation/berith-chain/trie"
+)
+
+// journalAccount is an account entry of a journalled diff layer.
+type journalAccount struct {
+	Hash common.Hash
+	Blob []byte
+}
+
+// journalStorage is the storage changes of an account of a journalled diff layer.
+type journalStorage struct {
+	Hash  common.Hash
+	Keys  []common.Hash
+	Blobs [][]byte
+}
+
+// journalLayer is a diff layer persisted in the snapshot journal.
+type journalLayer struct {
+	Root      common.Hash
+	Parent    common.Hash
+	Destructs []common.Hash
+	Accounts  []journalAccount
+	Storage   []journalStorage
+}
+
+// encodeJournal encodes the given diff layers, ordered top-down, into a journal
+// listing them bottom-up.
+func encodeJournal(diffs []*diffLayer) ([]byte, error) {
+	layers := make([]journalLayer, 0, len(diffs))
+	for i := len(diffs) - 1; i >= 0; i-- {
+		diff := diffs[i]
+
+		layer := journalLayer{Root: diff.root, Parent: diff.parentLayer().Root()}
+		for hash := range diff.destructs {
+			layer.Destructs = append(layer.Destructs, hash)
+		}
+		for hash, blob := range diff.accounts {
+			layer.Accounts = append(layer.Accounts, journalAccount{Hash: hash, Blob: blob})
+		}
+		for hash, storage := range diff.storage {
+			entry := journalStorage{Hash: hash}
+			for key, blob := range storage {
+				entry.Keys = append(entry.Keys, key)
+				entry.Blobs = append(entry.Blobs, blob)
+			}
+			layer.Storage = append(layer.Storage, entry)
+		}
+		layers = append(layers, layer)
+	}
+	return rlp.EncodeToBytes(layers)
+}
+
+// loadSnapshot loads the disk layer and the journalled diff layers of a snapshot,
+// returning the head layer which must match the given root. The journal is
+// consumed, so that it isn't applied again after a crash.
+func loadSnapshot(diskdb berithdb.Database, triedb *trie.Database, root common.Hash) (snapshot, error) {
+	journal := rawdb.ReadSnapshotJournal(diskdb)
+	if len(journal) > 0 {
+		rawdb.DeleteSnapshotJournal(diskdb)
+	}
+	baseRoot := rawdb.ReadSnapshotRoot(diskdb)
+	if baseRoot == (common.Hash{}) {
+		return nil, errors.New("missing or corrupted snapshot")
+	}
+	progress, err := readGeneratorProgress(diskdb)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot generator progress: %v", err)
+	}
+	base := &diskLayer{
+		diskdb:    diskdb,
+		triedb:    triedb,
+		root:      baseRoot,
+		genMarker: progress.Marker,
+		genWiping: progress.Wiping,
+	}
+	var head snapshot = base
+	if len(journal) > 0 {
+		var layers []journalLayer
+		if err := rlp.DecodeBytes(journal, &layers); err != nil {
+			return nil, fmt.Errorf("invalid snapshot journal: %v", err)
+		}
+		for _, layer := range layers {
+			if layer.Parent != head.Root() {
+				return nil, fmt.Errorf("snapshot journal discontinuous: parent %#x, have %#x", layer.Parent, head.Root())
+			}
+			destructs := make(map[common.Hash]struct{}, len(layer.Destructs))
+			for _, hash := range layer.Destructs {
+				destructs[hash] = struct{}{}
+			}
+			accounts := make(map[common.Hash][]byte, len(layer.Accounts))
+			for _, entry := range layer.Accounts {
+				accounts[entry.Hash] = entry.Blob
+			}
+			storage := make(map[common.Hash]map[common.Hash][]byte, len(layer.Storage))
+			for _, entry := range layer.Storage {
+				if len(entry.Keys) != len(entry.Blobs) {
+					return nil, errors.New("invalid snapshot journal storage")
+				}
+				slots := make(map[common.Hash][]byte, len(entry.Keys))
+				for i, key := range entry.Keys {
+					slots[key] = entry.Blobs[i]
+				}
+				storage[entry.Hash] = slots
+			}
+			head = newDiffLayer(head, layer.Root, destructs, accounts, storage)
+		}
+	}
+	if head.Root() != root {
+		return nil, fmt.Errorf("snapshot head mismatch: have %#x, want %#x", head.Root(), root)
+	}
+	// Resume the generation if the snapshot wasn't complete yet
+	if base.genMarker != nil {
+		base.genAbort = make(chan chan struct{})
+		go base.generate()
+	}
+	return head, nil
+}
@@ -0,0 +1,276 @@
+/*
+[BERITH]
+state trie 를 거치지 않고 account (stake, point 포함) 와 storage 를 읽기 위한 flat snapshot
+디스크의 flat key-value 위에 최근 블록들의 diff layer 를 쌓아 head 와 동기화한다
+*/
+
+// Package snapshot implements a flat key-value snapshot of the accounts and the
+// storage slots of the state, kept in sync with the chain head through in-memory
+// diff layers on top of a persistent disk layer.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/log"
+	"github.com/BerithFoundation/berith-chain/trie"
+)
+
+var (
+	// ErrSnapshotStale is returned from data accessors if the underlying snapshot
+	// layer had been flattened or invalidated and the data it held is not valid
+	// anymore. The caller should fall back to the state trie.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrNotCoveredYet is returned from data accessors if the requested entry is
+	// not generated into the disk layer yet. The caller should fall back to the
+	// state trie.
+	ErrNotCoveredYet = errors.New("not covered yet")
+
+	// errSnapshotCycle is returned if a snapshot is attempted to be inserted
+	// that forms a cycle in the snapshot tree.
+	errSnapshotCycle = errors.New("snapshot cycle")
+)
+
+// Snapshot represents the functionality supported by a snapshot storage layer.
+// The returned values are the values of the state trie leaves, an empty value
+// meaning that the entry doesn't exist.
+type Snapshot interface {
+	// Root returns the state root of the snapshot.
+	Root() common.Hash
+
+	// Account retrieves the account trie value of the account with the given
+	// address hash.
+	Account(hash common.Hash) ([]byte, error)
+
+	// Storage retrieves the storage trie value of a storage slot of an account,
+	// given the hashes of the address and of the slot.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is the internal version of the snapshot layers.
+type snapshot interface {
+	Snapshot
+
+	// parentLayer returns the layer below this one, nil for the disk layer.
+	parentLayer() snapshot
+
+	// markStale invalidates the layer, its accessors failing afterwards.
+	markStale()
+}
+
+// Tree is a tree of snapshot layers: a single persistent disk layer with diff
+// layers of the recent blocks on top of it, possibly forking. Every layer is
+// identified by the state root it represents.
+//
+// The number of diff layers kept is bounded by the depth of the tree, the lower
+// ones being flattened into the disk layer as the chain progresses.
+type Tree struct {
+	diskdb berithdb.Database        // Persistent database to store the snapshot
+	triedb *trie.Database           // In-memory cache to access the state tries
+	depth  int                      // Number of diff layers to keep above the disk layer
+	layers map[common.Hash]snapshot // Collection of all known layers
+
+	lock sync.RWMutex
+}
+
+// New attempts to load an already existing snapshot from a persistent key-value
+// store, the head of its diff layers being the given root. If the snapshot is
+// missing or doesn't match the head, it is rebuilt from the state trie in the
+// background, the state trie serving the reads until then.
+func New(diskdb berithdb.Database, triedb *trie.Database, root common.Hash, depth int) *Tree {
+	snap := &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		depth:  depth,
+		layers: make(map[common.Hash]snapshot),
+	}
+	head, err := loadSnapshot(diskdb, triedb, root)
+	if err != nil {
+		log.Warn("Failed to load snapshot, regenerating", "err", err)
+		snap.Rebuild(root)
+		return snap
+	}
+	for head != nil {
+		snap.layers[head.Root()] = head
+		head = head.parentLayer()
+	}
+	return snap
+}
+
+// Depth returns the number of diff layers kept above the disk layer.
+func (t *Tree) Depth() int {
+	return t.depth
+}
+
+// Snapshot retrieves a snapshot belonging to the given state root, or nil if no
+// snapshot is maintained for that root.
+func (t *Tree) Snapshot(root common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if layer, ok := t.layers[root]; ok {
+		return layer
+	}
+	return nil
+}
+
+// Update adds a new diff layer on top of the snapshot of the parent state. The
+// destructed accounts have their storage wiped before the account and storage
+// changes are applied, an empty value deleting an entry.
+func (t *Tree) Update(root common.Hash, parent common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	if root == parent {
+		return errSnapshotCycle
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// The same state reached again, e.g. by a re-executed block, is already known
+	if _, ok := t.layers[root]; ok {
+		return nil
+	}
+	base, ok := t.layers[parent]
+	if !ok {
+		return fmt.Errorf("parent [%#x] snapshot missing", parent)
+	}
+	t.layers[root] = newDiffLayer(base, root, destructs, accounts, storage)
+	return nil
+}
+
+// Cap traverses downwards the snapshot tree from the given root, keeping the
+// given number of diff layers and flattening the ones below into the disk layer.
+// The layers not descending from the new disk layer are dropped.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	layer, ok := t.layers[root]
+	if !ok {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	diff, ok := layer.(*diffLayer)
+	if !ok {
+		return nil // Disk layer, nothing to flatten
+	}
+	// Find the lowest diff layer to keep, nil if all of them are flattened
+	var keep *diffLayer
+	if layers > 0 {
+		keep = diff
+		for i := 1; i < layers; i++ {
+			parent, ok := keep.parentLayer().(*diffLayer)
+			if !ok {
+				return nil // Not enough layers to flatten anything
+			}
+			keep = parent
+		}
+		if diff, ok = keep.parentLayer().(*diffLayer); !ok {
+			return nil
+		}
+	}
+	// Flatten the layers below, starting with the one on top of the disk layer
+	var flatten []*diffLayer
+	for layer := snapshot(diff); ; layer = layer.parentLayer() {
+		if diff, ok := layer.(*diffLayer); ok {
+			flatten = append(flatten, diff)
+			continue
+		}
+		break
+	}
+	var base *diskLayer
+	for i := len(flatten) - 1; i >= 0; i-- {
+		base = diffToDisk(flatten[i])
+	}
+	if keep != nil {
+		keep.lock.Lock()
+		keep.parent = base
+		keep.lock.Unlock()
+	}
+	t.prune(base)
+	return nil
+}
+
+// prune drops the layers not descending from the given disk layer, marking them
+// stale for the readers still holding them.
+func (t *Tree) prune(base *diskLayer) {
+	children := make(map[common.Hash][]common.Hash)
+	for root, layer := range t.layers {
+		if diff, ok := layer.(*diffLayer); ok {
+			parent := diff.parentLayer().Root()
+			children[parent] = append(children[parent], root)
+		}
+	}
+	keep := map[common.Hash]snapshot{base.root: base}
+	queue := []common.Hash{base.root}
+	for len(queue) > 0 {
+		root := queue[0]
+		queue = queue[1:]
+		for _, child := range children[root] {
+			layer := t.layers[child]
+			// A layer whose parent was flattened is still chained to stale layers
+			if layer.parentLayer() != keep[root] {
+				continue
+			}
+			keep[child] = layer
+			queue = append(queue, child)
+		}
+	}
+	for root, layer := range t.layers {
+		if _, ok := keep[root]; !ok {
+			layer.markStale()
+		}
+	}
+	t.layers = keep
+}
+
+// Journal persists the diff layers from the disk layer up to the given root, so
+// that the snapshot can be loaded back without regeneration on the next start.
+// The generation of the disk layer is stopped, the tree must not be updated
+// afterwards.
+func (t *Tree) Journal(root common.Hash) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	layer, ok := t.layers[root]
+	if !ok {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	var diffs []*diffLayer
+	for ; ; layer = layer.parentLayer() {
+		diff, ok := layer.(*diffLayer)
+		if !ok {
+			break
+		}
+		diffs = append(diffs, diff)
+	}
+	layer.(*diskLayer).stopGeneration()
+
+	journal, err := encodeJournal(diffs)
+	if err != nil {
+		return err
+	}
+	rawdb.WriteSnapshotJournal(t.diskdb, journal)
+	log.Info("Persisted snapshot journal", "root", root, "layers", len(diffs))
+	return nil
+}
+
+// Rebuild wipes all available snapshot data from the persistent database and
+// discards all the layers, regenerating the snapshot of the given root in the
+// background.
+func (t *Tree) Rebuild(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, layer := range t.layers {
+		if disk, ok := layer.(*diskLayer); ok {
+			disk.stopGeneration()
+		}
+		layer.markStale()
+	}
+	log.Info("Rebuilding state snapshot", "root", root)
+	t.layers = map[common.Hash]snapshot{root: generateSnapshot(t.diskdb, t.triedb, root)}
+}
@@ -0,0 +1,214 @@
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/crypto"
+	"github.com/BerithFoundation/berith-chain/rlp"
+	"github.com/BerithFoundation/berith-chain/trie"
+)
+
+// testAccount is the prefix of the account encoding needed by the snapshot.
+type testAccount struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// makeTestState creates a state of a few accounts, every other one having some
+// storage, and returns its root.
+func makeTestState(t *testing.T, triedb *trie.Database) common.Hash {
+	accTrie, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+	for i := byte(0); i < 20; i++ {
+		acc := testAccount{Nonce: uint64(i), Balance: big.NewInt(int64(i)), Root: emptyRoot, CodeHash: crypto.Keccak256(nil)}
+		if i%2 == 0 {
+			storeTrie, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+			for j := byte(1); j < 5; j++ {
+				value, _ := rlp.EncodeToBytes([]byte{i, j})
+				storeTrie.Update(common.BytesToHash([]byte{j}).Bytes(), value)
+			}
+			root, err := storeTrie.Commit(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := triedb.Commit(root, false); err != nil {
+				t.Fatal(err)
+			}
+			acc.Root = root
+		}
+		data, _ := rlp.EncodeToBytes(&acc)
+		accTrie.Update(common.BytesToAddress([]byte{i}).Bytes(), data)
+	}
+	root, err := accTrie.Commit(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := triedb.Commit(root, false); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// waitGeneration waits until the disk layer of the tree is fully generated.
+func waitGeneration(t *testing.T, snaps *Tree) {
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		snaps.lock.RLock()
+		var done bool
+		for _, layer := range snaps.layers {
+			if disk, ok := layer.(*diskLayer); ok {
+				disk.lock.RLock()
+				done = disk.genMarker == nil
+				disk.lock.RUnlock()
+			}
+		}
+		snaps.lock.RUnlock()
+
+		if done {
+			return
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("snapshot not generated in time")
+		}
+	}
+}
+
+// addressHash returns the snapshot key of the test account i.
+func addressHash(i byte) common.Hash {
+	return crypto.Keccak256Hash(common.BytesToAddress([]byte{i}).Bytes())
+}
+
+// storageHash returns the snapshot key of the test storage slot j.
+func storageHash(j byte) common.Hash {
+	return crypto.Keccak256Hash(common.BytesToHash([]byte{j}).Bytes())
+}
+
+// Tests that a snapshot generated from the state trie matches it, and that the
+// verification detects a tampered snapshot.
+func TestGenerateAndVerify(t *testing.T) {
+	var (
+		diskdb = berithdb.NewMemDatabase()
+		triedb = trie.NewDatabase(diskdb)
+		root   = makeTestState(t, triedb)
+	)
+	// A stale entry of a previous snapshot is wiped by the generation
+	rawdb.WriteAccountSnapshot(diskdb, common.HexToHash("0xdead"), []byte{0x01})
+
+	snaps := New(diskdb, triedb, root, 4)
+	waitGeneration(t, snaps)
+
+	if have, err := Verify(diskdb, triedb); err != nil || have != root {
+		t.Fatalf("verification failed: root %x, err %v", have, err)
+	}
+	snap := snaps.Snapshot(root)
+	data, err := snap.Account(addressHash(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var acc testAccount
+	if err := rlp.DecodeBytes(data, &acc); err != nil || acc.Nonce != 3 {
+		t.Fatalf("account mismatch: have %v, err %v", acc, err)
+	}
+	if data, _ := snap.Storage(addressHash(4), storageHash(2)); !bytes.Equal(data, []byte{0x82, 4, 2}) {
+		t.Fatalf("storage mismatch: have %x", data)
+	}
+	if data, _ := snap.Account(common.HexToHash("0xdead")); len(data) != 0 {
+		t.Fatalf("stale account not wiped: %x", data)
+	}
+	// Any difference with the trie fails the verification
+	rawdb.WriteStorageSnapshot(diskdb, addressHash(4), storageHash(9), []byte{0x01})
+	if _, err := Verify(diskdb, triedb); err == nil {
+		t.Fatalf("dangling storage entry not detected")
+	}
+	rawdb.DeleteStorageSnapshot(diskdb, addressHash(4), storageHash(9))
+	rawdb.WriteAccountSnapshot(diskdb, addressHash(5), []byte{0x01})
+	if _, err := Verify(diskdb, triedb); err == nil {
+		t.Fatalf("account mismatch not detected")
+	}
+}
+
+// Tests that diff layers shadow the layers below them, survive being flattened
+// into the disk layer and are restored from the journal.
+func TestDiffLayers(t *testing.T) {
+	var (
+		diskdb = berithdb.NewMemDatabase()
+		triedb = trie.NewDatabase(diskdb)
+		root   = makeTestState(t, triedb)
+		root1  = common.HexToHash("0x01")
+		root2  = common.HexToHash("0x02")
+		root3  = common.HexToHash("0x03")
+	)
+	snaps := New(diskdb, triedb, root, 1)
+	waitGeneration(t, snaps)
+
+	// Layer 1 destructs account 2 and changes account 1, layer 2 recreates
+	// account 2 with new storage and deletes a slot of account 4
+	err := snaps.Update(root1, root, map[common.Hash]struct{}{addressHash(2): {}}, map[common.Hash][]byte{addressHash(1): {0x01}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = snaps.Update(root2, root1, nil, map[common.Hash][]byte{addressHash(2): {0x02}}, map[common.Hash]map[common.Hash][]byte{
+		addressHash(2): {storageHash(3): {0x03}},
+		addressHash(4): {storageHash(1): nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(snap Snapshot) {
+		t.Helper()
+
+		if data, err := snap.Account(addressHash(1)); err != nil || !bytes.Equal(data, []byte{0x01}) {
+			t.Errorf("changed account mismatch: have %x, err %v", data, err)
+		}
+		if data, err := snap.Account(addressHash(2)); err != nil || !bytes.Equal(data, []byte{0x02}) {
+			t.Errorf("recreated account mismatch: have %x, err %v", data, err)
+		}
+		if data, err := snap.Storage(addressHash(2), storageHash(1)); err != nil || len(data) != 0 {
+			t.Errorf("destructed storage not wiped: have %x, err %v", data, err)
+		}
+		if data, err := snap.Storage(addressHash(2), storageHash(3)); err != nil || !bytes.Equal(data, []byte{0x03}) {
+			t.Errorf("recreated storage mismatch: have %x, err %v", data, err)
+		}
+		if data, err := snap.Storage(addressHash(4), storageHash(1)); err != nil || len(data) != 0 {
+			t.Errorf("deleted slot still present: have %x, err %v", data, err)
+		}
+		if data, err := snap.Storage(addressHash(4), storageHash(2)); err != nil || !bytes.Equal(data, []byte{0x82, 4, 2}) {
+			t.Errorf("untouched slot mismatch: have %x, err %v", data, err)
+		}
+	}
+	check(snaps.Snapshot(root2))
+
+	// A fork on top of the disk layer is dropped once layer 1 is flattened
+	if err := snaps.Update(root3, root, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	layer1, fork := snaps.Snapshot(root1), snaps.Snapshot(root3)
+	if err := snaps.Cap(root2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := layer1.Account(addressHash(1)); err != ErrSnapshotStale {
+		t.Errorf("flattened layer not stale: %v", err)
+	}
+	if _, err := fork.Account(addressHash(1)); err != ErrSnapshotStale {
+		t.Errorf("dropped fork not stale: %v", err)
+	}
+	if snaps.Snapshot(root3) != nil {
+		t.Errorf("dropped fork still in the tree")
+	}
+	if root := rawdb.ReadSnapshotRoot(diskdb); root != root1 {
+		t.Errorf("disk layer root mismatch: have %x, want %x", root, root1)
+	}
+	check(snaps.Snapshot(root2))
+
+	// The journalled layers are loaded back on top of the disk layer
+	if err := snaps.Journal(root2); err != nil {
+		t.Fatal(err)
+	}
+	check(New(diskdb, triedb, root2, 1).Snapshot(root2))
+}
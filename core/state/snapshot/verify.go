@@ -0,0 +1,104 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/log"
+	"github.com/BerithFoundation/berith-chain/rlp"
+	"github.com/BerithFoundation/berith-chain/trie"
+)
+
+// Verify checks the flat snapshot on disk against the state trie of its root,
+// every account and storage slot having to match a trie leaf and the other way
+// around. It returns the verified root. The snapshot must be fully generated.
+func Verify(diskdb berithdb.Database, triedb *trie.Database) (common.Hash, error) {
+	root := rawdb.ReadSnapshotRoot(diskdb)
+	if root == (common.Hash{}) {
+		return common.Hash{}, errors.New("missing or corrupted snapshot")
+	}
+	progress, err := readGeneratorProgress(diskdb)
+	if err != nil {
+		return root, fmt.Errorf("invalid snapshot generator progress: %v", err)
+	}
+	if !progress.Done {
+		return root, errors.New("snapshot not fully generated")
+	}
+	accTrie, err := trie.NewSecure(root, triedb, 0)
+	if err != nil {
+		return root, err
+	}
+	var accounts, slots int
+	err = verifyRange(diskdb, rawdb.SnapshotAccountPrefix, trie.NewIterator(accTrie.NodeIterator(nil)), func(key, value []byte) error {
+		accounts++
+
+		var acc accountRoot
+		if err := rlp.DecodeBytes(value, &acc); err != nil {
+			return err
+		}
+		if acc.Root == emptyRoot || acc.Root == (common.Hash{}) {
+			acc.Root = common.Hash{}
+		}
+		storeTrie, err := trie.NewSecure(acc.Root, triedb, 0)
+		if err != nil {
+			return err
+		}
+		prefix := rawdb.StorageSnapshotsPrefix(common.BytesToHash(key))
+		return verifyRange(diskdb, prefix, trie.NewIterator(storeTrie.NodeIterator(nil)), func(key, value []byte) error {
+			slots++
+			return nil
+		})
+	})
+	if err != nil {
+		return root, err
+	}
+	log.Info("Verified state snapshot", "root", root, "accounts", accounts, "slots", slots)
+	return root, nil
+}
+
+// verifyRange compares the snapshot entries under the given prefix with the
+// leaves of a trie, calling onLeaf for every matching entry.
+func verifyRange(diskdb berithdb.Database, prefix []byte, trieIt *trie.Iterator, onLeaf func(key, value []byte) error) error {
+	it := diskdb.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	// next moves to the next snapshot entry of the range, skipping the longer keys
+	// of other ranges sharing the prefix
+	next := func() bool {
+		for it.Next() {
+			if len(it.Key()) == len(prefix)+common.HashLength {
+				return true
+			}
+		}
+		return false
+	}
+	for {
+		hasTrie, hasSnap := trieIt.Next(), next()
+		if !hasTrie && !hasSnap {
+			break
+		}
+		var snapKey []byte
+		if hasSnap {
+			snapKey = it.Key()[len(prefix):]
+		}
+		switch {
+		case !hasSnap || (hasTrie && bytes.Compare(trieIt.Key, snapKey) < 0):
+			return fmt.Errorf("snapshot entry %x%x missing", prefix, trieIt.Key)
+		case !hasTrie || bytes.Compare(snapKey, trieIt.Key) < 0:
+			return fmt.Errorf("dangling snapshot entry %x%x", prefix, snapKey)
+		case !bytes.Equal(it.Value(), trieIt.Value):
+			return fmt.Errorf("snapshot entry %x%x mismatch: have %x, want %x", prefix, snapKey, it.Value(), trieIt.Value)
+		}
+		if err := onLeaf(trieIt.Key, trieIt.Value); err != nil {
+			return err
+		}
+	}
+	if trieIt.Err != nil {
+		return trieIt.Err
+	}
+	return it.Error()
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/types"
 	"github.com/BerithFoundation/berith-chain/crypto"
 	"github.com/BerithFoundation/berith-chain/rlp"
 )
@@ -92,7 +93,7 @@ type stateObject struct {
 
 // empty returns whether the account is considered empty.
 func (s *stateObject) empty() bool {
-	return s.data.Nonce == 0 && s.data.Balance.Sign() == 0 && bytes.Equal(s.data.CodeHash, emptyCodeHash)
+	return s.data.Nonce == 0 && s.data.Balance.Sign() == 0 && bytes.Equal(s.data.CodeHash, emptyCodeHash) && len(s.data.Ext) == 0
 }
 
 // Account is the Berith consensus representation of accounts.
@@ -115,6 +116,39 @@ type Account struct {
 	BehindBalance  []Behind //behind balance
 	Penalty        uint64
 	PenlatyUpdated *big.Int //Block Number when the penalty was updated
+
+	// Extensions of the account added by forks, at most one entry. Being the
+	// tail keeps the encoding of accounts not using them unchanged.
+	Ext []AccountExt `rlp:"tail"`
+}
+
+// AccountExt holds the state of the account features added by forks. A feature
+// added later has to be a tail field, so that the encoding of the accounts using
+// only the earlier ones is unchanged.
+type AccountExt struct {
+	Multisig []types.MultisigConfig // Configuration of multisig accounts, at most one entry
+
+	// Balances locked by vesting schedules. Being the tail keeps the encoding
+	// of the multisig accounts of BIP7 unchanged.
+	Vesting []Vesting `rlp:"tail"`
+}
+
+// empty returns whether none of the features is used.
+func (ext *AccountExt) empty() bool {
+	return len(ext.Multisig) == 0 && len(ext.Vesting) == 0
+}
+
+// Vesting is a balance locked by a vesting schedule.
+type Vesting struct {
+	types.VestingSchedule
+	Amount   *big.Int `json:"amount"`   // Total locked balance
+	Released *big.Int `json:"released"` // Part of the balance released into the main balance
+	Staked   *big.Int `json:"staked"`   // Part of the locked balance moved into the stake balance
+}
+
+// Locked returns the part of the balance which is not released yet.
+func (v *Vesting) Locked() *big.Int {
+	return new(big.Int).Sub(v.Amount, v.Released)
 }
 
 /*
@@ -227,11 +261,26 @@ func (self *stateObject) GetCommittedState(db Database, key common.Hash) common.
 	if cached {
 		return value
 	}
-	// Otherwise load the value from the database
-	enc, err := self.getTrie(db).TryGet(key[:])
-	if err != nil {
-		self.setError(err)
-		return common.Hash{}
+	// Otherwise load the value from the snapshot if available, falling back to
+	// the database if the snapshot can't serve it
+	var (
+		enc []byte
+		err error
+	)
+	if self.db.snap != nil {
+		// The storage of an account destructed in this block is gone, the
+		// snapshot of the parent state still holding it
+		if _, destructed := self.db.snapDestructs[self.addrHash]; destructed {
+			self.originStorage[key] = common.Hash{}
+			return common.Hash{}
+		}
+		enc, err = self.db.snap.Storage(self.addrHash, crypto.Keccak256Hash(key[:]))
+	}
+	if self.db.snap == nil || err != nil {
+		if enc, err = self.getTrie(db).TryGet(key[:]); err != nil {
+			self.setError(err)
+			return common.Hash{}
+		}
 	}
 	if len(enc) > 0 {
 		_, content, _, err := rlp.Split(enc)
@@ -267,6 +316,15 @@ func (self *stateObject) setState(key, value common.Hash) {
 // updateTrie writes cached storage modifications into the object's storage trie.
 func (self *stateObject) updateTrie(db Database) Trie {
 	tr := self.getTrie(db)
+
+	// Collect the storage changes for the snapshot as well
+	var storage map[common.Hash][]byte
+	if self.db.snap != nil && len(self.dirtyStorage) > 0 {
+		if storage = self.db.snapStorage[self.addrHash]; storage == nil {
+			storage = make(map[common.Hash][]byte)
+			self.db.snapStorage[self.addrHash] = storage
+		}
+	}
 	for key, value := range self.dirtyStorage {
 		delete(self.dirtyStorage, key)
 
@@ -278,11 +336,17 @@ func (self *stateObject) updateTrie(db Database) Trie {
 
 		if (value == common.Hash{}) {
 			self.setError(tr.TryDelete(key[:]))
+			if storage != nil {
+				storage[crypto.Keccak256Hash(key[:])] = nil
+			}
 			continue
 		}
 		// Encoding []byte cannot fail, ok to ignore the error.
 		v, _ := rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
 		self.setError(tr.TryUpdate(key[:], v))
+		if storage != nil {
+			storage[crypto.Keccak256Hash(key[:])] = v
+		}
 	}
 	return tr
 }
@@ -472,9 +536,23 @@ func (c *stateObject) RemoveStakeBalance() {
 		return
 	}
 
+	// [BERITH] Locked vesting balances in the stake balance are locked again
+	// instead of being returned
+	var (
+		locked   = new(big.Int)
+		unstaked = make([]Vesting, 0, len(c.Vesting()))
+	)
+	for _, v := range c.Vesting() {
+		locked.Add(locked, v.Staked)
+		unstaked = append(unstaked, Vesting{v.VestingSchedule, v.Amount, v.Released, new(big.Int)})
+	}
+	if locked.Sign() > 0 {
+		c.SetVesting(unstaked)
+	}
+
 	calcResult := new(big.Int).Sub(stakeBalance, stakeBalance)
 	c.SetStaking(calcResult, c.data.StakeUpdated)
-	c.AddBalance(stakeBalance)
+	c.AddBalance(new(big.Int).Sub(stakeBalance, locked))
 }
 
 /*
@@ -527,6 +605,18 @@ func (self *stateObject) SetBehind(number, amount *big.Int) {
 	self.setBehind(append(self.data.BehindBalance, behind))
 }
 
+/*
+[BERITH]
+BehindBalance 배열 전체를 교체 하는 함수
+*/
+func (self *stateObject) SetBehindBalance(behind []Behind) {
+	self.db.journal.append(behindChange{
+		account: &self.address,
+		prev:    self.data.BehindBalance,
+	})
+	self.setBehind(behind)
+}
+
 func (self *stateObject) setBehind(behind []Behind) {
 	self.data.BehindBalance = behind
 }
@@ -566,6 +656,49 @@ func (self *stateObject) RemoveFirstBehindBalance() {
 	self.setBehind(behind[1:])
 }
 
+/*
+[BERITH]
+BehindBalance 배열에서 주어진 블록넘버 이하에 지급된 보상의 합을 반환 하는 함수
+*/
+func (self *stateObject) MaturedReward(number *big.Int) *big.Int {
+	matured := new(big.Int)
+	for _, behind := range self.data.BehindBalance {
+		if behind.Number.Cmp(number) > 0 {
+			break
+		}
+		matured.Add(matured, behind.Balance)
+	}
+	return matured
+}
+
+/*
+[BERITH]
+주어진 블록넘버 이하에 지급된 보상에서 오래된 순으로 amount 만큼 차감 하는 함수
+*/
+func (self *stateObject) SubMaturedReward(number, amount *big.Int) {
+	var (
+		left   = new(big.Int).Set(amount)
+		behind = make([]Behind, 0, len(self.data.BehindBalance))
+	)
+	for _, b := range self.data.BehindBalance {
+		if left.Sign() == 0 || b.Number.Cmp(number) > 0 {
+			behind = append(behind, b)
+			continue
+		}
+		if b.Balance.Cmp(left) > 0 {
+			behind = append(behind, Behind{Number: b.Number, Balance: new(big.Int).Sub(b.Balance, left)})
+			left.SetUint64(0)
+			continue
+		}
+		left.Sub(left, b.Balance)
+	}
+	self.db.journal.append(behindChange{
+		account: &self.address,
+		prev:    self.data.BehindBalance,
+	})
+	self.setBehind(behind)
+}
+
 /*
 [BERITH]
 Selection Point 의 값을 대입해주는 함수
@@ -648,7 +781,71 @@ func (self *stateObject) RemovePenalty(blockNumber *big.Int) {
 	self.setPenalty(0, blockNumber)
 }
 
+//SetPenalty [BERITH] 패널티 값과 마지막으로 변경된 블록 넘버를 대입하는 함수
+func (self *stateObject) SetPenalty(amount uint64, blockNumber *big.Int) {
+	self.db.journal.append(penaltyChange{
+		account:     &self.address,
+		prevPenalty: self.data.Penalty,
+		prevBlock:   self.data.PenlatyUpdated,
+	})
+	self.setPenalty(amount, blockNumber)
+}
+
 func (self *stateObject) setPenalty(amount uint64, blockNumber *big.Int) {
 	self.data.Penalty = amount
 	self.data.PenlatyUpdated = blockNumber
 }
+
+//ext [BERITH] 포크로 추가된 계정 정보를 반환하는 함수
+func (self *stateObject) ext() AccountExt {
+	if len(self.data.Ext) == 0 {
+		return AccountExt{}
+	}
+	return self.data.Ext[0]
+}
+
+//SetExt [BERITH] 포크로 추가된 계정 정보를 저장하는 함수, 사용하지 않으면 인코딩에서 제외한다.
+func (self *stateObject) SetExt(ext AccountExt) {
+	self.db.journal.append(extChange{
+		account: &self.address,
+		prev:    self.data.Ext,
+	})
+	if ext.empty() {
+		self.setExt(nil)
+	} else {
+		self.setExt([]AccountExt{ext})
+	}
+}
+
+func (self *stateObject) setExt(ext []AccountExt) {
+	self.data.Ext = ext
+}
+
+//Multisig [BERITH] 다중서명 계정의 설정을 반환하는 함수, 다중서명 계정이 아니면 nil 을 반환한다.
+func (self *stateObject) Multisig() *types.MultisigConfig {
+	ext := self.ext()
+	if len(ext.Multisig) == 0 {
+		return nil
+	}
+	config := ext.Multisig[0]
+	return &types.MultisigConfig{Threshold: config.Threshold, Owners: append([]common.Address{}, config.Owners...)}
+}
+
+//SetMultisig [BERITH] 다중서명 계정의 설정을 저장하는 함수
+func (self *stateObject) SetMultisig(config *types.MultisigConfig) {
+	ext := self.ext()
+	ext.Multisig = []types.MultisigConfig{*config}
+	self.SetExt(ext)
+}
+
+//Vesting [BERITH] 베스팅 일정으로 잠긴 잔액 목록을 반환하는 함수
+func (self *stateObject) Vesting() []Vesting {
+	return self.ext().Vesting
+}
+
+//SetVesting [BERITH] 베스팅 일정으로 잠긴 잔액 목록을 저장하는 함수
+func (self *stateObject) SetVesting(vesting []Vesting) {
+	ext := self.ext()
+	ext.Vesting = vesting
+	self.SetExt(ext)
+}
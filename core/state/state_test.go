@@ -18,12 +18,16 @@ package state
 
 import (
 	"bytes"
+	"encoding/json"
 	"math/big"
 	"testing"
 
 	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/types"
 	"github.com/BerithFoundation/berith-chain/crypto"
 	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/rlp"
+	"github.com/BerithFoundation/berith-chain/trie"
 	checker "gopkg.in/check.v1"
 )
 
@@ -243,3 +247,336 @@ func compareStateObjects(so0, so1 *stateObject, t *testing.T) {
 		}
 	}
 }
+
+func TestMaturedReward(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(berithdb.NewMemDatabase()))
+	addr := common.BytesToAddress([]byte("reward"))
+
+	state.AddBehindBalance(addr, big.NewInt(10), big.NewInt(100))
+	state.AddBehindBalance(addr, big.NewInt(20), big.NewInt(200))
+	state.AddBehindBalance(addr, big.NewInt(30), big.NewInt(300))
+
+	if matured := state.GetMaturedReward(addr, big.NewInt(20)); matured.Cmp(big.NewInt(300)) != 0 {
+		t.Fatalf("matured reward mismatch: have %v, want %v", matured, 300)
+	}
+
+	snapshot := state.Snapshot()
+	state.SubMaturedReward(addr, big.NewInt(20), big.NewInt(150))
+
+	behind := state.GetBehindBalance(addr)
+	if len(behind) != 2 || behind[0].Number.Uint64() != 20 || behind[0].Balance.Cmp(big.NewInt(150)) != 0 {
+		t.Fatalf("behind balance mismatch after withdrawal: %v", behind)
+	}
+	if matured := state.GetMaturedReward(addr, big.NewInt(30)); matured.Cmp(big.NewInt(450)) != 0 {
+		t.Fatalf("matured reward mismatch: have %v, want %v", matured, 450)
+	}
+
+	state.RevertToSnapshot(snapshot)
+	if matured := state.GetMaturedReward(addr, big.NewInt(30)); matured.Cmp(big.NewInt(600)) != 0 {
+		t.Fatalf("matured reward mismatch after revert: have %v, want %v", matured, 600)
+	}
+}
+
+func TestAutoCompound(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(berithdb.NewMemDatabase()))
+	addr := common.BytesToAddress([]byte("compound"))
+
+	if state.GetAutoCompound(addr) {
+		t.Fatal("auto-compound enabled by default")
+	}
+	state.SetAutoCompound(addr, true)
+	if !state.GetAutoCompound(addr) {
+		t.Fatal("auto-compound not enabled")
+	}
+	state.SetAutoCompound(addr, false)
+	if state.GetAutoCompound(addr) {
+		t.Fatal("auto-compound not disabled")
+	}
+}
+
+func TestMigrateAccount(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(berithdb.NewMemDatabase()))
+	from, to := common.BytesToAddress([]byte("old")), common.BytesToAddress([]byte("new"))
+
+	state.SetBalance(from, big.NewInt(1000))
+	state.SetStaking(from, big.NewInt(500), big.NewInt(7))
+	state.SetPoint(from, big.NewInt(42))
+	state.AddBehindBalance(from, big.NewInt(10), big.NewInt(100))
+	state.AddPenalty(from, big.NewInt(9))
+	state.SetAutoCompound(from, true)
+	state.SetBalance(to, big.NewInt(1))
+
+	snapshot := state.Snapshot()
+	state.MigrateAccount(from, to)
+
+	if balance := state.GetBalance(to); balance.Cmp(big.NewInt(1001)) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", balance, 1001)
+	}
+	if stake, updated := state.GetStakeBalance(to), state.GetStakeUpdated(to); stake.Cmp(big.NewInt(500)) != 0 || updated.Cmp(big.NewInt(7)) != 0 {
+		t.Errorf("stake mismatch: have %v at %v, want %v at %v", stake, updated, 500, 7)
+	}
+	if point := state.GetPoint(to); point.Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("point mismatch: have %v, want %v", point, 42)
+	}
+	if matured := state.GetMaturedReward(to, big.NewInt(10)); matured.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("behind balance mismatch: have %v, want %v", matured, 100)
+	}
+	if penalty := state.GetPenalty(to); penalty != 1 {
+		t.Errorf("penalty mismatch: have %v, want %v", penalty, 1)
+	}
+	if !state.GetAutoCompound(to) || state.GetAutoCompound(from) {
+		t.Error("auto-compound setting not moved")
+	}
+	if state.GetBalance(from).Sign() != 0 || state.GetStakeBalance(from).Sign() != 0 || state.GetPoint(from).Sign() != 0 ||
+		len(state.GetBehindBalance(from)) != 0 || state.GetPenalty(from) != 0 {
+		t.Errorf("migrated account not emptied: %+v", state.GetAccountInfo(from))
+	}
+
+	state.RevertToSnapshot(snapshot)
+	if state.GetStakeBalance(from).Cmp(big.NewInt(500)) != 0 || state.GetPoint(from).Cmp(big.NewInt(42)) != 0 || state.GetStakeBalance(to).Sign() != 0 {
+		t.Error("migration not reverted")
+	}
+}
+
+func TestVesting(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(berithdb.NewMemDatabase()))
+	linear, cliff := common.BytesToAddress([]byte("linear")), common.BytesToAddress([]byte("cliff"))
+
+	state.AddVesting(linear, types.VestingSchedule{Start: 100, Cliff: 100, End: 200, Stakable: true}, big.NewInt(1000))
+	state.AddVesting(cliff, types.VestingSchedule{Start: 100, Cliff: 150, End: 150}, big.NewInt(500))
+
+	// Stake part of the stakable locked balance before it vests
+	if stakable := state.GetStakableVesting(linear); stakable.Cmp(big.NewInt(1000)) != 0 {
+		t.Fatalf("stakable balance mismatch: have %v, want %v", stakable, 1000)
+	}
+	state.StakeVesting(linear, big.NewInt(800), big.NewInt(50))
+	if stake := state.GetStakeBalance(linear); stake.Cmp(big.NewInt(800)) != 0 {
+		t.Fatalf("stake balance mismatch: have %v, want %v", stake, 800)
+	}
+	if stakable := state.GetStakableVesting(cliff); stakable.Sign() != 0 {
+		t.Fatalf("stakable balance of a non stakable vesting: %v", stakable)
+	}
+
+	// Half of the linear vesting is released, the unstaked part first
+	if releasable := state.GetReleasableVesting(linear, big.NewInt(150)); releasable.Cmp(big.NewInt(200)) != 0 {
+		t.Errorf("releasable balance mismatch: have %v, want %v", releasable, 200)
+	}
+	state.ReleaseVesting(linear, big.NewInt(150))
+	state.ReleaseVesting(cliff, big.NewInt(150))
+	if balance := state.GetBalance(linear); balance.Cmp(big.NewInt(200)) != 0 {
+		t.Errorf("released balance mismatch: have %v, want %v", balance, 200)
+	}
+	if balance := state.GetBalance(cliff); balance.Cmp(big.NewInt(500)) != 0 {
+		t.Errorf("cliff balance mismatch: have %v, want %v", balance, 500)
+	}
+	if vesting := state.GetVesting(cliff); len(vesting) != 0 {
+		t.Errorf("vested schedule not removed: %v", vesting)
+	}
+	vesting := state.GetVesting(linear)
+	if len(vesting) != 1 || vesting[0].Released.Cmp(big.NewInt(500)) != 0 || vesting[0].Staked.Cmp(big.NewInt(500)) != 0 {
+		t.Fatalf("vesting mismatch after release: %+v", vesting)
+	}
+
+	// Stopping staking locks the staked part which isn't released yet
+	state.RemoveStakeBalance(linear)
+	if balance := state.GetBalance(linear); balance.Cmp(big.NewInt(500)) != 0 {
+		t.Errorf("balance mismatch after unstaking: have %v, want %v", balance, 500)
+	}
+	if stakable := state.GetStakableVesting(linear); stakable.Cmp(big.NewInt(500)) != 0 {
+		t.Errorf("locked balance mismatch after unstaking: have %v, want %v", stakable, 500)
+	}
+
+	state.ReleaseVesting(linear, big.NewInt(200))
+	if balance := state.GetBalance(linear); balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("balance mismatch after vesting: have %v, want %v", balance, 1000)
+	}
+	if count := state.GetVestingCount(linear); count != 0 {
+		t.Errorf("vested schedules not removed: %d left", count)
+	}
+}
+
+// Tests that adding the vesting balances to the account extension keeps the
+// encoding of the multisig accounts of BIP7, and that the vesting survives the
+// encoding.
+func TestAccountExtEncoding(t *testing.T) {
+	multisig := types.MultisigConfig{Threshold: 1, Owners: []common.Address{{1}, {2}}}
+	account := Account{Balance: big.NewInt(1), Root: types.EmptyRootHash, CodeHash: emptyCodeHash, Ext: []AccountExt{{Multisig: []types.MultisigConfig{multisig}}}}
+
+	// The extension as BIP7 introduced it
+	type bip7Ext struct {
+		Multisig []types.MultisigConfig
+	}
+	bip7 := struct {
+		Nonce          uint64
+		Balance        *big.Int
+		Root           common.Hash
+		CodeHash       []byte
+		StakeBalance   *big.Int
+		StakeUpdated   *big.Int
+		Point          *big.Int
+		BehindBalance  []Behind
+		Penalty        uint64
+		PenlatyUpdated *big.Int
+		Ext            []bip7Ext `rlp:"tail"`
+	}{Balance: big.NewInt(1), Root: types.EmptyRootHash, CodeHash: emptyCodeHash, Ext: []bip7Ext{{Multisig: []types.MultisigConfig{multisig}}}}
+
+	have, _ := rlp.EncodeToBytes(&account)
+	want, _ := rlp.EncodeToBytes(&bip7)
+	if !bytes.Equal(have, want) {
+		t.Fatalf("multisig account encoding changed:\nhave %x\nwant %x", have, want)
+	}
+	account.Ext[0].Vesting = []Vesting{{types.VestingSchedule{Start: 1, Cliff: 2, End: 3}, big.NewInt(10), big.NewInt(0), big.NewInt(0)}}
+	enc, _ := rlp.EncodeToBytes(&account)
+	var dec Account
+	if err := rlp.DecodeBytes(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if len(dec.Ext) != 1 || len(dec.Ext[0].Multisig) != 1 || len(dec.Ext[0].Vesting) != 1 || dec.Ext[0].Vesting[0].Amount.Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("decoded extension mismatch: %+v", dec.Ext)
+	}
+}
+
+// Tests that the account proof of a historical state proves all the Berith
+// fields of the account against the state root.
+func TestAccountProof(t *testing.T) {
+	db := NewDatabase(berithdb.NewMemDatabase())
+	state, _ := New(common.Hash{}, db)
+	addr := common.BytesToAddress([]byte("proof"))
+
+	state.SetBalance(addr, big.NewInt(1000))
+	state.SetStaking(addr, big.NewInt(500), big.NewInt(7))
+	state.SetPoint(addr, big.NewInt(42))
+	state.AddBehindBalance(addr, big.NewInt(10), big.NewInt(100))
+	root, _ := state.Commit(false)
+	db.TrieDB().Commit(root, false)
+
+	// Change the account in a later state, the proof of the old one must hold
+	state.SetStaking(addr, big.NewInt(0), big.NewInt(8))
+	later, _ := state.Commit(false)
+	db.TrieDB().Commit(later, false)
+
+	state, _ = New(root, db)
+	proof, err := state.GetProof(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proofDb := berithdb.NewMemDatabase()
+	for _, node := range proof {
+		proofDb.Put(crypto.Keccak256(node), node)
+	}
+	value, _, err := trie.VerifyProof(root, crypto.Keccak256(addr.Bytes()), proofDb)
+	if err != nil {
+		t.Fatalf("proof verification failed: %v", err)
+	}
+	var account Account
+	if err := rlp.DecodeBytes(value, &account); err != nil {
+		t.Fatal(err)
+	}
+	if account.StakeBalance.Cmp(big.NewInt(500)) != 0 || account.StakeUpdated.Cmp(big.NewInt(7)) != 0 || account.Point.Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("proven stake mismatch: %+v", account)
+	}
+	if len(account.BehindBalance) != 1 || account.BehindBalance[0].Balance.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("proven behind balance mismatch: %+v", account.BehindBalance)
+	}
+}
+
+// Tests that the RPC representation of an account encodes the vesting balances
+// with hex quantities like the other fields.
+func TestAccountJSON(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(berithdb.NewMemDatabase()))
+	addr := toAddr([]byte("vesting"))
+	state.AddVesting(addr, types.VestingSchedule{Start: 10, Cliff: 20, End: 30, Stakable: true}, big.NewInt(1000))
+
+	enc, err := json.Marshal(NewAccountJSON(state.GetAccount(addr)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"vesting":[{"start":"0xa","cliff":"0x14","end":"0x1e","stakable":true,"amount":"0x3e8","released":"0x0","staked":"0x0"}]`
+	if !bytes.Contains(enc, []byte(want)) {
+		t.Errorf("vesting encoding mismatch: have %s, want %s", enc, want)
+	}
+}
+
+// Tests that the state diff records the before and after values of the changed
+// account fields and storage slots, ignoring the reverted changes.
+func TestStateDiff(t *testing.T) {
+	db := NewDatabase(berithdb.NewMemDatabase())
+	state, _ := New(common.Hash{}, db)
+	staker, contract, gone := toAddr([]byte("staker")), toAddr([]byte("contract")), toAddr([]byte("gone"))
+
+	state.SetBalance(staker, big.NewInt(1000))
+	state.SetNonce(contract, 1)
+	state.SetState(contract, common.HexToHash("0x01"), common.HexToHash("0x11"))
+	state.SetBalance(gone, big.NewInt(5))
+	root, _ := state.Commit(false)
+
+	before, _ := New(root, db)
+	state, _ = New(root, db)
+	state.EnableStateDiff()
+
+	state.SetStaking(staker, big.NewInt(400), big.NewInt(3))
+	state.AddBehindBalance(staker, big.NewInt(3), big.NewInt(10))
+	state.SetState(contract, common.HexToHash("0x01"), common.HexToHash("0x12"))
+	state.Finalise(true)
+
+	snapshot := state.Snapshot()
+	state.SetState(contract, common.HexToHash("0x02"), common.HexToHash("0x22"))
+	state.AddBalance(toAddr([]byte("reverted")), big.NewInt(1))
+	state.RevertToSnapshot(snapshot)
+	state.Suicide(gone)
+	state.IntermediateRoot(true)
+
+	// The changes are tracked by the copies of the state as well
+	diff := state.Copy().StateDiff(before)
+	if len(diff.Accounts) != 3 {
+		t.Fatalf("changed accounts mismatch: have %d, want 3", len(diff.Accounts))
+	}
+	for _, account := range diff.Accounts {
+		switch account.Address {
+		case staker:
+			if account.Before.StakeBalance.Sign() != 0 || account.After.StakeBalance.Cmp(big.NewInt(400)) != 0 {
+				t.Errorf("stake diff mismatch: before %v, after %v", account.Before.StakeBalance, account.After.StakeBalance)
+			}
+			if len(account.After.BehindBalance) != 1 {
+				t.Errorf("behind balance diff mismatch: %v", account.After.BehindBalance)
+			}
+		case contract:
+			want := []StorageDiff{{Key: common.HexToHash("0x01"), Before: common.HexToHash("0x11"), After: common.HexToHash("0x12")}}
+			if len(account.Storage) != 1 || account.Storage[0] != want[0] {
+				t.Errorf("storage diff mismatch: have %v, want %v", account.Storage, want)
+			}
+		case gone:
+			if account.Before == nil || account.After != nil {
+				t.Errorf("destructed account diff mismatch: before %v, after %v", account.Before, account.After)
+			}
+		default:
+			t.Errorf("unexpected account %x in diff", account.Address)
+		}
+	}
+	// The accounts are encoded with their RPC representation
+	enc, err := json.Marshal(diff.Accounts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var account struct {
+		After json.RawMessage
+	}
+	if err := json.Unmarshal(enc, &account); err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := json.Marshal(NewAccountJSON(diff.Accounts[0].After)); !bytes.Equal(account.After, want) {
+		t.Errorf("account diff encoding mismatch: have %s, want %s", account.After, want)
+	}
+	// The diff survives the persistence
+	data, err := rlp.EncodeToBytes(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := new(StateDiff)
+	if err := rlp.DecodeBytes(data, dec); err != nil {
+		t.Fatal(err)
+	}
+	if len(dec.Accounts) != 3 || !accountEqual(dec.Accounts[0].After, diff.Accounts[0].After) {
+		t.Errorf("decoded diff mismatch")
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"sort"
 
 	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/state/snapshot"
 	"github.com/BerithFoundation/berith-chain/core/types"
 	"github.com/BerithFoundation/berith-chain/crypto"
 	"github.com/BerithFoundation/berith-chain/log"
@@ -60,6 +61,14 @@ type StateDB struct {
 	db   Database
 	trie Trie
 
+	// Flat snapshot serving the reads instead of the tries if available, and
+	// the changes collected for the snapshot of the committed state.
+	snaps         *snapshot.Tree
+	snap          snapshot.Snapshot
+	snapDestructs map[common.Hash]struct{}
+	snapAccounts  map[common.Hash][]byte
+	snapStorage   map[common.Hash]map[common.Hash][]byte
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects      map[common.Address]*stateObject
 	stateObjectsDirty map[common.Address]struct{}
@@ -86,24 +95,50 @@ type StateDB struct {
 	journal        *journal
 	validRevisions []revision
 	nextRevisionId int
+
+	// Accounts and storage slots touched since the state diff was enabled
+	diff *diffTracker
 }
 
 // Create a new state from a given trie.
 func New(root common.Hash, db Database) (*StateDB, error) {
+	return NewWithSnapshot(root, db, nil)
+}
+
+// NewWithSnapshot creates a new state from a given trie, reading the accounts
+// and the storage from the flat snapshot of the root if the tree has one. The
+// snapshot of the committed state is added to the tree.
+func NewWithSnapshot(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error) {
 	tr, err := db.OpenTrie(root)
 	if err != nil {
 		return nil, err
 	}
-
-	return &StateDB{
+	sdb := &StateDB{
 		db:                db,
 		trie:              tr,
+		snaps:             snaps,
 		stateObjects:      make(map[common.Address]*stateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		logs:              make(map[common.Hash][]*types.Log),
 		preimages:         make(map[common.Hash][]byte),
 		journal:           newJournal(),
-	}, nil
+	}
+	sdb.openSnapshot(root)
+	return sdb, nil
+}
+
+// openSnapshot selects the flat snapshot of the given root to serve the reads,
+// if the tree has one.
+func (self *StateDB) openSnapshot(root common.Hash) {
+	self.snap, self.snapDestructs, self.snapAccounts, self.snapStorage = nil, nil, nil, nil
+	if self.snaps == nil {
+		return
+	}
+	if self.snap = self.snaps.Snapshot(root); self.snap != nil {
+		self.snapDestructs = make(map[common.Hash]struct{})
+		self.snapAccounts = make(map[common.Hash][]byte)
+		self.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+	}
 }
 
 // setError remembers the first non-nil error it is called with.
@@ -133,6 +168,10 @@ func (self *StateDB) Reset(root common.Hash) error {
 	self.logs = make(map[common.Hash][]*types.Log)
 	self.logSize = 0
 	self.preimages = make(map[common.Hash][]byte)
+	self.openSnapshot(root)
+	if self.diff != nil {
+		self.diff = newDiffTracker()
+	}
 	self.clearJournalAndRefund()
 	return nil
 }
@@ -253,6 +292,24 @@ func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
 	return common.BytesToHash(stateObject.CodeHash())
 }
 
+// GetAccount returns a copy of the account of the given address, nil if it
+// doesn't exist.
+func (self *StateDB) GetAccount(addr common.Address) *Account {
+	stateObject := self.getStateObject(addr)
+	if stateObject == nil {
+		return nil
+	}
+	data, err := rlp.EncodeToBytes(&stateObject.data)
+	if err != nil {
+		panic(err) // Cannot happen, here to catch dev errors
+	}
+	account := new(Account)
+	if err := rlp.DecodeBytes(data, account); err != nil {
+		panic(err)
+	}
+	return account
+}
+
 // GetState retrieves a value from the given account's storage trie.
 func (self *StateDB) GetState(addr common.Address, hash common.Hash) common.Hash {
 	stateObject := self.getStateObject(addr)
@@ -436,6 +493,225 @@ func (self *StateDB) GetBehindBalance(addr common.Address) []Behind {
 	return []Behind{}
 }
 
+// [BERITH] GetMaturedReward returns the rewards of the behind balance paid at
+// or before the given block number.
+func (self *StateDB) GetMaturedReward(addr common.Address, number *big.Int) *big.Int {
+	stateObject := self.getStateObject(addr)
+	if stateObject != nil {
+		return stateObject.MaturedReward(number)
+	}
+	return common.Big0
+}
+
+// [BERITH] SubMaturedReward removes amount from the rewards of the behind
+// balance paid at or before the given block number, oldest first.
+func (self *StateDB) SubMaturedReward(addr common.Address, number, amount *big.Int) {
+	stateObject := self.getStateObject(addr)
+	if stateObject == nil || amount.Sign() == 0 {
+		return
+	}
+	stateObject.SubMaturedReward(number, amount)
+}
+
+// autoCompoundKey is the storage slot of an account holding its auto-compound
+// setting. Regular accounts have no storage, so the slot can't collide.
+var autoCompoundKey = crypto.Keccak256Hash([]byte("berith.autoCompound"))
+
+// [BERITH] GetAutoCompound returns whether the matured rewards of the account
+// are moved into its stake balance instead of its main balance.
+func (self *StateDB) GetAutoCompound(addr common.Address) bool {
+	return self.GetState(addr, autoCompoundKey) != (common.Hash{})
+}
+
+// [BERITH] SetAutoCompound enables or disables the compounding of the matured
+// rewards of the account into its stake balance.
+func (self *StateDB) SetAutoCompound(addr common.Address, enabled bool) {
+	var value common.Hash
+	if enabled {
+		value[common.HashLength-1] = 1
+	}
+	self.SetState(addr, autoCompoundKey, value)
+}
+
+// [BERITH] GetMultisig returns the configuration of a multisig account, nil for
+// other accounts.
+func (self *StateDB) GetMultisig(addr common.Address) *types.MultisigConfig {
+	stateObject := self.getStateObject(addr)
+	if stateObject != nil {
+		return stateObject.Multisig()
+	}
+	return nil
+}
+
+// [BERITH] SetMultisig turns the account into a multisig account with the given
+// configuration.
+func (self *StateDB) SetMultisig(addr common.Address, config *types.MultisigConfig) {
+	stateObject := self.GetOrNewStateObject(addr)
+	if stateObject != nil {
+		stateObject.SetMultisig(config)
+	}
+}
+
+// [BERITH] MigrateAccount moves the main, stake, behind and vesting balances of
+// an account to another one, together with its selection point, penalty and
+// auto-compound setting, so that the new account keeps its staking position.
+func (self *StateDB) MigrateAccount(from, to common.Address) {
+	src := self.getStateObject(from)
+	if src == nil {
+		return
+	}
+	dst := self.GetOrNewStateObject(to)
+
+	dst.AddBalance(src.Balance())
+	src.SetBalance(new(big.Int))
+
+	dst.SetStaking(new(big.Int).Add(dst.StakeBalance(), src.StakeBalance()), new(big.Int).Set(src.StakeUpdated()))
+	src.SetStaking(new(big.Int), new(big.Int).Set(src.StakeUpdated()))
+
+	dst.SetPoint(new(big.Int).Add(dst.GetPoint(), src.GetPoint()))
+	src.SetPoint(new(big.Int))
+
+	dst.SetBehindBalance(append(append([]Behind{}, dst.BehindBalance()...), src.BehindBalance()...))
+	src.SetBehindBalance(make([]Behind, 0))
+
+	dst.SetPenalty(src.Penalty(), src.PenaltyUpdated())
+	src.SetPenalty(0, src.PenaltyUpdated())
+
+	if self.GetAutoCompound(from) {
+		self.SetAutoCompound(to, true)
+		self.SetAutoCompound(from, false)
+	}
+	if vesting := src.Vesting(); len(vesting) > 0 {
+		dst.SetVesting(append(append([]Vesting{}, dst.Vesting()...), vesting...))
+		src.SetVesting(nil)
+	}
+}
+
+// [BERITH] GetVesting returns the balances of the account locked by vesting
+// schedules.
+func (self *StateDB) GetVesting(addr common.Address) []Vesting {
+	stateObject := self.getStateObject(addr)
+	if stateObject != nil {
+		return stateObject.Vesting()
+	}
+	return nil
+}
+
+// [BERITH] AddVesting locks amount for the account with the given schedule. The
+// amount isn't taken from any balance, the caller moves it.
+func (self *StateDB) AddVesting(addr common.Address, schedule types.VestingSchedule, amount *big.Int) {
+	stateObject := self.GetOrNewStateObject(addr)
+	if stateObject == nil {
+		return
+	}
+	vesting := append(append([]Vesting{}, stateObject.Vesting()...), Vesting{
+		VestingSchedule: schedule,
+		Amount:          new(big.Int).Set(amount),
+		Released:        new(big.Int),
+		Staked:          new(big.Int),
+	})
+	stateObject.SetVesting(vesting)
+}
+
+// [BERITH] GetStakableVesting returns the locked balance of the account which
+// can be moved into its stake balance.
+func (self *StateDB) GetStakableVesting(addr common.Address) *big.Int {
+	stakable := new(big.Int)
+	for _, v := range self.GetVesting(addr) {
+		if v.Stakable {
+			stakable.Add(stakable, new(big.Int).Sub(v.Locked(), v.Staked))
+		}
+	}
+	return stakable
+}
+
+// [BERITH] StakeVesting moves amount of the stakable locked balances of the
+// account into its stake balance, oldest schedules first. The balances stay
+// locked until they are released.
+func (self *StateDB) StakeVesting(addr common.Address, amount, blockNumber *big.Int) {
+	stateObject := self.getStateObject(addr)
+	if stateObject == nil || amount.Sign() == 0 {
+		return
+	}
+	var (
+		left    = new(big.Int).Set(amount)
+		vesting = make([]Vesting, 0, len(stateObject.Vesting()))
+	)
+	for _, v := range stateObject.Vesting() {
+		if v.Stakable && left.Sign() > 0 {
+			stake := new(big.Int).Sub(v.Locked(), v.Staked)
+			if stake.Cmp(left) > 0 {
+				stake.Set(left)
+			}
+			left.Sub(left, stake)
+			v = Vesting{v.VestingSchedule, v.Amount, v.Released, new(big.Int).Add(v.Staked, stake)}
+		}
+		vesting = append(vesting, v)
+	}
+	stateObject.SetVesting(vesting)
+	stateObject.AddStakeBalance(new(big.Int).Sub(amount, left), blockNumber)
+}
+
+// [BERITH] ReleaseVesting releases the balances of the account vested at the
+// given block number into its main balance. The balances are released when the
+// account is touched by a transaction, not block by block. The released part of
+// a locked balance moved into the stake balance stays staked.
+func (self *StateDB) ReleaseVesting(addr common.Address, number *big.Int) {
+	stateObject := self.getStateObject(addr)
+	if stateObject == nil || len(stateObject.Vesting()) == 0 {
+		return
+	}
+	vesting, free, changed := releaseVesting(stateObject.Vesting(), number.Uint64())
+	if changed {
+		stateObject.SetVesting(vesting)
+		stateObject.AddBalance(free)
+	}
+}
+
+// [BERITH] GetReleasableVesting returns the balance of the account which
+// ReleaseVesting would move into the main balance at the given block number.
+func (self *StateDB) GetReleasableVesting(addr common.Address, number *big.Int) *big.Int {
+	_, free, _ := releaseVesting(self.GetVesting(addr), number.Uint64())
+	return free
+}
+
+// [BERITH] GetVestingCount returns the number of vesting schedules locking
+// balances of the account.
+func (self *StateDB) GetVestingCount(addr common.Address) int {
+	return len(self.GetVesting(addr))
+}
+
+// releaseVesting releases the balances vested at the given block number. It
+// returns the schedules left locked, the balance to move into the main balance
+// and whether any schedule changed.
+func releaseVesting(schedules []Vesting, number uint64) ([]Vesting, *big.Int, bool) {
+	var (
+		vesting = make([]Vesting, 0, len(schedules))
+		free    = new(big.Int)
+		changed bool
+	)
+	for _, v := range schedules {
+		vested := v.Vested(v.Amount, number)
+		if release := new(big.Int).Sub(vested, v.Released); release.Sign() > 0 {
+			// The unstaked part of the locked balance is released first
+			unstaked := new(big.Int).Sub(v.Locked(), v.Staked)
+			staked := new(big.Int)
+			if release.Cmp(unstaked) > 0 {
+				staked.Sub(release, unstaked)
+				free.Add(free, unstaked)
+			} else {
+				free.Add(free, release)
+			}
+			v = Vesting{v.VestingSchedule, v.Amount, vested, new(big.Int).Sub(v.Staked, staked)}
+			changed = true
+		}
+		if v.Released.Cmp(v.Amount) < 0 {
+			vesting = append(vesting, v)
+		}
+	}
+	return vesting, free, changed
+}
+
 //[BERITH] Penalty
 func (self *StateDB) AddPenalty(addr common.Address, blockNumber *big.Int) {
 	stateObject := self.getStateObject(addr)
@@ -546,6 +822,10 @@ func (self *StateDB) updateStateObject(stateObject *stateObject) {
 		panic(fmt.Errorf("can't encode object at %x: %v", addr[:], err))
 	}
 	self.setError(self.trie.TryUpdate(addr[:], data))
+
+	if self.snap != nil {
+		self.snapAccounts[stateObject.addrHash] = data
+	}
 }
 
 // deleteStateObject removes the given object from the state trie.
@@ -553,6 +833,12 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 	stateObject.deleted = true
 	addr := stateObject.Address()
 	self.setError(self.trie.TryDelete(addr[:]))
+
+	if self.snap != nil {
+		self.snapDestructs[stateObject.addrHash] = struct{}{}
+		delete(self.snapAccounts, stateObject.addrHash)
+		delete(self.snapStorage, stateObject.addrHash)
+	}
 }
 
 // Retrieve a state object given by the address. Returns nil if not found.
@@ -565,8 +851,18 @@ func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObje
 		return obj
 	}
 
-	// Load the object from the database.
-	enc, err := self.trie.TryGet(addr[:])
+	// Load the object from the snapshot if available, falling back to the trie
+	// if the snapshot can't serve it
+	var (
+		enc []byte
+		err error
+	)
+	if self.snap != nil {
+		enc, err = self.snap.Account(crypto.Keccak256Hash(addr[:]))
+	}
+	if self.snap == nil || err != nil {
+		enc, err = self.trie.TryGet(addr[:])
+	}
 	if len(enc) == 0 {
 		self.setError(err)
 		return nil
@@ -599,12 +895,21 @@ func (self *StateDB) GetOrNewStateObject(addr common.Address) *stateObject {
 // the given address, it is overwritten and returned as the second return value.
 func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObject) {
 	prev = self.getStateObject(addr)
+
+	// The storage of an overwritten account is wiped from the snapshot
+	var prevdestruct bool
+	if self.snap != nil && prev != nil {
+		_, prevdestruct = self.snapDestructs[prev.addrHash]
+		if !prevdestruct {
+			self.snapDestructs[prev.addrHash] = struct{}{}
+		}
+	}
 	newobj = newObject(self, addr, Account{})
 	newobj.setNonce(0) // sets the object to dirty
 	if prev == nil {
 		self.journal.append(createObjectChange{account: &addr})
 	} else {
-		self.journal.append(resetObjectChange{prev: prev})
+		self.journal.append(resetObjectChange{prev: prev, prevdestruct: prevdestruct})
 	}
 	self.setStateObject(newobj)
 	return newobj, prev
@@ -650,6 +955,8 @@ func (self *StateDB) Copy() *StateDB {
 	state := &StateDB{
 		db:                self.db,
 		trie:              self.db.CopyTrie(self.trie),
+		snaps:             self.snaps,
+		snap:              self.snap,
 		stateObjects:      make(map[common.Address]*stateObject, len(self.journal.dirties)),
 		stateObjectsDirty: make(map[common.Address]struct{}, len(self.journal.dirties)),
 		refund:            self.refund,
@@ -689,6 +996,28 @@ func (self *StateDB) Copy() *StateDB {
 	for hash, preimage := range self.preimages {
 		state.preimages[hash] = preimage
 	}
+	if self.diff != nil {
+		state.diff = self.diff.copy()
+		state.journal.diff = state.diff
+	}
+	if self.snap != nil {
+		state.snapDestructs = make(map[common.Hash]struct{}, len(self.snapDestructs))
+		for hash := range self.snapDestructs {
+			state.snapDestructs[hash] = struct{}{}
+		}
+		state.snapAccounts = make(map[common.Hash][]byte, len(self.snapAccounts))
+		for hash, data := range self.snapAccounts {
+			state.snapAccounts[hash] = data
+		}
+		state.snapStorage = make(map[common.Hash]map[common.Hash][]byte, len(self.snapStorage))
+		for hash, storage := range self.snapStorage {
+			cpy := make(map[common.Hash][]byte, len(storage))
+			for key, data := range storage {
+				cpy[key] = data
+			}
+			state.snapStorage[hash] = cpy
+		}
+	}
 	return state
 }
 
@@ -766,6 +1095,7 @@ func (self *StateDB) Prepare(thash, bhash common.Hash, ti int) {
 
 func (s *StateDB) clearJournalAndRefund() {
 	s.journal = newJournal()
+	s.journal.diff = s.diff
 	s.validRevisions = s.validRevisions[:0]
 	s.refund = 0
 }
@@ -815,6 +1145,18 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (root common.Hash, err error)
 		}
 		return nil
 	})
+	// Add the changes as a new layer on top of the snapshot of the parent state,
+	// the state transition being skipped for empty blocks
+	if s.snap != nil {
+		if parent := s.snap.Root(); err == nil && parent != root {
+			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
+				log.Warn("Failed to update snapshot tree", "from", parent, "to", root, "err", err)
+			} else if err := s.snaps.Cap(root, s.snaps.Depth()); err != nil {
+				log.Warn("Failed to cap snapshot tree", "root", root, "layers", s.snaps.Depth(), "err", err)
+			}
+		}
+		s.snap, s.snapDestructs, s.snapAccounts, s.snapStorage = nil, nil, nil, nil
+	}
 	log.Debug("Trie cache stats after commit", "misses", trie.CacheMisses(), "unloads", trie.CacheUnloads())
 	return root, err
 }
@@ -27,10 +27,12 @@ import (
 	"strings"
 	"testing"
 	"testing/quick"
+	"time"
 
 	check "gopkg.in/check.v1"
 
 	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/state/snapshot"
 	"github.com/BerithFoundation/berith-chain/core/types"
 	"github.com/BerithFoundation/berith-chain/berithdb"
 )
@@ -435,3 +437,69 @@ func TestCopyOfCopy(t *testing.T) {
 		t.Fatalf("2nd copy fail, expected 42, got %v", got)
 	}
 }
+
+// Tests that the states read through the flat snapshot match the ones read
+// through the tries, including the Berith account fields and the storage of
+// destructed accounts.
+func TestFlatSnapshotReads(t *testing.T) {
+	var (
+		diskdb = berithdb.NewMemDatabase()
+		sdb    = NewDatabase(diskdb)
+		addrs  = []common.Address{{1}, {2}, {3}}
+	)
+	state, _ := New(common.Hash{}, sdb)
+	for i, addr := range addrs {
+		state.AddBalance(addr, big.NewInt(int64(100*(i+1))))
+		state.AddStakeBalance(addr, big.NewInt(int64(10*(i+1))), big.NewInt(1))
+		state.SetPoint(addr, big.NewInt(int64(i+1)))
+		state.SetState(addr, common.Hash{1}, common.Hash{byte(i + 1)})
+	}
+	root, _ := state.Commit(false)
+	sdb.TrieDB().Commit(root, false)
+
+	snaps := snapshot.New(diskdb, sdb.TrieDB(), root, 8)
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := snapshot.Verify(diskdb, sdb.TrieDB()); err == nil {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("snapshot not generated in time")
+		}
+	}
+	// Change the state on top of the snapshot, destructing an account with storage
+	state, _ = NewWithSnapshot(root, sdb, snaps)
+	state.AddStakeBalance(addrs[0], big.NewInt(5), big.NewInt(2))
+	state.AddPoint(addrs[1], big.NewInt(7))
+	state.SetState(addrs[1], common.Hash{2}, common.Hash{9})
+	state.Suicide(addrs[2])
+	state.Finalise(false)
+	state.CreateAccount(addrs[2])
+	state.AddBalance(addrs[2], big.NewInt(1))
+
+	root, _ = state.Commit(false)
+	sdb.TrieDB().Commit(root, false)
+	if snaps.Snapshot(root) == nil {
+		t.Fatalf("snapshot of the committed state missing")
+	}
+	flat, _ := NewWithSnapshot(root, sdb, snaps)
+	tries, _ := New(root, sdb)
+	for _, addr := range addrs {
+		if have, want := flat.GetBalance(addr), tries.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("%x: balance mismatch: have %v, want %v", addr, have, want)
+		}
+		if have, want := flat.GetStakeBalance(addr), tries.GetStakeBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("%x: stake balance mismatch: have %v, want %v", addr, have, want)
+		}
+		if have, want := flat.GetPoint(addr), tries.GetPoint(addr); have.Cmp(want) != 0 {
+			t.Errorf("%x: point mismatch: have %v, want %v", addr, have, want)
+		}
+		for _, key := range []common.Hash{{1}, {2}} {
+			if have, want := flat.GetState(addr, key), tries.GetState(addr, key); have != want {
+				t.Errorf("%x: storage %x mismatch: have %x, want %x", addr, key, have, want)
+			}
+		}
+	}
+	if value := flat.GetState(addrs[2], common.Hash{1}); value != (common.Hash{}) {
+		t.Errorf("storage of the destructed account not wiped: %x", value)
+	}
+}
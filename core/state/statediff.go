@@ -0,0 +1,147 @@
+/*
+[BERITH]
+블록이 변경한 account 필드 (stake, point, behind balance 포함) 와 storage slot 의
+이전 값과 이후 값을 기록한다. 로그를 남기지 않는 BSRR 의 변경도 journal 을 거치므로
+모두 기록된다.
+*/
+
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/rlp"
+)
+
+// StateDiff is the set of the accounts and storage slots changed by a block,
+// with their values before and after it.
+type StateDiff struct {
+	Accounts []*AccountDiff `json:"accounts"`
+}
+
+// AccountDiff is the change of a single account. A nil account means that it
+// doesn't exist on that side of the change.
+type AccountDiff struct {
+	Address common.Address
+	Before  *Account `rlp:"nil"`
+	After   *Account `rlp:"nil"`
+	Storage []StorageDiff
+}
+
+// StorageDiff is the change of a single storage slot of an account.
+type StorageDiff struct {
+	Key    common.Hash `json:"key"`
+	Before common.Hash `json:"before"`
+	After  common.Hash `json:"after"`
+}
+
+// diffTracker records the accounts and storage slots touched by the entries
+// appended to the journal. Reverted entries stay recorded, the values being
+// compared when the diff is built.
+type diffTracker struct {
+	touched map[common.Address]map[common.Hash]struct{}
+}
+
+func newDiffTracker() *diffTracker {
+	return &diffTracker{touched: make(map[common.Address]map[common.Hash]struct{})}
+}
+
+// copy returns an independent copy of the tracker.
+func (t *diffTracker) copy() *diffTracker {
+	cpy := newDiffTracker()
+	for addr, slots := range t.touched {
+		cpy.touched[addr] = make(map[common.Hash]struct{}, len(slots))
+		for key := range slots {
+			cpy.touched[addr][key] = struct{}{}
+		}
+	}
+	return cpy
+}
+
+// touch records the account and the storage slot modified by a journal entry.
+func (t *diffTracker) touch(addr common.Address, entry journalEntry) {
+	slots, ok := t.touched[addr]
+	if !ok {
+		slots = make(map[common.Hash]struct{})
+		t.touched[addr] = slots
+	}
+	if change, ok := entry.(storageChange); ok {
+		slots[change.key] = struct{}{}
+	}
+}
+
+// EnableStateDiff starts recording the accounts and storage slots modified in
+// the state, so that the changes can be retrieved with StateDiff.
+func (self *StateDB) EnableStateDiff() {
+	self.diff = newDiffTracker()
+	self.journal.diff = self.diff
+}
+
+// StateDiffEnabled returns whether the state records its changes.
+func (self *StateDB) StateDiffEnabled() bool {
+	return self.diff != nil
+}
+
+// StateDiff returns the changes of the modified accounts and storage slots since
+// the state diff was enabled, compared with the given state before them. The
+// state should be finalised, so that the storage roots are up to date.
+func (self *StateDB) StateDiff(before *StateDB) *StateDiff {
+	diff := &StateDiff{Accounts: []*AccountDiff{}}
+	if self.diff == nil {
+		return diff
+	}
+	addrs := make([]common.Address, 0, len(self.diff.touched))
+	for addr := range self.diff.touched {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+
+	for _, addr := range addrs {
+		account := &AccountDiff{
+			Address: addr,
+			Before:  before.GetAccount(addr),
+			After:   self.GetAccount(addr),
+			Storage: []StorageDiff{},
+		}
+		keys := make([]common.Hash, 0, len(self.diff.touched[addr]))
+		for key := range self.diff.touched[addr] {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+
+		for _, key := range keys {
+			slot := StorageDiff{Key: key, Before: before.GetState(addr, key), After: self.GetState(addr, key)}
+			if slot.Before != slot.After {
+				account.Storage = append(account.Storage, slot)
+			}
+		}
+		if len(account.Storage) == 0 && accountEqual(account.Before, account.After) {
+			continue
+		}
+		diff.Accounts = append(diff.Accounts, account)
+	}
+	return diff
+}
+
+// accountEqual returns whether two accounts have the same encoding.
+func accountEqual(a, b *Account) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	encA, _ := rlp.EncodeToBytes(a)
+	encB, _ := rlp.EncodeToBytes(b)
+	return bytes.Equal(encA, encB)
+}
+
+// MarshalJSON encodes the accounts of the diff with their RPC representation.
+func (d *AccountDiff) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Address common.Address `json:"address"`
+		Before  *AccountJSON   `json:"before"`
+		After   *AccountJSON   `json:"after"`
+		Storage []StorageDiff  `json:"storage"`
+	}{d.Address, NewAccountJSON(d.Before), NewAccountJSON(d.After), d.Storage})
+}
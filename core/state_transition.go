@@ -28,6 +28,7 @@ import (
 	"github.com/BerithFoundation/berith-chain/core/vm"
 	"github.com/BerithFoundation/berith-chain/log"
 	"github.com/BerithFoundation/berith-chain/params"
+	"github.com/BerithFoundation/berith-chain/rlp"
 )
 
 var (
@@ -80,6 +81,10 @@ type Message interface {
 	Nonce() uint64
 	CheckNonce() bool
 	Data() []byte
+
+	// Approvers returns the owners who signed the transaction of a multisig
+	// account, nil for other messages.
+	Approvers() []common.Address
 }
 
 // IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
@@ -182,6 +187,19 @@ func (st *StateTransition) preCheck() error {
 			return ErrNonceTooLow
 		}
 	}
+	// Make sure enough owners of a multisig account approved its transaction
+	if approvers := st.msg.Approvers(); approvers != nil {
+		if !st.evm.ChainConfig().IsBIP7(st.evm.BlockNumber) {
+			return types.ErrNotMultisig
+		}
+		config := st.state.GetMultisig(st.msg.From())
+		if config == nil {
+			return types.ErrNotMultisig
+		}
+		if err := config.Verify(approvers); err != nil {
+			return err
+		}
+	}
 	return st.buyGas()
 }
 
@@ -190,6 +208,11 @@ func (st *StateTransition) preCheck() error {
 // An error indicates a consensus issue.
 //func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bool, err error) {
 func (st *StateTransition) TransitionDb(base types.JobWallet, target types.JobWallet) (ret []byte, usedGas uint64, failed bool, err error) {
+	// [BERITH] The vested balances of the sender are released before it pays for
+	// the gas
+	if st.evm.ChainConfig().IsBIP9(st.evm.BlockNumber) {
+		st.state.ReleaseVesting(st.msg.From(), st.evm.BlockNumber)
+	}
 	if err = st.preCheck(); err != nil {
 		return
 	}
@@ -202,15 +225,41 @@ func (st *StateTransition) TransitionDb(base types.JobWallet, target types.JobWa
 		return nil, 0, false, err
 	}
 
-	if !contractCreation && (base == types.Stake || target == types.Stake) && bytes.Compare(sender.Address().Bytes(), msg.To().Bytes()) != 0 {
+	if (base == types.Reward || target == types.Reward) && (contractCreation || !st.evm.ChainConfig().IsBIP5(st.evm.BlockNumber)) {
+		return nil, 0, false, types.ErrInvalidJobWallet
+	}
+
+	if (base == types.Vesting || target == types.Vesting) && (contractCreation || !st.evm.ChainConfig().IsBIP9(st.evm.BlockNumber)) {
+		return nil, 0, false, types.ErrInvalidJobWallet
+	}
+
+	if !contractCreation && (base == types.Stake || target == types.Stake || base == types.Reward) && bytes.Compare(sender.Address().Bytes(), msg.To().Bytes()) != 0 {
 		return nil, 0, false, ErrInvalidStakeReceiver
 	}
 
+	// [BERITH] An invalid key migration must not be included, since the staking
+	// list follows the migrations of the block without executing them.
+	migration := types.IsKeyMigration(st.evm.ChainConfig(), st.evm.BlockNumber, msg.To(), base, target)
+	var migrateTo common.Address
+	if migration {
+		migrateTo, err = ValidateKeyMigration(st.state, st.evm.ChainConfig().ChainID, st.evm.BlockNumber, msg.From(), st.value, st.data)
+		if err != nil {
+			return nil, 0, false, err
+		}
+	}
+
 	// Pay intrinsic gas
 	gas, err := IntrinsicGas(st.data, contractCreation, homestead)
 	if err != nil {
 		return nil, 0, false, err
 	}
+	gas += uint64(len(msg.Approvers())) * params.TxMultisigApprovalGas
+	if migration {
+		gas += params.KeyMigrationGas
+	}
+	if target == types.Vesting {
+		gas += params.VestingSetupGas
+	}
 	if err = st.useGas(gas); err != nil {
 		return nil, 0, false, err
 	}
@@ -229,8 +278,18 @@ func (st *StateTransition) TransitionDb(base types.JobWallet, target types.JobWa
 		st.state.SetNonce(msg.From(), st.state.GetNonce(sender.Address())+1)
 		//ret, st.gas, vmerr = evm.Call(sender, st.to(), st.data, st.gas, st.value)
 
-		// [BERITH] staking value false
-		ret, st.gas, vmerr = evm.Call(sender, st.to(), st.data, st.gas, st.value, base, target)
+		if base == types.Reward {
+			vmerr = st.moveReward(target)
+		} else if base == types.Vesting {
+			vmerr = st.stakeVesting()
+		} else if target == types.Vesting {
+			vmerr = st.lockVesting()
+		} else if IsMultisigSetup(st.evm.ChainConfig(), st.evm.BlockNumber, msg.To(), base, target) {
+			vmerr = st.setupMultisig()
+		} else if !migration {
+			// [BERITH] staking value false
+			ret, st.gas, vmerr = evm.Call(sender, st.to(), st.data, st.gas, st.value, base, target)
+		}
 	}
 	if vmerr != nil {
 		log.Debug("VM returned with error", "err", vmerr)
@@ -245,9 +304,145 @@ func (st *StateTransition) TransitionDb(base types.JobWallet, target types.JobWa
 	// [BERITH] Gas Fee
 	st.state.AddBalance(st.evm.Coinbase, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice))
 
+	// [BERITH] The account is migrated last to move the refunded gas along
+	if migration {
+		st.state.MigrateAccount(msg.From(), migrateTo)
+	}
+
 	return ret, st.gasUsed(), vmerr != nil, err
 }
 
+// moveReward applies a Reward wallet transaction of the sender. Reward -> Main
+// withdraws matured rewards into the main balance, Reward -> Stake compounds
+// them into the stake balance and Reward -> Reward switches auto-compounding on
+// (non-zero value) or off.
+func (st *StateTransition) moveReward(target types.JobWallet) error {
+	from, number := st.msg.From(), st.evm.BlockNumber
+	if target == types.Reward {
+		st.state.SetAutoCompound(from, st.value.Sign() > 0)
+		return nil
+	}
+	matured := MaturedRewardNumber(st.evm.ChainConfig(), number)
+	if st.state.GetMaturedReward(from, matured).Cmp(st.value) < 0 {
+		return vm.ErrInsufficientBalance
+	}
+	st.state.SubMaturedReward(from, matured, st.value)
+
+	switch target {
+	case types.Main:
+		st.state.AddBalance(from, st.value)
+	case types.Stake:
+		st.state.AddStakeBalance(from, st.value, number)
+	}
+	return nil
+}
+
+// lockVesting applies a Main -> Vesting transaction, locking its value for the
+// recipient with the schedule of the payload.
+func (st *StateTransition) lockVesting() error {
+	var schedule types.VestingSchedule
+	if err := rlp.DecodeBytes(st.data, &schedule); err != nil {
+		return types.ErrInvalidVesting
+	}
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	if st.value.Cmp(params.MinVestingAmount) < 0 {
+		return types.ErrVestingAmountTooLow
+	}
+	// The schedules released already don't count against the limit
+	to := st.to()
+	st.state.ReleaseVesting(to, st.evm.BlockNumber)
+	if st.state.GetVestingCount(to) >= params.MaxVestingSchedules {
+		return types.ErrTooManyVesting
+	}
+	from := st.msg.From()
+	if st.state.GetBalance(from).Cmp(st.value) < 0 {
+		return vm.ErrInsufficientBalance
+	}
+	st.state.SubBalance(from, st.value)
+	st.state.AddVesting(to, schedule, st.value)
+	return nil
+}
+
+// stakeVesting applies a Vesting -> Stake transaction, moving stakable locked
+// balances of the sender into its stake balance.
+func (st *StateTransition) stakeVesting() error {
+	from := st.msg.From()
+	if st.state.GetStakableVesting(from).Cmp(st.value) < 0 {
+		return vm.ErrInsufficientBalance
+	}
+	st.state.StakeVesting(from, st.value, st.evm.BlockNumber)
+	return nil
+}
+
+// IsMultisigSetup returns whether a transaction with the given recipient and
+// JobWallet fields creates a multisig account at the given block number.
+func IsMultisigSetup(config *params.ChainConfig, number *big.Int, to *common.Address, base, target types.JobWallet) bool {
+	return to != nil && *to == types.MultisigSetupAddress && base == types.Main && target == types.Main && config.IsBIP7(number)
+}
+
+// ValidateKeyMigration checks a transaction migrating the from account at the
+// given block number and returns the address of the new key. The new account
+// must not have been used, apart from receiving transfers, so that the staking
+// position of the migrated account is kept as is and its vesting schedules stay
+// within the limit.
+func ValidateKeyMigration(state vm.StateDB, chainId, number *big.Int, from common.Address, value *big.Int, payload []byte) (common.Address, error) {
+	if value.Sign() != 0 || state.GetMultisig(from) != nil {
+		return common.Address{}, types.ErrInvalidKeyMigration
+	}
+	to, err := types.KeyMigrationTarget(chainId, from, payload)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if state.GetNonce(to) != 0 || state.GetCodeSize(to) != 0 || state.GetMultisig(to) != nil ||
+		state.GetStakeBalance(to).Sign() != 0 || state.GetPoint(to).Sign() != 0 ||
+		state.GetMaturedReward(to, number).Sign() != 0 || state.GetPenalty(to) != 0 || state.GetVestingCount(to) != 0 {
+		return common.Address{}, ErrKeyMigrationTargetUsed
+	}
+	return to, nil
+}
+
+// setupMultisig applies a multisig setup transaction, creating a multisig account
+// with the configuration of the payload and moving the value into it.
+func (st *StateTransition) setupMultisig() error {
+	if st.gas < params.MultisigSetupGas {
+		st.gas = 0
+		return vm.ErrOutOfGas
+	}
+	st.gas -= params.MultisigSetupGas
+
+	var config types.MultisigConfig
+	if err := rlp.DecodeBytes(st.data, &config); err != nil {
+		return types.ErrInvalidMultisig
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	from := st.msg.From()
+	account := types.MultisigAddress(from, st.msg.Nonce())
+	if st.state.GetMultisig(account) != nil || st.state.GetNonce(account) != 0 || st.state.GetCodeSize(account) != 0 {
+		return vm.ErrContractAddressCollision
+	}
+	if st.state.GetBalance(from).Cmp(st.value) < 0 {
+		return vm.ErrInsufficientBalance
+	}
+	st.state.SetMultisig(account, &config)
+	st.state.SubBalance(from, st.value)
+	st.state.AddBalance(account, st.value)
+	return nil
+}
+
+// MaturedRewardNumber returns the last block whose rewards are matured at the
+// given block number, rewards being held in the behind balance for an epoch.
+func MaturedRewardNumber(config *params.ChainConfig, number *big.Int) *big.Int {
+	var epoch uint64
+	if config.Bsrr != nil {
+		epoch = config.Bsrr.Epoch
+	}
+	return new(big.Int).Sub(number, new(big.Int).SetUint64(epoch))
+}
+
 func (st *StateTransition) refundGas() {
 	// Apply refund counter, capped to half of the used gas.
 	refund := st.gasUsed() / 2
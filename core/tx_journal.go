@@ -40,23 +40,55 @@ type devNull struct{}
 func (*devNull) Write(p []byte) (n int, err error) { return len(p), nil }
 func (*devNull) Close() error                      { return nil }
 
+// TxJournalStats describes the state of the transaction pool persistence.
+type TxJournalStats struct {
+	Backend  string // Name of the persistence backend ("file" or "db")
+	Entries  int    // Number of transactions currently held by the journal
+	Loaded   int    // Number of journaled transactions found on startup
+	Dropped  int    // Number of journaled transactions rejected on startup
+	Inserted uint64 // Number of transactions journaled since startup
+	Rotated  uint64 // Number of journal rotations since startup
+}
+
+// txStore is a persistence backend of the transaction pool, allowing pooled
+// transactions to survive node restarts.
+type txStore interface {
+	// load injects the persisted transactions into the pool using add.
+	load(add func(txs []*types.Transaction, local bool) []error) error
+
+	// insert persists a single transaction accepted by the pool.
+	insert(tx *types.Transaction, local bool) error
+
+	// rotate regenerates the persisted contents from the given pool contents.
+	rotate(local, remote map[common.Address]types.Transactions) error
+
+	// stats retrieves the persistence statistics.
+	stats() TxJournalStats
+
+	// close flushes and releases the backend.
+	close() error
+}
+
 // txJournal is a rotating log of transactions with the aim of storing locally
 // created transactions to allow non-executed ones to survive node restarts.
 type txJournal struct {
 	path   string         // Filesystem path to store the transactions at
 	writer io.WriteCloser // Output stream to write new transactions into
+
+	status TxJournalStats // Persistence statistics reported by txpool_status
 }
 
 // newTxJournal creates a new transaction journal to
 func newTxJournal(path string) *txJournal {
 	return &txJournal{
-		path: path,
+		path:   path,
+		status: TxJournalStats{Backend: "file"},
 	}
 }
 
 // load parses a transaction journal dump from disk, loading its contents into
 // the specified pool.
-func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
+func (journal *txJournal) load(add func([]*types.Transaction, bool) []error) error {
 	// Skip the parsing if the journal file doesn't exist at all
 	if _, err := os.Stat(journal.path); os.IsNotExist(err) {
 		return nil
@@ -80,7 +112,7 @@ func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
 	// appropriate progress counters. Then use this method to load all the
 	// journaled transactions in small-ish batches.
 	loadBatch := func(txs types.Transactions) {
-		for _, err := range add(txs) {
+		for _, err := range add(txs, true) {
 			if err != nil {
 				log.Debug("Failed to add journaled transaction", "err", err)
 				dropped++
@@ -112,24 +144,31 @@ func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
 		}
 	}
 	log.Info("Loaded local transaction journal", "transactions", total, "dropped", dropped)
+	journal.status.Loaded, journal.status.Dropped = total, dropped
 
 	return failure
 }
 
-// insert adds the specified transaction to the local disk journal.
-func (journal *txJournal) insert(tx *types.Transaction) error {
+// insert adds the specified transaction to the local disk journal. Remote
+// transactions are not tracked by the file journal.
+func (journal *txJournal) insert(tx *types.Transaction, local bool) error {
+	if !local {
+		return nil
+	}
 	if journal.writer == nil {
 		return errNoActiveJournal
 	}
 	if err := rlp.Encode(journal.writer, tx); err != nil {
 		return err
 	}
+	journal.status.Entries++
+	journal.status.Inserted++
 	return nil
 }
 
-// rotate regenerates the transaction journal based on the current contents of
-// the transaction pool.
-func (journal *txJournal) rotate(all map[common.Address]types.Transactions) error {
+// rotate regenerates the transaction journal based on the current local
+// contents of the transaction pool.
+func (journal *txJournal) rotate(all, _ map[common.Address]types.Transactions) error {
 	// Close the current journal (if any is open)
 	if journal.writer != nil {
 		if err := journal.writer.Close(); err != nil {
@@ -163,11 +202,18 @@ func (journal *txJournal) rotate(all map[common.Address]types.Transactions) erro
 		return err
 	}
 	journal.writer = sink
+	journal.status.Entries = journaled
+	journal.status.Rotated++
 	log.Info("Regenerated local transaction journal", "transactions", journaled, "accounts", len(all))
 
 	return nil
 }
 
+// stats retrieves the persistence statistics of the journal.
+func (journal *txJournal) stats() TxJournalStats {
+	return journal.status
+}
+
 // close flushes the transaction journal contents to disk and closes the file.
 func (journal *txJournal) close() error {
 	var err error
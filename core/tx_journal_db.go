@@ -0,0 +1,163 @@
+package core
+
+import (
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/log"
+)
+
+// compacter is implemented by databases able to compact a key range, which is
+// used to reclaim the space of rotated journal entries.
+type compacter interface {
+	Compact(start []byte, limit []byte) error
+}
+
+// txDBJournal persists the whole transaction pool, local and remote, pending
+// and queued, into the node database. Transactions are appended under an ever
+// increasing sequence number and the range of live entries is tracked under a
+// single key, so the journal can be replayed without database iteration.
+// Rotation rewrites the current pool contents and drops the old entries in a
+// single atomic batch.
+type txDBJournal struct {
+	db          berithdb.Database
+	start, next uint64 // Sequence range [start, next) of the live entries
+	loading     bool   // Whether the journal is being replayed into the pool
+
+	status TxJournalStats // Persistence statistics reported by txpool_status
+}
+
+// newTxDBJournal creates a transaction pool journal backed by the given database.
+func newTxDBJournal(db berithdb.Database) *txDBJournal {
+	start, next := rawdb.ReadTxPoolJournalRange(db)
+	return &txDBJournal{
+		db:     db,
+		start:  start,
+		next:   next,
+		status: TxJournalStats{Backend: "db", Entries: int(next - start)},
+	}
+}
+
+// load reads the persisted pool transactions in their original order and
+// injects them into the pool, which re-validates them against the current state.
+func (journal *txDBJournal) load(add func([]*types.Transaction, bool) []error) error {
+	journal.loading = true
+	defer func() { journal.loading = false }()
+
+	var (
+		total, dropped int
+		batch          types.Transactions
+		batchLocal     bool
+	)
+	loadBatch := func() {
+		for _, err := range add(batch, batchLocal) {
+			if err != nil {
+				log.Debug("Failed to add journaled transaction", "err", err)
+				dropped++
+			}
+		}
+		batch = batch[:0]
+	}
+	for seq := journal.start; seq < journal.next; seq++ {
+		entry, err := rawdb.ReadTxPoolEntry(journal.db, seq)
+		if err != nil {
+			log.Debug("Skipping unreadable journaled transaction", "seq", seq, "err", err)
+			continue
+		}
+		total++
+
+		if batch.Len() > 0 && (entry.Local != batchLocal || batch.Len() >= 1024) {
+			loadBatch()
+		}
+		batch, batchLocal = append(batch, entry.Tx), entry.Local
+	}
+	if batch.Len() > 0 {
+		loadBatch()
+	}
+	log.Info("Loaded transaction pool journal", "transactions", total, "dropped", dropped)
+	journal.status.Loaded, journal.status.Dropped = total, dropped
+
+	return nil
+}
+
+// insert appends the specified transaction to the database journal.
+func (journal *txDBJournal) insert(tx *types.Transaction, local bool) error {
+	if journal.loading {
+		return nil
+	}
+	batch := journal.db.NewBatch()
+	if err := rawdb.WriteTxPoolEntry(batch, journal.next, &rawdb.TxPoolEntry{Tx: tx, Local: local}); err != nil {
+		return err
+	}
+	if err := rawdb.WriteTxPoolJournalRange(batch, journal.start, journal.next+1); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	journal.next++
+	journal.status.Entries++
+	journal.status.Inserted++
+	return nil
+}
+
+// rotate regenerates the database journal from the current pool contents,
+// dropping the entries of transactions that left the pool, and compacts the
+// freed key range.
+func (journal *txDBJournal) rotate(local, remote map[common.Address]types.Transactions) error {
+	var (
+		batch = journal.db.NewBatch()
+		seq   = journal.next
+	)
+	write := func(all map[common.Address]types.Transactions, isLocal bool) error {
+		for _, txs := range all {
+			for _, tx := range txs {
+				if err := rawdb.WriteTxPoolEntry(batch, seq, &rawdb.TxPoolEntry{Tx: tx, Local: isLocal}); err != nil {
+					return err
+				}
+				seq++
+			}
+		}
+		return nil
+	}
+	if err := write(local, true); err != nil {
+		return err
+	}
+	if err := write(remote, false); err != nil {
+		return err
+	}
+	for i := journal.start; i < journal.next; i++ {
+		if err := rawdb.DeleteTxPoolEntry(batch, i); err != nil {
+			return err
+		}
+	}
+	if err := rawdb.WriteTxPoolJournalRange(batch, journal.next, seq); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	journal.start, journal.next = journal.next, seq
+	journal.status.Entries = int(seq - journal.start)
+	journal.status.Rotated++
+
+	if db, ok := journal.db.(compacter); ok {
+		if err := db.Compact(rawdb.TxPoolEntryKeyRange()); err != nil {
+			log.Warn("Failed to compact transaction pool journal", "err", err)
+		}
+	}
+	log.Info("Regenerated transaction pool journal", "transactions", journal.status.Entries, "locals", len(local), "remotes", len(remote))
+
+	return nil
+}
+
+// stats retrieves the persistence statistics of the journal.
+func (journal *txDBJournal) stats() TxJournalStats {
+	return journal.status
+}
+
+// close is a noop, the database is owned and closed by the node.
+func (journal *txDBJournal) close() error {
+	return nil
+}
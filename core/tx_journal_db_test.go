@@ -0,0 +1,77 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/types"
+)
+
+func TestTxDBJournal(t *testing.T) {
+	var (
+		db     = berithdb.NewMemDatabase()
+		local  = common.HexToAddress("0x0000000000000000000000000000000000000001")
+		remote = common.HexToAddress("0x0000000000000000000000000000000000000002")
+		txs    = make([]*types.Transaction, 4)
+	)
+	for i := range txs {
+		txs[i] = types.NewTransaction(uint64(i), remote, big.NewInt(1), 21000, big.NewInt(1), nil, types.Main, types.Stake)
+	}
+
+	journal := newTxDBJournal(db)
+	for i, tx := range txs[:3] {
+		if err := journal.insert(tx, i == 0); err != nil {
+			t.Fatalf("failed to insert tx %d: %v", i, err)
+		}
+	}
+
+	// Reopen the journal and check that everything is replayed in order
+	type added struct {
+		hash  common.Hash
+		local bool
+	}
+	var replayed []added
+	load := func(txs []*types.Transaction, local bool) []error {
+		for _, tx := range txs {
+			replayed = append(replayed, added{tx.Hash(), local})
+		}
+		return make([]error, len(txs))
+	}
+	journal = newTxDBJournal(db)
+	if err := journal.load(load); err != nil {
+		t.Fatalf("failed to load journal: %v", err)
+	}
+	if len(replayed) != 3 {
+		t.Fatalf("replayed transaction count mismatch: have %d, want %d", len(replayed), 3)
+	}
+	for i, r := range replayed {
+		if r.hash != txs[i].Hash() || r.local != (i == 0) {
+			t.Errorf("replayed tx %d mismatch: have %x/%v", i, r.hash, r.local)
+		}
+	}
+
+	// Rotate with a pool that dropped all but one transaction and added another
+	err := journal.rotate(
+		map[common.Address]types.Transactions{local: {txs[0]}},
+		map[common.Address]types.Transactions{remote: {txs[3]}},
+	)
+	if err != nil {
+		t.Fatalf("failed to rotate journal: %v", err)
+	}
+	if stats := journal.stats(); stats.Entries != 2 || stats.Loaded != 3 || stats.Rotated != 1 {
+		t.Errorf("stats mismatch: %+v", stats)
+	}
+	if db.Len() != 3 { // two entries and the range marker
+		t.Errorf("stale entries left in database: have %d keys, want %d", db.Len(), 3)
+	}
+
+	replayed = replayed[:0]
+	if err := newTxDBJournal(db).load(load); err != nil {
+		t.Fatalf("failed to load journal: %v", err)
+	}
+	if len(replayed) != 2 || replayed[0].hash != txs[0].Hash() || !replayed[0].local || replayed[1].hash != txs[3].Hash() || replayed[1].local {
+		t.Errorf("rotated journal mismatch: %v", replayed)
+	}
+}
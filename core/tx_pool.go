@@ -26,6 +26,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/BerithFoundation/berith-chain/berithdb"
 	"github.com/BerithFoundation/berith-chain/common"
 	"github.com/BerithFoundation/berith-chain/common/prque"
 	"github.com/BerithFoundation/berith-chain/core/state"
@@ -34,6 +35,7 @@ import (
 	"github.com/BerithFoundation/berith-chain/log"
 	"github.com/BerithFoundation/berith-chain/metrics"
 	"github.com/BerithFoundation/berith-chain/params"
+	"github.com/BerithFoundation/berith-chain/rlp"
 )
 
 const (
@@ -132,6 +134,8 @@ type TxPoolConfig struct {
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
 
+	JournalRemotes bool // Persist the whole pool (local and remote) into the node database instead of the local journal
+
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
@@ -203,7 +207,7 @@ type TxPool struct {
 	currentMaxGas uint64              // Current gas limit for transaction caps
 
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *txJournal  // Journal of local transaction to back up to disk
+	journal txStore     // Journal of pooled transactions to back up to disk
 
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
@@ -217,8 +221,9 @@ type TxPool struct {
 }
 
 // NewTxPool creates a new transaction pool to gather, sort and filter inbound
-// transactions from the network.
-func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain blockChain) *TxPool {
+// transactions from the network. The database is only used to persist the
+// pool if JournalRemotes is configured, it may be nil otherwise.
+func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain blockChain, db berithdb.Database) *TxPool {
 	// Sanitize the input to ensure no vulnerable gas prices are set
 	config = (&config).sanitize()
 
@@ -227,7 +232,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		config:      config,
 		chainconfig: chainconfig,
 		chain:       chain,
-		signer:      types.NewEIP155Signer(chainconfig.ChainID),
+		signer:      types.NewBIP7Signer(chainconfig.ChainID),
 		pending:     make(map[common.Address]*txList),
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
@@ -243,14 +248,19 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 	pool.priced = newTxPricedList(pool.all)
 	pool.reset(nil, chain.CurrentBlock().Header())
 
-	// If local transactions and journaling is enabled, load from disk
-	if !config.NoLocals && config.Journal != "" {
+	// If the whole pool is persisted, or local transactions and journaling are
+	// enabled, load from disk
+	switch {
+	case config.JournalRemotes && db != nil:
+		pool.journal = newTxDBJournal(db)
+	case !config.NoLocals && config.Journal != "":
 		pool.journal = newTxJournal(config.Journal)
-
-		if err := pool.journal.load(pool.AddLocals); err != nil {
+	}
+	if pool.journal != nil {
+		if err := pool.journal.load(pool.addJournaled); err != nil {
 			log.Warn("Failed to load transaction journal", "err", err)
 		}
-		if err := pool.journal.rotate(pool.local()); err != nil {
+		if err := pool.journal.rotate(pool.local(), pool.remote()); err != nil {
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
@@ -333,12 +343,12 @@ func (pool *TxPool) loop() {
 			}
 			pool.mu.Unlock()
 
-		// Handle local transaction journal rotation
+		// Handle transaction journal rotation
 		case <-journal.C:
 			if pool.journal != nil {
 				pool.mu.Lock()
-				if err := pool.journal.rotate(pool.local()); err != nil {
-					log.Warn("Failed to rotate local tx journal", "err", err)
+				if err := pool.journal.rotate(pool.local(), pool.remote()); err != nil {
+					log.Warn("Failed to rotate tx journal", "err", err)
 				}
 				pool.mu.Unlock()
 			}
@@ -568,6 +578,47 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 	return txs
 }
 
+// remote retrieves all currently known remote transactions, grouped by origin
+// account. The returned transaction set is a copy and can be freely modified by
+// calling code.
+func (pool *TxPool) remote() map[common.Address]types.Transactions {
+	txs := make(map[common.Address]types.Transactions)
+	for addr, pending := range pool.pending {
+		if !pool.locals.contains(addr) {
+			txs[addr] = append(txs[addr], pending.Flatten()...)
+		}
+	}
+	for addr, queued := range pool.queue {
+		if !pool.locals.contains(addr) {
+			txs[addr] = append(txs[addr], queued.Flatten()...)
+		}
+	}
+	return txs
+}
+
+// JournalStats retrieves the persistence statistics of the pool journal, and
+// whether journaling is enabled at all.
+func (pool *TxPool) JournalStats() (TxJournalStats, bool) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	if pool.journal == nil {
+		return TxJournalStats{}, false
+	}
+	return pool.journal.stats(), true
+}
+
+// spendable returns the main balance of the account, including the vested
+// balances released when its next transaction is applied.
+func (pool *TxPool) spendable(addr common.Address) *big.Int {
+	balance := pool.currentState.GetBalance(addr)
+	next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+	if pool.chainconfig.IsBIP9(next) {
+		balance = new(big.Int).Add(balance, pool.currentState.GetReleasableVesting(addr, next))
+	}
+	return balance
+}
+
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
@@ -589,6 +640,29 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return ErrInvalidSender
 	}
+	next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+
+	// Hardware wallet signatures are only accepted from BIP6 on
+	if tx.MessageSigned() && !pool.chainconfig.IsBIP6(next) {
+		return ErrInvalidSender
+	}
+	// Multisig transactions need the approval of enough owners of their account
+	if tx.Multisig() != nil {
+		if !pool.chainconfig.IsBIP7(next) {
+			return ErrInvalidSender
+		}
+		approvers, err := pool.signer.Approvers(tx)
+		if err != nil {
+			return ErrInvalidSender
+		}
+		config := pool.currentState.GetMultisig(from)
+		if config == nil {
+			return types.ErrNotMultisig
+		}
+		if err := config.Verify(approvers); err != nil {
+			return err
+		}
+	}
 	// Drop non-local transactions under our own minimal accepted gas price
 	local = local || pool.locals.contains(from) // account may be local even if the transaction arrived from the network
 	if !local && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
@@ -609,18 +683,55 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 		return err
 	}
 
-	if (tx.Base() == types.Stake || tx.Target() == types.Stake) && bytes.Compare(tx.To().Bytes(), from.Bytes()) != 0 {
+	if tx.Base() == types.Reward || tx.Target() == types.Reward {
+		if tx.To() == nil || !pool.chainconfig.IsBIP5(next) {
+			return types.ErrInvalidJobWallet
+		}
+	}
+
+	if tx.Base() == types.Vesting || tx.Target() == types.Vesting {
+		if tx.To() == nil || !pool.chainconfig.IsBIP9(next) {
+			return types.ErrInvalidJobWallet
+		}
+	}
+
+	if (tx.Base() == types.Stake || tx.Target() == types.Stake || tx.Base() == types.Reward) && bytes.Compare(tx.To().Bytes(), from.Bytes()) != 0 {
 		return ErrInvalidStakeReceiver
 	}
 
+	balance := pool.spendable(from)
+
 	if tx.Base() == types.Main {
-		if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
+		if balance.Cmp(tx.Cost()) < 0 {
 			return ErrInsufficientFunds
 		}
 	}
 
-	if tx.Base() == types.Stake {
-		balance := pool.currentState.GetBalance(from)
+	if tx.Base() == types.Reward && tx.Target() != types.Reward {
+		matured := MaturedRewardNumber(pool.chainconfig, next)
+		if pool.currentState.GetMaturedReward(from, matured).Cmp(tx.Value()) < 0 {
+			return ErrInsufficientFunds
+		}
+	}
+
+	if tx.Target() == types.Vesting {
+		var schedule types.VestingSchedule
+		if err := rlp.DecodeBytes(tx.Data(), &schedule); err != nil {
+			return types.ErrInvalidVesting
+		}
+		if err := schedule.Validate(); err != nil {
+			return err
+		}
+		if tx.Value().Cmp(params.MinVestingAmount) < 0 {
+			return types.ErrVestingAmountTooLow
+		}
+	}
+
+	if tx.Base() == types.Vesting && pool.currentState.GetStakableVesting(from).Cmp(tx.Value()) < 0 {
+		return ErrInsufficientFunds
+	}
+
+	if tx.Base() == types.Stake || tx.Base() == types.Reward || tx.Base() == types.Vesting {
 		cost := tx.MainFee()
 		if balance.Cmp(cost) < 0 {
 			return ErrInsufficientFunds
@@ -631,6 +742,21 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return err
 	}
+	if m := tx.Multisig(); m != nil {
+		intrGas += uint64(len(m.Signatures)) * params.TxMultisigApprovalGas
+	}
+	if IsMultisigSetup(pool.chainconfig, next, tx.To(), tx.Base(), tx.Target()) {
+		intrGas += params.MultisigSetupGas
+	}
+	if tx.Target() == types.Vesting {
+		intrGas += params.VestingSetupGas
+	}
+	if types.IsKeyMigration(pool.chainconfig, next, tx.To(), tx.Base(), tx.Target()) {
+		if _, err := ValidateKeyMigration(pool.currentState, pool.chainconfig.ChainID, next, from, tx.Value(), tx.Data()); err != nil {
+			return err
+		}
+		intrGas += params.KeyMigrationGas
+	}
 	if tx.Gas() < intrGas {
 		return ErrIntrinsicGas
 	}
@@ -643,7 +769,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	stakedAmount := pool.currentState.GetStakeBalance(from)
 	totalStakingAmount := tx.Value().Add(tx.Value(), stakedAmount)
 	minimum := pool.chainconfig.Bsrr.StakeMinimum
-	if tx.Base() == types.Main && tx.Target() == types.Stake {
+	if tx.Base() != types.Stake && tx.Target() == types.Stake {
 		if totalStakingAmount.Cmp(minimum) == -1 {
 			return ErrStakingBalance
 		}
@@ -654,7 +780,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	stakedAmount = pool.currentState.GetStakeBalance(to)
 	totalStakingAmount = tx.Value().Add(tx.Value(), stakedAmount)
 	maximum := pool.chainconfig.Bsrr.StakeMaximum
-	if tx.Base() == types.Main && tx.Target() == types.Stake {
+	if tx.Base() != types.Stake && tx.Target() == types.Stake {
 		if totalStakingAmount.Cmp(maximum) >= 0 {
 			return ErrStakingBalance
 		}
@@ -772,16 +898,25 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 	return old != nil, nil
 }
 
-// journalTx adds the specified transaction to the local disk journal if it is
-// deemed to have been sent from a local account.
+// journalTx adds the specified transaction to the transaction journal. The
+// journal backend decides whether transactions of remote accounts are kept.
 func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
-	// Only journal if it's enabled and the transaction is local
-	if pool.journal == nil || !pool.locals.contains(from) {
+	// Only journal if it's enabled
+	if pool.journal == nil {
 		return
 	}
-	if err := pool.journal.insert(tx); err != nil {
-		log.Warn("Failed to journal local transaction", "err", err)
+	if err := pool.journal.insert(tx, pool.locals.contains(from)); err != nil {
+		log.Warn("Failed to journal transaction", "err", err)
+	}
+}
+
+// addJournaled reinjects a batch of journaled transactions into the pool with
+// the locality they were journaled with.
+func (pool *TxPool) addJournaled(txs []*types.Transaction, local bool) []error {
+	if local {
+		return pool.AddLocals(txs)
 	}
+	return pool.AddRemotes(txs)
 }
 
 // promoteTx adds a transaction to the pending (processable) list of transactions
@@ -999,7 +1134,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			pool.priced.Removed()
 		}
 		// Drop all transactions that are too costly (low balance or out of gas)
-		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
+		drops, _ := list.Filter(pool.spendable(addr), pool.currentMaxGas)
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable queued transaction", "hash", hash)
@@ -1163,7 +1298,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			pool.priced.Removed()
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
-		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
+		drops, invalids := list.Filter(pool.spendable(addr), pool.currentMaxGas)
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
@@ -3,7 +3,6 @@ package core
 import (
 	"crypto/ecdsa"
 	"math/big"
-	"os"
 	"testing"
 
 	"github.com/BerithFoundation/berith-chain/berith/staking"
@@ -11,7 +10,10 @@ import (
 	"github.com/BerithFoundation/berith-chain/berithdb"
 	"github.com/BerithFoundation/berith-chain/common/hexutil"
 	"github.com/BerithFoundation/berith-chain/consensus/bsrr"
+	"github.com/BerithFoundation/berith-chain/core/state"
+	"github.com/BerithFoundation/berith-chain/crypto"
 	"github.com/BerithFoundation/berith-chain/crypto/secp256k1"
+	"github.com/BerithFoundation/berith-chain/event"
 	"github.com/BerithFoundation/berith-chain/params"
 
 	"github.com/BerithFoundation/berith-chain/common"
@@ -101,13 +103,8 @@ func TestTransactionValidate(t *testing.T) {
 		},
 	}
 
-	stkDB := new(stakingdb.StakingDB)
-
-	if err := stkDB.CreateDB(os.TempDir()+"/stakingdb/", staking.NewStakers); err != nil {
-		t.Error(err)
-	}
-
 	memDB := berithdb.NewMemDatabase()
+	stkDB := stakingdb.NewStakingDB(memDB, staking.NewStakers)
 
 	SetupGenesisBlockWithOverride(memDB, genesis, big.NewInt(0))
 
@@ -126,7 +123,7 @@ func TestTransactionValidate(t *testing.T) {
 		t.Error(err)
 	}
 
-	pool := NewTxPool(DefaultTxPoolConfig, params.TestnetChainConfig, chain)
+	pool := NewTxPool(DefaultTxPoolConfig, params.TestnetChainConfig, chain, memDB)
 
 	signer := types.NewEIP155Signer(big.NewInt(206))
 
@@ -145,3 +142,135 @@ func TestTransactionValidate(t *testing.T) {
 		}
 	}
 }
+
+// testBlockChain serves a single head block and its state to the pool.
+type testBlockChain struct {
+	statedb       *state.StateDB
+	number        int64
+	chainHeadFeed event.Feed
+}
+
+func (bc *testBlockChain) CurrentBlock() *types.Block {
+	return types.NewBlock(&types.Header{Number: big.NewInt(bc.number), GasLimit: 10000000}, nil, nil, nil)
+}
+
+func (bc *testBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return bc.CurrentBlock()
+}
+
+func (bc *testBlockChain) StateAt(common.Hash) (*state.StateDB, error) {
+	return bc.statedb, nil
+}
+
+func (bc *testBlockChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription {
+	return bc.chainHeadFeed.Subscribe(ch)
+}
+
+// newTestTxPool creates a pool, without journal, on top of a chain whose head is
+// the block of the given number with the given state.
+func newTestTxPool(config *params.ChainConfig, statedb *state.StateDB, number int64) *TxPool {
+	poolConfig := DefaultTxPoolConfig
+	poolConfig.Journal = ""
+	return NewTxPool(poolConfig, config, &testBlockChain{statedb: statedb, number: number}, nil)
+}
+
+// Tests that the pool accepts Reward wallet transactions from BIP5 on, moving
+// the rewards matured at the next block into the sender's own wallets.
+func TestRewardTransactionValidate(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	config := *params.TestnetChainConfig
+	config.BIP5Block = big.NewInt(100)
+
+	// The block following 99 pays the rewards of block 60 and before
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(berithdb.NewMemDatabase()))
+	statedb.AddBalance(from, eth)
+	statedb.AddBehindBalance(from, big.NewInt(60), new(big.Int).Mul(big.NewInt(10), eth))
+	statedb.AddBehindBalance(from, big.NewInt(61), eth)
+
+	pool := newTestTxPool(&config, statedb, 99)
+	defer pool.Stop()
+
+	stakeMin := config.Bsrr.StakeMinimum
+	tests := []struct {
+		name   string
+		to     common.Address
+		value  *big.Int
+		target types.JobWallet
+		err    error
+	}{
+		{"other receiver", common.Address{1}, eth, types.Main, ErrInvalidStakeReceiver},
+		{"immature reward", from, new(big.Int).Mul(big.NewInt(11), eth), types.Main, ErrInsufficientFunds},
+		{"stake under minimum", from, eth, types.Stake, ErrStakingBalance},
+		{"withdraw", from, new(big.Int).Mul(big.NewInt(10), eth), types.Main, nil},
+	}
+	for i, tt := range tests {
+		if tt.target == types.Stake && stakeMin.Cmp(tt.value) <= 0 {
+			t.Fatalf("%s: stake minimum %v too low for the test", tt.name, stakeMin)
+		}
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), tt.to, tt.value, 21000, big.NewInt(1), nil, types.Reward, tt.target), pool.signer, key)
+		if err := pool.validateTx(tx, true); err != tt.err {
+			t.Errorf("%s: have %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	// Before BIP5 no Reward wallet transaction is accepted
+	pool = newTestTxPool(&config, statedb, 98)
+	defer pool.Stop()
+
+	tx, _ := types.SignTx(types.NewTransaction(0, from, eth, 21000, big.NewInt(1), nil, types.Reward, types.Main), pool.signer, key)
+	if err := pool.validateTx(tx, true); err != types.ErrInvalidJobWallet {
+		t.Errorf("before BIP5: have %v, want %v", err, types.ErrInvalidJobWallet)
+	}
+}
+
+// Tests that the pool only accepts the transactions of a multisig account which
+// enough of its owners approved.
+func TestMultisigTransactionValidate(t *testing.T) {
+	var (
+		keys   = make([]*ecdsa.PrivateKey, 3)
+		owners = make([]common.Address, 3)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		owners[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	config := *params.TestnetChainConfig
+	config.BIP7Block = big.NewInt(0)
+	bsrr := *config.Bsrr
+	bsrr.StakeMaximum = new(big.Int).Mul(bsrr.StakeMinimum, big.NewInt(10))
+	config.Bsrr = &bsrr
+
+	// A 2-of-2 account of owners 0 and 1, able to stake the minimum
+	account := types.MultisigAddress(owners[0], 0)
+	stake := config.Bsrr.StakeMinimum
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(berithdb.NewMemDatabase()))
+	statedb.AddBalance(account, new(big.Int).Mul(stake, big.NewInt(2)))
+	statedb.SetMultisig(account, &types.MultisigConfig{Threshold: 2, Owners: owners[:2]})
+
+	pool := newTestTxPool(&config, statedb, 1)
+	defer pool.Stop()
+
+	tests := []struct {
+		name      string
+		approvers []int
+		err       error
+	}{
+		{"under threshold", []int{0}, types.ErrMultisigThreshold},
+		{"non owner", []int{0, 2}, types.ErrUnknownMultisigOwner},
+		{"approved", []int{1, 0}, nil},
+	}
+	for _, tt := range tests {
+		tx := types.NewTransaction(0, account, stake, 21000+2*params.TxMultisigApprovalGas, big.NewInt(1), nil, types.Main, types.Stake)
+		tx = types.NewMultisigTransaction(config.ChainID, account, tx)
+		for _, i := range tt.approvers {
+			var err error
+			if tx, err = types.SignMultisigTx(tx, pool.signer, keys[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := pool.validateTx(tx, true); err != tt.err {
+			t.Errorf("%s: have %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
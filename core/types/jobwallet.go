@@ -11,6 +11,8 @@ type JobWallet uint8
 const (
 	Main = 1 + iota
 	Stake
+	Reward  // Matured rewards still kept in the behind balance, from BIP5 on
+	Vesting // Balances locked by vesting schedules, from BIP9 on
 	end
 )
 
@@ -18,13 +20,15 @@ var (
 	values = [...]string{
 		"main",
 		"stake",
+		"reward",
+		"vesting",
 	}
 
 	ErrInvalidJobWallet = errors.New("invalid wallet type")
 )
 
 func (m JobWallet) String() string {
-	return values[(m-1)%2]
+	return values[(m-1)%JobWallet(len(values))]
 }
 
 func ConvertJobWallet(s string) JobWallet {
@@ -33,6 +37,10 @@ func ConvertJobWallet(s string) JobWallet {
 		return Main
 	case "stake":
 		return Stake
+	case "reward":
+		return Reward
+	case "vesting":
+		return Vesting
 	default:
 		return Main
 	}
@@ -51,5 +59,16 @@ func ValidateJobWallet(base JobWallet, target JobWallet) error {
 		return ErrInvalidJobWallet
 	}
 
+	// Rewards can't be deposited, Reward -> Reward toggles auto-compounding
+	if base != Reward && target == Reward {
+		return ErrInvalidJobWallet
+	}
+
+	// Main -> Vesting locks a balance, Vesting -> Stake stakes locked balances.
+	// Vested balances are released into the main balance without transactions.
+	if (target == Vesting && base != Main) || (base == Vesting && target != Stake) {
+		return ErrInvalidJobWallet
+	}
+
 	return nil
 }
@@ -0,0 +1,60 @@
+/*
+[BERITH]
+계정 키 교체 (key migration)
+*/
+package types
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/crypto"
+	"github.com/BerithFoundation/berith-chain/params"
+)
+
+var (
+	// KeyMigrationAddress is the recipient of the transactions migrating their
+	// sender to a new key. The payload of such a transaction is the signature of
+	// the new key made by SignKeyMigration.
+	KeyMigrationAddress = common.BytesToAddress([]byte("migration"))
+
+	ErrInvalidKeyMigration = errors.New("invalid key migration")
+)
+
+// IsKeyMigration returns whether a transaction with the given recipient and
+// JobWallet fields migrates its sender to a new key at the given block number.
+func IsKeyMigration(config *params.ChainConfig, number *big.Int, to *common.Address, base, target JobWallet) bool {
+	return to != nil && *to == KeyMigrationAddress && base == Main && target == Main && config.IsBIP8(number)
+}
+
+// KeyMigrationHash returns the hash the new key signs to accept the migration of
+// the account, so that a mistyped key can't receive it.
+func KeyMigrationHash(chainId *big.Int, account common.Address) common.Hash {
+	return rlpHash([]interface{}{"berith key migration", chainId, account})
+}
+
+// SignKeyMigration returns the payload of the transaction migrating account to
+// the key prv.
+func SignKeyMigration(chainId *big.Int, account common.Address, prv *ecdsa.PrivateKey) ([]byte, error) {
+	h := KeyMigrationHash(chainId, account)
+	return crypto.Sign(h[:], prv)
+}
+
+// KeyMigrationTarget returns the address of the new key accepting the migration
+// of account with the given transaction payload.
+func KeyMigrationTarget(chainId *big.Int, account common.Address, payload []byte) (common.Address, error) {
+	if len(payload) != 65 {
+		return common.Address{}, ErrInvalidKeyMigration
+	}
+	R, S, V := new(big.Int).SetBytes(payload[:32]), new(big.Int).SetBytes(payload[32:64]), big.NewInt(int64(payload[64])+27)
+	target, err := recoverPlain(KeyMigrationHash(chainId, account), R, S, V, true)
+	if err != nil {
+		return common.Address{}, ErrInvalidKeyMigration
+	}
+	if target == account {
+		return common.Address{}, ErrInvalidKeyMigration
+	}
+	return target, nil
+}
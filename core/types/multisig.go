@@ -0,0 +1,114 @@
+/*
+[BERITH]
+M-of-N 다중서명 계정
+*/
+package types
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/common/hexutil"
+	"github.com/BerithFoundation/berith-chain/crypto"
+)
+
+// MaxMultisigOwners is the maximum number of owners of a multisig account.
+const MaxMultisigOwners = 16
+
+var (
+	// MultisigSetupAddress is the recipient of the transactions creating multisig
+	// accounts. The payload of such a transaction is the RLP encoded MultisigConfig
+	// and its value is moved into the new account.
+	MultisigSetupAddress = common.BytesToAddress([]byte("multisig"))
+
+	ErrInvalidMultisig        = errors.New("invalid multisig configuration")
+	ErrNotMultisig            = errors.New("sender is not a multisig account")
+	ErrMultisigThreshold      = errors.New("not enough multisig approvals")
+	ErrUnknownMultisigOwner   = errors.New("approval of a non owner")
+	ErrDuplicateMultisigOwner = errors.New("duplicate multisig approval")
+)
+
+// MultisigConfig is the M-of-N configuration of a multisig account, M being the
+// threshold of owner approvals a transaction of the account needs.
+type MultisigConfig struct {
+	Threshold uint64           `json:"threshold"`
+	Owners    []common.Address `json:"owners"`
+}
+
+// Validate checks that the threshold can be reached by the owners and that the
+// owners are distinct.
+func (c *MultisigConfig) Validate() error {
+	if c.Threshold == 0 || c.Threshold > uint64(len(c.Owners)) || len(c.Owners) > MaxMultisigOwners {
+		return ErrInvalidMultisig
+	}
+	seen := make(map[common.Address]bool)
+	for _, owner := range c.Owners {
+		if owner == (common.Address{}) || seen[owner] {
+			return ErrInvalidMultisig
+		}
+		seen[owner] = true
+	}
+	return nil
+}
+
+// IsOwner returns whether addr is one of the owners of the account.
+func (c *MultisigConfig) IsOwner(addr common.Address) bool {
+	for _, owner := range c.Owners {
+		if owner == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks that the distinct approvers are owners of the account and that
+// there are enough of them.
+func (c *MultisigConfig) Verify(approvers []common.Address) error {
+	for _, approver := range approvers {
+		if !c.IsOwner(approver) {
+			return ErrUnknownMultisigOwner
+		}
+	}
+	if uint64(len(approvers)) < c.Threshold {
+		return ErrMultisigThreshold
+	}
+	return nil
+}
+
+// MultisigAddress returns the address of the multisig account created by the
+// setup transaction of sender with the given nonce.
+func MultisigAddress(sender common.Address, nonce uint64) common.Address {
+	return crypto.CreateAddress(sender, nonce)
+}
+
+// Multisig is the multisig part of a transaction, naming the account sending it
+// and carrying the owner signatures collected for it.
+type Multisig struct {
+	Account    common.Address  `json:"account"`
+	Signatures []hexutil.Bytes `json:"signatures"` // [R || S || V] format where V is 0 or 1
+}
+
+// NewMultisigTransaction returns an unsigned transaction of a multisig account.
+// Owner signatures are added with SignMultisigTx.
+func NewMultisigTransaction(chainId *big.Int, account common.Address, tx *Transaction) *Transaction {
+	cpy := &Transaction{data: tx.data}
+	cpy.data.Multisig = []*Multisig{{Account: account}}
+
+	// Multisig transactions have no signature of their own, V only encodes the
+	// chain id to keep the replay protection of the signing hash.
+	cpy.data.V = new(big.Int).Add(new(big.Int).Mul(chainId, big.NewInt(2)), big.NewInt(35))
+	cpy.data.R, cpy.data.S = new(big.Int), new(big.Int)
+	return cpy
+}
+
+// SignMultisigTx adds the signature of an owner to a multisig transaction.
+func SignMultisigTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, error) {
+	h := s.Hash(tx)
+	sig, err := crypto.Sign(h[:], prv)
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithMultisigSignature(sig)
+}
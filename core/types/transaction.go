@@ -60,6 +60,10 @@ type txdata struct {
 
 	// This is only used when marshaling to JSON.
 	Hash *common.Hash `json:"hash" rlp:"-"`
+
+	// Multisig holds at most one entry, set on the transactions of multisig
+	// accounts. Being the tail keeps the encoding of other transactions unchanged.
+	Multisig []*Multisig `json:"multisig,omitempty" rlp:"tail"`
 }
 
 type txdataMarshaling struct {
@@ -112,7 +116,17 @@ func newTransaction(nonce uint64, to *common.Address, amount *big.Int, gasLimit
 
 // ChainId returns which chain id this transaction was signed for (if at all)
 func (tx *Transaction) ChainId() *big.Int {
-	return deriveChainId(tx.data.V)
+	chainId := deriveChainId(tx.data.V)
+	if chainId.Cmp(messageChainIdOffset) >= 0 {
+		chainId.Sub(chainId, messageChainIdOffset)
+	}
+	return chainId
+}
+
+// MessageSigned returns whether the transaction was signed by a hardware wallet
+// over the message hash of its signing hash (see MessageSigner).
+func (tx *Transaction) MessageSigned() bool {
+	return tx.Protected() && deriveChainId(tx.data.V).Cmp(messageChainIdOffset) >= 0
 }
 
 // Protected returns whether the transaction is protected from replay protection.
@@ -240,9 +254,40 @@ func (tx *Transaction) AsMessage(s Signer) (Message, error) {
 
 	var err error
 	msg.from, err = Sender(s, tx)
+	if err == nil && tx.Multisig() != nil {
+		msg.approvers, err = s.Approvers(tx)
+	}
 	return msg, err
 }
 
+// Multisig returns the multisig part of the transaction of a multisig account,
+// nil for other transactions.
+func (tx *Transaction) Multisig() *Multisig {
+	if len(tx.data.Multisig) == 0 {
+		return nil
+	}
+	m := tx.data.Multisig[0]
+	return &Multisig{Account: m.Account, Signatures: append([]hexutil.Bytes{}, m.Signatures...)}
+}
+
+// WithMultisigSignature returns a new multisig transaction with the given owner
+// signature added. This signature needs to be in the [R || S || V] format where
+// V is 0 or 1.
+func (tx *Transaction) WithMultisigSignature(sig []byte) (*Transaction, error) {
+	m := tx.Multisig()
+	if m == nil {
+		return nil, ErrNotMultisig
+	}
+	if len(sig) != 65 {
+		return nil, ErrInvalidSig
+	}
+	m.Signatures = append(m.Signatures, common.CopyBytes(sig))
+
+	cpy := &Transaction{data: tx.data}
+	cpy.data.Multisig = []*Multisig{m}
+	return cpy, nil
+}
+
 // WithSignature returns a new transaction with the given signature.
 // This signature needs to be formatted as described in the yellow paper (v+27).
 func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, error) {
@@ -409,6 +454,7 @@ type Message struct {
 	checkNonce bool
 	base       JobWallet
 	target     JobWallet
+	approvers  []common.Address
 }
 
 func NewMessage(from common.Address, to *common.Address, nonce uint64, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, checkNonce bool) Message {
@@ -453,3 +499,7 @@ func (m Message) CheckNonce() bool     { return m.checkNonce }
 //[Berith]
 func (m Message) Base() JobWallet   { return m.base }
 func (m Message) Target() JobWallet { return m.target }
+
+// Approvers returns the owners who signed the transaction of a multisig account,
+// nil for other messages.
+func (m Message) Approvers() []common.Address { return m.approvers }
@@ -42,6 +42,10 @@ type sigCache struct {
 func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
 	var signer Signer
 	switch {
+	case config.IsBIP7(blockNumber):
+		signer = NewBIP7Signer(config.ChainID)
+	case config.IsBIP6(blockNumber):
+		signer = NewBIP6Signer(config.ChainID)
 	case config.IsEIP155(blockNumber):
 		signer = NewEIP155Signer(config.ChainID)
 	case config.IsHomestead(blockNumber):
@@ -100,6 +104,9 @@ type Signer interface {
 	Hash(tx *Transaction) common.Hash
 	// Equal returns true if the given signer is the same as the receiver.
 	Equal(Signer) bool
+	// Approvers returns the distinct keys which signed the transaction of a
+	// multisig account.
+	Approvers(tx *Transaction) ([]common.Address, error)
 }
 
 // EIP155Transaction implements Signer using the EIP155 rules.
@@ -124,11 +131,17 @@ func (s EIP155Signer) Equal(s2 Signer) bool {
 
 var big8 = big.NewInt(8)
 
+// Approvers returns ErrNotMultisig, multisig transactions are only accepted
+// from BIP7 on.
+func (s EIP155Signer) Approvers(tx *Transaction) ([]common.Address, error) {
+	return nil, ErrNotMultisig
+}
+
 func (s EIP155Signer) Sender(tx *Transaction) (common.Address, error) {
 	if !tx.Protected() {
 		return HomesteadSigner{}.Sender(tx)
 	}
-	if tx.ChainId().Cmp(s.chainId) != 0 {
+	if tx.ChainId().Cmp(s.chainId) != 0 || tx.MessageSigned() {
 		return common.Address{}, ErrInvalidChainId
 	}
 	V := new(big.Int).Sub(tx.data.V, s.chainIdMul)
@@ -166,6 +179,177 @@ func (s EIP155Signer) Hash(tx *Transaction) common.Hash {
 	})
 }
 
+// messageChainIdOffset is added to the chain id encoded into the V value of
+// message signed transactions, keeping them apart from EIP155 signatures.
+var messageChainIdOffset = big.NewInt(1 << 31)
+
+// MessageSigner implements Signer for transactions signed by hardware wallets.
+// The devices only sign arbitrary data prefixed as a message, never a raw hash,
+// so the signature is made over the message hash of the EIP155 signing hash,
+// which covers the JobWallet fields a device wouldn't know about.
+type MessageSigner struct {
+	EIP155Signer
+	messageIdMul *big.Int
+}
+
+func NewMessageSigner(chainId *big.Int) MessageSigner {
+	signer := NewEIP155Signer(chainId)
+	return MessageSigner{
+		EIP155Signer: signer,
+		messageIdMul: new(big.Int).Mul(new(big.Int).Add(signer.chainId, messageChainIdOffset), big.NewInt(2)),
+	}
+}
+
+func (s MessageSigner) Equal(s2 Signer) bool {
+	message, ok := s2.(MessageSigner)
+	return ok && message.chainId.Cmp(s.chainId) == 0
+}
+
+func (s MessageSigner) Sender(tx *Transaction) (common.Address, error) {
+	if !tx.MessageSigned() || tx.ChainId().Cmp(s.chainId) != 0 {
+		return common.Address{}, ErrInvalidChainId
+	}
+	V := new(big.Int).Sub(tx.data.V, s.messageIdMul)
+	V.Sub(V, big8)
+	return recoverPlain(s.Hash(tx), tx.data.R, tx.data.S, V, true)
+}
+
+// SignatureValues returns signature values. This signature
+// needs to be in the [R || S || V] format where V is 0 or 1.
+func (s MessageSigner) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big.Int, err error) {
+	R, S, V, err = HomesteadSigner{}.SignatureValues(tx, sig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	V = big.NewInt(int64(sig[64] + 35))
+	V.Add(V, s.messageIdMul)
+	return R, S, V, nil
+}
+
+// Hash returns the message hash of the EIP155 signing hash, which is what a
+// hardware wallet signs when asked to sign the signing hash as a message.
+func (s MessageSigner) Hash(tx *Transaction) common.Hash {
+	return MessageHash(s.EIP155Signer.Hash(tx).Bytes())
+}
+
+// MessageHash returns the hash hardware wallets sign for a message, being
+// keccak256("\x19Ethereum Signed Message:\n"${message length}${message}).
+func MessageHash(data []byte) common.Hash {
+	msg := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(data), data)
+	return crypto.Keccak256Hash([]byte(msg))
+}
+
+// BIP6Signer implements Signer using the EIP155 rules, additionally accepting
+// the message signed transactions of hardware wallets from BIP6 on.
+type BIP6Signer struct {
+	EIP155Signer
+	message MessageSigner
+}
+
+func NewBIP6Signer(chainId *big.Int) BIP6Signer {
+	return BIP6Signer{
+		EIP155Signer: NewEIP155Signer(chainId),
+		message:      NewMessageSigner(chainId),
+	}
+}
+
+func (s BIP6Signer) Equal(s2 Signer) bool {
+	bip6, ok := s2.(BIP6Signer)
+	return ok && bip6.chainId.Cmp(s.chainId) == 0
+}
+
+func (s BIP6Signer) Sender(tx *Transaction) (common.Address, error) {
+	if tx.MessageSigned() {
+		return s.message.Sender(tx)
+	}
+	return s.EIP155Signer.Sender(tx)
+}
+
+// BIP7Signer implements Signer using the BIP6 rules, additionally accepting the
+// transactions of multisig accounts from BIP7 on.
+type BIP7Signer struct{ BIP6Signer }
+
+func NewBIP7Signer(chainId *big.Int) BIP7Signer {
+	return BIP7Signer{NewBIP6Signer(chainId)}
+}
+
+func (s BIP7Signer) Equal(s2 Signer) bool {
+	bip7, ok := s2.(BIP7Signer)
+	return ok && bip7.chainId.Cmp(s.chainId) == 0
+}
+
+// Sender returns the account of a multisig transaction after checking that its
+// signatures are made by distinct keys. Whether those are enough owners of the
+// account depends on the state, so it's checked when the transaction is applied.
+func (s BIP7Signer) Sender(tx *Transaction) (common.Address, error) {
+	if m := tx.Multisig(); m != nil {
+		if _, err := s.Approvers(tx); err != nil {
+			return common.Address{}, err
+		}
+		return m.Account, nil
+	}
+	return s.BIP6Signer.Sender(tx)
+}
+
+// Hash returns the hash to be signed by the sender, or by the owners of the
+// account sending a multisig transaction.
+func (s BIP7Signer) Hash(tx *Transaction) common.Hash {
+	if m := tx.Multisig(); m != nil {
+		return rlpHash([]interface{}{
+			tx.data.AccountNonce,
+			tx.data.Price,
+			tx.data.GasLimit,
+			tx.data.Recipient,
+			tx.data.Amount,
+			tx.data.Payload,
+			tx.data.Base,
+			tx.data.Target,
+			s.chainId,
+			m.Account,
+		})
+	}
+	return s.BIP6Signer.Hash(tx)
+}
+
+// Approvers recovers the distinct keys which signed the transaction of a
+// multisig account.
+func (s BIP7Signer) Approvers(tx *Transaction) ([]common.Address, error) {
+	m := tx.Multisig()
+	if m == nil {
+		return nil, ErrNotMultisig
+	}
+	if tx.ChainId().Cmp(s.chainId) != 0 || tx.MessageSigned() {
+		return nil, ErrInvalidChainId
+	}
+	if len(m.Signatures) == 0 {
+		return nil, ErrMultisigThreshold
+	}
+	if len(m.Signatures) > MaxMultisigOwners {
+		return nil, ErrInvalidMultisig
+	}
+	var (
+		hash      = s.Hash(tx)
+		approvers = make([]common.Address, 0, len(m.Signatures))
+		seen      = make(map[common.Address]bool)
+	)
+	for _, sig := range m.Signatures {
+		if len(sig) != 65 {
+			return nil, ErrInvalidSig
+		}
+		R, S, V := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64]), big.NewInt(int64(sig[64]+27))
+		approver, err := recoverPlain(hash, R, S, V, true)
+		if err != nil {
+			return nil, err
+		}
+		if seen[approver] {
+			return nil, ErrDuplicateMultisigOwner
+		}
+		seen[approver] = true
+		approvers = append(approvers, approver)
+	}
+	return approvers, nil
+}
+
 // HomesteadTransaction implements TransactionInterface using the
 // homestead rules.
 type HomesteadSigner struct{ FrontierSigner }
@@ -221,6 +405,12 @@ func (fs FrontierSigner) Sender(tx *Transaction) (common.Address, error) {
 	return recoverPlain(fs.Hash(tx), tx.data.R, tx.data.S, tx.data.V, false)
 }
 
+// Approvers returns ErrNotMultisig, multisig transactions are only accepted
+// from BIP7 on.
+func (fs FrontierSigner) Approvers(tx *Transaction) ([]common.Address, error) {
+	return nil, ErrNotMultisig
+}
+
 func recoverPlain(sighash common.Hash, R, S, Vb *big.Int, homestead bool) (common.Address, error) {
 	if Vb.BitLen() > 8 {
 		return common.Address{}, ErrInvalidSig
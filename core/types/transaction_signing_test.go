@@ -17,6 +17,7 @@
 package types
 
 import (
+	"crypto/ecdsa"
 	"math/big"
 	"testing"
 
@@ -44,6 +45,179 @@ func TestEIP155Signing(t *testing.T) {
 	}
 }
 
+// Tests that transactions signed as a message by hardware wallets are only
+// accepted by the BIP6 signer, keeping the JobWallet fields covered.
+func TestMessageSigning(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	for _, jobs := range [][2]JobWallet{{Main, Main}, {Main, Stake}, {Stake, Main}} {
+		signer := NewMessageSigner(big.NewInt(18))
+		tx, err := SignTx(NewTransaction(0, addr, new(big.Int), 0, new(big.Int), nil, jobs[0], jobs[1]), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tx.MessageSigned() || tx.ChainId().Cmp(big.NewInt(18)) != 0 {
+			t.Fatalf("%v->%v: message signature not detected, chain id %v", jobs[0], jobs[1], tx.ChainId())
+		}
+		// A device signs the EIP155 signing hash prefixed as a message
+		if have, want := signer.Hash(tx), crypto.Keccak256Hash([]byte("\x19Ethereum Signed Message:\n32"), NewEIP155Signer(big.NewInt(18)).Hash(tx).Bytes()); have != want {
+			t.Fatalf("%v->%v: signing hash mismatch: have %x, want %x", jobs[0], jobs[1], have, want)
+		}
+		if _, err := Sender(NewEIP155Signer(big.NewInt(18)), tx); err != ErrInvalidChainId {
+			t.Errorf("%v->%v: EIP155 signer error mismatch: have %v, want %v", jobs[0], jobs[1], err, ErrInvalidChainId)
+		}
+		if _, err := Sender(NewBIP6Signer(big.NewInt(19)), tx); err != ErrInvalidChainId {
+			t.Errorf("%v->%v: foreign chain error mismatch: have %v, want %v", jobs[0], jobs[1], err, ErrInvalidChainId)
+		}
+		from, err := Sender(NewBIP6Signer(big.NewInt(18)), tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if from != addr {
+			t.Errorf("%v->%v: sender mismatch: have %x, want %x", jobs[0], jobs[1], from, addr)
+		}
+		// Changing the JobWallet fields must invalidate the signature
+		v, r, s := tx.RawSignatureValues()
+		forged := NewTransaction(0, addr, new(big.Int), 0, new(big.Int), nil, jobs[1], jobs[0])
+		forged.data.V, forged.data.R, forged.data.S = v, r, s
+		if from, err := Sender(NewBIP6Signer(big.NewInt(18)), forged); jobs[0] != jobs[1] && err == nil && from == addr {
+			t.Errorf("%v->%v: swapped JobWallet fields kept the sender", jobs[0], jobs[1])
+		}
+	}
+	// Plain EIP155 transactions are still accepted by the BIP6 signer
+	tx, err := SignTx(NewTransaction(0, addr, new(big.Int), 0, new(big.Int), nil, Main, Main), NewEIP155Signer(big.NewInt(18)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from, err := Sender(NewBIP6Signer(big.NewInt(18)), tx); err != nil || from != addr {
+		t.Errorf("EIP155 transaction: have %x (%v), want %x", from, err, addr)
+	}
+}
+
+func TestMultisigSigning(t *testing.T) {
+	var (
+		keys   = make([]*ecdsa.PrivateKey, 3)
+		owners = make([]common.Address, 3)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		owners[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	config := &MultisigConfig{Threshold: 2, Owners: owners}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	account := MultisigAddress(owners[0], 0)
+	signer := NewBIP7Signer(big.NewInt(18))
+
+	tx := NewMultisigTransaction(big.NewInt(18), account, NewTransaction(0, owners[1], big.NewInt(10), 50000, big.NewInt(1), nil, Main, Main))
+	if _, err := Sender(signer, tx); err != ErrMultisigThreshold {
+		t.Fatalf("unsigned transaction error mismatch: have %v, want %v", err, ErrMultisigThreshold)
+	}
+	for _, key := range keys[:2] {
+		var err error
+		if tx, err = SignMultisigTx(tx, signer, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The signatures must survive the RLP round trip of a raw transaction
+	enc, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx = new(Transaction)
+	if err := rlp.DecodeBytes(enc, tx); err != nil {
+		t.Fatal(err)
+	}
+	if from, err := Sender(signer, tx); err != nil || from != account {
+		t.Fatalf("sender mismatch: have %x (%v), want %x", from, err, account)
+	}
+	approvers, err := signer.Approvers(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Verify(approvers); err != nil {
+		t.Errorf("approvals of %d owners rejected: %v", len(approvers), err)
+	}
+	if err := config.Verify(approvers[:1]); err != ErrMultisigThreshold {
+		t.Errorf("single approval error mismatch: have %v, want %v", err, ErrMultisigThreshold)
+	}
+	if _, err := NewBIP6Signer(big.NewInt(18)).Approvers(tx); err != ErrNotMultisig {
+		t.Errorf("BIP6 signer error mismatch: have %v, want %v", err, ErrNotMultisig)
+	}
+	if _, err := Sender(NewBIP7Signer(big.NewInt(19)), tx); err != ErrInvalidChainId {
+		t.Errorf("foreign chain error mismatch: have %v, want %v", err, ErrInvalidChainId)
+	}
+	// An owner signing twice is only counted once
+	dup, err := SignMultisigTx(tx, signer, keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Sender(signer, dup); err != ErrDuplicateMultisigOwner {
+		t.Errorf("duplicate approval error mismatch: have %v, want %v", err, ErrDuplicateMultisigOwner)
+	}
+	// Approvals of other keys must not be counted for the account
+	stranger, _ := crypto.GenerateKey()
+	if tx, err = SignMultisigTx(tx, signer, stranger); err != nil {
+		t.Fatal(err)
+	}
+	if approvers, err = signer.Approvers(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Verify(approvers); err != ErrUnknownMultisigOwner {
+		t.Errorf("non owner approval error mismatch: have %v, want %v", err, ErrUnknownMultisigOwner)
+	}
+}
+
+func TestMultisigConfigValidate(t *testing.T) {
+	a, b := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	tests := []struct {
+		config MultisigConfig
+		valid  bool
+	}{
+		{MultisigConfig{Threshold: 1, Owners: []common.Address{a}}, true},
+		{MultisigConfig{Threshold: 2, Owners: []common.Address{a, b}}, true},
+		{MultisigConfig{Threshold: 0, Owners: []common.Address{a, b}}, false},
+		{MultisigConfig{Threshold: 3, Owners: []common.Address{a, b}}, false},
+		{MultisigConfig{Threshold: 1, Owners: []common.Address{a, a}}, false},
+		{MultisigConfig{Threshold: 1, Owners: []common.Address{{}}}, false},
+		{MultisigConfig{Threshold: 1, Owners: make([]common.Address, MaxMultisigOwners+1)}, false},
+	}
+	for i, test := range tests {
+		if err := test.config.Validate(); (err == nil) != test.valid {
+			t.Errorf("test %d: validity mismatch: have %v, want %v", i, err, test.valid)
+		}
+	}
+}
+
+func TestKeyMigrationTarget(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	account := common.HexToAddress("0xb6e4")
+
+	payload, err := SignKeyMigration(big.NewInt(18), account, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to, err := KeyMigrationTarget(big.NewInt(18), account, payload); err != nil || to != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("target mismatch: have %x (%v), want %x", to, err, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	// The acceptance of the new key is bound to the chain and the account
+	if to, _ := KeyMigrationTarget(big.NewInt(19), account, payload); to == crypto.PubkeyToAddress(key.PublicKey) {
+		t.Error("acceptance replayed on another chain")
+	}
+	if to, _ := KeyMigrationTarget(big.NewInt(18), common.HexToAddress("0xb6e5"), payload); to == crypto.PubkeyToAddress(key.PublicKey) {
+		t.Error("acceptance replayed for another account")
+	}
+	if _, err := KeyMigrationTarget(big.NewInt(18), account, payload[:64]); err != ErrInvalidKeyMigration {
+		t.Errorf("short payload error mismatch: have %v, want %v", err, ErrInvalidKeyMigration)
+	}
+	self, _ := SignKeyMigration(big.NewInt(18), crypto.PubkeyToAddress(key.PublicKey), key)
+	if _, err := KeyMigrationTarget(big.NewInt(18), crypto.PubkeyToAddress(key.PublicKey), self); err != ErrInvalidKeyMigration {
+		t.Errorf("migration to itself error mismatch: have %v, want %v", err, ErrInvalidKeyMigration)
+	}
+}
+
 func TestEIP155ChainId(t *testing.T) {
 	key, _ := crypto.GenerateKey()
 	addr := crypto.PubkeyToAddress(key.PublicKey)
@@ -0,0 +1,47 @@
+/*
+[BERITH]
+베스팅 (vesting) 일정으로 잠긴 잔액
+*/
+package types
+
+import (
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrInvalidVesting      = errors.New("invalid vesting schedule")
+	ErrVestingAmountTooLow = errors.New("vesting amount below the minimum")
+	ErrTooManyVesting      = errors.New("too many vesting schedules")
+)
+
+// VestingSchedule is the release schedule of a locked balance. Nothing is
+// released before the Cliff block, then the balance is released linearly from
+// the Start block to the End block. A cliff vesting has Cliff equal to End, a
+// linear vesting has Cliff equal to Start.
+type VestingSchedule struct {
+	Start    uint64 `json:"start"`
+	Cliff    uint64 `json:"cliff"`
+	End      uint64 `json:"end"`
+	Stakable bool   `json:"stakable"` // Whether the locked balance can be staked before it is released
+}
+
+// Validate checks that the blocks of the schedule are in order.
+func (s *VestingSchedule) Validate() error {
+	if s.Start > s.Cliff || s.Cliff > s.End || s.End == 0 {
+		return ErrInvalidVesting
+	}
+	return nil
+}
+
+// Vested returns the part of amount released at the given block number.
+func (s *VestingSchedule) Vested(amount *big.Int, number uint64) *big.Int {
+	switch {
+	case number < s.Cliff || number < s.Start:
+		return new(big.Int)
+	case number >= s.End:
+		return new(big.Int).Set(amount)
+	}
+	vested := new(big.Int).Mul(amount, new(big.Int).SetUint64(number-s.Start))
+	return vested.Div(vested, new(big.Int).SetUint64(s.End-s.Start))
+}
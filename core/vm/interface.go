@@ -71,6 +71,12 @@ type StateDB interface {
 	AddStakeBalance(common.Address, *big.Int, *big.Int)
 	RemoveStakeBalance(common.Address)
 
+	//Rewards
+	GetMaturedReward(common.Address, *big.Int) *big.Int
+	SubMaturedReward(common.Address, *big.Int, *big.Int)
+	GetAutoCompound(common.Address) bool
+	SetAutoCompound(common.Address, bool)
+
 	//Selection Point
 	SetPoint(addr common.Address, amount *big.Int)
 	GetPoint(common.Address) *big.Int
@@ -81,6 +87,20 @@ type StateDB interface {
 	RemovePenalty(common.Address, *big.Int)
 	GetPenalty(common.Address) uint64
 	GetPenaltyUpdated(common.Address) *big.Int
+
+	//Multisig
+	GetMultisig(common.Address) *types.MultisigConfig
+	SetMultisig(common.Address, *types.MultisigConfig)
+
+	//Key migration
+	MigrateAccount(from common.Address, to common.Address)
+
+	//Vesting
+	AddVesting(common.Address, types.VestingSchedule, *big.Int)
+	ReleaseVesting(common.Address, *big.Int)
+	GetVestingCount(common.Address) int
+	GetStakableVesting(common.Address) *big.Int
+	StakeVesting(common.Address, *big.Int, *big.Int)
 }
 
 // CallContext provides a basic interface for the EVM calling conventions. The EVM
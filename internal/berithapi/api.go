@@ -32,6 +32,7 @@ import (
 	"github.com/BerithFoundation/berith-chain/common/math"
 	"github.com/BerithFoundation/berith-chain/core"
 	"github.com/BerithFoundation/berith-chain/core/rawdb"
+	"github.com/BerithFoundation/berith-chain/core/state"
 	"github.com/BerithFoundation/berith-chain/core/types"
 	"github.com/BerithFoundation/berith-chain/core/vm"
 	"github.com/BerithFoundation/berith-chain/crypto"
@@ -116,7 +117,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range pending {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = NewRPCPendingTransaction(tx)
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -124,20 +125,32 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range queue {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = NewRPCPendingTransaction(tx)
 		}
 		content["queued"][account.Hex()] = dump
 	}
 	return content
 }
 
-// Status returns the number of pending and queued transaction in the pool.
-func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
+// Status returns the number of pending and queued transaction in the pool,
+// and the persistence statistics of the pool journal if journaling is enabled.
+func (s *PublicTxPoolAPI) Status() map[string]interface{} {
 	pending, queue := s.b.Stats()
-	return map[string]hexutil.Uint{
+	status := map[string]interface{}{
 		"pending": hexutil.Uint(pending),
 		"queued":  hexutil.Uint(queue),
 	}
+	if stats, ok := s.b.TxPoolJournalStats(); ok {
+		status["journal"] = map[string]interface{}{
+			"backend":  stats.Backend,
+			"entries":  hexutil.Uint(stats.Entries),
+			"loaded":   hexutil.Uint(stats.Loaded),
+			"dropped":  hexutil.Uint(stats.Dropped),
+			"inserted": hexutil.Uint64(stats.Inserted),
+			"rotated":  hexutil.Uint64(stats.Rotated),
+		}
+	}
+	return status
 }
 
 // Inspect retrieves the content of the transaction pool and flattens it into an
@@ -518,12 +531,8 @@ func (s *PublicBlockChainAPI) GetBalance(ctx context.Context, address common.Add
 
 // Result structs for GetProof
 type AccountResult struct {
-	Address      common.Address  `json:"address"`
+	AccountState
 	AccountProof []string        `json:"accountProof"`
-	Balance      *hexutil.Big    `json:"balance"`
-	CodeHash     common.Hash     `json:"codeHash"`
-	Nonce        hexutil.Uint64  `json:"nonce"`
-	StorageHash  common.Hash     `json:"storageHash"`
 	StorageProof []StorageResult `json:"storageProof"`
 }
 type StorageResult struct {
@@ -532,7 +541,44 @@ type StorageResult struct {
 	Proof []string     `json:"proof"`
 }
 
+// AccountState is the complete Berith account model of an address. Together with
+// the account proof it allows to verify the stake and the pending rewards of an
+// account against the state root of a header, by re-encoding the account leaf.
+type AccountState struct {
+	Address common.Address `json:"address"`
+	state.AccountJSON
+}
+
+// accountState gathers the account model of an address from the given state.
+func accountState(statedb *state.StateDB, address common.Address) AccountState {
+	data := statedb.GetAccount(address)
+	if data == nil {
+		// the account does not exist, so the codeHash is the hash of an empty bytearray.
+		data = &state.Account{Root: types.EmptyRootHash, CodeHash: crypto.Keccak256(nil)}
+	}
+	account := AccountState{Address: address, AccountJSON: *state.NewAccountJSON(data)}
+	// if we have a storageTrie, (which means the account exists), we can update the storagehash
+	if storageTrie := statedb.StorageTrie(address); storageTrie != nil {
+		account.StorageHash = storageTrie.Hash()
+	}
+	return account
+}
+
+// GetAccount returns the complete account model of an address, including the
+// stake, the selection point, the rewards of the behind balance and the penalty,
+// in the state of the given block.
+func (s *PublicBlockChainAPI) GetAccount(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*AccountState, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	account := accountState(state, address)
+	return &account, state.Error()
+}
+
 // GetProof returns the Merkle-proof for a given account and optionally some storage keys.
+// The account is returned with all its Berith fields, so that the proof can be verified
+// against the state root of the block.
 func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*AccountResult, error) {
 	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
@@ -540,18 +586,8 @@ func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Addre
 	}
 
 	storageTrie := state.StorageTrie(address)
-	storageHash := types.EmptyRootHash
-	codeHash := state.GetCodeHash(address)
 	storageProof := make([]StorageResult, len(storageKeys))
 
-	// if we have a storageTrie, (which means the account exists), we can update the storagehash
-	if storageTrie != nil {
-		storageHash = storageTrie.Hash()
-	} else {
-		// no storageTrie means the account does not exist, so the codeHash is the hash of an empty bytearray.
-		codeHash = crypto.Keccak256Hash(nil)
-	}
-
 	// create the proof for the storageKeys
 	for i, key := range storageKeys {
 		if storageTrie != nil {
@@ -572,12 +608,8 @@ func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Addre
 	}
 
 	return &AccountResult{
-		Address:      address,
+		AccountState: accountState(state, address),
 		AccountProof: common.ToHexArray(accountProof),
-		Balance:      (*hexutil.Big)(state.GetBalance(address)),
-		CodeHash:     codeHash,
-		Nonce:        hexutil.Uint64(state.GetNonce(address)),
-		StorageHash:  storageHash,
 		StorageProof: storageProof,
 	}, state.Error()
 }
@@ -963,7 +995,7 @@ type RPCTransaction struct {
 func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber uint64, index uint64, base types.JobWallet, target types.JobWallet) *RPCTransaction {
 	var signer types.Signer = types.FrontierSigner{}
 	if tx.Protected() {
-		signer = types.NewEIP155Signer(tx.ChainId())
+		signer = types.NewBIP7Signer(tx.ChainId())
 	}
 	from, _ := types.Sender(signer, tx)
 	v, r, s := tx.RawSignatureValues()
@@ -991,8 +1023,8 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 	return result
 }
 
-// newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
-func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
+// NewRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
+func NewRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 	return newRPCTransaction(tx, common.Hash{}, 0, 0, tx.Base(), tx.Target())
 }
 
@@ -1113,12 +1145,103 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, has
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+		return NewRPCPendingTransaction(tx)
 	}
 	// Transaction unknown, return as such
 	return nil
 }
 
+// addressTxPageSize is the number of transactions per page returned by
+// GetTransactionsByAddress.
+const addressTxPageSize = 100
+
+// AddressTransactions is a page of the transactions sent from or to an address.
+type AddressTransactions struct {
+	Transactions []*RPCTransaction `json:"transactions"`
+	Page         hexutil.Uint64    `json:"page"`
+	HasMore      bool              `json:"hasMore"`
+}
+
+// GetTransactionsByAddress returns the transactions sent from or to the given
+// address within the block range, oldest first, in pages of 100 transactions.
+// The blocks covered by the address index are looked up in it, the few recent
+// blocks not yet indexed are scanned.
+func (s *PublicTransactionPoolAPI) GetTransactionsByAddress(ctx context.Context, address common.Address, fromBlock, toBlock rpc.BlockNumber, page hexutil.Uint64) (*AddressTransactions, error) {
+	size, sections := s.b.AddressIndexStatus()
+	if size == 0 {
+		return nil, errors.New("address index is disabled")
+	}
+	head := s.b.CurrentBlock().NumberU64()
+	begin, end := uint64(fromBlock), uint64(toBlock)
+	if fromBlock < 0 {
+		begin = head
+	}
+	if toBlock < 0 || end > head {
+		end = head
+	}
+	var (
+		db      = s.b.ChainDb()
+		entries []rawdb.AddressTxEntry
+	)
+	// The blocks below the base of a chain bootstrapped from a state snapshot
+	// are missing
+	if base := rawdb.ReadChainBase(db); base != nil && begin < *base {
+		begin = *base
+	}
+	for section := begin / size; section < sections && section*size <= end; section++ {
+		for _, entry := range rawdb.ReadAddressTxEntries(db, address, section) {
+			if entry.BlockNumber < begin || entry.BlockNumber > end {
+				continue
+			}
+			// Skip the transactions of blocks reorged away after indexing
+			blockHash, number, _ := rawdb.ReadTxLookupEntry(db, entry.Hash)
+			if number != entry.BlockNumber || blockHash != rawdb.ReadCanonicalHash(db, number) {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+	if indexed := sections * size; begin < indexed {
+		begin = indexed
+	}
+	if end >= begin && end-begin >= 2*size {
+		return nil, fmt.Errorf("address index is still being built, indexed up to block #%d", sections*size)
+	}
+	for number := begin; number <= end; number++ {
+		block, err := s.b.BlockByNumber(ctx, rpc.BlockNumber(number))
+		if block == nil || err != nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+		signer := types.MakeSigner(s.b.ChainConfig(), block.Number())
+		for i, tx := range block.Transactions() {
+			from, _ := types.Sender(signer, tx)
+			if from == address || (tx.To() != nil && *tx.To() == address) {
+				entries = append(entries, rawdb.AddressTxEntry{BlockNumber: number, Index: uint64(i), Hash: tx.Hash()})
+			}
+		}
+	}
+	result := &AddressTransactions{
+		Transactions: []*RPCTransaction{},
+		Page:         page,
+	}
+	first := uint64(page) * addressTxPageSize
+	if first >= uint64(len(entries)) {
+		return result, nil
+	}
+	last := first + addressTxPageSize
+	if last >= uint64(len(entries)) {
+		last = uint64(len(entries))
+	} else {
+		result.HasMore = true
+	}
+	for _, entry := range entries[first:last] {
+		if tx, blockHash, number, index, base, target := rawdb.ReadTransaction(db, entry.Hash); tx != nil {
+			result.Transactions = append(result.Transactions, newRPCTransaction(tx, blockHash, number, index, base, target))
+		}
+	}
+	return result, nil
+}
+
 // GetRawTransactionByHash returns the bytes of the transaction for the given hash.
 func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
 	var tx *types.Transaction
@@ -1151,7 +1274,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 
 	var signer types.Signer = types.FrontierSigner{}
 	if tx.Protected() {
-		signer = types.NewEIP155Signer(tx.ChainId())
+		signer = types.NewBIP7Signer(tx.ChainId())
 	}
 	from, _ := types.Sender(signer, tx)
 
@@ -1415,11 +1538,11 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() ([]*RPCTransaction, err
 	for _, tx := range pending {
 		var signer types.Signer = types.HomesteadSigner{}
 		if tx.Protected() {
-			signer = types.NewEIP155Signer(tx.ChainId())
+			signer = types.NewBIP7Signer(tx.ChainId())
 		}
 		from, _ := types.Sender(signer, tx)
 		if _, exists := accounts[from]; exists {
-			transactions = append(transactions, newRPCPendingTransaction(tx))
+			transactions = append(transactions, NewRPCPendingTransaction(tx))
 		}
 	}
 	return transactions, nil
@@ -1443,7 +1566,7 @@ func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, sendArgs SendTxAr
 	for _, p := range pending {
 		var signer types.Signer = types.HomesteadSigner{}
 		if p.Protected() {
-			signer = types.NewEIP155Signer(p.ChainId())
+			signer = types.NewBIP7Signer(p.ChainId())
 		}
 		wantSigHash := signer.Hash(matchTx)
 
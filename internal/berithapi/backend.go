@@ -64,11 +64,16 @@ type Backend interface {
 	GetPoolTransaction(txHash common.Hash) *types.Transaction
 	GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
 	Stats() (pending int, queued int)
+	TxPoolJournalStats() (core.TxJournalStats, bool)
 	TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
 	SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription
 
 	ChainConfig() *params.ChainConfig
 	CurrentBlock() *types.Block
+
+	// AddressIndexStatus returns the section size and the number of sections of
+	// the address to transaction index, or zeroes if the index is disabled.
+	AddressIndexStatus() (uint64, uint64)
 }
 
 func GetAPIs(apiBackend Backend) []rpc.API {
@@ -0,0 +1,162 @@
+package berithapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/BerithFoundation/berith-chain/accounts"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/common/hexutil"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/rlp"
+	"github.com/BerithFoundation/berith-chain/rpc"
+)
+
+// MultisigSetupArgs represents the arguments to create a new multisig account.
+type MultisigSetupArgs struct {
+	From      common.Address   `json:"from"`
+	Owners    []common.Address `json:"owners"`
+	Threshold hexutil.Uint64   `json:"threshold"`
+	Gas       *hexutil.Uint64  `json:"gas"`
+	GasPrice  *hexutil.Big     `json:"gasPrice"`
+	Value     *hexutil.Big     `json:"value"`
+	Nonce     *hexutil.Uint64  `json:"nonce"`
+}
+
+// MultisigSetupResult represents the multisig account created by a submitted
+// setup transaction.
+type MultisigSetupResult struct {
+	Account common.Address `json:"account"`
+	Hash    common.Hash    `json:"hash"`
+}
+
+// MultisigTransactionResult represents an unsigned multisig transaction and the
+// hash its owners have to sign.
+type MultisigTransactionResult struct {
+	Raw  hexutil.Bytes      `json:"raw"`
+	Tx   *types.Transaction `json:"tx"`
+	Hash common.Hash        `json:"hash"`
+}
+
+// CreateMultisigAccount submits a transaction of the from account creating a
+// multisig account of the given owners, funded with the value of the
+// transaction. The account exists once the transaction is mined.
+func (s *PublicTransactionPoolAPI) CreateMultisigAccount(ctx context.Context, args MultisigSetupArgs) (*MultisigSetupResult, error) {
+	// Before BIP7 the setup transaction would be a plain transfer, losing the value
+	next := new(big.Int).Add(s.b.CurrentBlock().Number(), big.NewInt(1))
+	if !s.b.ChainConfig().IsBIP7(next) {
+		return nil, errors.New("multisig accounts are not enabled yet")
+	}
+	config := &types.MultisigConfig{Threshold: uint64(args.Threshold), Owners: args.Owners}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := rlp.EncodeToBytes(config)
+	if err != nil {
+		return nil, err
+	}
+	to := types.MultisigSetupAddress
+	sendArgs := SendTxArgs{
+		From:     args.From,
+		To:       &to,
+		Gas:      args.Gas,
+		GasPrice: args.GasPrice,
+		Value:    args.Value,
+		Nonce:    args.Nonce,
+		Data:     (*hexutil.Bytes)(&data),
+		Base:     "main",
+		Target:   "main",
+	}
+	if args.Nonce == nil {
+		// Hold the addresse's mutex around signing to prevent concurrent assignment of
+		// the same nonce to multiple accounts.
+		s.nonceLock.LockAddr(args.From)
+		defer s.nonceLock.UnlockAddr(args.From)
+	}
+	if err := sendArgs.setDefaults(ctx, s.b); err != nil {
+		return nil, err
+	}
+	signed, err := s.sign(args.From, sendArgs.toTransaction())
+	if err != nil {
+		return nil, err
+	}
+	hash, err := submitTransaction(ctx, s.b, signed)
+	if err != nil {
+		return nil, err
+	}
+	return &MultisigSetupResult{types.MultisigAddress(args.From, uint64(*sendArgs.Nonce)), hash}, nil
+}
+
+// GetMultisigAccount returns the configuration of a multisig account for the
+// given block number, nil if the address is not a multisig account.
+func (s *PublicTransactionPoolAPI) GetMultisigAccount(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*types.MultisigConfig, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	return state.GetMultisig(address), state.Error()
+}
+
+// NewMultisigTransaction creates an unsigned transaction of the multisig account
+// given as from. The owners sign it with SignMultisigTransaction and it is
+// submitted with SendRawTransaction once it has enough signatures.
+func (s *PublicTransactionPoolAPI) NewMultisigTransaction(ctx context.Context, args SendTxArgs) (*MultisigTransactionResult, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, rpc.PendingBlockNumber)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	if state.GetMultisig(args.From) == nil {
+		return nil, types.ErrNotMultisig
+	}
+	if err := args.setDefaults(ctx, s.b); err != nil {
+		return nil, err
+	}
+	chainID := s.b.ChainConfig().ChainID
+	tx := types.NewMultisigTransaction(chainID, args.From, args.toTransaction())
+
+	data, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	return &MultisigTransactionResult{data, tx, types.NewBIP7Signer(chainID).Hash(tx)}, nil
+}
+
+// SignMultisigTransaction adds the signature of the given owner to an encoded
+// multisig transaction. The node needs to have the private key of the owner and
+// it needs to be unlocked.
+func (s *PublicTransactionPoolAPI) SignMultisigTransaction(ctx context.Context, owner common.Address, encodedTx hexutil.Bytes) (*SignTransactionResult, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
+		return nil, err
+	}
+	if tx.Multisig() == nil {
+		return nil, types.ErrNotMultisig
+	}
+	// Look up the wallet containing the requested owner
+	account := accounts.Account{Address: owner}
+
+	wallet, err := s.b.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signer := types.NewBIP7Signer(s.b.ChainConfig().ChainID)
+	hash := signer.Hash(tx)
+	sig, err := wallet.SignHash(account, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	signed, err := tx.WithMultisigSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	// Reject signing twice, the approvals of an owner are only counted once
+	if _, err := signer.Approvers(signed); err != nil {
+		return nil, err
+	}
+	data, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		return nil, err
+	}
+	return &SignTransactionResult{data, signed}, nil
+}
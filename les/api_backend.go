@@ -135,6 +135,14 @@ func (b *LesApiBackend) Stats() (pending int, queued int) {
 	return b.e.txPool.Stats(), 0
 }
 
+func (b *LesApiBackend) TxPoolJournalStats() (core.TxJournalStats, bool) {
+	return core.TxJournalStats{}, false
+}
+
+func (b *LesApiBackend) AddressIndexStatus() (uint64, uint64) {
+	return 0, 0
+}
+
 func (b *LesApiBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
 	return b.e.txPool.Content()
 }
@@ -94,11 +94,10 @@ func New(ctx *node.ServiceContext, config *berith.Config) (*LightBerith, error)
 	peers := newPeerSet()
 	quitSync := make(chan struct{})
 
-	stakingDB := &stakingdb.StakingDB{}
-	stakingDBPath := ctx.ResolvePath("stakingDB")
-	if stkErr := stakingDB.CreateDB(stakingDBPath, staking.NewStakers); stkErr != nil {
-		return nil, stkErr
+	if err := stakingdb.Migrate(chainDb, ctx.ResolvePath("stakingDB")); err != nil {
+		return nil, err
 	}
+	stakingDB := stakingdb.NewStakingDB(chainDb, staking.NewStakers)
 
 	lber := &LightBerith{
 		lesCommons: lesCommons{
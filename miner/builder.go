@@ -0,0 +1,305 @@
+package miner
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/types"
+)
+
+// Names of the block builders shipped with the miner.
+const (
+	PriceBuilder    = "price"    // Gas price and nonce ordering, locals first
+	FCFSBuilder     = "fcfs"     // First come first served ordering
+	PriorityBuilder = "priority" // Whitelisted senders first, then gas price ordering
+)
+
+var (
+	errUnknownBuilder   = errors.New("unknown block builder")
+	errNoPrioritySender = errors.New("priority builder requires at least one priority sender")
+)
+
+// TransactionSet is an ordered set of transactions a block is filled from. The
+// worker executes Peek, then either Shift to continue with the next transaction
+// of the same sender, or Pop to drop the remaining transactions of the sender.
+type TransactionSet interface {
+	Peek() *types.Transaction
+	Shift()
+	Pop()
+}
+
+// BuildContext holds the information a block builder may rely on to order the
+// pending transactions of a new block.
+type BuildContext struct {
+	Signer  types.Signer
+	Header  *types.Header                         // Header of the block being built
+	Pending map[common.Address]types.Transactions // Executable pool transactions, owned by the builder
+	Locals  []common.Address                      // Accounts treated as local by the pool
+}
+
+// BlockBuilder decides which pending transactions go into a new block and in
+// which order. The returned sets are committed one after the other until the
+// block is full.
+type BlockBuilder interface {
+	Build(ctx *BuildContext) []TransactionSet
+}
+
+// TxObserver is an optional interface of block builders interested in the
+// transactions entering the pool, e.g. to track their arrival order.
+type TxObserver interface {
+	ObserveTxs(txs []*types.Transaction)
+}
+
+// BuilderConfig holds the operator settings passed to the block builder
+// constructors.
+type BuilderConfig struct {
+	PrioritySenders []common.Address // Senders served by the priority lane
+}
+
+// BuilderConstructor creates a block builder from the operator settings.
+type BuilderConstructor func(config *BuilderConfig) (BlockBuilder, error)
+
+var (
+	buildersMu sync.RWMutex
+	builders   = map[string]BuilderConstructor{
+		PriceBuilder: func(*BuilderConfig) (BlockBuilder, error) { return new(priceBuilder), nil },
+		FCFSBuilder:  func(*BuilderConfig) (BlockBuilder, error) { return newFCFSBuilder(), nil },
+		PriorityBuilder: func(config *BuilderConfig) (BlockBuilder, error) {
+			if len(config.PrioritySenders) == 0 {
+				return nil, errNoPrioritySender
+			}
+			return newPriorityBuilder(config.PrioritySenders), nil
+		},
+	}
+)
+
+// RegisterBlockBuilder makes a block builder available under the given name,
+// allowing operators to plug alternative ordering strategies into the worker
+// without forking it. Registering an existing name replaces the builder.
+func RegisterBlockBuilder(name string, constructor BuilderConstructor) {
+	buildersMu.Lock()
+	defer buildersMu.Unlock()
+
+	builders[name] = constructor
+}
+
+// BlockBuilders returns the names of the registered block builders.
+func BlockBuilders() []string {
+	buildersMu.RLock()
+	defer buildersMu.RUnlock()
+
+	names := make([]string, 0, len(builders))
+	for name := range builders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewBlockBuilder creates the registered block builder with the given name.
+// The empty name selects the default gas price ordering.
+func NewBlockBuilder(name string, config *BuilderConfig) (BlockBuilder, error) {
+	if name == "" {
+		name = PriceBuilder
+	}
+	buildersMu.RLock()
+	constructor, ok := builders[name]
+	buildersMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%v: %q", errUnknownBuilder, name)
+	}
+	if config == nil {
+		config = new(BuilderConfig)
+	}
+	return constructor(config)
+}
+
+// priceBuilder is the default block builder, filling the block with local
+// transactions first and then remote ones, each by gas price and nonce.
+type priceBuilder struct{}
+
+func (b *priceBuilder) Build(ctx *BuildContext) []TransactionSet {
+	// Split the pending transactions into locals and remotes
+	localTxs, remoteTxs := make(map[common.Address]types.Transactions), ctx.Pending
+	for _, account := range ctx.Locals {
+		if txs := remoteTxs[account]; len(txs) > 0 {
+			delete(remoteTxs, account)
+			localTxs[account] = txs
+		}
+	}
+	var sets []TransactionSet
+	if len(localTxs) > 0 {
+		sets = append(sets, types.NewTransactionsByPriceAndNonce(ctx.Signer, localTxs))
+	}
+	if len(remoteTxs) > 0 {
+		sets = append(sets, types.NewTransactionsByPriceAndNonce(ctx.Signer, remoteTxs))
+	}
+	return sets
+}
+
+// priorityBuilder serves the transactions of whitelisted senders before any
+// other, both lanes ordered by gas price and nonce.
+type priorityBuilder struct {
+	senders map[common.Address]struct{}
+}
+
+func newPriorityBuilder(senders []common.Address) *priorityBuilder {
+	b := &priorityBuilder{senders: make(map[common.Address]struct{})}
+	for _, sender := range senders {
+		b.senders[sender] = struct{}{}
+	}
+	return b
+}
+
+func (b *priorityBuilder) Build(ctx *BuildContext) []TransactionSet {
+	priority, others := make(map[common.Address]types.Transactions), ctx.Pending
+	for sender := range b.senders {
+		if txs := others[sender]; len(txs) > 0 {
+			delete(others, sender)
+			priority[sender] = txs
+		}
+	}
+	var sets []TransactionSet
+	if len(priority) > 0 {
+		sets = append(sets, types.NewTransactionsByPriceAndNonce(ctx.Signer, priority))
+	}
+	if len(others) > 0 {
+		sets = append(sets, types.NewTransactionsByPriceAndNonce(ctx.Signer, others))
+	}
+	return sets
+}
+
+// fcfsBuilder orders the pending transactions by the time they were first seen
+// by the worker, ignoring the gas price. Transactions the worker never saw
+// arriving (e.g. loaded from the journal on startup) go after all others.
+type fcfsBuilder struct {
+	mu   sync.Mutex
+	seq  uint64
+	seen map[common.Hash]uint64 // Arrival sequence number of pool transactions
+}
+
+func newFCFSBuilder() *fcfsBuilder {
+	return &fcfsBuilder{seen: make(map[common.Hash]uint64)}
+}
+
+// ObserveTxs records the arrival order of new pool transactions.
+func (b *fcfsBuilder) ObserveTxs(txs []*types.Transaction) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, tx := range txs {
+		if _, ok := b.seen[tx.Hash()]; !ok {
+			b.seq++
+			b.seen[tx.Hash()] = b.seq
+		}
+	}
+}
+
+func (b *fcfsBuilder) Build(ctx *BuildContext) []TransactionSet {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Forget about transactions that already left the pool
+	live := make(map[common.Hash]uint64)
+	for _, txs := range ctx.Pending {
+		for _, tx := range txs {
+			if seq, ok := b.seen[tx.Hash()]; ok {
+				live[tx.Hash()] = seq
+			}
+		}
+	}
+	b.seen = live
+
+	if len(ctx.Pending) == 0 {
+		return nil
+	}
+	return []TransactionSet{newTxsByArrivalAndNonce(ctx.Signer, ctx.Pending, live)}
+}
+
+// arrivalHead is the next transaction of an account with its arrival order.
+type arrivalHead struct {
+	tx  *types.Transaction
+	seq uint64
+}
+
+type arrivalHeap []arrivalHead
+
+func (h arrivalHeap) Len() int { return len(h) }
+func (h arrivalHeap) Less(i, j int) bool {
+	if h[i].seq != h[j].seq {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].tx.Hash().Big().Cmp(h[j].tx.Hash().Big()) < 0
+}
+func (h arrivalHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *arrivalHeap) Push(x interface{}) { *h = append(*h, x.(arrivalHead)) }
+func (h *arrivalHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+// txsByArrivalAndNonce is a TransactionSet returning transactions in arrival
+// order while honouring the nonce order of every account.
+type txsByArrivalAndNonce struct {
+	txs    map[common.Address]types.Transactions
+	heads  arrivalHeap
+	seen   map[common.Hash]uint64
+	signer types.Signer
+}
+
+func newTxsByArrivalAndNonce(signer types.Signer, txs map[common.Address]types.Transactions, seen map[common.Hash]uint64) *txsByArrivalAndNonce {
+	set := &txsByArrivalAndNonce{
+		txs:    make(map[common.Address]types.Transactions, len(txs)),
+		heads:  make(arrivalHeap, 0, len(txs)),
+		seen:   seen,
+		signer: signer,
+	}
+	for _, accTxs := range txs {
+		acc, _ := types.Sender(signer, accTxs[0])
+		set.heads = append(set.heads, set.head(accTxs[0]))
+		set.txs[acc] = accTxs[1:]
+	}
+	heap.Init(&set.heads)
+	return set
+}
+
+// head wraps a transaction with its arrival order, unseen ones go last.
+func (t *txsByArrivalAndNonce) head(tx *types.Transaction) arrivalHead {
+	seq, ok := t.seen[tx.Hash()]
+	if !ok {
+		seq = ^uint64(0)
+	}
+	return arrivalHead{tx: tx, seq: seq}
+}
+
+// Peek returns the earliest arrived transaction.
+func (t *txsByArrivalAndNonce) Peek() *types.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0].tx
+}
+
+// Shift replaces the current head with the next one from the same account.
+func (t *txsByArrivalAndNonce) Shift() {
+	acc, _ := types.Sender(t.signer, t.heads[0].tx)
+	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
+		t.heads[0], t.txs[acc] = t.head(txs[0]), txs[1:]
+		heap.Fix(&t.heads, 0)
+	} else {
+		heap.Pop(&t.heads)
+	}
+}
+
+// Pop removes the current head without replacing it from the same account.
+func (t *txsByArrivalAndNonce) Pop() {
+	heap.Pop(&t.heads)
+}
@@ -0,0 +1,136 @@
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/crypto"
+)
+
+var builderSigner = types.HomesteadSigner{}
+
+// signedTx returns a transfer of the given key with the given nonce and price.
+func signedTx(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, price int64) *types.Transaction {
+	tx := types.NewTransaction(nonce, common.Address{}, big.NewInt(1), 21000, big.NewInt(price), nil, types.Main, types.Main)
+	tx, err := types.SignTx(tx, builderSigner, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// drain returns the transactions of a set in the order the worker commits them.
+func drain(set TransactionSet) []*types.Transaction {
+	var txs []*types.Transaction
+	for tx := set.Peek(); tx != nil; tx = set.Peek() {
+		txs = append(txs, tx)
+		set.Shift()
+	}
+	return txs
+}
+
+// checkOrder fails if the transactions aren't the wanted ones in that order.
+func checkOrder(t *testing.T, name string, have, want []*types.Transaction) {
+	if len(have) != len(want) {
+		t.Fatalf("%s: transaction count mismatch: have %d, want %d", name, len(have), len(want))
+	}
+	for i := range have {
+		if have[i].Hash() != want[i].Hash() {
+			t.Errorf("%s: transaction %d mismatch: have %x, want %x", name, i, have[i].Hash(), want[i].Hash())
+		}
+	}
+}
+
+// Tests that the first come first served builder orders the transactions by
+// arrival whatever their price, keeps the nonce order of every sender and puts
+// the transactions it never saw arriving last.
+func TestFCFSBuilder(t *testing.T) {
+	keyA, _ := crypto.GenerateKey()
+	keyB, _ := crypto.GenerateKey()
+	keyC, _ := crypto.GenerateKey()
+	var (
+		a0, a1 = signedTx(t, keyA, 0, 1), signedTx(t, keyA, 1, 1)
+		b0     = signedTx(t, keyB, 0, 100)
+		c0     = signedTx(t, keyC, 0, 1000)
+		gone   = signedTx(t, keyC, 5, 1)
+	)
+	builder, err := NewBlockBuilder(FCFSBuilder, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second transaction of A arrives before the first one
+	builder.(TxObserver).ObserveTxs([]*types.Transaction{a1, b0, gone, a0})
+
+	sets := builder.Build(&BuildContext{
+		Signer: builderSigner,
+		Pending: map[common.Address]types.Transactions{
+			crypto.PubkeyToAddress(keyA.PublicKey): {a0, a1},
+			crypto.PubkeyToAddress(keyB.PublicKey): {b0},
+			crypto.PubkeyToAddress(keyC.PublicKey): {c0},
+		},
+	})
+	if len(sets) != 1 {
+		t.Fatalf("set count mismatch: have %d, want 1", len(sets))
+	}
+	checkOrder(t, "fcfs", drain(sets[0]), []*types.Transaction{b0, a0, a1, c0})
+
+	// Transactions which left the pool are forgotten
+	if _, ok := builder.(*fcfsBuilder).seen[gone.Hash()]; ok {
+		t.Error("transaction gone from the pool still tracked")
+	}
+}
+
+// Tests that the priority builder commits the transactions of the whitelisted
+// senders before the better paying ones of the other senders.
+func TestPriorityBuilder(t *testing.T) {
+	keyA, _ := crypto.GenerateKey()
+	keyB, _ := crypto.GenerateKey()
+	var (
+		addrA  = crypto.PubkeyToAddress(keyA.PublicKey)
+		addrB  = crypto.PubkeyToAddress(keyB.PublicKey)
+		a0, a1 = signedTx(t, keyA, 0, 1), signedTx(t, keyA, 1, 1)
+		b0     = signedTx(t, keyB, 0, 100)
+	)
+	if _, err := NewBlockBuilder(PriorityBuilder, nil); err != errNoPrioritySender {
+		t.Fatalf("priority builder without senders: have %v, want %v", err, errNoPrioritySender)
+	}
+	builder, err := NewBlockBuilder(PriorityBuilder, &BuilderConfig{PrioritySenders: []common.Address{addrA}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sets := builder.Build(&BuildContext{
+		Signer:  builderSigner,
+		Pending: map[common.Address]types.Transactions{addrA: {a0, a1}, addrB: {b0}},
+	})
+	if len(sets) != 2 {
+		t.Fatalf("lane count mismatch: have %d, want 2", len(sets))
+	}
+	checkOrder(t, "priority lane", drain(sets[0]), []*types.Transaction{a0, a1})
+	checkOrder(t, "other lane", drain(sets[1]), []*types.Transaction{b0})
+}
+
+// Tests that builders are created by their registered name.
+func TestNewBlockBuilder(t *testing.T) {
+	if builder, err := NewBlockBuilder("", nil); err != nil {
+		t.Fatal(err)
+	} else if _, ok := builder.(*priceBuilder); !ok {
+		t.Errorf("default builder is %T, want the price builder", builder)
+	}
+	if _, err := NewBlockBuilder("unknown", nil); err == nil {
+		t.Error("unknown builder created")
+	}
+	RegisterBlockBuilder("test", func(*BuilderConfig) (BlockBuilder, error) { return newFCFSBuilder(), nil })
+	defer func() {
+		buildersMu.Lock()
+		delete(builders, "test")
+		buildersMu.Unlock()
+	}()
+	if builder, err := NewBlockBuilder("test", nil); err != nil {
+		t.Fatal(err)
+	} else if _, ok := builder.(*fcfsBuilder); !ok {
+		t.Errorf("registered builder is %T, want the fcfs builder", builder)
+	}
+}
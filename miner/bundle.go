@@ -0,0 +1,111 @@
+package miner
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/log"
+)
+
+// maxBundles is the maximum number of bundles waiting for inclusion.
+const maxBundles = 256
+
+var (
+	errEmptyBundle    = errors.New("empty bundle")
+	errBundlePoolFull = errors.New("bundle pool is full")
+	errStaleBundle    = errors.New("bundle targets a past block")
+)
+
+// Bundle is an ordered list of transactions to be included atomically, either
+// all of them at the top of the target block or none at all.
+type Bundle struct {
+	Txs         types.Transactions
+	BlockNumber uint64 // Block the bundle targets
+}
+
+// bundlePool holds the bundles submitted through the private miner API until
+// their target block passes. A bundle is retried on every recommit of its
+// target block, so it is included at most once per canonical chain.
+type bundlePool struct {
+	mu      sync.Mutex
+	bundles []*Bundle
+}
+
+// add queues a bundle for inclusion.
+func (p *bundlePool) add(bundle *Bundle, head uint64) error {
+	if len(bundle.Txs) == 0 {
+		return errEmptyBundle
+	}
+	if bundle.BlockNumber <= head {
+		return errStaleBundle
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.bundles) >= maxBundles {
+		return errBundlePoolFull
+	}
+	p.bundles = append(p.bundles, bundle)
+	return nil
+}
+
+// pending drops the bundles whose target block passed and returns the ones
+// eligible for the given block number.
+func (p *bundlePool) pending(number uint64) []*Bundle {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var (
+		live    = p.bundles[:0]
+		pending []*Bundle
+	)
+	for _, bundle := range p.bundles {
+		if bundle.BlockNumber < number {
+			continue
+		}
+		live = append(live, bundle)
+		if bundle.BlockNumber == number {
+			pending = append(pending, bundle)
+		}
+	}
+	p.bundles = live
+	return pending
+}
+
+// commitBundle executes all transactions of the bundle on top of the current
+// environment, reverting the whole bundle if any of them fails.
+func (w *worker) commitBundle(bundle *Bundle, coinbase common.Address) error {
+	// Every transaction finalises the state and clears its journal, so the
+	// state is restored from a copy rather than from a snapshot.
+	var (
+		state    = w.current.state.Copy()
+		gas      = w.current.gasPool.Gas()
+		gasUsed  = w.current.header.GasUsed
+		txs      = len(w.current.txs)
+		receipts = len(w.current.receipts)
+		tcount   = w.current.tcount
+	)
+	for _, tx := range bundle.Txs {
+		err := core.ErrInvalidSender
+		if !tx.Protected() || w.config.IsEIP155(w.current.header.Number) {
+			w.current.state.Prepare(tx.Hash(), common.Hash{}, w.current.tcount)
+			_, err = w.commitTransaction(tx, coinbase)
+		}
+		if err != nil {
+			log.Debug("Bundle transaction failed, bundle discarded", "hash", tx.Hash(), "err", err)
+
+			w.current.state = state
+			w.current.gasPool = new(core.GasPool).AddGas(gas)
+			w.current.header.GasUsed = gasUsed
+			w.current.txs = w.current.txs[:txs]
+			w.current.receipts = w.current.receipts[:receipts]
+			w.current.tcount = tcount
+			return err
+		}
+		w.current.tcount++
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/BerithFoundation/berith-chain/berith/staking"
+	"github.com/BerithFoundation/berith-chain/berith/stakingdb"
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/consensus/bsrr"
+	"github.com/BerithFoundation/berith-chain/core"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/core/vm"
+	"github.com/BerithFoundation/berith-chain/crypto"
+	"github.com/BerithFoundation/berith-chain/params"
+)
+
+var (
+	bundleKey, _ = crypto.GenerateKey()
+	bundleAddr   = crypto.PubkeyToAddress(bundleKey.PublicKey)
+)
+
+// newBundleWorker creates a worker building block 1 on top of a genesis funding
+// the bundle account.
+func newBundleWorker(t *testing.T) *worker {
+	db := berithdb.NewMemDatabase()
+	config := params.TestnetChainConfig
+	genesis := &core.Genesis{
+		Config:     config,
+		GasLimit:   params.GenesisGasLimit,
+		Difficulty: big.NewInt(1),
+		Alloc:      core.GenesisAlloc{bundleAddr: {Balance: big.NewInt(1e18)}},
+	}
+	block := genesis.MustCommit(db)
+
+	stkDB := stakingdb.NewStakingDB(db, staking.NewStakers)
+	engine := bsrr.NewCliqueWithStakingDB(stkDB, config.Bsrr, db)
+	chain, err := core.NewBlockChain(stkDB, db, nil, config, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb, err := chain.StateAt(block.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &types.Header{ParentHash: block.Hash(), Number: big.NewInt(1), GasLimit: block.GasLimit(), Time: big.NewInt(1), Difficulty: big.NewInt(1)}
+	return &worker{
+		config: config,
+		chain:  chain,
+		current: &environment{
+			signer:  types.MakeSigner(config, header.Number),
+			state:   statedb,
+			header:  header,
+			gasPool: new(core.GasPool).AddGas(header.GasLimit),
+		},
+	}
+}
+
+// bundleTx returns a signed transfer of the bundle account.
+func bundleTx(t *testing.T, w *worker, key *ecdsa.PrivateKey, nonce uint64) *types.Transaction {
+	tx := types.NewTransaction(nonce, common.Address{1}, big.NewInt(1), 21000, big.NewInt(1), nil, types.Main, types.Main)
+	tx, err := types.SignTx(tx, w.current.signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// Tests that a bundle is committed in order, and that a bundle with a failing
+// transaction leaves no trace in the block being built.
+func TestCommitBundle(t *testing.T) {
+	w := newBundleWorker(t)
+	var (
+		root = w.current.state.IntermediateRoot(true)
+		gas  = w.current.gasPool.Gas()
+	)
+	// The second transaction has a nonce gap and fails
+	failing := &Bundle{Txs: types.Transactions{bundleTx(t, w, bundleKey, 0), bundleTx(t, w, bundleKey, 2)}, BlockNumber: 1}
+	if err := w.commitBundle(failing, common.Address{}); err == nil {
+		t.Fatal("failing bundle committed")
+	}
+	switch {
+	case len(w.current.txs) != 0 || len(w.current.receipts) != 0 || w.current.tcount != 0:
+		t.Fatalf("failing bundle left %d transactions, %d receipts, count %d", len(w.current.txs), len(w.current.receipts), w.current.tcount)
+	case w.current.gasPool.Gas() != gas || w.current.header.GasUsed != 0:
+		t.Fatalf("failing bundle used gas: pool %d, header %d", w.current.gasPool.Gas(), w.current.header.GasUsed)
+	case w.current.state.IntermediateRoot(true) != root:
+		t.Fatal("failing bundle changed the state")
+	}
+	bundle := &Bundle{Txs: types.Transactions{bundleTx(t, w, bundleKey, 0), bundleTx(t, w, bundleKey, 1)}, BlockNumber: 1}
+	if err := w.commitBundle(bundle, common.Address{}); err != nil {
+		t.Fatalf("bundle rejected: %v", err)
+	}
+	checkOrder(t, "bundle", w.current.txs, bundle.Txs)
+	if w.current.tcount != 2 || w.current.state.GetNonce(bundleAddr) != 2 {
+		t.Fatalf("bundle count %d, nonce %d, want 2", w.current.tcount, w.current.state.GetNonce(bundleAddr))
+	}
+}
+
+// Tests that bundles are only handed out for their target block and dropped
+// once it passed.
+func TestBundleExpiry(t *testing.T) {
+	var pool bundlePool
+	early := &Bundle{Txs: make(types.Transactions, 1), BlockNumber: 5}
+	late := &Bundle{Txs: make(types.Transactions, 1), BlockNumber: 6}
+	for _, bundle := range []*Bundle{early, late} {
+		if err := pool.add(bundle, 4); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pending := pool.pending(5); len(pending) != 1 || pending[0] != early {
+		t.Fatalf("bundles of block 5: have %v, want the early one", pending)
+	}
+	// A recommit of the same block retries the bundle
+	if pending := pool.pending(5); len(pending) != 1 {
+		t.Fatalf("bundles of block 5 retried: have %d, want 1", len(pending))
+	}
+	if pending := pool.pending(6); len(pending) != 1 || pending[0] != late {
+		t.Fatalf("bundles of block 6: have %v, want the late one", pending)
+	}
+	if pending := pool.pending(5); len(pending) != 0 || len(pool.bundles) != 1 {
+		t.Fatalf("expired bundle kept: %d pending, %d queued", len(pending), len(pool.bundles))
+	}
+	if pool.pending(7); len(pool.bundles) != 0 {
+		t.Fatalf("expired bundles kept: %d", len(pool.bundles))
+	}
+}
+
+// Tests the validation of the bundles submitted through the miner API.
+func TestSubmitBundle(t *testing.T) {
+	w := newBundleWorker(t)
+	miner := &Miner{worker: w}
+	tx := bundleTx(t, w, bundleKey, 0)
+
+	if err := miner.SubmitBundle(nil, 0); err != errEmptyBundle {
+		t.Errorf("empty bundle: have %v, want %v", err, errEmptyBundle)
+	}
+	// The head is the genesis, block 0 is past
+	if err := w.submitBundle(&Bundle{Txs: types.Transactions{tx}}); err != errStaleBundle {
+		t.Errorf("stale bundle: have %v, want %v", err, errStaleBundle)
+	}
+	if err := miner.SubmitBundle(types.Transactions{tx}, 0); err != nil {
+		t.Fatalf("bundle of the next block rejected: %v", err)
+	}
+	if pending := w.bundles.pending(1); len(pending) != 1 || pending[0].BlockNumber != 1 {
+		t.Fatalf("bundle without block number not queued for the next block: %v", pending)
+	}
+	for i := 1; i < maxBundles; i++ {
+		if err := miner.SubmitBundle(types.Transactions{tx}, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := miner.SubmitBundle(types.Transactions{tx}, 2); err != errBundlePoolFull {
+		t.Errorf("bundle over the limit: have %v, want %v", err, errBundlePoolFull)
+	}
+}
@@ -150,6 +150,20 @@ func (self *Miner) SetRecommitInterval(interval time.Duration) {
 	self.worker.setRecommitInterval(interval)
 }
 
+// SetBlockBuilder replaces the strategy ordering the transactions of new blocks.
+func (self *Miner) SetBlockBuilder(builder BlockBuilder) {
+	self.worker.setBuilder(builder)
+}
+
+// SubmitBundle queues a list of transactions for atomic inclusion at the top of
+// the given block. A zero block number targets the next block.
+func (self *Miner) SubmitBundle(txs types.Transactions, number uint64) error {
+	if number == 0 {
+		number = self.worker.chain.CurrentBlock().NumberU64() + 1
+	}
+	return self.worker.submitBundle(&Bundle{Txs: txs, BlockNumber: number})
+}
+
 // Pending returns the currently pending block and associated state.
 func (self *Miner) Pending() (*types.Block, *state.StateDB) {
 	return self.worker.pending()
@@ -154,9 +154,12 @@ type worker struct {
 	remoteUncles map[common.Hash]*types.Block // A set of side blocks as the possible uncle blocks.
 	unconfirmed  *unconfirmedBlocks           // A set of locally mined blocks pending canonicalness confirmations.
 
-	mu       sync.RWMutex // The lock used to protect the coinbase and extra fields
+	mu       sync.RWMutex // The lock used to protect the coinbase, extra and builder fields
 	coinbase common.Address
 	extra    []byte
+	builder  BlockBuilder // Strategy ordering the pending transactions of new blocks
+
+	bundles bundlePool // Transaction bundles submitted for atomic inclusion
 
 	pendingMu    sync.RWMutex
 	pendingTasks map[common.Hash]*task
@@ -188,6 +191,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, e Backend, m
 		chain:              e.BlockChain(),
 		gasFloor:           gasFloor,
 		gasCeil:            gasCeil,
+		builder:            new(priceBuilder),
 		isLocalBlock:       isLocalBlock,
 		localUncles:        make(map[common.Hash]*types.Block),
 		remoteUncles:       make(map[common.Hash]*types.Block),
@@ -241,6 +245,18 @@ func (w *worker) setExtra(extra []byte) {
 	w.extra = extra
 }
 
+// setBuilder replaces the block builder used to order the pending transactions.
+func (w *worker) setBuilder(builder BlockBuilder) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.builder = builder
+}
+
+// submitBundle queues a bundle for atomic inclusion into its target block.
+func (w *worker) submitBundle(bundle *Bundle) error {
+	return w.bundles.add(bundle, w.chain.CurrentBlock().NumberU64())
+}
+
 // setRecommitInterval updates the interval for miner sealing work recommitting.
 func (w *worker) setRecommitInterval(interval time.Duration) {
 	w.resubmitIntervalCh <- interval
@@ -440,6 +456,12 @@ func (w *worker) mainLoop() {
 			}
 
 		case ev := <-w.txsCh:
+			w.mu.RLock()
+			if observer, ok := w.builder.(TxObserver); ok {
+				observer.ObserveTxs(ev.Txs)
+			}
+			w.mu.RUnlock()
+
 			// Apply transactions to the pending state if we're not mining.
 			//
 			// Note all transactions received may not be continuous with transactions
@@ -599,6 +621,10 @@ func (w *worker) makeCurrent(parent *types.Block, header *types.Header) error {
 	if err != nil {
 		return err
 	}
+	// The chain writes the state changes of the mined block along with its state
+	if w.chain.StateDiffsEnabled() {
+		state.EnableStateDiff()
+	}
 	env := &environment{
 		signer:    types.NewEIP155Signer(w.config.ChainID),
 		state:     state,
@@ -607,6 +633,12 @@ func (w *worker) makeCurrent(parent *types.Block, header *types.Header) error {
 		uncles:    mapset.NewSet(),
 		header:    header,
 	}
+	switch {
+	case w.config.IsBIP7(header.Number):
+		env.signer = types.NewBIP7Signer(w.config.ChainID)
+	case w.config.IsBIP6(header.Number):
+		env.signer = types.NewBIP6Signer(w.config.ChainID)
+	}
 
 	// when 08 is processed ancestors contain 07 (quick block)
 	for _, ancestor := range w.chain.GetBlocksFromHash(parent.Hash(), 7) {
@@ -689,7 +721,7 @@ func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Addres
 	return receipt.Logs, nil
 }
 
-func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coinbase common.Address, interrupt *int32) bool {
+func (w *worker) commitTransactions(txs TransactionSet, coinbase common.Address, interrupt *int32) bool {
 	// Short circuit if current is nil
 	if w.current == nil {
 		return true
@@ -895,6 +927,15 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		w.commit(uncles, nil, false, tstart)
 	}
 
+	// Place the bundles targeting this block on top of it
+	if w.current.gasPool == nil {
+		w.current.gasPool = new(core.GasPool).AddGas(w.current.header.GasLimit)
+	}
+	for _, bundle := range w.bundles.pending(header.Number.Uint64()) {
+		if err := w.commitBundle(bundle, w.coinbase); err != nil {
+			log.Debug("Skipping transaction bundle", "txs", len(bundle.Txs), "err", err)
+		}
+	}
 	// Fill the block with all available pending transactions.
 	pending, err := w.e.TxPool().Pending()
 	if err != nil {
@@ -902,26 +943,18 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		return
 	}
 	// Short circuit if there is no available pending transactions
-	if len(pending) == 0 {
+	if len(pending) == 0 && w.current.tcount == 0 {
 		w.updateSnapshot()
 		return
 	}
-	// Split the pending transactions into locals and remotes
-	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
-	for _, account := range w.e.TxPool().Locals() {
-		if txs := remoteTxs[account]; len(txs) > 0 {
-			delete(remoteTxs, account)
-			localTxs[account] = txs
-		}
-	}
-	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs)
-		if w.commitTransactions(txs, w.coinbase, interrupt) {
-			return
-		}
-	}
-	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs)
+	// Let the block builder order the pending transactions
+	sets := w.builder.Build(&BuildContext{
+		Signer:  w.current.signer,
+		Header:  header,
+		Pending: pending,
+		Locals:  w.e.TxPool().Locals(),
+	})
+	for _, txs := range sets {
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
@@ -586,7 +586,20 @@ func (n *Node) OpenDatabase(name string, cache, handles int) (berithdb.Database,
 	if n.config.DataDir == "" {
 		return berithdb.NewMemDatabase(), nil
 	}
-	return berithdb.NewLDBDatabase(n.config.ResolvePath(name), cache, handles)
+	return berithdb.OpenBackend(n.config.DBEngine, n.config.ResolvePath(name), cache, handles)
+}
+
+// OpenDatabaseWithFreezer opens an existing database with the given name (or
+// creates one if no previous can be found) from within the node's instance
+// directory, also attaching a chain freezer to it that moves the blocks older
+// than threshold blocks below the head into the ancient directory. A zero
+// threshold disables the freezer. If the node is ephemeral, a memory database is
+// returned.
+func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer string, threshold uint64) (berithdb.Database, error) {
+	if n.config.DataDir == "" || threshold == 0 {
+		return n.OpenDatabase(name, cache, handles)
+	}
+	return openDatabaseWithFreezer(n.config, name, cache, handles, freezer, threshold)
 }
 
 // ResolvePath returns the absolute path of a resource in the instance directory.
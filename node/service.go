@@ -17,10 +17,12 @@
 package node
 
 import (
+	"path/filepath"
 	"reflect"
 
 	"github.com/BerithFoundation/berith-chain/accounts"
 	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/core/rawdb"
 	"github.com/BerithFoundation/berith-chain/event"
 	"github.com/BerithFoundation/berith-chain/p2p"
 	"github.com/BerithFoundation/berith-chain/rpc"
@@ -43,13 +45,25 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (be
 	if ctx.config.DataDir == "" {
 		return berithdb.NewMemDatabase(), nil
 	}
-	db, err := berithdb.NewLDBDatabase(ctx.config.ResolvePath(name), cache, handles)
+	db, err := berithdb.OpenBackend(ctx.config.DBEngine, ctx.config.ResolvePath(name), cache, handles)
 	if err != nil {
 		return nil, err
 	}
 	return db, nil
 }
 
+// OpenDatabaseWithFreezer opens an existing database with the given name (or
+// creates one if no previous can be found) from within the node's data directory,
+// also attaching a chain freezer to it that moves the blocks older than threshold
+// blocks below the head into the ancient directory. A zero threshold disables the
+// freezer. If the node is an ephemeral one, a memory database is returned.
+func (ctx *ServiceContext) OpenDatabaseWithFreezer(name string, cache int, handles int, freezer string, threshold uint64) (berithdb.Database, error) {
+	if ctx.config.DataDir == "" || threshold == 0 {
+		return ctx.OpenDatabase(name, cache, handles)
+	}
+	return openDatabaseWithFreezer(ctx.config, name, cache, handles, freezer, threshold)
+}
+
 // ResolvePath resolves a user path into the data directory if that was relative
 // and if the user actually uses persistent storage. It will return an empty string
 // for emphemeral storage and the user's own input for absolute paths.
@@ -96,3 +110,27 @@ type Service interface {
 	// are all terminated.
 	Stop() error
 }
+
+// openDatabaseWithFreezer opens the named key-value database of the data directory
+// with a chain freezer in the given ancient directory, inside the database
+// directory by default.
+func openDatabaseWithFreezer(config *Config, name string, cache int, handles int, freezer string, threshold uint64) (berithdb.Database, error) {
+	root := config.ResolvePath(name)
+
+	switch {
+	case freezer == "":
+		freezer = filepath.Join(root, "ancient")
+	case !filepath.IsAbs(freezer):
+		freezer = config.ResolvePath(freezer)
+	}
+	kvdb, err := berithdb.OpenBackend(config.DBEngine, root, cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	db, err := rawdb.NewDatabaseWithFreezer(kvdb, freezer, "berith/db/"+name+"/", threshold)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return db, nil
+}
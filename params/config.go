@@ -135,6 +135,11 @@ type ChainConfig struct {
 	BIP2Block *big.Int    `json:"bip2Block,omitempty"`
 	BIP3Block *big.Int    `json:"bip3Block,omitempty"`
 	BIP4Block *big.Int    `json:"bip4Block,omitempty"`
+	BIP5Block *big.Int    `json:"bip5Block,omitempty"` // Reward wallet transactions and auto-compounding
+	BIP6Block *big.Int    `json:"bip6Block,omitempty"` // Hardware wallet transaction signatures
+	BIP7Block *big.Int    `json:"bip7Block,omitempty"` // Multisig accounts
+	BIP8Block *big.Int    `json:"bip8Block,omitempty"` // Account key migration
+	BIP9Block *big.Int    `json:"bip9Block,omitempty"` // Vesting balances
 }
 type BSRRConfig struct {
 	Period       uint64   `json:"period"`       // Number of seconds between blocks to enforce
@@ -227,6 +232,41 @@ func (c *ChainConfig) IsBIP4(num *big.Int) bool {
 	return isForked(c.BIP4Block, num)
 }
 
+// IsBIP5 returns whether num is either equal to the BIP5 fork block or greater.
+// From BIP5 on, matured rewards can be moved by Reward wallet transactions and
+// accounts may compound their rewards into stake automatically.
+func (c *ChainConfig) IsBIP5(num *big.Int) bool {
+	return isForked(c.BIP5Block, num)
+}
+
+// IsBIP6 returns whether num is either equal to the BIP6 fork block or greater.
+// From BIP6 on, transactions may be signed by hardware wallets over the message
+// hash of their signing hash.
+func (c *ChainConfig) IsBIP6(num *big.Int) bool {
+	return isForked(c.BIP6Block, num)
+}
+
+// IsBIP7 returns whether num is either equal to the BIP7 fork block or greater.
+// From BIP7 on, multisig accounts can be created and send transactions approved
+// by their owners.
+func (c *ChainConfig) IsBIP7(num *big.Int) bool {
+	return isForked(c.BIP7Block, num)
+}
+
+// IsBIP8 returns whether num is either equal to the BIP8 fork block or greater.
+// From BIP8 on, an account can migrate its balances, stake and selection point
+// to a new key.
+func (c *ChainConfig) IsBIP8(num *big.Int) bool {
+	return isForked(c.BIP8Block, num)
+}
+
+// IsBIP9 returns whether num is either equal to the BIP9 fork block or greater.
+// From BIP9 on, balances can be locked by vesting schedules, which are released
+// into the main balance when the account sends a transaction.
+func (c *ChainConfig) IsBIP9(num *big.Int) bool {
+	return isForked(c.BIP9Block, num)
+}
+
 func (c *ChainConfig) IsBIP1Block(num *big.Int) bool {
 	if c.BIP1Block == nil || num == nil {
 		return false
@@ -316,6 +356,21 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.BIP3Block, newcfg.BIP3Block, head) {
 		return newCompatError("bip3 fork block", c.BIP3Block, newcfg.BIP3Block)
 	}
+	if isForkIncompatible(c.BIP5Block, newcfg.BIP5Block, head) {
+		return newCompatError("bip5 fork block", c.BIP5Block, newcfg.BIP5Block)
+	}
+	if isForkIncompatible(c.BIP6Block, newcfg.BIP6Block, head) {
+		return newCompatError("bip6 fork block", c.BIP6Block, newcfg.BIP6Block)
+	}
+	if isForkIncompatible(c.BIP7Block, newcfg.BIP7Block, head) {
+		return newCompatError("bip7 fork block", c.BIP7Block, newcfg.BIP7Block)
+	}
+	if isForkIncompatible(c.BIP8Block, newcfg.BIP8Block, head) {
+		return newCompatError("bip8 fork block", c.BIP8Block, newcfg.BIP8Block)
+	}
+	if isForkIncompatible(c.BIP9Block, newcfg.BIP9Block, head) {
+		return newCompatError("bip9 fork block", c.BIP9Block, newcfg.BIP9Block)
+	}
 	return nil
 }
 
@@ -32,6 +32,18 @@ const (
 	// considered probably final and its rotated bits are calculated.
 	BloomConfirms = 256
 
+	// AddressIndexBlocks is the number of blocks a single section of the address
+	// to transaction index contains.
+	AddressIndexBlocks uint64 = 1024
+
+	// AddressIndexConfirms is the number of confirmation blocks before an address
+	// index section is considered probably final and is written.
+	AddressIndexConfirms = 256
+
+	// ImmutabilityThreshold is the default number of recent blocks kept in the
+	// key-value database, the older ones are moved to the ancient store.
+	ImmutabilityThreshold uint64 = 90000
+
 	// CHTFrequencyClient is the block frequency for creating CHTs on the client side.
 	CHTFrequencyClient = 32768
 
@@ -35,6 +35,11 @@ const (
 	LogDataGas            uint64 = 8     // Per byte in a LOG* operation's data.
 	CallStipend           uint64 = 2300  // Free gas given at beginning of call.
 
+	TxMultisigApprovalGas uint64 = 3000  // Per owner signature of a multisig account transaction.
+	MultisigSetupGas      uint64 = 32000 // Paid by transactions creating a multisig account.
+	KeyMigrationGas       uint64 = 50000 // Paid by transactions migrating an account to a new key.
+	VestingSetupGas       uint64 = 25000 // Paid by transactions locking a balance with a vesting schedule.
+
 	Sha3Gas     uint64 = 30 // Once per SHA3 operation.
 	Sha3WordGas uint64 = 6  // Once per word of the SHA3 operation's data.
 
@@ -71,6 +76,8 @@ const (
 
 	MaxCodeSize = 24576 // Maximum bytecode to permit for a contract
 
+	MaxVestingSchedules = 16 // Maximum number of vesting schedules locking balances of an account.
+
 	// Precompiled contract gas prices
 
 	EcrecoverGas            uint64 = 3000   // Elliptic curve sender recovery gas price
@@ -92,4 +99,5 @@ var (
 	GenesisDifficulty      = big.NewInt(131072) // Difficulty of the Genesis block.
 	MinimumDifficulty      = big.NewInt(131072) // The minimum that the difficulty may ever be.
 	DurationLimit          = big.NewInt(13)     // The decision boundary on the blocktime duration used to determine whether difficulty should go up or not.
+	MinVestingAmount       = big.NewInt(Ber)    // Minimum balance locked by a vesting schedule.
 )
@@ -36,8 +36,13 @@ import (
 	"github.com/BerithFoundation/berith-chain/rlp"
 )
 
-// numberOfAccountsToDerive For hardware wallets, the number of accounts to derive
-const numberOfAccountsToDerive = 10
+const (
+	// numberOfAccountsToDerive For hardware wallets, the number of accounts to derive
+	numberOfAccountsToDerive = 10
+	// ExternalAPIVersion is the version of the external API, bumped whenever the
+	// requests or responses change. 1.1.0 added the base and target job wallets.
+	ExternalAPIVersion = "1.1.0"
+)
 
 // ExternalAPI defines the external API through which signing requests are made.
 type ExternalAPI interface {
@@ -55,6 +60,8 @@ type ExternalAPI interface {
 	// Should be moved to Internal API, in next phase when we have
 	// bi-directional communication
 	//Import(ctx context.Context, keyJSON json.RawMessage) (Account, error)
+	// Version info about the APIs
+	Version(ctx context.Context) (string, error)
 }
 
 // SignerUI specifies what method a UI needs to implement to be able to be used as a UI for the signer
@@ -446,6 +453,14 @@ func logDiff(original *SignTxRequest, new *SignTxResponse) bool {
 		modified = true
 		log.Info("Nonce changed by UI", "was", n0, "is", n1)
 	}
+	if b0, b1 := original.Transaction.Base, new.Transaction.Base; b0 != b1 {
+		modified = true
+		log.Info("Base wallet changed by UI", "was", b0, "is", b1)
+	}
+	if t0, t1 := original.Transaction.Target, new.Transaction.Target; t0 != t1 {
+		modified = true
+		log.Info("Target wallet changed by UI", "was", t0, "is", t1)
+	}
 	return modified
 }
 
@@ -491,7 +506,11 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args SendTxArgs, meth
 		return nil, err
 	}
 	// Convert fields into a real transaction
-	var unsignedTx = result.Transaction.toTransaction()
+	unsignedTx, err := result.Transaction.toTransaction()
+	if err != nil {
+		api.UI.ShowError(err.Error())
+		return nil, err
+	}
 
 	// The one to sign is the one that was returned from the UI
 	signedTx, err := wallet.SignTxWithPassphrase(acc, result.Password, unsignedTx, api.chainID)
@@ -510,6 +529,11 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args SendTxArgs, meth
 
 }
 
+// Version returns the version of the external API.
+func (api *SignerAPI) Version(ctx context.Context) (string, error) {
+	return ExternalAPIVersion, nil
+}
+
 // Sign calculates an Ethereum ECDSA signature for:
 // keccack256("\x19Ethereum Signed Message:\n" + len(message) + message))
 //
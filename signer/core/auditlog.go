@@ -65,7 +65,7 @@ func (l *AuditLogger) SignTransaction(ctx context.Context, args SendTxArgs, meth
 
 func (l *AuditLogger) Sign(ctx context.Context, addr common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error) {
 	l.log.Info("Sign", "type", "request", "metadata", MetadataFromContext(ctx).String(),
-		"addr", addr.String(), "data", common.Bytes2Hex(data))
+		"addr", mixedcaseString(&addr), "data", common.Bytes2Hex(data))
 	b, e := l.api.Sign(ctx, addr, data)
 	l.log.Info("Sign", "type", "response", "data", common.Bytes2Hex(b), "error", e)
 	return b, e
@@ -80,6 +80,13 @@ func (l *AuditLogger) Export(ctx context.Context, addr common.Address) (json.Raw
 	return j, e
 }
 
+func (l *AuditLogger) Version(ctx context.Context) (string, error) {
+	l.log.Info("Version", "type", "request", "metadata", MetadataFromContext(ctx).String())
+	data, err := l.api.Version(ctx)
+	l.log.Info("Version", "type", "response", "data", data, "error", err)
+	return data, err
+}
+
 //func (l *AuditLogger) Import(ctx context.Context, keyJSON json.RawMessage) (Account, error) {
 //	// Don't actually log the json contents
 //	l.log.Info("Import", "type", "request", "metadata", MetadataFromContext(ctx).String(),
@@ -109,6 +109,14 @@ func (ui *CommandlineUI) confirm() bool {
 	return false
 }
 
+// orMain returns the job wallet name, defaulting to the main wallet.
+func orMain(wallet string) string {
+	if wallet == "" {
+		return "main"
+	}
+	return wallet
+}
+
 func showMetadata(metadata Metadata) {
 	fmt.Printf("Request context:\n\t%v -> %v -> %v\n", metadata.Remote, metadata.Scheme, metadata.Local)
 	fmt.Printf("\nAdditional HTTP header data, provided by the external caller:\n")
@@ -122,18 +130,21 @@ func (ui *CommandlineUI) ApproveTx(request *SignTxRequest) (SignTxResponse, erro
 	weival := request.Transaction.Value.ToInt()
 	fmt.Printf("--------- Transaction request-------------\n")
 	if to := request.Transaction.To; to != nil {
-		fmt.Printf("to:    %v\n", to.Original())
+		fmt.Printf("to:    %v\n", berithAddress(to))
 		if !to.ValidChecksum() {
 			fmt.Printf("\nWARNING: Invalid checksum on to-address!\n\n")
 		}
 	} else {
 		fmt.Printf("to:    <contact creation>\n")
 	}
-	fmt.Printf("from:     %v\n", request.Transaction.From.String())
+	fmt.Printf("from:     %v\n", mixedcaseString(&request.Transaction.From))
 	fmt.Printf("value:    %v wei\n", weival)
 	fmt.Printf("gas:      %v (%v)\n", request.Transaction.Gas, uint64(request.Transaction.Gas))
 	fmt.Printf("gasprice: %v wei\n", request.Transaction.GasPrice.ToInt())
 	fmt.Printf("nonce:    %v (%v)\n", request.Transaction.Nonce, uint64(request.Transaction.Nonce))
+	if base, target := request.Transaction.Base, request.Transaction.Target; base != "" || target != "" {
+		fmt.Printf("wallets:  %v -> %v\n", orMain(base), orMain(target))
+	}
 	if request.Transaction.Data != nil {
 		d := *request.Transaction.Data
 		if len(d) > 0 {
@@ -164,7 +175,7 @@ func (ui *CommandlineUI) ApproveSignData(request *SignDataRequest) (SignDataResp
 	defer ui.mu.Unlock()
 
 	fmt.Printf("-------- Sign data request--------------\n")
-	fmt.Printf("Account:  %s\n", request.Address.String())
+	fmt.Printf("Account:  %s\n", mixedcaseString(&request.Address))
 	fmt.Printf("message:  \n%q\n", request.Message)
 	fmt.Printf("raw data: \n%v\n", request.Rawdata)
 	fmt.Printf("message hash:  %v\n", request.Hash)
@@ -102,25 +102,93 @@ type SendTxArgs struct {
 	// We accept "data" and "input" for backwards-compatibility reasons.
 	Data  *hexutil.Bytes `json:"data"`
 	Input *hexutil.Bytes `json:"input"`
+	// Job wallets the value moves between, "main" if omitted.
+	Base   string `json:"base,omitempty"`
+	Target string `json:"target,omitempty"`
 }
 
 func (args SendTxArgs) String() string {
-	s, err := json.Marshal(args)
+	// [BERITH] Show the addresses with the berith address prefix, the JSON
+	// encoding of the request itself keeps the hex prefix
+	type plainArgs SendTxArgs
+	formatted := struct {
+		plainArgs
+		From string  `json:"from"`
+		To   *string `json:"to"`
+	}{plainArgs: plainArgs(args), From: berithAddress(&args.From)}
+	if args.To != nil {
+		to := berithAddress(args.To)
+		formatted.To = &to
+	}
+	s, err := json.Marshal(formatted)
 	if err == nil {
 		return string(s)
 	}
 	return err.Error()
 }
 
-func (args *SendTxArgs) toTransaction() *types.Transaction {
+// berithAddress formats an address of a request with the berith address prefix,
+// keeping the case of its hex digits.
+func berithAddress(addr *common.MixedcaseAddress) string {
+	original := addr.Original()
+	if strings.HasPrefix(original, "0x") || strings.HasPrefix(original, "0X") || common.HasAddressPrefix(original) {
+		original = original[2:]
+	}
+	return common.AddressPrefix + original
+}
+
+// mixedcaseString formats an address of a request like common.MixedcaseAddress
+// does, but with the berith address prefix.
+func mixedcaseString(addr *common.MixedcaseAddress) string {
+	if addr.ValidChecksum() {
+		return fmt.Sprintf("%s [chksum ok]", berithAddress(addr))
+	}
+	return fmt.Sprintf("%s [chksum INVALID]", berithAddress(addr))
+}
+
+// jobWallets returns the base and target job wallets of the transaction.
+// Unlike types.ConvertJobWallet, unknown wallet names are rejected instead of
+// silently falling back to the main wallet.
+func (args *SendTxArgs) jobWallets() (types.JobWallet, types.JobWallet, error) {
+	base, err := parseJobWallet(args.Base)
+	if err != nil {
+		return 0, 0, err
+	}
+	target, err := parseJobWallet(args.Target)
+	if err != nil {
+		return 0, 0, err
+	}
+	return base, target, nil
+}
+
+func parseJobWallet(name string) (types.JobWallet, error) {
+	switch name {
+	case "", "main":
+		return types.Main, nil
+	case "stake", "reward", "vesting":
+		return types.ConvertJobWallet(name), nil
+	default:
+		return 0, fmt.Errorf("%v: %q", types.ErrInvalidJobWallet, name)
+	}
+}
+
+func (args *SendTxArgs) toTransaction() (*types.Transaction, error) {
 	var input []byte
 	if args.Data != nil {
 		input = *args.Data
 	} else if args.Input != nil {
 		input = *args.Input
 	}
+	// The UI may have changed the job wallets after validation, check them again
+	base, target, err := args.jobWallets()
+	if err != nil {
+		return nil, err
+	}
+	if err := types.ValidateJobWallet(base, target); err != nil {
+		return nil, err
+	}
 	if args.To == nil {
-		return types.NewContractCreation(uint64(args.Nonce), (*big.Int)(&args.Value), uint64(args.Gas), (*big.Int)(&args.GasPrice), input, types.Main, types.Main)
+		return types.NewContractCreation(uint64(args.Nonce), (*big.Int)(&args.Value), uint64(args.Gas), (*big.Int)(&args.GasPrice), input, base, target), nil
 	}
-	return types.NewTransaction(uint64(args.Nonce), args.To.Address(), (*big.Int)(&args.Value), (uint64)(args.Gas), (*big.Int)(&args.GasPrice), input, types.Main, types.Main)
+	return types.NewTransaction(uint64(args.Nonce), args.To.Address(), (*big.Int)(&args.Value), (uint64)(args.Gas), (*big.Int)(&args.GasPrice), input, base, target), nil
 }
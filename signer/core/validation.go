@@ -24,6 +24,7 @@ import (
 	"regexp"
 
 	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/core/types"
 )
 
 // The validation package contains validation checks for transactions
@@ -114,7 +115,9 @@ func (v *Validator) validate(msgs *ValidationMessages, txargs *SendTxArgs, metho
 	if txargs.Data != nil {
 		data = *txargs.Data
 	}
-
+	if err := v.validateJobWallets(msgs, txargs); err != nil {
+		return err
+	}
 	if txargs.To == nil {
 		//Contract creation should contain sufficient data to deploy a contract
 		// A typical error is omitting sender due to some quirk in the javascript call
@@ -149,6 +152,47 @@ func (v *Validator) validate(msgs *ValidationMessages, txargs *SendTxArgs, metho
 	return nil
 }
 
+// validateJobWallets checks the base and target job wallets of the transaction.
+// Moving value between the wallets of an account is only valid as a plain
+// transaction to the sender itself.
+func (v *Validator) validateJobWallets(msgs *ValidationMessages, txargs *SendTxArgs) error {
+	base, target, err := txargs.jobWallets()
+	if err != nil {
+		return err
+	}
+	if err := types.ValidateJobWallet(base, target); err != nil {
+		return fmt.Errorf("%v: %v -> %v", err, base, target)
+	}
+	if base == types.Main && target == types.Main {
+		return nil
+	}
+	if txargs.To == nil {
+		return fmt.Errorf("contract creation from the %v to the %v wallet", base, target)
+	}
+	// Balances are locked for any beneficiary, with the schedule as payload
+	if target == types.Vesting {
+		msgs.info(fmt.Sprintf("Tx locks %v wei in a vesting balance of %v", txargs.Value.ToInt(), txargs.To.Address().Hex()))
+		return nil
+	}
+	if txargs.To.Address() != txargs.From.Address() {
+		return fmt.Errorf("%v to %v wallet transaction must be sent to the sender itself", base, target)
+	}
+	if txargs.Data != nil && len(*txargs.Data) > 0 {
+		msgs.warn(fmt.Sprintf("Tx moves value from the %v to the %v wallet, but contains data", base, target))
+	}
+	switch {
+	case base == types.Reward && target == types.Reward:
+		if txargs.Value.ToInt().Sign() > 0 {
+			msgs.info("Tx enables auto-compounding of rewards")
+		} else {
+			msgs.info("Tx disables auto-compounding of rewards")
+		}
+	default:
+		msgs.info(fmt.Sprintf("Tx moves %v wei from the %v to the %v wallet", txargs.Value.ToInt(), base, target))
+	}
+	return nil
+}
+
 // ValidateTransaction does a number of checks on the supplied transaction, and returns either a list of warnings,
 // or an error, indicating that the transaction should be immediately rejected
 func (v *Validator) ValidateTransaction(txArgs *SendTxArgs, methodSelector *string) (*ValidationMessages, error) {
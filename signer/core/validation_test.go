@@ -65,11 +65,14 @@ func dummyTxArgs(t txtestcase) *SendTxArgs {
 		Gas:      gas,
 		Data:     data,
 		Input:    input,
+		Base:     t.base,
+		Target:   t.target,
 	}
 }
 
 type txtestcase struct {
 	from, to, n, g, gp, value, d, i string
+	base, target                    string
 	expectErr                       bool
 	numMessages                     int
 }
@@ -108,6 +111,33 @@ func TestValidator(t *testing.T) {
 		// Small payload for create
 		{from: "000000000000000000000000000000000000dead", to: "",
 			n: "0x01", g: "0x20", gp: "0x40", value: "0x01", d: "0x01", numMessages: 1},
+		// Stake to self
+		{from: "000000000000000000000000000000000000dead", to: "0x000000000000000000000000000000000000dEaD",
+			n: "0x01", g: "0x20", gp: "0x40", value: "0x01", base: "main", target: "stake", numMessages: 1},
+		// Stake to another account
+		{from: "000000000000000000000000000000000000dead", to: "0x000000000000000000000000000000000000bEEF",
+			n: "0x01", g: "0x20", gp: "0x40", value: "0x01", base: "main", target: "stake", expectErr: true},
+		// Stake with contract creation
+		{from: "000000000000000000000000000000000000dead", to: "",
+			n: "0x01", g: "0x20", gp: "0x40", value: "0x01", d: "0x01", base: "main", target: "stake", expectErr: true},
+		// Stake to stake
+		{from: "000000000000000000000000000000000000dead", to: "0x000000000000000000000000000000000000dEaD",
+			n: "0x01", g: "0x20", gp: "0x40", value: "0x01", base: "stake", target: "stake", expectErr: true},
+		// Deposit into the reward wallet
+		{from: "000000000000000000000000000000000000dead", to: "0x000000000000000000000000000000000000dEaD",
+			n: "0x01", g: "0x20", gp: "0x40", value: "0x01", base: "main", target: "reward", expectErr: true},
+		// Unknown wallet
+		{from: "000000000000000000000000000000000000dead", to: "0x000000000000000000000000000000000000dEaD",
+			n: "0x01", g: "0x20", gp: "0x40", value: "0x01", base: "savings", target: "main", expectErr: true},
+		// Toggle auto-compounding
+		{from: "000000000000000000000000000000000000dead", to: "0x000000000000000000000000000000000000dEaD",
+			n: "0x01", g: "0x20", gp: "0x40", value: "0x01", base: "reward", target: "reward", numMessages: 1},
+		// Lock a vesting balance for another account
+		{from: "000000000000000000000000000000000000dead", to: "0x000000000000000000000000000000000000bEEF",
+			n: "0x01", g: "0x20", gp: "0x40", value: "0x01", base: "main", target: "vesting", numMessages: 1},
+		// Withdraw a locked vesting balance
+		{from: "000000000000000000000000000000000000dead", to: "0x000000000000000000000000000000000000dEaD",
+			n: "0x01", g: "0x20", gp: "0x40", value: "0x01", base: "vesting", target: "main", expectErr: true},
 	}
 	for i, test := range testcases {
 		msgs, err := v.ValidateTransaction(dummyTxArgs(test), nil)
@@ -163,3 +193,19 @@ func TestPasswordValidation(t *testing.T) {
 		}
 	}
 }
+
+func TestSendTxArgsString(t *testing.T) {
+	from, _ := mixAddr("0x000000000000000000000000000000000000dead")
+	to, _ := mixAddr("0x0000000000000000000000000000000000001337")
+	args := SendTxArgs{From: *from, To: to, Base: "main", Target: "stake"}
+
+	// The request is formatted with the berith address prefix
+	want := `{"gas":"0x0","gasPrice":"0x0","value":"0x0","nonce":"0x0","data":null,"input":null,"base":"main","target":"stake",` +
+		`"from":"Bx000000000000000000000000000000000000dead","to":"Bx0000000000000000000000000000000000001337"}`
+	if have := args.String(); have != want {
+		t.Errorf("request mismatch:\nhave %s\nwant %s", have, want)
+	}
+	if have, want := mixedcaseString(from), "Bx000000000000000000000000000000000000dead [chksum INVALID]"; have != want {
+		t.Errorf("address mismatch: have %s, want %s", have, want)
+	}
+}
@@ -482,7 +482,7 @@ func dummySigned(value *big.Int) *types.Transaction {
 	gas := uint64(21000)
 	gasPrice := big.NewInt(2000000)
 	data := make([]byte, 0)
-	return types.NewTransaction(3, to, value, gas, gasPrice, data)
+	return types.NewTransaction(3, to, value, gas, gasPrice, data, types.Main, types.Main)
 
 }
 func TestLimitWindow(t *testing.T) {
@@ -522,6 +522,91 @@ func TestLimitWindow(t *testing.T) {
 
 }
 
+const ExampleStakeWindow = `
+	function big(str){
+		if(str.slice(0,2) == "0x"){ return new BigNumber(str.slice(2),16)}
+		return new BigNumber(str)
+	}
+
+	// Time window: 1 day
+	var window = 1000*3600*24;
+
+	// Limit : 1 ber staked per day
+	var limit = new BigNumber("1e18");
+
+	// Signed transactions carry the job wallets as numbers: 1 main, 2 stake, 3 reward
+	var MAIN = 1, STAKE = 2;
+
+	function stakedToday(){
+		var windowstart = new Date().getTime() - window;
+		var stakes = [];
+		var stored = storage.Get('stakes');
+		if(stored != ""){
+			stakes = JSON.parse(stored)
+		}
+		return stakes.filter(function(s){return s.tstamp > windowstart})
+			.reduce(function(agg, s){ return big(s.value).plus(agg)}, new BigNumber(0));
+	}
+	function ApproveTx(r){
+		var tx = r.transaction;
+		if (tx.base != "main" || tx.target != "stake"){
+			return "Reject"
+		}
+		if (stakedToday().plus(big(tx.value)).lte(limit)){
+			return "Approve"
+		}
+		return "Reject"
+	}
+	function OnApprovedTx(resp){
+		if (resp.tx.base != MAIN || resp.tx.target != STAKE){
+			return
+		}
+		var stakes = [];
+		var stored = storage.Get('stakes');
+		if(stored != ""){
+			stakes = JSON.parse(stored)
+		}
+		stakes.push({tstamp: new Date().getTime(), value: big(resp.tx.value)});
+		storage.Put("stakes", JSON.stringify(stakes));
+	}
+`
+
+func TestStakeLimitWindow(t *testing.T) {
+	r, err := initRuleEngine(ExampleStakeWindow)
+	if err != nil {
+		t.Fatalf("Couldn't create evaluator %v", err)
+	}
+	stake := func(value *big.Int) *core.SignTxRequest {
+		req := dummyTx(hexutil.Big(*value))
+		req.Transaction.Base, req.Transaction.Target = "main", "stake"
+		return req
+	}
+	// 0.3 ber: 429D069189E0000 wei
+	v := big.NewInt(0).SetBytes(common.Hex2Bytes("0429D069189E0000"))
+
+	// Plain transfers are never approved by the staking rules
+	if resp, _ := r.ApproveTx(dummyTx(hexutil.Big(*v))); resp.Approved {
+		t.Errorf("Expected transfer to resolve to 'Reject'")
+	}
+	// The first three stakes should succeed
+	for i := 0; i < 3; i++ {
+		resp, err := r.ApproveTx(stake(v))
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if !resp.Approved {
+			t.Errorf("Stake %d: expected check to resolve to 'Approve'", i)
+		}
+		to := common.HexToAddress("000000000000000000000000000000000000dead")
+		signed := types.NewTransaction(3, to, v, 21000, big.NewInt(2000000), nil, types.Main, types.Stake)
+		r.OnApprovedTx(berithapi.SignTransactionResult{Tx: signed, Raw: common.Hex2Bytes("deadbeef")})
+	}
+	// Fourth should exceed the daily limit
+	if resp, _ := r.ApproveTx(stake(v)); resp.Approved {
+		t.Errorf("Expected check to resolve to 'Reject'")
+	}
+}
+
 // dontCallMe is used as a next-handler that does not want to be called - it invokes test failure
 type dontCallMe struct {
 	t *testing.T
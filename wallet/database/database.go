@@ -1,23 +1,64 @@
 package walletdb
 
 import (
+	"errors"
 	"io"
 	"strings"
+	"sync"
 
+	"github.com/BerithFoundation/berith-chain/accounts/keystore"
 	"github.com/BerithFoundation/berith-chain/berithdb"
 	"github.com/BerithFoundation/berith-chain/common"
 	"github.com/BerithFoundation/berith-chain/rlp"
 )
 
-// 로그인 계정 table ( key 값은 id 로 설정)
+var (
+	// ErrUnknownMember is returned if no member is registered with an id.
+	ErrUnknownMember = errors.New("unknown member")
+
+	// ErrMemberExists is returned if a member is registered with an id in use.
+	ErrMemberExists = errors.New("member already exists")
+
+	// ErrLocked is returned if user data is accessed before a member logged in.
+	ErrLocked = errors.New("wallet database is locked")
+
+	// ErrUnknownAccount is returned if an account isn't managed by the member
+	// logged in.
+	ErrUnknownAccount = errors.New("unknown account")
+
+	// ErrAccountExists is returned if an account is added to a member twice.
+	ErrAccountExists = errors.New("account already exists")
+
+	errNotFound = errors.New("not found")
+)
+
+// 로그인 계정 table ( key 값은 "m" + id )
+// 개인키와 비밀번호는 저장하지 않는다. 인증은 노드의 keystore 로 하고, Key 는
+// 로그인 비밀번호로 암호화된 사용자 데이터 암호화 키이다.
 type Member struct {
-	Address    common.Address // 계정주소
-	PrivateKey string         // 개인키
-	ID         string         // id
-	Password   string         // pwd
+	Address  common.Address   // 기본 계정주소 (로그인에 사용)
+	ID       string           // id
+	Key      []byte           // 암호화된 데이터 키 (keystore.CryptoJSON)
+	Accounts []common.Address `rlp:"tail"` // 추가로 생성하거나 가져온 계정주소
+}
+
+// 계정이 관리하는 모든 주소를 반환하는 함수, 기본 계정주소가 처음에 온다.
+func (m *Member) AllAccounts() []common.Address {
+	return append([]common.Address{m.Address}, m.Accounts...)
+}
+
+// 계정이 account 를 관리하는지 확인하는 함수
+func (m *Member) Owns(account common.Address) bool {
+	for _, a := range m.AllAccounts() {
+		if a == account {
+			return true
+		}
+	}
+	return false
 }
 
-// 트랜잭션 리스트 Detail table ( key 값은 해당 transaction을 전파받은 블록넘버값)
+// 트랜잭션 리스트 Detail table ( key 값은 "h" + 계정주소 + transaction hash 값)
+// 이전 버전에서 저장한 transaction 은 hash 대신 전파받은 블록넘버값을 키로 사용한다.
 type TxHistory struct {
 	TxBlockNumber string         // tx 발생 블록넘버
 	TxAddress     common.Address // tx 주소값
@@ -31,10 +72,10 @@ type TxHistory struct {
 	GasUsed       string         // 실제 사용 수수료
 }
 
-// 주소록 table ( key 는 저장 주소값 )
+// 주소록 table ( key 는 "c" + 계정주소 )
 type Contact map[common.Address]string
 
-// 트랜잭션 리스트 Master table ( key 값은 해당 transaction을 전파받은 블록넘버값)
+// 트랜잭션 리스트 Master table ( key 는 "t" + 계정주소, 값은 Detail 키 목록)
 type TxHistoryMaster map[string]string
 
 // Contact table RLP encode 함수
@@ -97,32 +138,49 @@ func (c TxHistoryMaster) DecodeRLP(r *rlp.Stream) error {
 	return nil
 }
 
-type Transactions struct {
-	txs []common.Hash
-}
-
 type WalletDB struct {
 	db berithdb.Database
+
+	scryptN int
+	scryptP int
+
+	lock    sync.RWMutex
+	member  *Member        // 로그인한 계정, 없으면 nil
+	dataKey []byte         // 로그인한 계정의 데이터 키
+	account common.Address // 로그인한 계정에서 선택한 주소
 }
 
 // Ldb 생성 함수
+// 이전 버전의 평문 데이터베이스는 처음 열 때 암호화된 형식으로 변환된다.
 func NewWalletDB(dir string) (*WalletDB, error) {
-	db, err := berithdb.NewLDBDatabase(dir, 128, 1024)
+	return newWalletDB(dir, keystore.StandardScryptN, keystore.StandardScryptP)
+}
+
+func newWalletDB(dir string, scryptN, scryptP int) (*WalletDB, error) {
+	db, err := berithdb.OpenBackend("", dir, 128, 1024)
 
 	if err != nil {
 		return nil, err
 	}
-
-	return &WalletDB{
-		db: db,
-	}, nil
+	wdb := &WalletDB{
+		db:      db,
+		scryptN: scryptN,
+		scryptP: scryptP,
+	}
+	if err := wdb.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return wdb, nil
 }
+
 func (db *WalletDB) CloseDB() {
+	db.Lock()
 	db.db.Close()
 }
 
-// db insert 함수
-func (db *WalletDB) Insert(key []byte, value interface{}) error {
+// db insert 함수 (평문)
+func (db *WalletDB) insert(key []byte, value interface{}) error {
 	data, err := rlp.EncodeToBytes(value)
 	if err != nil {
 		return err
@@ -131,21 +189,197 @@ func (db *WalletDB) Insert(key []byte, value interface{}) error {
 	return db.db.Put(key, data)
 }
 
-// db select 함수
-func (db *WalletDB) Select(key []byte, holder interface{}) error {
-	data, err := db.db.Get(key)
+// db select 함수 (평문)
+func (db *WalletDB) selectValue(key []byte, holder interface{}) error {
+	data, err := db.get(key)
+	if err != nil {
+		return err
+	}
+
+	return rlp.DecodeBytes(data, holder)
+}
+
+// 로그인한 계정의 데이터 키로 암호화해서 저장하는 함수
+func (db *WalletDB) insertSecure(key []byte, value interface{}) error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.dataKey == nil {
+		return ErrLocked
+	}
+	data, err := rlp.EncodeToBytes(value)
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(db.dataKey, key, data)
 	if err != nil {
 		return err
 	}
+	return db.db.Put(key, sealed)
+}
+
+// 로그인한 계정의 데이터 키로 복호화해서 조회하는 함수
+func (db *WalletDB) selectSecure(key []byte, holder interface{}) error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
 
+	if db.dataKey == nil {
+		return ErrLocked
+	}
+	sealed, err := db.get(key)
+	if err != nil {
+		return err
+	}
+	data, err := open(db.dataKey, key, sealed)
+	if err != nil {
+		return err
+	}
 	return rlp.DecodeBytes(data, holder)
 }
 
-//func (db *WalletDB) Select2(key []byte, holder interface{}) error {
-//	data, err := db.db.Get(key)
-//	if err != nil {
-//		return err
-//	}
-//
-//	return rlp.DecodeBytes(data, holder)
-//}
+// account 가 로그인한 계정인지 확인하는 함수
+func (db *WalletDB) checkOwner(account common.Address) error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.member == nil {
+		return ErrLocked
+	}
+	if !db.member.Owns(account) {
+		return ErrUnknownAccount
+	}
+	return nil
+}
+
+// 주소록 조회 함수
+func (db *WalletDB) SelectContact(account common.Address) (Contact, error) {
+	contact := make(Contact)
+	if err := db.checkOwner(account); err != nil {
+		return nil, err
+	}
+	if err := db.selectSecure(contactKey(account), &contact); err != nil && err != errNotFound {
+		return nil, err
+	}
+	return contact, nil
+}
+
+// 주소록 저장 함수
+func (db *WalletDB) InsertContact(account common.Address, contact Contact) error {
+	if err := db.checkOwner(account); err != nil {
+		return err
+	}
+	return db.insertSecure(contactKey(account), contact)
+}
+
+// 트랜잭션 리스트 조회 함수
+func (db *WalletDB) SelectTxHistory(account common.Address) ([]TxHistory, error) {
+	if err := db.checkOwner(account); err != nil {
+		return nil, err
+	}
+	master := make(TxHistoryMaster)
+	if err := db.selectSecure(txMasterKey(account), &master); err != nil && err != errNotFound {
+		return nil, err
+	}
+	txs := make([]TxHistory, 0, len(master))
+	for _, number := range master {
+		var tx TxHistory
+		if err := db.selectSecure(txHistoryKey(account, number), &tx); err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// 트랜잭션 저장 함수, 이미 저장된 트랜잭션이면 false 를 반환한다.
+// 같은 블록에 포함된 여러 트랜잭션을 구분하기 위해 hash 로 저장한다.
+func (db *WalletDB) InsertTxHistory(account common.Address, tx TxHistory) (bool, error) {
+	if err := db.checkOwner(account); err != nil {
+		return false, err
+	}
+	master := make(TxHistoryMaster)
+	if err := db.selectSecure(txMasterKey(account), &master); err != nil && err != errNotFound {
+		return false, err
+	}
+	id := tx.TxBlockNumber
+	if tx.Hash != (common.Hash{}) {
+		id = tx.Hash.Hex()
+	}
+	if _, ok := master[id]; ok {
+		return false, nil
+	}
+	// 이전 버전에서 블록넘버로 저장한 트랜잭션인지 확인
+	if number, ok := master[tx.TxBlockNumber]; ok {
+		var old TxHistory
+		if err := db.selectSecure(txHistoryKey(account, number), &old); err == nil && old.Hash == tx.Hash {
+			return false, nil
+		}
+	}
+	if err := db.insertSecure(txHistoryKey(account, id), tx); err != nil {
+		return false, err
+	}
+	master[id] = id
+	if err := db.insertSecure(txMasterKey(account), master); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 노드의 주소 인덱스에서 트랜잭션 리스트를 가져온 마지막 블록넘버 조회 함수
+// 아직 동기화하지 않았으면 0 을 반환한다.
+func (db *WalletDB) SyncedBlock(account common.Address) (uint64, error) {
+	if err := db.checkOwner(account); err != nil {
+		return 0, err
+	}
+	var number uint64
+	if err := db.selectSecure(syncedKey(account), &number); err != nil && err != errNotFound {
+		return 0, err
+	}
+	return number, nil
+}
+
+// 노드의 주소 인덱스에서 트랜잭션 리스트를 가져온 마지막 블록넘버 저장 함수
+func (db *WalletDB) SetSyncedBlock(account common.Address, number uint64) error {
+	if err := db.checkOwner(account); err != nil {
+		return err
+	}
+	return db.insertSecure(syncedKey(account), number)
+}
+
+// Export 는 id 계정의 레코드를 암호화된 상태 그대로 dst 로 복사한다.
+func (db *WalletDB) Export(dst *WalletDB, id string) error {
+	var member Member
+	if err := db.selectValue(memberKey(id), &member); err != nil {
+		if err == errNotFound {
+			return ErrUnknownMember
+		}
+		return err
+	}
+	batch := dst.db.NewBatch()
+	if err := dst.insert(memberKey(id), member); err != nil {
+		return err
+	}
+	for _, account := range member.AllAccounts() {
+		for _, prefix := range [][]byte{contactKey(account), txMasterKey(account), txHistoryKey(account, ""), syncedKey(account)} {
+			it := db.db.NewIteratorWithPrefix(prefix)
+			for it.Next() {
+				batch.Put(common.CopyBytes(it.Key()), common.CopyBytes(it.Value()))
+			}
+			it.Release()
+			if err := it.Error(); err != nil {
+				return err
+			}
+		}
+	}
+	return batch.Write()
+}
+
+// db 조회 함수, 키가 없으면 errNotFound 를 반환한다.
+func (db *WalletDB) get(key []byte) ([]byte, error) {
+	if has, err := db.db.Has(key); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errNotFound
+	}
+	return db.db.Get(key)
+}
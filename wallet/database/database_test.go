@@ -1,75 +1,277 @@
 package walletdb
 
 import (
-	"github.com/BerithFoundation/berith-chain/common"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
-)
 
-func Test01(t *testing.T) {
+	"github.com/BerithFoundation/berith-chain/accounts/keystore"
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/rlp"
+)
 
-	member := Member{
-		Address:  common.BigToAddress(common.Big257),
-		ID:       "kukugi",
-		Password: "1234",
+func tmpWalletDB(t *testing.T) (string, *WalletDB) {
+	dir, err := ioutil.TempDir("", "walletdb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := newWalletDB(filepath.Join(dir, "test.ldb"), keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
 	}
+	return dir, db
+}
 
-	//member.PrivateKey[0] = 12
+func Test01(t *testing.T) {
+	dir, db := tmpWalletDB(t)
+	defer os.RemoveAll(dir)
+	defer db.CloseDB()
 
-	var contact Contact
-	contact = make(map[common.Address]string,0)
-	//hexToAddress
+	account := common.BigToAddress(common.Big257)
+	if _, err := db.InsertMember("kukugi", account, "1234"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.InsertMember("kukugi", account, "1234"); err != ErrMemberExists {
+		t.Fatalf("duplicate member: have %v, want %v", err, ErrMemberExists)
+	}
+	contact := make(Contact)
 	contact[common.BigToAddress(common.Big32)] = "kimmegi"
 	contact[common.BigToAddress(common.Big3)] = "gorilla"
-	db, err := NewWalletDB("/Users/kimmegi/test.ldb")
+	if err := db.InsertContact(account, contact); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.InsertTxHistory(account, TxHistory{TxBlockNumber: "100", TxAmount: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := db.InsertTxHistory(account, TxHistory{TxBlockNumber: "100", TxAmount: "2"}); ok {
+		t.Fatalf("inserted transaction of the same block twice")
+	}
+	// Nothing but the address and the id may be readable from the disk
+	it := db.db.NewIterator()
+	for it.Next() {
+		for _, secret := range []string{"kimmegi", "gorilla", "1234"} {
+			if bytes.Contains(it.Value(), []byte(secret)) {
+				t.Errorf("record %q contains %q in plain text", it.Key(), secret)
+			}
+		}
+	}
+	it.Release()
+
+	// User data is only accessible after logging in with the right password
+	db.Lock()
+	if _, err := db.SelectContact(account); err != ErrLocked {
+		t.Fatalf("locked database: have %v, want %v", err, ErrLocked)
+	}
+	if _, err := db.Login("kukugi", "4321"); err != keystore.ErrDecrypt {
+		t.Fatalf("wrong password: have %v, want %v", err, keystore.ErrDecrypt)
+	}
+	if _, err := db.Login("kukugi", "1234"); err != nil {
+		t.Fatal(err)
+	}
+	if have, err := db.SelectContact(account); err != nil || len(have) != 2 || have[common.BigToAddress(common.Big3)] != "gorilla" {
+		t.Fatalf("contact mismatch: have %v (%v), want %v", have, err, contact)
+	}
+	if _, err := db.SelectContact(common.BigToAddress(common.Big3)); err == nil {
+		t.Fatalf("accessed the contacts of another account")
+	}
+	// Changing the password keeps the data accessible
+	if _, err := db.UpdatePassword("kukugi", "1234", "5678"); err != nil {
+		t.Fatal(err)
+	}
+	db.Lock()
+	if _, err := db.Login("kukugi", "5678"); err != nil {
+		t.Fatal(err)
+	}
+	if txs, err := db.SelectTxHistory(account); err != nil || len(txs) != 1 || txs[0].TxAmount != "1" {
+		t.Fatalf("transaction mismatch: have %v (%v)", txs, err)
+	}
+}
+
+// Tests that plain text databases are encrypted on first start.
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walletdb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.ldb")
+
+	// Create a database in the old format
+	ldb, err := berithdb.NewLDBDatabase(path, 128, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := common.HexToAddress("Bx0000000000000000000000000000000000000101")
+	put := func(key string, value interface{}) {
+		enc, err := rlp.EncodeToBytes(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ldb.Put([]byte(key), enc)
+	}
+	put("kukugi", legacyMember{Address: account, ID: "kukugi", PrivateKey: "deadbeef", Password: "1234"})
+	put("c"+"Bx0000000000000000000000000000000000000101", Contact{common.BigToAddress(common.Big3): "gorilla"})
+	put("t"+"Bx0000000000000000000000000000000000000101", TxHistoryMaster{"100": "100"})
+	put("100", TxHistory{TxBlockNumber: "100", TxAmount: "1"})
+	ldb.Close()
+
+	db, err := newWalletDB(path, keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.CloseDB()
+
+	it := db.db.NewIterator()
+	for it.Next() {
+		for _, secret := range []string{"deadbeef", "1234", "gorilla"} {
+			if bytes.Contains(it.Value(), []byte(secret)) {
+				t.Errorf("record %q contains %q in plain text", it.Key(), secret)
+			}
+		}
+	}
+	it.Release()
+
+	member, err := db.Login("kukugi", "1234")
 	if err != nil {
-		t.Error(err)
-		return
+		t.Fatal(err)
+	}
+	if member.Address != account {
+		t.Errorf("address mismatch: have %x, want %x", member.Address, account)
+	}
+	if contact, err := db.SelectContact(account); err != nil || contact[common.BigToAddress(common.Big3)] != "gorilla" {
+		t.Errorf("contact mismatch: have %v (%v)", contact, err)
+	}
+	if txs, err := db.SelectTxHistory(account); err != nil || len(txs) != 1 || txs[0].TxAmount != "1" {
+		t.Errorf("transaction mismatch: have %v (%v)", txs, err)
 	}
+}
 
-	err = db.Insert([]byte("swk"), member)
+// Tests that the plain text secrets of a migrated database are gone from the
+// files of the database, not only from its records.
+func TestMigrateFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walletdb-test")
 	if err != nil {
-		t.Error(err)
-		return
+		t.Fatal(err)
 	}
-	err = db.Insert([]byte("soni"), contact)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.ldb")
+
+	ldb, err := berithdb.NewLDBDatabase(path, 128, 1024)
 	if err != nil {
-		t.Error(err)
-		return
+		t.Fatal(err)
 	}
+	account := common.HexToAddress("Bx0000000000000000000000000000000000000101")
+	secrets := []string{"c0ffee-private-key", "plain-text-password"}
+	enc, _ := rlp.EncodeToBytes(legacyMember{Address: account, ID: "kukugi", PrivateKey: secrets[0], Password: secrets[1]})
+	ldb.Put([]byte("kukugi"), enc)
+	ldb.Close()
 
-	db.db.Close()
+	db, err := newWalletDB(path, keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.CloseDB()
 
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(filepath.Join(path, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range secrets {
+			if bytes.Contains(data, []byte(secret)) {
+				t.Errorf("file %s contains %q in plain text", file.Name(), secret)
+			}
+		}
+	}
+}
+
+// Tests that members keep separate contacts per account.
+func TestMultipleAccounts(t *testing.T) {
+	dir, db := tmpWalletDB(t)
+	defer os.RemoveAll(dir)
+	defer db.CloseDB()
+
+	operation, staking := common.BigToAddress(common.Big1), common.BigToAddress(common.Big2)
+	if _, err := db.InsertMember("kukugi", operation, "1234"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SelectAccount(staking); err != ErrUnknownAccount {
+		t.Fatalf("selecting foreign account: have %v, want %v", err, ErrUnknownAccount)
+	}
+	if _, err := db.AddAccount(staking); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.AddAccount(staking); err != ErrAccountExists {
+		t.Fatalf("adding account twice: have %v, want %v", err, ErrAccountExists)
+	}
+	if err := db.InsertContact(operation, Contact{common.BigToAddress(common.Big3): "gorilla"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SelectAccount(staking); err != nil {
+		t.Fatal(err)
+	}
+	if account, _ := db.Account(); account != staking {
+		t.Fatalf("selected account mismatch: have %x, want %x", account, staking)
+	}
+	if contact, err := db.SelectContact(staking); err != nil || len(contact) != 0 {
+		t.Fatalf("staking contacts: have %v (%v), want none", contact, err)
+	}
+	// The accounts survive logging in again, starting with the login account
+	db.Lock()
+	if _, err := db.Login("kukugi", "1234"); err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := db.Accounts()
+	if err != nil || len(accounts) != 2 || accounts[0] != operation || accounts[1] != staking {
+		t.Fatalf("accounts mismatch: have %x (%v)", accounts, err)
+	}
+	if account, _ := db.Account(); account != operation {
+		t.Fatalf("selected account mismatch: have %x, want %x", account, operation)
+	}
+	if contact, err := db.SelectContact(operation); err != nil || contact[common.BigToAddress(common.Big3)] != "gorilla" {
+		t.Fatalf("operation contacts: have %v (%v)", contact, err)
+	}
+}
+
+func TestTxHistorySync(t *testing.T) {
+	dir, db := tmpWalletDB(t)
+	defer os.RemoveAll(dir)
+	defer db.CloseDB()
+
+	account := common.BigToAddress(common.Big257)
+	if _, err := db.InsertMember("kukugi", account, "1234"); err != nil {
+		t.Fatal(err)
+	}
+	// Transactions of the same block are told apart by their hashes
+	for i, hash := range []common.Hash{common.BigToHash(common.Big1), common.BigToHash(common.Big2), common.BigToHash(common.Big1)} {
+		ok, err := db.InsertTxHistory(account, TxHistory{TxBlockNumber: "0x64", Hash: hash})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i < 2; ok != want {
+			t.Errorf("insert %d: have %v, want %v", i, ok, want)
+		}
+	}
+	if txs, err := db.SelectTxHistory(account); err != nil || len(txs) != 2 {
+		t.Fatalf("transactions mismatch: have %d (%v), want 2", len(txs), err)
+	}
+	if number, err := db.SyncedBlock(account); err != nil || number != 0 {
+		t.Fatalf("initial synced block: have %d (%v), want 0", number, err)
+	}
+	if err := db.SetSyncedBlock(account, 100); err != nil {
+		t.Fatal(err)
+	}
+	if number, err := db.SyncedBlock(account); err != nil || number != 100 {
+		t.Fatalf("synced block: have %d (%v), want 100", number, err)
+	}
+	if err := db.SetSyncedBlock(common.BigToAddress(common.Big3), 100); err != ErrUnknownAccount {
+		t.Fatalf("foreign account: have %v, want %v", err, ErrUnknownAccount)
+	}
 }
-//
-//func Test01(t *testing.T) {
-//
-//	var (
-//		err     error
-//		member  Member
-//		contact = make(Contact,0)
-//	)
-//
-//	db, err := NewWalletDB("/Users/kimmegi/test.ldb")
-//	if err != nil {
-//		t.Error(err)
-//		return
-//	}
-//
-//	err = db.Select([]byte("swk"), &member)
-//	if err != nil {
-//		t.Error(err)
-//		return
-//	}
-//
-//	err = db.Select([]byte("soni"), &contact)
-//	if err != nil {
-//		t.Error(err)
-//		return
-//	}
-//
-//	t.Log(member)
-//	t.Log(contact)
-//
-//	db.db.Close()
-//
-//}
@@ -0,0 +1,254 @@
+package walletdb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/BerithFoundation/berith-chain/accounts/keystore"
+	"github.com/BerithFoundation/berith-chain/common"
+)
+
+const dataKeyLength = 32
+
+var (
+	memberPrefix    = []byte("m") // memberPrefix + id -> Member
+	contactPrefix   = []byte("c") // contactPrefix + address -> 암호화된 Contact
+	txMasterPrefix  = []byte("t") // txMasterPrefix + address -> 암호화된 TxHistoryMaster
+	txHistoryPrefix = []byte("h") // txHistoryPrefix + address + 블록넘버 또는 tx hash -> 암호화된 TxHistory
+	syncedPrefix    = []byte("s") // syncedPrefix + address -> 암호화된 노드 인덱스 동기화 블록넘버
+
+	errCorruptRecord = errors.New("corrupt wallet record")
+)
+
+func memberKey(id string) []byte {
+	return append(common.CopyBytes(memberPrefix), id...)
+}
+
+func contactKey(account common.Address) []byte {
+	return append(common.CopyBytes(contactPrefix), account.Bytes()...)
+}
+
+func txMasterKey(account common.Address) []byte {
+	return append(common.CopyBytes(txMasterPrefix), account.Bytes()...)
+}
+
+func txHistoryKey(account common.Address, number string) []byte {
+	return append(append(common.CopyBytes(txHistoryPrefix), account.Bytes()...), number...)
+}
+
+func syncedKey(account common.Address) []byte {
+	return append(common.CopyBytes(syncedPrefix), account.Bytes()...)
+}
+
+// 계정 조회 함수
+func (db *WalletDB) SelectMember(id string) (*Member, error) {
+	var member Member
+	if err := db.selectValue(memberKey(id), &member); err != nil {
+		if err == errNotFound {
+			return nil, ErrUnknownMember
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+// 계정 등록 함수
+// 새 데이터 키를 만들어 password 로 암호화해서 저장하고, 등록한 계정으로 로그인한다.
+// password 는 호출하는 쪽에서 keystore 로 확인해야 한다.
+func (db *WalletDB) InsertMember(id string, account common.Address, password string) (*Member, error) {
+	if has, err := db.db.Has(memberKey(id)); err != nil {
+		return nil, err
+	} else if has {
+		return nil, ErrMemberExists
+	}
+	dataKey := make([]byte, dataKeyLength)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	member := &Member{Address: account, ID: id}
+	if err := db.wrapKey(member, dataKey, password); err != nil {
+		return nil, err
+	}
+	if err := db.insert(memberKey(id), member); err != nil {
+		return nil, err
+	}
+	db.unlock(member, dataKey)
+	return member, nil
+}
+
+// 로그인 함수
+// 계정의 데이터 키를 password 로 복호화해서 사용자 데이터를 열람할 수 있게 한다.
+// 비밀번호가 틀리면 keystore.ErrDecrypt 를 반환한다.
+func (db *WalletDB) Login(id string, password string) (*Member, error) {
+	member, err := db.SelectMember(id)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := unwrapKey(member, password)
+	if err != nil {
+		return nil, err
+	}
+	db.unlock(member, dataKey)
+	return member, nil
+}
+
+// 로그아웃 함수, 메모리의 데이터 키를 지운다.
+func (db *WalletDB) Lock() {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	for i := range db.dataKey {
+		db.dataKey[i] = 0
+	}
+	db.member, db.dataKey, db.account = nil, nil, common.Address{}
+}
+
+// 비밀번호 변경 함수
+// 데이터 키를 새 비밀번호로 다시 암호화하므로 사용자 데이터는 다시 암호화하지 않는다.
+func (db *WalletDB) UpdatePassword(id string, password, newPassword string) (*Member, error) {
+	member, err := db.SelectMember(id)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := unwrapKey(member, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.wrapKey(member, dataKey, newPassword); err != nil {
+		return nil, err
+	}
+	if err := db.insert(memberKey(id), member); err != nil {
+		return nil, err
+	}
+	db.lock.Lock()
+	if db.member != nil && db.member.ID == id {
+		db.member = member
+	}
+	db.lock.Unlock()
+	return member, nil
+}
+
+// 로그인한 계정에 주소를 추가하는 함수
+func (db *WalletDB) AddAccount(account common.Address) (*Member, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.member == nil {
+		return nil, ErrLocked
+	}
+	if db.member.Owns(account) {
+		return nil, ErrAccountExists
+	}
+	member := *db.member
+	member.Accounts = append(append([]common.Address{}, member.Accounts...), account)
+	if err := db.insert(memberKey(member.ID), &member); err != nil {
+		return nil, err
+	}
+	db.member = &member
+	return &member, nil
+}
+
+// 로그인한 계정이 관리하는 주소 목록 조회 함수
+func (db *WalletDB) Accounts() ([]common.Address, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.member == nil {
+		return nil, ErrLocked
+	}
+	return db.member.AllAccounts(), nil
+}
+
+// 트랜잭션을 보내고 주소록과 트랜잭션 리스트를 조회할 주소를 선택하는 함수
+func (db *WalletDB) SelectAccount(account common.Address) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.member == nil {
+		return ErrLocked
+	}
+	if !db.member.Owns(account) {
+		return ErrUnknownAccount
+	}
+	db.account = account
+	return nil
+}
+
+// 선택한 주소 조회 함수
+func (db *WalletDB) Account() (common.Address, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.member == nil {
+		return common.Address{}, ErrLocked
+	}
+	return db.account, nil
+}
+
+func (db *WalletDB) unlock(member *Member, dataKey []byte) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.member, db.dataKey, db.account = member, dataKey, member.Address
+}
+
+// 데이터 키를 keystore 와 같은 scrypt 설정으로 암호화하는 함수
+func (db *WalletDB) wrapKey(member *Member, dataKey []byte, password string) error {
+	cryptoStruct, err := keystore.EncryptDataV3(dataKey, []byte(password), db.scryptN, db.scryptP)
+	if err != nil {
+		return err
+	}
+	member.Key, err = json.Marshal(cryptoStruct)
+	return err
+}
+
+// 암호화된 데이터 키를 복호화하는 함수
+func unwrapKey(member *Member, password string) ([]byte, error) {
+	var cryptoStruct keystore.CryptoJSON
+	if err := json.Unmarshal(member.Key, &cryptoStruct); err != nil {
+		return nil, err
+	}
+	return keystore.DecryptDataV3(cryptoStruct, password)
+}
+
+// AES-GCM 으로 암호화하는 함수, 레코드 키를 인증 데이터로 사용해서 다른 키로 옮겨진
+// 레코드는 복호화되지 않는다.
+func seal(dataKey, key, data []byte) ([]byte, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, key), nil
+}
+
+// AES-GCM 으로 복호화하는 함수
+func open(dataKey, key, sealed []byte) ([]byte, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errCorruptRecord
+	}
+	data, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], key)
+	if err != nil {
+		return nil, errCorruptRecord
+	}
+	return data, nil
+}
+
+func newAEAD(dataKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,154 @@
+package walletdb
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/BerithFoundation/berith-chain/berithdb"
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/log"
+	"github.com/BerithFoundation/berith-chain/rlp"
+)
+
+// 데이터베이스 형식 버전
+// 0 : 개인키와 비밀번호를 포함한 모든 데이터를 평문으로 저장
+// 1 : 계정에는 주소와 암호화된 데이터 키만 저장하고 사용자 데이터는 암호화
+const dbVersion = 1
+
+var versionKey = []byte("WalletDBVersion")
+
+// 버전 0 의 로그인 계정 table ( key 값은 id )
+type legacyMember struct {
+	Address    common.Address
+	PrivateKey string
+	ID         string
+	Password   string
+}
+
+// 버전 0 데이터베이스를 버전 1 로 변환하는 함수
+// 버전 0 은 계정마다 비밀번호를 평문으로 저장했으므로, 그 비밀번호로 데이터 키를 암호화하고
+// 계정의 주소록과 트랜잭션 리스트를 암호화한 뒤 평문 레코드는 모두 지운다.
+func (db *WalletDB) migrate() error {
+	var version uint64
+	if err := db.selectValue(versionKey, &version); err == nil {
+		return nil
+	} else if err != errNotFound {
+		return err
+	}
+	records := make(map[string][]byte)
+	it := db.db.NewIterator()
+	for it.Next() {
+		records[string(it.Key())] = common.CopyBytes(it.Value())
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return err
+	}
+	batch := db.db.NewBatch()
+	for key := range records {
+		batch.Delete([]byte(key))
+	}
+	// 같은 주소를 쓰는 계정끼리는 데이터 키를 공유한다.
+	dataKeys := make(map[common.Address][]byte)
+	for key, blob := range records {
+		var legacy legacyMember
+		if err := rlp.DecodeBytes(blob, &legacy); err != nil || legacy.ID != key {
+			continue
+		}
+		dataKey, ok := dataKeys[legacy.Address]
+		if !ok {
+			dataKey = make([]byte, dataKeyLength)
+			if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+				return err
+			}
+			if err := migrateUserData(batch, legacy.Address, dataKey, records); err != nil {
+				return err
+			}
+			dataKeys[legacy.Address] = dataKey
+		}
+		member := &Member{Address: legacy.Address, ID: legacy.ID}
+		if err := db.wrapKey(member, dataKey, legacy.Password); err != nil {
+			return err
+		}
+		enc, err := rlp.EncodeToBytes(member)
+		if err != nil {
+			return err
+		}
+		batch.Put(memberKey(member.ID), enc)
+	}
+	enc, _ := rlp.EncodeToBytes(uint64(dbVersion))
+	batch.Put(versionKey, enc)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	// 지운 평문 레코드가 디스크의 이전 파일에 남지 않도록 변환한 전체 범위를 compact 한다.
+	if store, ok := db.db.(berithdb.Compacter); ok {
+		if err := store.Compact(nil, nil); err != nil {
+			return err
+		}
+	}
+	log.Info("Encrypted wallet database", "accounts", len(dataKeys), "records", len(records))
+	return nil
+}
+
+// 버전 0 의 주소록과 트랜잭션 리스트 중 account 의 것을 암호화해서 옮기는 함수
+// 버전 0 의 키는 "c" / "t" + 노드가 반환한 주소 문자열이고, 트랜잭션 Detail 의 키는 블록넘버였다.
+func migrateUserData(batch berithdb.Batch, account common.Address, dataKey []byte, records map[string][]byte) error {
+	for key, blob := range records {
+		if len(key) < 2 {
+			continue
+		}
+		if owner := legacyAccount(key[1:]); owner == (common.Address{}) || owner != account {
+			continue
+		}
+		switch key[:1] {
+		case string(contactPrefix):
+			sealed, err := seal(dataKey, contactKey(account), blob)
+			if err != nil {
+				return err
+			}
+			batch.Put(contactKey(account), sealed)
+
+		case string(txMasterPrefix):
+			legacyMaster := make(TxHistoryMaster)
+			if err := rlp.DecodeBytes(blob, &legacyMaster); err != nil {
+				log.Warn("Dropping corrupt wallet transaction list", "account", account, "err", err)
+				continue
+			}
+			master := make(TxHistoryMaster)
+			for number, detailKey := range legacyMaster {
+				detail, ok := records[detailKey]
+				if !ok {
+					continue
+				}
+				sealed, err := seal(dataKey, txHistoryKey(account, number), detail)
+				if err != nil {
+					return err
+				}
+				batch.Put(txHistoryKey(account, number), sealed)
+				master[number] = number
+			}
+			enc, err := rlp.EncodeToBytes(master)
+			if err != nil {
+				return err
+			}
+			sealed, err := seal(dataKey, txMasterKey(account), enc)
+			if err != nil {
+				return err
+			}
+			batch.Put(txMasterKey(account), sealed)
+		}
+	}
+	return nil
+}
+
+// 버전 0 키에 들어있는 주소 문자열을 해석하는 함수, 주소가 아니면 빈 주소를 반환한다.
+func legacyAccount(s string) common.Address {
+	if b := common.FromHex(s); len(b) == common.AddressLength && len(s) >= 2*common.AddressLength {
+		return common.BytesToAddress(b)
+	}
+	return common.Address{}
+}
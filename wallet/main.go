@@ -93,7 +93,11 @@ func start_ui() {
 						client = nodeChannel.v.(*rpc.Client)
 						stack = nodeChannel.stack.(*node.Node)
 						dir, _ := stack.FetchKeystoreDir()
-						WalletDB, _ = walletdb.NewWalletDB(dir + "/test.ldb")
+						db, err := walletdb.NewWalletDB(dir + "/test.ldb")
+						if err != nil {
+							astilog.Error(errors.Wrap(err, "opening wallet database failed"))
+						}
+						WalletDB = db
 						ctx = context.TODO()
 						if err := bootstrap.SendMessage(w, "notify_hide", ""); err != nil {
 							astilog.Error(errors.Wrap(err, "sending check.out.menu event failed"))
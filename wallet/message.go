@@ -3,13 +3,14 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/BerithFoundation/berith-chain/accounts"
+	"github.com/BerithFoundation/berith-chain/accounts/keystore"
 	"github.com/BerithFoundation/berith-chain/common"
 	"github.com/BerithFoundation/berith-chain/log"
 	"github.com/BerithFoundation/berith-chain/wallet/database"
 	"github.com/asticode/go-astilectron"
 	"github.com/asticode/go-astilectron-bootstrap"
 	"github.com/asticode/go-astilog"
-	"github.com/pkg/errors"
 	"io/ioutil"
 	"os"
 	"reflect"
@@ -84,6 +85,9 @@ func callNodeApi(api interface{}, args ...interface{}) (string, error) {
 		if apiName == "berith_sendTransaction" || apiName == "berith_stake" || apiName == "berith_rewardToBalance" || apiName == "berith_rewardToStake" || apiName == "berith_stopStaking" {
 			temp := reflect.ValueOf(item).Interface()
 			itemMap := temp.(map[string]interface{})
+			if err := setSender(itemMap); err != nil {
+				return err.Error(), err
+			}
 			p = append(p, itemMap)
 		} else {
 			p = append(p, item)
@@ -104,18 +108,78 @@ func callDB(api interface{}, args ...interface{}) (interface{}, error) {
 	for _, item := range args {
 		key = append(key, item.(string))
 	}
-	acc, err := callNodeApi("berith_coinbase", nil)
-	// acc = strings.ReplaceAll(acc , "\"","")
-	acc = strings.Replace(acc, "\"", "", -1)
-	if err != nil {
-		astilog.Error(errors.Wrap(err, "insert error"))
-	}
+	// 주소록과 트랜잭션 리스트는 지갑에서 선택한 주소의 것을 사용한다.
+	account, _ := WalletDB.Account()
 	switch api.(string) {
 
-	case "selectContact":
-		contact := make(walletdb.Contact, 0)
-		err := WalletDB.Select([]byte("c"+acc), &contact)
+	case "selectAccounts":
+		accounts, err := WalletDB.Accounts()
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			Accounts []common.Address
+			Selected common.Address
+		}{accounts, account}, nil
+		break
+	case "createAccount":
+		// 새 계정을 만들어 로그인한 계정에 추가한다.
+		newAcc, err := callNodeApi("personal_newAccount", key[0])
+		if err != nil {
+			return "err", err
+		}
+		newAcc = strings.Replace(newAcc, "\"", "", -1)
+		member, err := WalletDB.AddAccount(common.HexToAddress(newAcc))
+		if err != nil {
+			return "err", err
+		}
+		return member, nil
+		break
+	case "importAccount":
+		// 개인키를 keystore 로 가져와서 로그인한 계정에 추가한다.
+		newAcc, err := callNodeApi("personal_importRawKey", key[0], key[1])
+		if err != nil {
+			return "err", err
+		}
+		newAcc = strings.Replace(newAcc, "\"", "", -1)
+		member, err := WalletDB.AddAccount(common.HexToAddress(newAcc))
+		if err != nil {
+			return "err", err
+		}
+		return member, nil
+		break
+	case "addAccount":
+		// keystore 에 이미 있는 계정을 비밀번호를 확인하고 로그인한 계정에 추가한다.
+		address := common.HexToAddress(key[0])
+		if err := checkPassword(address, key[1]); err != nil {
+			return "err", err
+		}
+		member, err := WalletDB.AddAccount(address)
+		if err != nil {
+			return "err", err
+		}
+		return member, nil
+		break
+	case "selectAccount":
+		// 선택한 주소로 트랜잭션을 보낼 수 있도록 노드에서 잠금을 해제한다.
+		address := common.HexToAddress(key[0])
+		if err := ownsAccount(address); err != nil {
+			return "err", err
+		}
+		if err := checkPassword(address, key[1]); err != nil {
+			return "err", err
+		}
+		if _, err := callNodeApi("personal_unlockAccount", address.Hex(), key[1], 0); err != nil {
+			return "err", err
+		}
+		if err := WalletDB.SelectAccount(address); err != nil {
+			return "err", err
+		}
+		return address, nil
+		break
 
+	case "selectContact":
+		contact, err := WalletDB.SelectContact(account)
 		if err != nil {
 			return nil, err
 		}
@@ -123,102 +187,71 @@ func callDB(api interface{}, args ...interface{}) (interface{}, error) {
 
 		break
 	case "selectMember":
-		var member walletdb.Member
-
-		err := WalletDB.Select([]byte("qwer5910"), &member)
+		member, err := WalletDB.SelectMember(key[0])
 		if err != nil {
 			return nil, err
 		}
 		return member, nil
 		break
 	case "insertContact":
-		contact := make(walletdb.Contact, 0)
-		WalletDB.Select([]byte("c"+acc), &contact)
+		contact, err := WalletDB.SelectContact(account)
+		if err != nil {
+			return nil, err
+		}
 		contact[common.HexToAddress(key[0])] = key[1]
 
-		err := WalletDB.Insert([]byte("c"+acc), contact)
+		err = WalletDB.InsertContact(account, contact)
 		if err != nil {
 			return nil, err
 		}
 		return contact, nil
 		break
 	case "updateContact":
-		contact := make(walletdb.Contact, 0)
-		WalletDB.Select([]byte("c"+acc), &contact)
+		contact, err := WalletDB.SelectContact(account)
+		if err != nil {
+			return nil, err
+		}
 		delete(contact, common.HexToAddress(key[0]))
-		err := WalletDB.Insert([]byte("c"+acc), contact)
+		err = WalletDB.InsertContact(account, contact)
 		if err != nil {
 			return nil, err
 		}
 		return contact, nil
 		break
 	case "restoreMember":
-		var mem walletdb.Member
-		err := WalletDB.Select([]byte(key[1]), &mem)
-		if err == nil {
-			mem = walletdb.Member{
-				Address: common.HexToAddress(key[0]),
-				ID : key[1],
-				Password: key[2],
-				PrivateKey: key[3],
-			}
-			return mem, nil
-		}
-		member := walletdb.Member{
-			Address:    common.HexToAddress(key[0]),
-			ID:         key[1],
-			Password:   key[2],
-			PrivateKey: key[3],
+		// keystore 로 복원한 계정의 비밀번호를 확인하고 계정을 등록한다.
+		address := common.HexToAddress(key[0])
+		if err := checkPassword(address, key[2]); err != nil {
+			return "err", err
 		}
-		err = WalletDB.Insert([]byte(key[1]), member)
+		member, err := WalletDB.InsertMember(key[1], address, key[2])
 		if err != nil {
-			return nil, err
+			return "err", err
 		}
 		return member, nil
 		break
 	case "updateMember":
-		var mem walletdb.Member
-		err := WalletDB.Select([]byte(key[0]), &mem)
+		// keystore 비밀번호와 같이 데이터 키의 비밀번호를 변경한다.
+		member, err := WalletDB.UpdatePassword(key[0], key[1], key[2])
 		if err != nil {
 			return "err", err
 		}
-		mem.Password = key[1]
-		err = WalletDB.Insert([]byte(key[0]), mem)
-		if err != nil {
-			return nil, err
-		}
-		return mem, nil
+		return member, nil
 		break
 	case "selectTxInfo":
-		txMaster := make(walletdb.TxHistoryMaster, 0)
-		err := WalletDB.Select([]byte("t"+acc), &txMaster)
+		// 노드의 주소 인덱스에서 지갑을 켜기 전이나 다른 지갑에서 보낸 트랜잭션을 가져온다.
+		if inserted, err := syncTxHistory(account); err != nil {
+			astilog.Debugf("Failed to sync transaction history: %v", err)
+		} else if inserted > 0 {
+			astilog.Debugf("Synced %d transactions", inserted)
+		}
+		txDetails, err := WalletDB.SelectTxHistory(account)
 		if err != nil {
 			return nil, err
 		}
-		txDetails := make([]walletdb.TxHistory, 0)
-		for _, val := range txMaster {
-			var txDetail walletdb.TxHistory
-			err2 := WalletDB.Select([]byte(val), &txDetail)
-			if err2 != nil {
-				return nil, err
-			}
-			txDetails = append(txDetails, txDetail)
-		}
 		return txDetails, nil
 		break
 	case "insertTxInfo":
-		var tempTxInfo walletdb.TxHistory
-		err := WalletDB.Select([]byte(key[0]), &tempTxInfo)
-		if err == nil {
-			return "err", err
-		}
-		txMaster := make(walletdb.TxHistoryMaster, 0)
-		WalletDB.Select([]byte("t"+acc), &txMaster)
-		txMaster[key[0]] = key[0]
-		err = WalletDB.Insert([]byte("t"+acc), txMaster)
-		if err != nil {
-			return nil, err
-		}
 		txinfo := walletdb.TxHistory{
 			TxBlockNumber : key[0],
 			TxAddress: common.HexToAddress(key[1]),
@@ -230,44 +263,54 @@ func callDB(api interface{}, args ...interface{}) (interface{}, error) {
 			GasPrice: key[6],
 			GasUsed: key[7],
 		}
-		err = WalletDB.Insert([]byte(key[0]), txinfo)
+		inserted, err := WalletDB.InsertTxHistory(account, txinfo)
 		if err != nil {
 			return nil, err
 		}
+		if !inserted {
+			return "err", nil
+		}
 		return txinfo, nil
 
 		break
 	case "insertMember":
-		var mem walletdb.Member
-		err := WalletDB.Select([]byte(key[0]), &mem)
-		if err == nil {
-			return "err", err
+		if _, err := WalletDB.SelectMember(key[0]); err == nil {
+			return "err", walletdb.ErrMemberExists
 		}
 		newAcc, err := callNodeApi("personal_newAccount", key[1])
+		if err != nil {
+			return nil, err
+		}
 		newAcc = strings.Replace(newAcc, "\"", "" ,-1)
 		privateKey, err := callNodeApi("personal_privateKey", newAcc, key[1])
-		privateKey = strings.Replace(privateKey, "\"", "" , -1)
-		member := walletdb.Member{
-			Address: common.HexToAddress(newAcc),
-			ID:      key[0],
-			Password:   key[1],
-			PrivateKey: privateKey,
+		if err != nil {
+			return nil, err
 		}
-
-		err = WalletDB.Insert([]byte(key[0]), member)
-
+		privateKey = strings.Replace(privateKey, "\"", "" , -1)
+		member, err := WalletDB.InsertMember(key[0], common.HexToAddress(newAcc), key[1])
 		if err != nil {
 			return nil, err
 		}
-		return member, nil
+		// 개인키는 저장하지 않고 백업할 수 있도록 한번만 보여준다.
+		return struct {
+			Address    common.Address
+			ID         string
+			PrivateKey string
+		}{member.Address, member.ID, privateKey}, nil
 		break
 
 	case "checkLogin":
-		var member walletdb.Member
-
-		err := WalletDB.Select([]byte(key[0]), &member)
+		// 노드 keystore 로 비밀번호를 확인한 뒤 사용자 데이터를 복호화할 수 있게 한다.
+		member, err := WalletDB.SelectMember(key[0])
 		if err != nil {
-			return nil, err
+			return "unknownMember", err
+		}
+		if err := checkPassword(member.Address, key[1]); err != nil {
+			return "invalidPassword", err
+		}
+		member, err = WalletDB.Login(key[0], key[1])
+		if err != nil {
+			return "invalidPassword", err
 		}
 		return member, nil
 		break
@@ -276,6 +319,56 @@ func callDB(api interface{}, args ...interface{}) (interface{}, error) {
 	return nil, nil
 }
 
+// 로그인한 계정이 address 를 관리하는지 확인하는 함수
+func ownsAccount(address common.Address) error {
+	accounts, err := WalletDB.Accounts()
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		if account == address {
+			return nil
+		}
+	}
+	return walletdb.ErrUnknownAccount
+}
+
+// 트랜잭션을 보낼 주소를 설정하는 함수
+// from 이 없으면 지갑에서 선택한 주소로 보내고, 다른 주소는 로그인한 계정이 관리할 때만 허용한다.
+func setSender(args map[string]interface{}) error {
+	if from, _ := args["from"].(string); from != "" {
+		return ownsAccount(common.HexToAddress(from))
+	}
+	account, err := WalletDB.Account()
+	if err != nil {
+		return err
+	}
+	args["from"] = account.Hex()
+	return nil
+}
+
+// 노드 keystore 의 키 파일로 계정 비밀번호를 확인하는 함수
+func checkPassword(address common.Address, password string) error {
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	account, err := ks.Find(accounts.Account{Address: address})
+	if err != nil {
+		return err
+	}
+	keyjson, err := ioutil.ReadFile(account.URL.Path)
+	if err != nil {
+		return err
+	}
+	key, err := keystore.DecryptKey(keyjson, password)
+	if err != nil {
+		return err
+	}
+	b := key.PrivateKey.D.Bits()
+	for i := range b {
+		b[i] = 0
+	}
+	return nil
+}
+
 // 개인키 내보내기 함수
 
 func exportKeystore(args []interface{}) (interface{}, error) {
@@ -308,31 +401,14 @@ func exportKeystore(args []interface{}) (interface{}, error) {
 	}
 	// 끝
 	// export 하는 계정에 관련된 db 정보만 따로 추출하는 부분
-	WalletDB2 ,_ = walletdb.NewWalletDB(tmmp+"/test.ldb")
-	var mem walletdb.Member
-	var txInfo walletdb.TxHistory
-	contact := make(walletdb.Contact, 0)
-	txMaster := make(walletdb.TxHistoryMaster, 0)
-	WalletDB.Select([]byte("c"+args[0].(string)), &contact)
-	WalletDB.Select([]byte("t"+args[0].(string)), &txMaster)
-	for key , _ := range txMaster{
-		WalletDB.Select([]byte(key), &txInfo)
-		WalletDB2.Insert([]byte(key), txInfo)
-	}
-	err = WalletDB.Select([]byte(args[2].(string)), &mem)
-	if err != nil {
-		return nil, err
-	}
-	err = WalletDB2.Insert([]byte(args[2].(string)), mem)
-	if err != nil {
-		return nil, err
-	}
-	err = WalletDB2.Insert([]byte("c"+args[0].(string)), contact)
+	// 레코드는 암호화된 상태 그대로 복사된다.
+	WalletDB2, err = walletdb.NewWalletDB(tmmp+"/test.ldb")
 	if err != nil {
 		return nil, err
 	}
-	err = WalletDB2.Insert([]byte("t"+args[0].(string)), txMaster)
+	err = WalletDB.Export(WalletDB2, args[2].(string))
 	if err != nil {
+		WalletDB2.CloseDB()
 		return nil, err
 	}// 끝
 	password := args[1].(string)
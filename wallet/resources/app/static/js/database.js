@@ -30,11 +30,11 @@ let database = {
         result = await sendMessage("callDB", "checkLogin", [memberName, memberPwd]);
         return result;
     },
-    selectMember : function () {
+    selectMember : function (memberName) {
         let message = {"name" : "callDB"}
         message.payload = {
             "api" : "selectMember",
-            "args" : ["aa"]
+            "args" : [memberName]
         }
         asticode.loader.show()
         astilectron.sendMessage(message , function (message) {
@@ -45,11 +45,9 @@ let database = {
             var contents = ''
             // // console.log("ADD :: " + obj.Address)
             // // console.log("id :: " + obj.ID)
-            // // console.log("pwd :: " + obj.Password)
             contents += '<tr>'
             contents += '<td><input type="text" value="'+obj.Address+'"></td>'
             contents += '<td><input type="text" value="'+obj.ID+'"></td>'
-            contents += '<td><input type="text" value="'+obj.Password+'"></td>'
             contents += '</tr>'
             $('#memberData').append(contents)
         })
@@ -107,11 +105,11 @@ let database = {
             }) // settimeout
         }) // promise
     },
-    updateMember : function (memberName , memberPwd) {
+    updateMember : function (memberName , memberPwd , newMemberPwd) {
         let message = {"name" : "callDB"}
         message.payload = {
             "api" : "updateMember",
-            "args" : [memberName , memberPwd]
+            "args" : [memberName , memberPwd , newMemberPwd]
         }
         asticode.loader.show()
         astilectron.sendMessage(message , function (message) {
@@ -140,17 +138,16 @@ let database = {
             }else{
                 // console.log("ADD :: " + obj.Address)
                 // console.log("id :: " + obj.ID)
-                // console.log("pwd :: " + obj.Password)
                 // console.log("private :: " + obj.PrivateKey)
                 location.href="createAccountConfirm.html?Address="+obj.Address+"&ID="+obj.ID+"&PrivateKey="+obj.PrivateKey;
             }
         });
     },
-    restoreMember : function (add , id , pwd , privateKey) {
+    restoreMember : function (add , id , pwd) {
         let message = {"name" : "callDB"}
         message.payload = {
             "api" : "restoreMember",
-            "args" : [add , id , pwd , privateKey]
+            "args" : [add , id , pwd]
         }
         asticode.loader.show()
         astilectron.sendMessage(message , function (message) {
@@ -163,11 +160,33 @@ let database = {
             }else{
                 // console.log("ADD :: " + obj.Address)
                 // console.log("id :: " + obj.ID)
-                // console.log("pwd :: " + obj.Password)
-                // console.log("private :: " + obj.PrivateKey)
                 location.href="keystoreRestoreConfirm.html";
             }
         });
+    },
+    selectAccounts : async function () {
+        result = await sendMessage("callDB", "selectAccounts", []);
+        return result;
+    },
+    createAccount : async function (memberPwd) {
+        result = await sendMessage("callDB", "createAccount", [memberPwd]);
+        return result;
+    },
+    importAccount : async function (privateKey , accountPwd) {
+        result = await sendMessage("callDB", "importAccount", [privateKey, accountPwd]);
+        return result;
+    },
+    addAccount : async function (address , accountPwd) {
+        result = await sendMessage("callDB", "addAccount", [address, accountPwd]);
+        return result;
+    },
+    // 트랜잭션을 보낼 계정 선택, 성공하면 세션의 account 를 바꾼다.
+    selectAccount : async function (address , accountPwd) {
+        result = await sendMessage("callDB", "selectAccount", [address, accountPwd]);
+        if (result.name != "error") {
+            sessionStorage.setItem('account', address);
+        }
+        return result;
     }
 
 
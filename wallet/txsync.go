@@ -0,0 +1,104 @@
+package main
+
+import (
+	"time"
+
+	"github.com/BerithFoundation/berith-chain/common"
+	"github.com/BerithFoundation/berith-chain/common/hexutil"
+	"github.com/BerithFoundation/berith-chain/core/types"
+	"github.com/BerithFoundation/berith-chain/internal/berithapi"
+	"github.com/BerithFoundation/berith-chain/wallet/database"
+)
+
+// 노드의 주소 인덱스로 account 의 트랜잭션 리스트를 동기화하는 함수
+// 마지막으로 동기화한 블록 이후의 트랜잭션을 모두 저장하고 새로 저장한 개수를 반환한다.
+// 노드가 주소 인덱스를 사용하지 않으면 에러를 반환하고, 블록을 받을 때 저장하는 트랜잭션만 남는다.
+func syncTxHistory(account common.Address) (int, error) {
+	synced, err := WalletDB.SyncedBlock(account)
+	if err != nil {
+		return 0, err
+	}
+	var head hexutil.Uint64
+	if err := client.Call(&head, "berith_blockNumber"); err != nil {
+		return 0, err
+	}
+	// genesis 블록에는 트랜잭션이 없으므로 0 은 동기화하지 않은 상태로 사용한다.
+	from := synced + 1
+	if from > uint64(head) {
+		return 0, nil
+	}
+	inserted := 0
+	times := make(map[uint64]string)
+	for page := uint64(0); ; page++ {
+		var result berithapi.AddressTransactions
+		if err := client.Call(&result, "berith_getTransactionsByAddress", account, hexutil.Uint64(from), head, hexutil.Uint64(page)); err != nil {
+			return inserted, err
+		}
+		for _, tx := range result.Transactions {
+			txinfo, err := newTxHistory(account, tx, times)
+			if err != nil {
+				return inserted, err
+			}
+			ok, err := WalletDB.InsertTxHistory(account, txinfo)
+			if err != nil {
+				return inserted, err
+			}
+			if ok {
+				inserted++
+			}
+		}
+		if !result.HasMore {
+			break
+		}
+	}
+	return inserted, WalletDB.SetSyncedBlock(account, uint64(head))
+}
+
+// 노드가 반환한 트랜잭션을 트랜잭션 리스트 형식으로 바꾸는 함수
+// 트랜잭션 타입은 블록을 받을 때 저장하는 트랜잭션과 같은 값을 사용한다.
+func newTxHistory(account common.Address, tx *berithapi.RPCTransaction, times map[uint64]string) (walletdb.TxHistory, error) {
+	var receipt struct {
+		GasUsed hexutil.Uint64 `json:"gasUsed"`
+	}
+	if err := client.Call(&receipt, "berith_getTransactionReceipt", tx.Hash); err != nil {
+		return walletdb.TxHistory{}, err
+	}
+	number := tx.BlockNumber.ToInt().Uint64()
+	if _, ok := times[number]; !ok {
+		var header struct {
+			Time *hexutil.Big `json:"timestamp"`
+		}
+		if err := client.Call(&header, "berith_getBlockByNumber", hexutil.Uint64(number), false); err != nil {
+			return walletdb.TxHistory{}, err
+		}
+		times[number] = time.Unix(header.Time.ToInt().Int64(), 0).Format("2006-01-02 15:04:05")
+	}
+	return walletdb.TxHistory{
+		TxBlockNumber: hexutil.EncodeUint64(number),
+		TxAddress:     account,
+		TxType:        txType(account, tx),
+		TxAmount:      tx.Value.String(),
+		Txtime:        times[number],
+		Hash:          tx.Hash,
+		GasLimit:      tx.Gas.String(),
+		GasPrice:      tx.GasPrice.String(),
+		GasUsed:       receipt.GasUsed.String(),
+	}, nil
+}
+
+// 트랜잭션 타입 ( 0 : send, 1 : receive, 2 : stake, 3 : stopstaking, 4 : rewardToStake, 5 : rewardToMain )
+func txType(account common.Address, tx *berithapi.RPCTransaction) string {
+	switch {
+	case tx.From != account && tx.To != nil && *tx.To == account && tx.Base == types.Main && tx.Target == types.Main:
+		return "1 "
+	case tx.Base == types.Main && tx.Target == types.Stake:
+		return "2"
+	case tx.Base == types.Stake && tx.Target == types.Main:
+		return "3"
+	case tx.Base == types.Reward && tx.Target == types.Stake:
+		return "4"
+	case tx.Base == types.Reward && tx.Target == types.Main:
+		return "5"
+	}
+	return "0"
+}